@@ -1,6 +1,231 @@
 package v1alpha1
 
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrLeadershipLost interrupts the scaling operations when this replica loses the leadership in the middle of them,
+// so the new leader is the only one acting
+var ErrLeadershipLost = errors.New("leadership lost")
+
 // Context TODO
 type Context struct {
 	Config *ConfigSpec
+
+	// Parent is the context of the execution of the autoscaler, cancelled when it is stopped
+	Parent context.Context
+
+	// State is the state of the autoscaler, persisted across restarts when a state store is configured
+	State AutoscalerState
+
+	// MIGRoundRobinIndex points to the next MIG to select when using the round-robin policy
+	MIGRoundRobinIndex int
+
+	// Mutex guards the fields read by the admin API while the autoscaler is running
+	Mutex sync.Mutex
+
+	// LastDecision is the result of the last evaluation of the conditions
+	LastDecision *Decision
+
+	// History holds the last scaling actions executed, oldest first
+	History []Decision
+
+	// Requests receives the scaling actions requested manually through the admin API, the control plane API and the
+	// trigger endpoints
+	Requests chan ActionRequest
+
+	// Reloads receives the config of the autoscaler changed in its remote location, applied between evaluations
+	Reloads chan *ConfigSpec
+
+	// ConsecutiveErrors counts the evaluations failed in a row, to alert when they are repeated
+	ConsecutiveErrors int
+
+	// LastHealthCheck is when the autohealing last checked the nodes of the MIGs
+	LastHealthCheck time.Time
+
+	// LastTemplateCheck is when the instance templates of the MIGs were last checked
+	LastTemplateCheck time.Time
+
+	// LastStatusNotification is when the status was last notified without action taken
+	LastStatusNotification time.Time
+
+	// UnhealthyChecks counts, by instance, the consecutive autohealing checks in which it was unhealthy
+	UnhealthyChecks map[string]int
+
+	// DryRunActions holds the last actions skipped in a dry run, oldest first
+	DryRunActions []DryRunAction
+
+	// Drain is the drain of an Elasticsearch node in progress, if any. It is replaced on every update, never modified
+	Drain *DrainProgress
+
+	// IsLeader reports whether this replica still holds the leadership, checked before every destructive step of
+	// the scaling operations. When nil, the replica is always the leader
+	IsLeader func() bool
+}
+
+// ActionRequest is a scaling action requested manually, executed ignoring the conditions
+type ActionRequest struct {
+	Action string
+
+	// Reason tells where the action was requested from, recorded in its decision
+	Reason string
+}
+
+// ConnContext returns the context the calls to the external services derive from: the one of the execution
+// of the autoscaler, or the background context when it is not running, like in the commands inspecting it
+func (c *Context) ConnContext() context.Context {
+	if c == nil || c.Parent == nil {
+		return context.Background()
+	}
+	return c.Parent
+}
+
+// CheckLeadership returns ErrLeadershipLost when this replica does not hold the leadership anymore
+func (c *Context) CheckLeadership() error {
+	if c == nil || c.IsLeader == nil || c.IsLeader() {
+		return nil
+	}
+	return ErrLeadershipLost
+}
+
+const (
+	// Actions of the decisions taken by the autoscaler. Scaling actions can be requested manually too
+	DecisionNone      = "none"
+	DecisionScaleUp   = "scale-up"
+	DecisionScaleDown = "scale-down"
+
+	// ActionScaleToMax is the emergency action requested manually during incidents, adding every node up to the
+	// maximum size at once. It is recorded as a scale up
+	ActionScaleToMax = "scale-to-max"
+
+	// Outcomes of the decisions taken by the autoscaler
+	OutcomeSuccess = "success"
+	OutcomeFailed  = "failed"
+	OutcomeSkipped = "skipped"
+)
+
+// DryRunAction is an action skipped in a dry run, recorded to review what the autoscaler would have done
+type DryRunAction struct {
+	Time   time.Time `json:"time"`
+	Module string    `json:"module"`
+	Action string    `json:"action"`
+}
+
+// MetricSample is a sample returned by the query of a scaling condition
+type MetricSample struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+// Decision describes the result of an evaluation of the autoscaler
+type Decision struct {
+	Time         time.Time `json:"time"`
+	Autoscaler   string    `json:"autoscaler"`
+	Action       string    `json:"action"`
+	Trigger      string    `json:"trigger"`
+	Outcome      string    `json:"outcome"`
+	Reason       string    `json:"reason,omitempty"`
+	Condition    string    `json:"condition,omitempty"`
+	MetricValues []float64 `json:"metricValues,omitempty"`
+
+	// MetricSamples are the samples returned by the query of the condition, with their labels
+	MetricSamples []MetricSample `json:"metricSamples,omitempty"`
+
+	MIG          string `json:"mig,omitempty"`
+	Instance     string `json:"instance,omitempty"`
+	PreviousSize int32  `json:"previousSize,omitempty"`
+	Size         int32  `json:"size,omitempty"`
+	DurationMs   int64  `json:"durationMs,omitempty"`
+	Error        string `json:"error,omitempty"`
+
+	// HourlyCostDelta is the estimated difference of the hourly cost caused by the scaling action
+	HourlyCostDelta float64 `json:"hourlyCostDelta,omitempty"`
+}
+
+// AutoscalerState holds what an autoscaler needs to remember across restarts
+type AutoscalerState struct {
+	LastScaleUpTime   time.Time `json:"lastScaleUpTime,omitempty"`
+	LastScaleUpMIG    string    `json:"lastScaleUpMIG,omitempty"`
+	LastScaleDownTime time.Time `json:"lastScaleDownTime,omitempty"`
+	CooldownUntil     time.Time `json:"cooldownUntil,omitempty"`
+
+	// AwaitingReplication is set after a scale down until the data of the removed node is fully replicated
+	AwaitingReplication bool `json:"awaitingReplication,omitempty"`
+
+	// ScaleDownFailedTime is set when a scale down fails, starting its quarantine, until the quarantine ends
+	ScaleDownFailedTime time.Time `json:"scaleDownFailedTime,omitempty"`
+
+	// InFlightOperation is the scaling operation being executed. If the process crashes in the
+	// middle of it, it is recovered on the next start
+	InFlightOperation *Operation `json:"inFlightOperation,omitempty"`
+
+	// Number of consecutive evaluations where the up or down conditions were met
+	ConsecutiveUpConditions   int `json:"consecutiveUpConditions"`
+	ConsecutiveDownConditions int `json:"consecutiveDownConditions"`
+
+	// Pause suspends the scaling decisions while set. It is managed from outside the autoscaler loop
+	Pause *Pause `json:"pause,omitempty"`
+
+	// DrainDurations are the last drains of Elasticsearch nodes finished, oldest first
+	DrainDurations []DrainDuration `json:"drainDurations,omitempty"`
+
+	// RaisedSettings are the values of the Elasticsearch cluster settings raised while a node is drained, by layer and
+	// name, to restore them when the drain finishes or on the next start after a crash. Nil values were not set
+	RaisedSettings map[string]map[string]*string `json:"raisedSettings,omitempty"`
+}
+
+// DrainDuration is the time taken to drain an Elasticsearch node holding the given number of shards
+type DrainDuration struct {
+	Node        string    `json:"node"`
+	Shards      int       `json:"shards"`
+	DurationSec float64   `json:"durationSec"`
+	FinishedAt  time.Time `json:"finishedAt"`
+}
+
+// DrainProgress is the drain of an Elasticsearch node in progress, with the shards it held when started and the ones
+// remaining in it
+type DrainProgress struct {
+	Node            string    `json:"node"`
+	StartedAt       time.Time `json:"startedAt"`
+	InitialShards   int       `json:"initialShards"`
+	RemainingShards int       `json:"remainingShards"`
+}
+
+// NextScaling tells when the autoscaler is allowed to scale up and down again, and why it is idle until then.
+// Times are zero when scaling is not allowed in the foreseeable future
+type NextScaling struct {
+	ScaleUpAt   time.Time `json:"scaleUpAt"`
+	ScaleDownAt time.Time `json:"scaleDownAt"`
+	Reason      string    `json:"reason,omitempty"`
+}
+
+// Pause describes a suspension of the scaling decisions
+type Pause struct {
+	Reason   string    `json:"reason,omitempty"`
+	PausedAt time.Time `json:"pausedAt"`
+
+	// Until is the moment the autoscaler resumes automatically. Zero means paused until resumed
+	Until time.Time `json:"until,omitempty"`
+}
+
+// Operation describes a scaling operation being executed
+type Operation struct {
+	Type      string    `json:"type"`
+	Phase     string    `json:"phase"`
+	MIG       string    `json:"mig"`
+	Instance  string    `json:"instance,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+
+	// Zone is the zone of the instance, to reach it once it left its MIG
+	Zone string `json:"zone,omitempty"`
+
+	// Key identifies the scaling action, so it is not issued twice when it is retried or recovered after a crash
+	Key string `json:"key,omitempty"`
+
+	// PreviousSize and ExpectedSize are the target sizes of the MIG before and after the scaling action
+	PreviousSize int32 `json:"previousSize,omitempty"`
+	ExpectedSize int32 `json:"expectedSize,omitempty"`
 }
@@ -2,22 +2,199 @@ package v1alpha1
 
 // Configuration struct
 type ConfigSpec struct {
+	// APIVersion is the version of the schema of the config. It is only read from the root of the config
+	APIVersion string `yaml:"apiVersion,omitempty"`
+
+	Name string `yaml:"name,omitempty"`
+
+	// Autoscalers allows running several autoscalers in the same process, each one
+	// with its own configuration. When empty, this config defines the only autoscaler
+	Autoscalers []ConfigSpec `yaml:"autoscalers,omitempty"`
+
+	// Defaults are inherited by every autoscaler of the list, which override them. They are merged when the
+	// config is read, so they are always empty afterwards. It is only read from the root of the config
+	Defaults *ConfigSpec `yaml:"defaults,omitempty"`
+
+	// Include lists the files merged under this config, in order, when it is read, so it is always empty afterwards.
+	// It is only read from the root of the config
+	Include []string `yaml:"include,omitempty"`
+
+	// LeaderElection allows running several replicas of the process, only acting the leader.
+	// It is only read from the root of the config
+	LeaderElection struct {
+		Enabled          bool   `yaml:"enabled,omitempty"`
+		Backend          string `yaml:"backend,omitempty"`
+		Identity         string `yaml:"identity,omitempty"`
+		LeaseDurationSec int    `yaml:"leaseDurationSec,omitempty"`
+		RenewIntervalSec int    `yaml:"renewIntervalSec,omitempty"`
+		GCS              struct {
+			Bucket          string `yaml:"bucket"`
+			Object          string `yaml:"object"`
+			CredentialsFile string `yaml:"credentialsFile,omitempty"`
+		} `yaml:"gcs,omitempty"`
+		Kubernetes struct {
+			Namespace string `yaml:"namespace,omitempty"`
+			LeaseName string `yaml:"leaseName,omitempty"`
+		} `yaml:"kubernetes,omitempty"`
+	} `yaml:"leaderElection,omitempty"`
+
+	// Admin configures the HTTP API to inspect and control the autoscalers at runtime.
+	// It is only read from the root of the config
+	Admin struct {
+		Enabled bool   `yaml:"enabled"`
+		Address string `yaml:"address,omitempty"`
+		Token   string `yaml:"token"`
+	} `yaml:"admin,omitempty"`
+
+	// Health configures the liveness and readiness endpoints used by the health checks of the platform.
+	// It is only read from the root of the config
+	Health struct {
+		Enabled bool   `yaml:"enabled"`
+		Address string `yaml:"address,omitempty"`
+	} `yaml:"health,omitempty"`
+
+	// Approvals configures the endpoint receiving the clicks on the Slack approval messages.
+	// It is only read from the root of the config
+	Approvals struct {
+		Address            string `yaml:"address,omitempty"`
+		SlackSigningSecret string `yaml:"slackSigningSecret"`
+	} `yaml:"approvals,omitempty"`
+
+	// Triggers configures the endpoints receiving the scaling actions requested by ChatOps bots and runbooks,
+	// protected by their own bearer token. It is only read from the root of the config
+	Triggers struct {
+		Enabled bool   `yaml:"enabled"`
+		Address string `yaml:"address,omitempty"`
+		Token   string `yaml:"token"`
+	} `yaml:"triggers,omitempty"`
+
+	// ControlPlane configures the gRPC API to inspect and control the autoscalers programmatically, streaming their
+	// decisions. It is only read from the root of the config
+	ControlPlane struct {
+		Enabled bool   `yaml:"enabled"`
+		Address string `yaml:"address,omitempty"`
+		Token   string `yaml:"token"`
+	} `yaml:"controlPlane,omitempty"`
+
+	// Audit defines where every decision of the autoscalers is recorded for compliance and post-incident review.
+	// It is only read from the root of the config
+	Audit struct {
+		RetentionDays int `yaml:"retentionDays,omitempty"`
+		File          struct {
+			Path string `yaml:"path"`
+		} `yaml:"file,omitempty"`
+		GCS struct {
+			Bucket          string `yaml:"bucket"`
+			Prefix          string `yaml:"prefix,omitempty"`
+			CredentialsFile string `yaml:"credentialsFile,omitempty"`
+		} `yaml:"gcs,omitempty"`
+		BigQuery struct {
+			ProjectID       string `yaml:"projectID"`
+			Dataset         string `yaml:"dataset"`
+			Table           string `yaml:"table"`
+			CredentialsFile string `yaml:"credentialsFile,omitempty"`
+		} `yaml:"bigquery,omitempty"`
+	} `yaml:"audit,omitempty"`
+
+	// Logging exports the logs, in addition to stderr, to Google Cloud Logging and syslog, so the decisions appear
+	// alongside the logs of the applications. It is only read from the root of the config
+	Logging struct {
+		CloudLogging struct {
+			ProjectID       string `yaml:"projectID"`
+			LogName         string `yaml:"logName,omitempty"`
+			CredentialsFile string `yaml:"credentialsFile,omitempty"`
+		} `yaml:"cloudLogging,omitempty"`
+		Syslog struct {
+			Enabled bool   `yaml:"enabled"`
+			Network string `yaml:"network,omitempty"`
+			Address string `yaml:"address,omitempty"`
+			Tag     string `yaml:"tag,omitempty"`
+		} `yaml:"syslog,omitempty"`
+	} `yaml:"logging,omitempty"`
+
+	// Pushgateway receives the metrics of the autoscalers and the outcome of their decisions at the end of the
+	// executions with --once, so they are observable when driven by a scheduler. It is only read from the root of the config
+	Pushgateway struct {
+		URL      string `yaml:"url"`
+		Job      string `yaml:"job,omitempty"`
+		Username string `yaml:"username,omitempty"`
+		Password string `yaml:"password,omitempty"`
+	} `yaml:"pushgateway,omitempty"`
+
+	// StatsD receives the counters of the decisions of the autoscalers, and the events of their scaling actions with
+	// DogStatsD, for the agents of Datadog. It is only read from the root of the config
+	StatsD struct {
+		Address   string   `yaml:"address"`
+		Prefix    string   `yaml:"prefix,omitempty"`
+		DogStatsD bool     `yaml:"dogStatsD,omitempty"`
+		Tags      []string `yaml:"tags,omitempty"`
+	} `yaml:"statsd,omitempty"`
+
+	// KubernetesEvents emits Kubernetes Events of the scaling actions when the autoscaler runs inside a Kubernetes
+	// cluster, on its own Pod or on the object set, so kubectl describe shows them. It is only read from the root of the config
+	KubernetesEvents struct {
+		Disabled bool `yaml:"disabled,omitempty"`
+		Object   struct {
+			APIVersion string `yaml:"apiVersion"`
+			Kind       string `yaml:"kind"`
+			Namespace  string `yaml:"namespace,omitempty"`
+			Name       string `yaml:"name"`
+		} `yaml:"object,omitempty"`
+	} `yaml:"kubernetesEvents,omitempty"`
+
+	// State defines where the state of the autoscalers is persisted across restarts.
+	// It is only read from the root of the config
+	State struct {
+		Backend string `yaml:"backend,omitempty"`
+		File    struct {
+			Path string `yaml:"path"`
+		} `yaml:"file,omitempty"`
+		GCS struct {
+			Bucket          string `yaml:"bucket"`
+			Prefix          string `yaml:"prefix,omitempty"`
+			CredentialsFile string `yaml:"credentialsFile,omitempty"`
+		} `yaml:"gcs,omitempty"`
+	} `yaml:"state,omitempty"`
+
+	// GCPRateLimit limits the calls to the Compute API made by every autoscaler in the process,
+	// to stay under the quotas of the project. It is only read from the root of the config
+	GCPRateLimit struct {
+		RequestsPerSecond float64 `yaml:"requestsPerSecond,omitempty"`
+		Burst             int     `yaml:"burst,omitempty"`
+	} `yaml:"gcpRateLimit,omitempty"`
+
 	Metrics struct {
 		Prometheus struct {
 			URL           string            `yaml:"url"`
-			UpCondition   string            `yaml:"upCondition"`
-			DownCondition string            `yaml:"downCondition"`
+			UpCondition   string            `yaml:"upCondition,omitempty"`
+			DownCondition string            `yaml:"downCondition,omitempty"`
+			TimeoutSec    int               `yaml:"timeoutSec,omitempty"`
 			Headers       map[string]string `yaml:"headers,omitempty"`
+			Proxy         ProxySpec         `yaml:"proxy,omitempty"`
+
+			// UpConditions and DownConditions are evaluated by priority, acting on the first one met with its step.
+			// upCondition and downCondition are evaluated after the conditions with the same priority, 0
+			UpConditions   []ConditionSpec `yaml:"upConditions,omitempty"`
+			DownConditions []ConditionSpec `yaml:"downConditions,omitempty"`
+
+			// TLS versions and cipher suites allowed to connect to Prometheus. Suites only apply to TLS 1.2
+			TLSMinVersion   string   `yaml:"tlsMinVersion,omitempty"`
+			TLSMaxVersion   string   `yaml:"tlsMaxVersion,omitempty"`
+			TLSCipherSuites []string `yaml:"tlsCipherSuites,omitempty"`
+
+			// CacheTTLSec reuses the results of the queries for this time, shared by the autoscalers of the process
+			CacheTTLSec int `yaml:"cacheTtlSec,omitempty"`
+
+			// WarmupExclusion replaces {{warmingNodes}} in the queries by the Elasticsearch nodes started less than
+			// periodSec ago, so their low load is excluded from the conditions. No node is excluded when it is 0
+			WarmupExclusion struct {
+				PeriodSec int `yaml:"periodSec,omitempty"`
+			} `yaml:"warmupExclusion,omitempty"`
 		} `yaml:"prometheus"`
 	} `yaml:"metrics"`
 
 	Infrastructure struct {
-		GCP struct {
-			ProjectID       string `yaml:"projectId"`
-			Zone            string `yaml:"zone"`
-			MIGName         string `yaml:"migName"`
-			CredentialsFile string `yaml:"credentialsFile,omitempty"`
-		} `yaml:"gcp"`
+		GCP GCPSpec `yaml:"gcp"`
 	} `yaml:"infrastructure"`
 
 	Target struct {
@@ -26,21 +203,154 @@ type ConfigSpec struct {
 			User                  string `yaml:"user,omitempty"`
 			Password              string `yaml:"password,omitempty"`
 			SSLInsecureSkipVerify bool   `yaml:"sslInsecureSkipVerify,omitempty"`
+			TLSMinVersion         string `yaml:"tlsMinVersion,omitempty"`
 			DrainTimeoutSec       int    `yaml:"drainTimeoutSec,omitempty"`
+			DrainPollIntervalSec  int    `yaml:"drainPollIntervalSec,omitempty"`
+			MaxConcurrentDrains   int    `yaml:"maxConcurrentDrains,omitempty"`
+			DrainLockIndex        string `yaml:"drainLockIndex,omitempty"`
+			RequestTimeoutSec     int    `yaml:"requestTimeoutSec,omitempty"`
+
+			// TLSMaxVersion and TLSCipherSuites restrict the TLS connections to the cluster along with TLSMinVersion.
+			// Suites only apply to TLS 1.2
+			TLSMaxVersion   string   `yaml:"tlsMaxVersion,omitempty"`
+			TLSCipherSuites []string `yaml:"tlsCipherSuites,omitempty"`
+
+			// Proxy is used by the requests sent to the cluster
+			Proxy ProxySpec `yaml:"proxy,omitempty"`
+
+			// OnDrainTimeout is what happens when a drain does not finish in drainTimeoutSec: rollback, force, extend
+			// or approval
+			OnDrainTimeout string `yaml:"onDrainTimeout,omitempty"`
+
+			// SnapshotNodesPolicy is how the nodes holding only searchable snapshots are removed: drain or no-drain
+			SnapshotNodesPolicy string `yaml:"snapshotNodesPolicy,omitempty"`
+
+			// MaxRelocatingShards defers the scale downs while the cluster relocates more shards
+			MaxRelocatingShards int `yaml:"maxRelocatingShards,omitempty"`
+
+			// MinHealthy refuses the scale downs leaving less than nodes healthy data nodes in the cluster, whatever the
+			// minimum size of the MIGs is. Nodes are healthy while the cluster is green and their heap is below
+			// maxHeapPercent. It is disabled when nodes is 0
+			MinHealthy struct {
+				Nodes          int     `yaml:"nodes,omitempty"`
+				MaxHeapPercent float64 `yaml:"maxHeapPercent,omitempty"`
+			} `yaml:"minHealthy,omitempty"`
+
+			// ReplicaSync sets the replicas of the indices matching the patterns after every scaling action,
+			// following the number of data nodes
+			ReplicaSync []ReplicaSyncSpec `yaml:"replicaSync,omitempty"`
+
+			// ConcurrencyCheck compares, before every drain, the time needed to relocate the shards of the node with
+			// cluster_concurrent_rebalance and node_concurrent_recoveries against drainTimeoutSec: warn, tune or disabled
+			ConcurrencyCheck struct {
+				Policy             string `yaml:"policy,omitempty"`
+				ShardRelocationSec int    `yaml:"shardRelocationSec,omitempty"`
+			} `yaml:"concurrencyCheck,omitempty"`
+
+			// IgnorableIndices are the indices, like tiny system ones, whose shards do not block the drain of a node
+			// after timeoutSec, so the node is removed with them
+			IgnorableIndices struct {
+				Patterns   []string `yaml:"patterns,omitempty"`
+				TimeoutSec int      `yaml:"timeoutSec,omitempty"`
+			} `yaml:"ignorableIndices,omitempty"`
+
+			// IndexOperations defers the scale downs while force merges or rollovers run on the indices matching
+			// the patterns, as their I/O competes with the drains. It is disabled when no pattern is set.
+			// MatchUnknownIndices defers them too while the tasks not naming their indices run
+			IndexOperations struct {
+				Patterns            []string `yaml:"patterns,omitempty"`
+				MatchUnknownIndices bool     `yaml:"matchUnknownIndices,omitempty"`
+			} `yaml:"indexOperations,omitempty"`
+
+			// Reconciliation periodically checks that every instance of the MIGs has joined the cluster as a data node.
+			// It is disabled when intervalSec is 0
+			Reconciliation struct {
+				IntervalSec int `yaml:"intervalSec,omitempty"`
+				GraceSec    int `yaml:"graceSec,omitempty"`
+			} `yaml:"reconciliation,omitempty"`
 		} `yaml:"elasticsearch,omitempty"`
 	} `yaml:"target"`
 
 	Notifications struct {
 		Slack struct {
-			WebhookURL string `yaml:"webhookUrl,omitempty"`
+			WebhookURL  string `yaml:"webhookUrl,omitempty"`
+			BotToken    string `yaml:"botToken,omitempty"`
+			Channel     string `yaml:"channel,omitempty"`
+			MinSeverity string `yaml:"minSeverity,omitempty"`
 		} `yaml:"slack,omitempty"`
+		Channels []NotificationChannelSpec `yaml:"channels,omitempty"`
+		Alerts   struct {
+			RepeatedErrors     int `yaml:"repeatedErrors,omitempty"`
+			MaxSizeEvaluations int `yaml:"maxSizeEvaluations,omitempty"`
+		} `yaml:"alerts,omitempty"`
+
+		// NotifyOnNoAction posts the compact status of the autoscaler when no action is taken, at most once every
+		// intervalSec, so on-call can confirm it is alive
+		NotifyOnNoAction struct {
+			Enabled     bool `yaml:"enabled,omitempty"`
+			IntervalSec int  `yaml:"intervalSec,omitempty"`
+		} `yaml:"notifyOnNoAction,omitempty"`
+
+		// TimeoutSec bounds the requests sent to Slack and the other notification channels
+		TimeoutSec int `yaml:"timeoutSec,omitempty"`
+
+		// Proxy is used by the requests sent to Slack, including the approvals, and the other notification channels
+		Proxy ProxySpec `yaml:"proxy,omitempty"`
 	} `yaml:"notifications,omitempty"`
 
+	// Cost defines the prices used to estimate the cost of the scaling actions, and the optional monthly budget
+	Cost struct {
+		Enabled       bool               `yaml:"enabled"`
+		Currency      string             `yaml:"currency,omitempty"`
+		Prices        map[string]float64 `yaml:"prices,omitempty"`
+		MonthlyBudget float64            `yaml:"monthlyBudget,omitempty"`
+	} `yaml:"cost,omitempty"`
+
+	Hooks struct {
+		PreScaleDown  []HookSpec `yaml:"preScaleDown,omitempty"`
+		PostScaleDown []HookSpec `yaml:"postScaleDown,omitempty"`
+		PostScaleUp   []HookSpec `yaml:"postScaleUp,omitempty"`
+	} `yaml:"hooks,omitempty"`
+
+	// Retry defines how the calls to Prometheus, Elasticsearch and GCP are retried when they fail
+	Retry struct {
+		MaxAttempts        int     `yaml:"maxAttempts,omitempty"`
+		InitialIntervalSec int     `yaml:"initialIntervalSec,omitempty"`
+		MaxIntervalSec     int     `yaml:"maxIntervalSec,omitempty"`
+		Multiplier         float64 `yaml:"multiplier,omitempty"`
+	} `yaml:"retry,omitempty"`
+
+	// CircuitBreaker stops the scaling actions when the calls to GCP or Elasticsearch keep failing,
+	// probing them periodically until they recover
+	CircuitBreaker struct {
+		FailureThreshold int `yaml:"failureThreshold,omitempty"`
+		ProbeIntervalSec int `yaml:"probeIntervalSec,omitempty"`
+	} `yaml:"circuitBreaker,omitempty"`
+
+	// Heartbeat pings the URL of a dead man's switch after every evaluation, so an external system alerts when the
+	// autoscaler stops evaluating. It is disabled when the URLs are empty
+	Heartbeat HeartbeatSpec `yaml:"heartbeat,omitempty"`
+
+	// Autohealing detects the instances whose Elasticsearch node is missing or overloaded, optionally replacing them.
+	// It is disabled when intervalSec is 0
+	Autohealing struct {
+		IntervalSec     int     `yaml:"intervalSec,omitempty"`
+		MaxHeapPercent  float64 `yaml:"maxHeapPercent,omitempty"`
+		MaxDiskPercent  float64 `yaml:"maxDiskPercent,omitempty"`
+		UnhealthyChecks int     `yaml:"unhealthyChecks,omitempty"`
+		Recreate        bool    `yaml:"recreate,omitempty"`
+	} `yaml:"autohealing,omitempty"`
+
 	Autoscaler struct {
 		DebugMode                          bool `yaml:"debugMode,omitempty"`
+		DryRunInfrastructure               bool `yaml:"dryRunInfrastructure,omitempty"`
+		DryRunTarget                       bool `yaml:"dryRunTarget,omitempty"`
+		EvaluationIntervalSec              int  `yaml:"evaluationIntervalSec,omitempty"`
+		EvaluationJitterSec                int  `yaml:"evaluationJitterSec,omitempty"`
 		DefaultCooldownPeriodSec           int  `yaml:"defaultCooldownPeriodSec"`
-		ScaleDownCooldownPeriodSec         int  `yaml:"scaledownCooldownPeriodSec"`
+		ScaleDownCooldownPeriodSec         int  `yaml:"scaleDownCooldownPeriodSec"`
 		RetryIntervalSec                   int  `yaml:"retryIntervalSec"`
+		MaxRetryIntervalSec                int  `yaml:"maxRetryIntervalSec,omitempty"`
 		MinSize                            int  `yaml:"minSize"`
 		MaxSize                            int  `yaml:"maxSize"`
 		ScaleUpThreshold                   int  `yaml:"scaleUpThreshold"`
@@ -51,5 +361,224 @@ type ConfigSpec struct {
 			MaxSize          int    `yaml:"maxSize"`
 			ScaleUpThreshold int    `yaml:"scaleUpThreshold"`
 		} `yaml:"advancedCustomScalingConfiguration,omitempty"`
+		MaintenanceWindows []MaintenanceWindowSpec `yaml:"maintenanceWindows,omitempty"`
+		ScaleDownApproval  ScaleDownApprovalSpec   `yaml:"scaleDownApproval,omitempty"`
+
+		// Warmup skips the scale downs while the nodes added by the last scale up are warming up.
+		// It is disabled when periodSec is 0
+		Warmup struct {
+			PeriodSec      int `yaml:"periodSec,omitempty"`
+			JoinTimeoutSec int `yaml:"joinTimeoutSec,omitempty"`
+		} `yaml:"warmup,omitempty"`
+
+		// Quarantine skips the scale downs for periodSec after one failed, like a drain failed or rolled back,
+		// and until the Elasticsearch cluster is green. Only the green cluster is waited for when periodSec is 0
+		Quarantine struct {
+			PeriodSec int `yaml:"periodSec,omitempty"`
+		} `yaml:"quarantine,omitempty"`
+
+		// ScaleUpSurge bounds the new instances created at once by a scale up, adding them in waves
+		// that wait for the startup probe before the next one. Every instance is created at once when it is 0
+		ScaleUpSurge int `yaml:"scaleUpSurge,omitempty"`
+
+		StartupProbe StartupProbeSpec `yaml:"startupProbe,omitempty"`
 	} `yaml:"autoscaler"`
 }
+
+// MaintenanceWindowSpec defines a period where the scaling actions are restricted
+type MaintenanceWindowSpec struct {
+	Days     string `yaml:"days"`
+	HoursUTC string `yaml:"hoursUTC,omitempty"`
+	Mode     string `yaml:"mode,omitempty"`
+}
+
+// StartupProbeSpec defines how to wait for the new instances to be running and pass the probe before notifying the scale up.
+// It is disabled when timeoutSec is 0. Without a type, only the status of the instances is checked
+type StartupProbeSpec struct {
+	TimeoutSec         int    `yaml:"timeoutSec,omitempty"`
+	PeriodSec          int    `yaml:"periodSec,omitempty"`
+	Type               string `yaml:"type,omitempty"`
+	Port               int    `yaml:"port,omitempty"`
+	Path               string `yaml:"path,omitempty"`
+	Scheme             string `yaml:"scheme,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify,omitempty"`
+}
+
+// ScaleDownApprovalSpec defines when a human must approve on Slack the scale down before draining the instance.
+// When days are defined, the approval is only required inside those days and hours
+type ScaleDownApprovalSpec struct {
+	Enabled         bool   `yaml:"enabled"`
+	SlackWebhookURL string `yaml:"slackWebhookUrl"`
+	TimeoutSec      int    `yaml:"timeoutSec,omitempty"`
+	OnTimeout       string `yaml:"onTimeout,omitempty"`
+	Days            string `yaml:"days,omitempty"`
+	HoursUTC        string `yaml:"hoursUTC,omitempty"`
+}
+
+// ConditionSpec defines a named scaling condition. Step is the number of nodes added or removed when it is met,
+// the default of the autoscaler when 0. Conditions with a higher priority are evaluated first
+type ConditionSpec struct {
+	Name     string `yaml:"name"`
+	Query    string `yaml:"query"`
+	Step     int    `yaml:"step,omitempty"`
+	Priority int    `yaml:"priority,omitempty"`
+
+	// Threshold sizes the step of an up condition from the load observed instead: the highest sample over the
+	// threshold, as a ratio of it, times the current size, up to maxStep nodes. It is disabled when 0
+	Threshold float64 `yaml:"threshold,omitempty"`
+	MaxStep   int     `yaml:"maxStep,omitempty"`
+}
+
+// ProxySpec defines the proxy of the requests sent by a client. When the URL is empty,
+// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are honored
+type ProxySpec struct {
+	URL string `yaml:"url,omitempty"`
+
+	// NoProxy is a comma separated list of hosts, domains and CIDRs reached without the proxy, as in NO_PROXY
+	NoProxy string `yaml:"noProxy,omitempty"`
+}
+
+// GCPSpec defines the project, the MIGs and the credentials of the calls to GCP
+type GCPSpec struct {
+	ProjectID       string `yaml:"projectId"`
+	Zone            string `yaml:"zone"`
+	Region          string `yaml:"region,omitempty"`
+	MIGName         string `yaml:"migName"`
+	MinPerZone      int    `yaml:"minPerZone,omitempty"`
+	ScaleDownAction string `yaml:"scaleDownAction,omitempty"`
+	CredentialsFile string `yaml:"credentialsFile,omitempty"`
+
+	// ImpersonateServiceAccount is the email of the service account impersonated by the calls to GCP,
+	// with the credentials of credentialsFile, the Workload Identity Federation or the default ones
+	ImpersonateServiceAccount string `yaml:"impersonateServiceAccount,omitempty"`
+
+	// WorkloadIdentityFederation exchanges the tokens of an external identity provider for GCP credentials,
+	// so no service account keys are exported. It is enabled when the audience is set
+	WorkloadIdentityFederation WorkloadIdentityFederationSpec `yaml:"workloadIdentityFederation,omitempty"`
+
+	// OperationTimeoutSec bounds every call to the GCP API
+	OperationTimeoutSec int `yaml:"operationTimeoutSec,omitempty"`
+
+	// DeletionProtectionPolicy defines what to do with instances that have deletion protection enabled
+	DeletionProtectionPolicy string `yaml:"deletionProtectionPolicy,omitempty"`
+
+	// DrainStateLabel is the key of the label set to draining in the instances while they are drained before
+	// being removed, so other automation and humans can see which one is being evacuated. Disabled when empty
+	DrainStateLabel string `yaml:"drainStateLabel,omitempty"`
+
+	// AllowUnstableMIGs operates the MIGs that are not stable too. By default, the autoscaler refuses to operate them,
+	// as another controller may be changing them, as it always does with the MIGs with a GCE autoscaler attached
+	AllowUnstableMIGs bool `yaml:"allowUnstableMIGs,omitempty"`
+
+	// MIGs allows managing several MIGs with the same autoscaler. When empty, the MIG
+	// defined by migName and zone is used
+	MIGs               []MIGSpec `yaml:"migs,omitempty"`
+	MIGSelectionPolicy string    `yaml:"migSelectionPolicy,omitempty"`
+
+	// GKE scales the MIGs of a GKE node pool instead of migName and migs, cordoning and draining the
+	// Kubernetes node of every instance before removing it. It is enabled when the node pool is set
+	GKE GKESpec `yaml:"gke,omitempty"`
+
+	// InstanceTemplate keeps the MIGs on the intended instance template. It is enabled when the name is set
+	InstanceTemplate InstanceTemplateSpec `yaml:"instanceTemplate,omitempty"`
+}
+
+// InstanceTemplateSpec defines the instance template the MIGs must use, and whether their instances are replaced
+// when they differ from it
+type InstanceTemplateSpec struct {
+	// Name is the name of a global instance template, or the URL of any template
+	Name string `yaml:"name"`
+
+	// IntervalSec is the time between the checks of the templates of the MIGs and their instances
+	IntervalSec int `yaml:"intervalSec,omitempty"`
+
+	// RollingReplace sets the template in the MIGs differing from it, and drains and recreates one instance
+	// created from another template on every check
+	RollingReplace bool `yaml:"rollingReplace,omitempty"`
+}
+
+// GKESpec defines the GKE node pool scaled by the autoscaler. Its MIGs are read from the GKE API
+type GKESpec struct {
+	Cluster string `yaml:"cluster"`
+
+	// Location is the zone or the region of the cluster
+	Location string `yaml:"location"`
+	NodePool string `yaml:"nodePool"`
+
+	// DrainTimeoutSec bounds the wait for the pods of a Kubernetes node to be evicted
+	DrainTimeoutSec int `yaml:"drainTimeoutSec,omitempty"`
+}
+
+// WorkloadIdentityFederationSpec defines the workload identity provider and where its token is read from:
+// a file, like a projected Kubernetes service account token, or a URL
+type WorkloadIdentityFederationSpec struct {
+	// Audience is the full resource name of the provider, e.g.
+	// //iam.googleapis.com/projects/<number>/locations/global/workloadIdentityPools/<pool>/providers/<provider>
+	Audience         string `yaml:"audience"`
+	SubjectTokenType string `yaml:"subjectTokenType,omitempty"`
+	TokenFile        string `yaml:"tokenFile,omitempty"`
+	TokenURL         string `yaml:"tokenUrl,omitempty"`
+}
+
+// MIGSpec defines one of the Managed Instance Groups handled by the autoscaler
+type MIGSpec struct {
+	Name       string `yaml:"name"`
+	Zone       string `yaml:"zone,omitempty"`
+	Region     string `yaml:"region,omitempty"`
+	Weight     int    `yaml:"weight,omitempty"`
+	MinSize    int    `yaml:"minSize,omitempty"`
+	MaxSize    int    `yaml:"maxSize,omitempty"`
+	MinPerZone int    `yaml:"minPerZone,omitempty"`
+}
+
+// ReplicaSyncSpec defines the indices whose replicas follow the number of data nodes, one copy of every shard
+// in each node, within the bounds. MaxReplicas is unbounded when 0
+type ReplicaSyncSpec struct {
+	Pattern     string `yaml:"pattern"`
+	MinReplicas int    `yaml:"minReplicas,omitempty"`
+	MaxReplicas int    `yaml:"maxReplicas,omitempty"`
+}
+
+// HeartbeatSpec defines the URLs requested after the evaluations: URL when they succeed, and FailURL when they fail
+type HeartbeatSpec struct {
+	URL        string    `yaml:"url,omitempty"`
+	FailURL    string    `yaml:"failUrl,omitempty"`
+	TimeoutSec int       `yaml:"timeoutSec,omitempty"`
+	Proxy      ProxySpec `yaml:"proxy,omitempty"`
+}
+
+// HookSpec defines a shell command and/or a webhook executed around scaling events
+type HookSpec struct {
+	Command    string `yaml:"command,omitempty"`
+	WebhookURL string `yaml:"webhookUrl,omitempty"`
+	TimeoutSec int    `yaml:"timeoutSec,omitempty"`
+}
+
+// NotificationChannelSpec defines one of the channels receiving the notifications of the autoscaler
+type NotificationChannelSpec struct {
+	Name        string   `yaml:"name,omitempty"`
+	Type        string   `yaml:"type"`
+	MinSeverity string   `yaml:"minSeverity,omitempty"`
+	Events      []string `yaml:"events,omitempty"`
+	Slack       struct {
+		WebhookURL string `yaml:"webhookUrl,omitempty"`
+		BotToken   string `yaml:"botToken,omitempty"`
+		Channel    string `yaml:"channel,omitempty"`
+	} `yaml:"slack,omitempty"`
+	PagerDuty struct {
+		RoutingKey string            `yaml:"routingKey"`
+		Severities map[string]string `yaml:"severities,omitempty"`
+	} `yaml:"pagerduty,omitempty"`
+	Webhook struct {
+		URL        string `yaml:"url"`
+		Secret     string `yaml:"secret,omitempty"`
+		MaxRetries int    `yaml:"maxRetries,omitempty"`
+	} `yaml:"webhook,omitempty"`
+	Telegram struct {
+		BotToken string `yaml:"botToken"`
+		ChatID   string `yaml:"chatID"`
+	} `yaml:"telegram,omitempty"`
+	Discord struct {
+		WebhookURL string `yaml:"webhookUrl"`
+	} `yaml:"discord,omitempty"`
+}
@@ -4,6 +4,7 @@ package v1alpha1
 type NodeInfo struct {
 	IP          string `json:"ip"`
 	HeapPercent string `json:"heap.percent"`
+	DiskPercent string `json:"disk.used_percent"`
 	RAMPercent  string `json:"ram.percent"`
 	CPU         string `json:"cpu"`
 	Load1m      string `json:"load_1m"`
@@ -32,3 +33,11 @@ type ElasticsearchSettings struct {
 	Persistent map[string]interface{} `json:"persistent"`
 	Transient  map[string]interface{} `json:"transient"`
 }
+
+// ClusterHealth holds the shard counters of the health of the Elasticsearch cluster
+type ClusterHealth struct {
+	Status             string `json:"status"`
+	RelocatingShards   int    `json:"relocating_shards"`
+	InitializingShards int    `json:"initializing_shards"`
+	UnassignedShards   int    `json:"unassigned_shards"`
+}
@@ -0,0 +1,11 @@
+// Package samples embeds the sample configs, so they can be generated by the config init subcommand
+package samples
+
+import (
+	_ "embed"
+)
+
+// Autoscaler is the complete and commented sample config of an autoscaler
+//
+//go:embed autoscaler.yaml
+var Autoscaler []byte
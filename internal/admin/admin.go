@@ -0,0 +1,316 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/google"
+	"custom-vm-autoscaler/internal/leader"
+	"custom-vm-autoscaler/internal/state"
+	"custom-vm-autoscaler/internal/version"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// dashboard is the web page served at the root of the admin API
+//
+//go:embed dashboard.html
+var dashboard []byte
+
+// Server exposes the HTTP API to inspect and control the autoscalers at runtime
+type Server struct {
+	address     string
+	token       string
+	autoscalers []*v1alpha1.Context
+	elector     *leader.Elector
+}
+
+// autoscalerStatus is the response of the status endpoint for every autoscaler
+type autoscalerStatus struct {
+	Name                 string                  `json:"name"`
+	Leader               bool                    `json:"leader"`
+	Version              string                  `json:"version"`
+	MIGs                 map[string]int32        `json:"migs,omitempty"`
+	CurrentSize          int32                   `json:"currentSize"`
+	MinSize              int32                   `json:"minSize"`
+	MaxSize              int32                   `json:"maxSize"`
+	SizeError            string                  `json:"sizeError,omitempty"`
+	LastDecision         *v1alpha1.Decision      `json:"lastDecision,omitempty"`
+	CooldownRemainingSec int                     `json:"cooldownRemainingSec"`
+	NextScaling          v1alpha1.NextScaling    `json:"nextScaling"`
+	NextScalingError     string                  `json:"nextScalingError,omitempty"`
+	Pause                *v1alpha1.Pause         `json:"pause,omitempty"`
+	InFlightOperation    *v1alpha1.Operation     `json:"inFlightOperation,omitempty"`
+	Drain                *v1alpha1.DrainProgress `json:"drain,omitempty"`
+
+	// DryRunActions are the last actions skipped in a dry run
+	DryRunActions []v1alpha1.DryRunAction `json:"dryRunActions,omitempty"`
+}
+
+// pauseRequest is the optional body of the pause endpoint
+type pauseRequest struct {
+	Reason string `json:"reason"`
+	TTLSec int    `json:"ttlSec"`
+}
+
+// scaleToMaxRequest is the body of the emergency scale endpoint
+type scaleToMaxRequest struct {
+	Reason string `json:"reason"`
+}
+
+// NewServer creates the admin API for the autoscalers, configured from the root of the config
+func NewServer(config *v1alpha1.ConfigSpec, autoscalers []*v1alpha1.Context, elector *leader.Elector) (*Server, error) {
+	if config.Admin.Token == "" {
+		return nil, fmt.Errorf("token is required for the admin API")
+	}
+
+	return &Server{
+		address:     config.Admin.Address,
+		token:       config.Admin.Token,
+		autoscalers: autoscalers,
+		elector:     elector,
+	}, nil
+}
+
+// Run serves the admin API until it fails
+func (s *Server) Run() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", handleDashboard)
+	mux.HandleFunc("GET /status", s.authenticate(s.handleStatus))
+	mux.HandleFunc("GET /history", s.authenticate(s.handleHistory))
+	mux.HandleFunc("POST /pause", s.authenticate(s.handlePause))
+	mux.HandleFunc("POST /resume", s.authenticate(s.handleResume))
+	mux.HandleFunc("POST /scale-up", s.authenticate(s.handleScale(v1alpha1.DecisionScaleUp)))
+	mux.HandleFunc("POST /scale-down", s.authenticate(s.handleScale(v1alpha1.DecisionScaleDown)))
+	mux.HandleFunc("POST /scale-to-max", s.authenticate(s.handleScaleToMax))
+
+	log.Printf("Starting admin API on %s", s.address)
+	server := &http.Server{
+		Addr:              s.address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServe()
+}
+
+// handleDashboard serves the web page showing the status of the autoscalers. The page holds no data: it asks for the
+// token and reads it from the authenticated endpoints
+func handleDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+	_, _ = w.Write(dashboard)
+}
+
+// authenticate rejects the requests without the bearer token configured
+func (s *Server) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	expected := []byte("Bearer " + s.token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// selectAutoscalers returns the autoscalers named in the "autoscaler" query parameter, or all of them when missing
+func (s *Server) selectAutoscalers(r *http.Request) ([]*v1alpha1.Context, error) {
+	name := r.URL.Query().Get("autoscaler")
+	if name == "" {
+		return s.autoscalers, nil
+	}
+
+	// The config is swapped when reloaded, so it is read under the mutex
+	for _, ctx := range s.autoscalers {
+		ctx.Mutex.Lock()
+		found := ctx.Config.Name == name
+		ctx.Mutex.Unlock()
+		if found {
+			return []*v1alpha1.Context{ctx}, nil
+		}
+	}
+	return nil, fmt.Errorf("autoscaler %s not found", name)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	autoscalers, err := s.selectAutoscalers(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	statuses := make([]autoscalerStatus, 0, len(autoscalers))
+	for _, ctx := range autoscalers {
+		status := autoscalerStatus{
+			Name:    ctx.Config.Name,
+			Leader:  s.elector.IsLeader(),
+			Version: version.String(),
+		}
+
+		migSizes, currentSize, minSize, maxSize, err := google.GetMIGSizes(ctx)
+		if err != nil {
+			status.SizeError = err.Error()
+		}
+		status.MIGs, status.CurrentSize, status.MinSize, status.MaxSize = migSizes, currentSize, minSize, maxSize
+
+		status.NextScaling, err = state.GetNextScaling(ctx, time.Now())
+		if err != nil {
+			status.NextScalingError = err.Error()
+		}
+
+		ctx.Mutex.Lock()
+		status.LastDecision = ctx.LastDecision
+		status.CooldownRemainingSec = max(0, int(time.Until(ctx.State.CooldownUntil).Seconds()))
+		status.Pause = ctx.State.Pause
+		status.InFlightOperation = ctx.State.InFlightOperation
+		status.Drain = ctx.Drain
+		status.DryRunActions = append([]v1alpha1.DryRunAction{}, ctx.DryRunActions...)
+		ctx.Mutex.Unlock()
+
+		statuses = append(statuses, status)
+	}
+
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	autoscalers, err := s.selectAutoscalers(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	history := make(map[string][]v1alpha1.Decision, len(autoscalers))
+	for _, ctx := range autoscalers {
+		ctx.Mutex.Lock()
+		history[ctx.Config.Name] = append([]v1alpha1.Decision{}, ctx.History...)
+		ctx.Mutex.Unlock()
+	}
+
+	writeJSON(w, http.StatusOK, history)
+}
+
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	autoscalers, err := s.selectAutoscalers(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	var request pauseRequest
+	if r.ContentLength != 0 {
+		err = json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid body: %v", err))
+			return
+		}
+	}
+
+	pause := &v1alpha1.Pause{
+		Reason:   request.Reason,
+		PausedAt: time.Now(),
+	}
+	if request.TTLSec > 0 {
+		pause.Until = pause.PausedAt.Add(time.Duration(request.TTLSec) * time.Second)
+	}
+
+	for _, ctx := range autoscalers {
+		err = state.SetPause(ctx, pause)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("error pausing autoscaler %s: %v", ctx.Config.Name, err))
+			return
+		}
+		log.Printf("Paused autoscaler %s from the admin API", ctx.Config.Name)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"result": "paused"})
+}
+
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	autoscalers, err := s.selectAutoscalers(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	for _, ctx := range autoscalers {
+		err = state.SetPause(ctx, nil)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("error resuming autoscaler %s: %v", ctx.Config.Name, err))
+			return
+		}
+		log.Printf("Resumed autoscaler %s from the admin API", ctx.Config.Name)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"result": "resumed"})
+}
+
+// handleScale requests the scaling action to the autoscaler, which executes it asynchronously
+func (s *Server) handleScale(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.requestAction(w, r, v1alpha1.ActionRequest{Action: action, Reason: "Requested from the admin API"})
+	}
+}
+
+// handleScaleToMax requests the emergency scale up to the maximum size, which requires the reason of the incident
+func (s *Server) handleScaleToMax(w http.ResponseWriter, r *http.Request) {
+	var request scaleToMaxRequest
+	if r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid body: %v", err))
+			return
+		}
+	}
+	if strings.TrimSpace(request.Reason) == "" {
+		writeError(w, http.StatusBadRequest, "the reason of the emergency scale up is required")
+		return
+	}
+
+	s.requestAction(w, r, v1alpha1.ActionRequest{Action: v1alpha1.ActionScaleToMax, Reason: "Emergency requested from the admin API: " + request.Reason})
+}
+
+// requestAction enqueues the scaling action in the autoscaler selected, answering once it is accepted
+func (s *Server) requestAction(w http.ResponseWriter, r *http.Request, request v1alpha1.ActionRequest) {
+	autoscalers, err := s.selectAutoscalers(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if len(autoscalers) != 1 {
+		writeError(w, http.StatusBadRequest, "the autoscaler query parameter is required when several autoscalers are running")
+		return
+	}
+	if !s.elector.IsLeader() {
+		writeError(w, http.StatusServiceUnavailable, "this replica is not the leader")
+		return
+	}
+
+	ctx := autoscalers[0]
+	select {
+	case ctx.Requests <- request:
+		log.Printf("Requested %s for autoscaler %s from the admin API. %s", request.Action, ctx.Config.Name, request.Reason)
+		writeJSON(w, http.StatusAccepted, map[string]string{"result": "requested"})
+	default:
+		writeError(w, http.StatusConflict, "another scaling action is already pending")
+	}
+}
+
+// writeJSON encodes the body as the JSON response
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		log.Printf("Error encoding admin API response: %v", err)
+	}
+}
+
+// writeError sends the error message as the JSON response
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	writeJSON(w, statusCode, map[string]string{"error": message})
+}
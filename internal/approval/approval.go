@@ -0,0 +1,169 @@
+package approval
+
+import (
+	"crypto/rand"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/maintenance"
+	"custom-vm-autoscaler/internal/proxy"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	// Actions of the buttons in the approval messages
+	ActionApprove = "approve"
+	ActionReject  = "reject"
+
+	// Behaviours when nobody answers the approval message in time
+	OnTimeoutProceed = "proceed"
+	OnTimeoutCancel  = "cancel"
+)
+
+// ErrRejected is returned when the scale down is rejected, or nobody approved it in time and it must be cancelled
+var ErrRejected = errors.New("scale down not approved")
+
+// answer is the click of a user on one of the buttons of an approval message
+type answer struct {
+	approved bool
+	user     string
+}
+
+// pendingRequest is an approval message waiting for an answer
+type pendingRequest struct {
+	description string
+	answers     chan answer
+}
+
+var (
+	// pendingRequests holds the approval messages waiting for an answer, by request ID
+	pendingRequests = map[string]*pendingRequest{}
+
+	// pendingRequestsMutex serializes the accesses to the pending requests, shared by every autoscaler in the process
+	pendingRequestsMutex sync.Mutex
+)
+
+// IsRequired checks if the scale down of the autoscaler must be approved at this moment
+func IsRequired(ctx *v1alpha1.Context) (bool, error) {
+	spec := ctx.Config.Autoscaler.ScaleDownApproval
+	if !spec.Enabled {
+		return false, nil
+	}
+	if spec.Days == "" {
+		return true, nil
+	}
+	return maintenance.IsWithinWindow(time.Now().UTC(), spec.Days, spec.HoursUTC)
+}
+
+// Request asks on Slack for the approval of removing the instance from the MIG, waiting for the answer.
+// It returns nil when the scale down is approved or no approval is required, and ErrRejected otherwise
+func Request(ctx *v1alpha1.Context, mig, instance string) error {
+	required, err := IsRequired(ctx)
+	if err != nil {
+		return fmt.Errorf("invalid hours in scale down approval: %w", err)
+	}
+	if !required {
+		return nil
+	}
+	return ask(ctx, fmt.Sprintf("scale down of autoscaler %s removing instance %s from MIG %s", ctx.Config.Name, instance, mig))
+}
+
+// RequestForcedRemoval asks on Slack for the approval of removing the instance whose Elasticsearch drain timed out
+// with shards remaining, waiting for the answer. It returns nil when the removal is approved, and ErrRejected otherwise.
+// The Slack webhook, timeout and behaviour on timeout of the scale down approval are used, even when it is not enabled
+func RequestForcedRemoval(ctx *v1alpha1.Context, instance string, remainingShards int) error {
+	return ask(ctx, fmt.Sprintf("forced removal of instance %s by autoscaler %s after its Elasticsearch drain timed out with %d shards remaining",
+		instance, ctx.Config.Name, remainingShards))
+}
+
+// ask posts the approval message with the description on Slack, waiting for the answer
+func ask(ctx *v1alpha1.Context, description string) error {
+	spec := ctx.Config.Autoscaler.ScaleDownApproval
+
+	id, err := newRequestID()
+	if err != nil {
+		return fmt.Errorf("error generating approval request ID: %w", err)
+	}
+
+	request := &pendingRequest{description: description, answers: make(chan answer, 1)}
+	pendingRequestsMutex.Lock()
+	pendingRequests[id] = request
+	pendingRequestsMutex.Unlock()
+	defer func() {
+		pendingRequestsMutex.Lock()
+		delete(pendingRequests, id)
+		pendingRequestsMutex.Unlock()
+	}()
+
+	// Post the message with the buttons to approve or reject the scale down
+	text := fmt.Sprintf("Approval required for the %s. Without an answer in %d seconds it will %s", description, spec.TimeoutSec, spec.OnTimeout)
+	approveButton := slack.NewButtonBlockElement(ActionApprove, id, slack.NewTextBlockObject(slack.PlainTextType, "Approve", false, false))
+	approveButton.Style = slack.StylePrimary
+	rejectButton := slack.NewButtonBlockElement(ActionReject, id, slack.NewTextBlockObject(slack.PlainTextType, "Reject", false, false))
+	rejectButton.Style = slack.StyleDanger
+	msg := slack.WebhookMessage{
+		Text: text,
+		Blocks: &slack.Blocks{BlockSet: []slack.Block{
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+			slack.NewActionBlock("approval-"+id, approveButton, rejectButton),
+		}},
+	}
+	transport, err := proxy.Transport(ctx.Config.Notifications.Proxy)
+	if err != nil {
+		return fmt.Errorf("error configuring the proxy of Slack: %w", err)
+	}
+	client := &http.Client{Timeout: time.Duration(ctx.Config.Notifications.TimeoutSec) * time.Second, Transport: transport}
+	err = slack.PostWebhookCustomHTTPContext(ctx.ConnContext(), spec.SlackWebhookURL, client, &msg)
+	if err != nil {
+		return fmt.Errorf("error posting approval message to Slack: %w", err)
+	}
+	log.Printf("Waiting for the approval of the %s", description)
+
+	select {
+	case a := <-request.answers:
+		if !a.approved {
+			return fmt.Errorf("%w: rejected by %s", ErrRejected, a.user)
+		}
+		log.Printf("The %s was approved by %s", description, a.user)
+		return nil
+	case <-time.After(time.Duration(spec.TimeoutSec) * time.Second):
+		if spec.OnTimeout == OnTimeoutProceed {
+			log.Printf("Nobody answered the approval of the %s in %d seconds, proceeding", description, spec.TimeoutSec)
+			return nil
+		}
+		return fmt.Errorf("%w: nobody answered in %d seconds", ErrRejected, spec.TimeoutSec)
+	case <-ctx.ConnContext().Done():
+		return fmt.Errorf("waiting for the approval of the %s interrupted: %w", description, ctx.ConnContext().Err())
+	}
+}
+
+// answerRequest delivers the answer to the pending request, returning its description.
+// It returns false when the request is not waiting anymore, e.g. it timed out
+func answerRequest(id string, a answer) (string, bool) {
+	pendingRequestsMutex.Lock()
+	defer pendingRequestsMutex.Unlock()
+
+	request, ok := pendingRequests[id]
+	if !ok {
+		return "", false
+	}
+	delete(pendingRequests, id)
+	request.answers <- a
+	return request.description, true
+}
+
+// newRequestID generates a random ID identifying the approval message
+func newRequestID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
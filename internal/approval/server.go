@@ -0,0 +1,110 @@
+package approval
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/proxy"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Server receives the clicks on the buttons of the approval messages from Slack
+type Server struct {
+	address       string
+	signingSecret string
+	client        *http.Client
+}
+
+// NewServer creates the endpoint for the interactions of Slack, configured from the root of the config
+func NewServer(config *v1alpha1.ConfigSpec) (*Server, error) {
+	if config.Approvals.SlackSigningSecret == "" {
+		return nil, fmt.Errorf("slackSigningSecret is required for the scale down approvals")
+	}
+
+	transport, err := proxy.Transport(config.Notifications.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		address:       config.Approvals.Address,
+		signingSecret: config.Approvals.SlackSigningSecret,
+		client:        &http.Client{Timeout: time.Duration(config.Notifications.TimeoutSec) * time.Second, Transport: transport},
+	}, nil
+}
+
+// Run serves the endpoint for the interactions of Slack until it fails
+func (s *Server) Run() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /slack/interactions", s.handleInteraction)
+
+	log.Printf("Starting approvals server on %s", s.address)
+	server := &http.Server{
+		Addr:              s.address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServe()
+}
+
+// handleInteraction verifies the signature of the request sent by Slack and delivers the answer to the pending request
+func (s *Server) handleInteraction(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error reading body", http.StatusBadRequest)
+		return
+	}
+
+	verifier, err := slack.NewSecretsVerifier(r.Header, s.signingSecret)
+	if err != nil {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	_, _ = verifier.Write(body)
+	if verifier.Ensure() != nil {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	var callback slack.InteractionCallback
+	err = json.Unmarshal([]byte(form.Get("payload")), &callback)
+	if err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	for _, action := range callback.ActionCallback.BlockActions {
+		if action.ActionID != ActionApprove && action.ActionID != ActionReject {
+			continue
+		}
+
+		// Replace the buttons with the answer, so nobody can click them again
+		a := answer{approved: action.ActionID == ActionApprove, user: callback.User.Name}
+		text := "This approval request is not pending anymore"
+		if description, ok := answerRequest(action.Value, a); ok {
+			text = fmt.Sprintf("The %s was rejected by %s", description, a.user)
+			if a.approved {
+				text = fmt.Sprintf("The %s was approved by %s", description, a.user)
+			}
+		}
+		go func(responseURL string) {
+			err := slack.PostWebhookCustomHTTP(responseURL, s.client, &slack.WebhookMessage{Text: text, ReplaceOriginal: true})
+			if err != nil {
+				log.Printf("Error updating approval message in Slack: %v", err)
+			}
+		}(callback.ResponseURL)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// retentionInterval is the time between the removals of the events older than the retention
+const retentionInterval = time.Hour
+
+// sink is implemented by every backend able to record the decisions of the autoscalers
+type sink interface {
+	write(event v1alpha1.Decision) error
+	read(since time.Time) ([]v1alpha1.Decision, error)
+	prune(before time.Time) error
+}
+
+var (
+	// sinks are the backends configured. When empty, the decisions are not recorded
+	sinks []sink
+
+	// retention is the time the events are kept. Zero keeps them forever
+	retention time.Duration
+
+	// sinksMutex serializes the accesses to the sinks, shared by every autoscaler in the process
+	sinksMutex sync.Mutex
+)
+
+// Setup configures the audit backends defined in the config. Several of them can be used at the same time
+func Setup(config *v1alpha1.ConfigSpec) error {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+
+	sinks = nil
+	if config.Audit.File.Path != "" {
+		fileSink, err := newFileSink(config)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, fileSink)
+	}
+	if config.Audit.GCS.Bucket != "" {
+		gcsSink, err := newGCSSink(config)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, gcsSink)
+	}
+	if config.Audit.BigQuery.Table != "" {
+		bigQuerySink, err := newBigQuerySink(config)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, bigQuerySink)
+	}
+
+	retention = time.Duration(config.Audit.RetentionDays) * 24 * time.Hour
+	return nil
+}
+
+// Record appends the decision to every audit backend. Errors are logged, as the autoscaler must keep working
+func Record(event v1alpha1.Decision) {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+
+	for _, s := range sinks {
+		err := s.write(event)
+		if err != nil {
+			log.Printf("Error recording audit event: %v", err)
+		}
+	}
+}
+
+// Read returns the events recorded since the given time, oldest first. The first backend configured is read,
+// in the order file, gcs and bigquery
+func Read(since time.Time) ([]v1alpha1.Decision, error) {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+
+	if len(sinks) == 0 {
+		return nil, fmt.Errorf("no audit backend configured")
+	}
+
+	events, err := sinks[0].read(since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit events: %w", err)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events, nil
+}
+
+// RunRetention removes periodically the events older than the retention configured
+func RunRetention() {
+	if retention == 0 {
+		return
+	}
+
+	for {
+		sinksMutex.Lock()
+		before := time.Now().Add(-retention)
+		for _, s := range sinks {
+			err := s.prune(before)
+			if err != nil {
+				log.Printf("Error removing old audit events: %v", err)
+			}
+		}
+		sinksMutex.Unlock()
+
+		time.Sleep(retentionInterval)
+	}
+}
@@ -0,0 +1,141 @@
+package audit
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	bigquery "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// bigQuerySink streams the events into a BigQuery table for long-term analytics.
+// The table is created partitioned by day when missing, and the partitions expire after the retention
+type bigQuerySink struct {
+	service   *bigquery.Service
+	projectID string
+	dataset   string
+	table     string
+}
+
+// newBigQuerySink creates a sink backed by the BigQuery table defined in the config
+func newBigQuerySink(config *v1alpha1.ConfigSpec) (*bigQuerySink, error) {
+	bq := config.Audit.BigQuery
+	if bq.ProjectID == "" || bq.Dataset == "" || bq.Table == "" {
+		return nil, fmt.Errorf("projectID, dataset and table are required for bigquery audit backend")
+	}
+
+	var opts []option.ClientOption
+	if bq.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(bq.CredentialsFile))
+	}
+
+	service, err := bigquery.NewService(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create BigQuery client: %w", err)
+	}
+
+	s := &bigQuerySink{service: service, projectID: bq.ProjectID, dataset: bq.Dataset, table: bq.Table}
+	err = s.ensureTable(time.Duration(config.Audit.RetentionDays) * 24 * time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ensureTable creates the table with the schema of the events when it does not exist
+func (s *bigQuerySink) ensureTable(retention time.Duration) error {
+	ctxConn, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := s.service.Tables.Get(s.projectID, s.dataset, s.table).Context(ctxConn).Do()
+	var apiErr *googleapi.Error
+	if err == nil || !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound {
+		return err
+	}
+
+	table := &bigquery.Table{
+		TableReference: &bigquery.TableReference{ProjectId: s.projectID, DatasetId: s.dataset, TableId: s.table},
+		Schema: &bigquery.TableSchema{Fields: []*bigquery.TableFieldSchema{
+			{Name: "time", Type: "TIMESTAMP", Mode: "REQUIRED"},
+			{Name: "autoscaler", Type: "STRING"},
+			{Name: "action", Type: "STRING"},
+			{Name: "trigger", Type: "STRING"},
+			{Name: "outcome", Type: "STRING"},
+			{Name: "reason", Type: "STRING"},
+			{Name: "condition", Type: "STRING"},
+			{Name: "metricValues", Type: "FLOAT", Mode: "REPEATED"},
+			{Name: "mig", Type: "STRING"},
+			{Name: "instance", Type: "STRING"},
+			{Name: "previousSize", Type: "INTEGER"},
+			{Name: "size", Type: "INTEGER"},
+			{Name: "durationMs", Type: "INTEGER"},
+			{Name: "error", Type: "STRING"},
+		}},
+		TimePartitioning: &bigquery.TimePartitioning{Type: "DAY", Field: "time", ExpirationMs: retention.Milliseconds()},
+	}
+
+	_, err = s.service.Tables.Insert(s.projectID, s.dataset, table).Context(ctxConn).Do()
+	if err != nil {
+		return fmt.Errorf("failed to create BigQuery table: %w", err)
+	}
+	log.Printf("Created BigQuery table %s.%s.%s for audit events", s.projectID, s.dataset, s.table)
+	return nil
+}
+
+func (s *bigQuerySink) write(event v1alpha1.Decision) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+	var row map[string]bigquery.JsonValue
+	err = json.Unmarshal(data, &row)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+
+	ctxConn, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// The fields of the events missing in the schema of the table, like the labels of the metric samples,
+	// are dropped instead of rejecting the whole event
+	request := &bigquery.TableDataInsertAllRequest{
+		IgnoreUnknownValues: true,
+		Rows: []*bigquery.TableDataInsertAllRequestRows{{
+			InsertId: fmt.Sprintf("%s-%d", event.Autoscaler, event.Time.UnixNano()),
+			Json:     row,
+		}},
+	}
+	res, err := s.service.Tabledata.InsertAll(s.projectID, s.dataset, s.table, request).Context(ctxConn).Do()
+	if err != nil {
+		return fmt.Errorf("failed to stream audit event to BigQuery: %w", err)
+	}
+
+	var rowErrors []string
+	for _, insertErr := range res.InsertErrors {
+		for _, errorProto := range insertErr.Errors {
+			rowErrors = append(rowErrors, errorProto.Message)
+		}
+	}
+	if len(rowErrors) > 0 {
+		return fmt.Errorf("failed to stream audit event to BigQuery: %s", strings.Join(rowErrors, ", "))
+	}
+	return nil
+}
+
+// read is not supported, as BigQuery is meant to be queried from the analytics dashboards
+func (s *bigQuerySink) read(since time.Time) ([]v1alpha1.Decision, error) {
+	return nil, fmt.Errorf("reading is not supported by the bigquery audit backend")
+}
+
+// prune does nothing, as the partitions of the table expire after the retention
+func (s *bigQuerySink) prune(before time.Time) error {
+	return nil
+}
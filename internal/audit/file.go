@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fileSink appends the events to a local JSONL file
+type fileSink struct {
+	path string
+}
+
+// newFileSink creates a sink backed by the local file defined in the config
+func newFileSink(config *v1alpha1.ConfigSpec) (*fileSink, error) {
+	err := os.MkdirAll(filepath.Dir(config.Audit.File.Path), 0o750)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	return &fileSink{path: config.Audit.File.Path}, nil
+}
+
+func (s *fileSink) write(event v1alpha1.Decision) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+func (s *fileSink) read(since time.Time) ([]v1alpha1.Decision, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeEvents(data, since)
+}
+
+// prune rewrites the file without the old events. The new file is renamed afterwards, so a crash never corrupts it
+func (s *fileSink) prune(before time.Time) error {
+	events, err := s.read(before)
+	if err != nil {
+		return err
+	}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	for _, event := range events {
+		err = encoder.Encode(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit event: %w", err)
+		}
+	}
+
+	tmpPath := s.path + ".tmp"
+	err = os.WriteFile(tmpPath, buffer.Bytes(), 0o640)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+// decodeEvents parses the JSONL events recorded since the given time
+func decodeEvents(data []byte, since time.Time) ([]v1alpha1.Decision, error) {
+	var events []v1alpha1.Decision
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var event v1alpha1.Decision
+		err := json.Unmarshal(scanner.Bytes(), &event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode audit event: %w", err)
+		}
+		if event.Time.Before(since) {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
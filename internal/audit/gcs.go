@@ -0,0 +1,161 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"google.golang.org/api/option"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// gcsFlushInterval is the maximum time the events without scaling actions are buffered before uploading them
+const gcsFlushInterval = 5 * time.Minute
+
+// gcsSink uploads the events to a GCS bucket as JSONL objects. Events are buffered to avoid creating one object
+// per evaluation, and uploaded as soon as a scaling action is recorded
+type gcsSink struct {
+	service  *storage.Service
+	bucket   string
+	prefix   string
+	hostname string
+
+	buffer    bytes.Buffer
+	flushedAt time.Time
+}
+
+// newGCSSink creates a sink backed by the GCS bucket defined in the config
+func newGCSSink(config *v1alpha1.ConfigSpec) (*gcsSink, error) {
+	gcs := config.Audit.GCS
+
+	var opts []option.ClientOption
+	if gcs.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(gcs.CredentialsFile))
+	}
+
+	service, err := storage.NewService(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	return &gcsSink{service: service, bucket: gcs.Bucket, prefix: gcs.Prefix, hostname: hostname, flushedAt: time.Now()}, nil
+}
+
+func (s *gcsSink) write(event v1alpha1.Decision) error {
+	err := json.NewEncoder(&s.buffer).Encode(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+
+	if event.Action == v1alpha1.DecisionNone && time.Since(s.flushedAt) < gcsFlushInterval {
+		return nil
+	}
+	return s.flush()
+}
+
+// flush uploads the buffered events as a new object, named after the upload time
+func (s *gcsSink) flush() error {
+	if s.buffer.Len() == 0 {
+		return nil
+	}
+
+	ctxConn, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	name := path.Join(s.prefix, now.Format("2006/01/02"), fmt.Sprintf("%s-%s.jsonl", now.Format("150405.000000000"), s.hostname))
+	_, err := s.service.Objects.Insert(s.bucket, &storage.Object{Name: name, ContentType: "application/x-ndjson"}).
+		Media(bytes.NewReader(s.buffer.Bytes())).
+		Context(ctxConn).
+		Do()
+	if err != nil {
+		return fmt.Errorf("failed to upload audit events: %w", err)
+	}
+
+	s.buffer.Reset()
+	s.flushedAt = time.Now()
+	return nil
+}
+
+// listObjects calls the function for every object holding events
+func (s *gcsSink) listObjects(ctxConn context.Context, f func(*storage.Object) error) error {
+	prefix := s.prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	return s.service.Objects.List(s.bucket).Prefix(prefix).Pages(ctxConn, func(objects *storage.Objects) error {
+		for _, object := range objects.Items {
+			err := f(object)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *gcsSink) read(since time.Time) ([]v1alpha1.Decision, error) {
+	ctxConn, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var events []v1alpha1.Decision
+	err := s.listObjects(ctxConn, func(object *storage.Object) error {
+		// Objects are never modified, so the ones uploaded before the time only hold older events
+		updated, err := time.Parse(time.RFC3339, object.Updated)
+		if err == nil && updated.Before(since) {
+			return nil
+		}
+
+		res, err := s.service.Objects.Get(s.bucket, object.Name).Context(ctxConn).Download()
+		if err != nil {
+			return fmt.Errorf("failed to download %s: %w", object.Name, err)
+		}
+		defer res.Body.Close()
+
+		data, err := io.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to download %s: %w", object.Name, err)
+		}
+
+		objectEvents, err := decodeEvents(data, since)
+		if err != nil {
+			return err
+		}
+		events = append(events, objectEvents...)
+		return nil
+	})
+
+	// Include the events not uploaded yet
+	bufferEvents, bufferErr := decodeEvents(s.buffer.Bytes(), since)
+	if bufferErr == nil {
+		events = append(events, bufferEvents...)
+	}
+	return events, err
+}
+
+// prune deletes the objects uploaded before the time, as they only hold older events
+func (s *gcsSink) prune(before time.Time) error {
+	ctxConn, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	return s.listObjects(ctxConn, func(object *storage.Object) error {
+		updated, err := time.Parse(time.RFC3339, object.Updated)
+		if err != nil || !updated.Before(before) {
+			return nil
+		}
+
+		err = s.service.Objects.Delete(s.bucket, object.Name).Context(ctxConn).Do()
+		if err != nil {
+			return fmt.Errorf("failed to delete %s: %w", object.Name, err)
+		}
+		return nil
+	})
+}
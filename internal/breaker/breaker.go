@@ -0,0 +1,89 @@
+package breaker
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/notifier"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Dependencies protected by a circuit breaker
+	DependencyGCP           = "gcp"
+	DependencyElasticsearch = "elasticsearch"
+)
+
+// circuit counts the consecutive failures of the calls to a dependency of an autoscaler
+type circuit struct {
+	failures int
+	openedAt time.Time
+}
+
+var (
+	// circuits holds the state of the circuit of every dependency, by autoscaler and dependency
+	circuits = map[string]*circuit{}
+
+	// circuitsMutex serializes the accesses to the circuits, shared by every autoscaler in the process
+	circuitsMutex sync.Mutex
+)
+
+// Record counts the result of a call to the dependency, once its retries are exhausted. The circuit is opened after
+// failureThreshold consecutive failures, stopping the scaling actions, and closed by the first call succeeding
+func Record(ctx *v1alpha1.Context, dependency string, err error) {
+	circuitsMutex.Lock()
+	id := ctx.Config.Name + "/" + dependency
+	c, ok := circuits[id]
+	if !ok {
+		c = &circuit{}
+		circuits[id] = c
+	}
+
+	if err == nil {
+		wasOpen := !c.openedAt.IsZero()
+		delete(circuits, id)
+		circuitsMutex.Unlock()
+
+		if wasOpen {
+			log.Printf("Circuit of %s closed, calls succeeding again", dependency)
+			notifier.Resolve(ctx, alertKey(dependency), fmt.Sprintf("Calls to %s succeeding again, scaling resumed", dependency))
+		}
+		return
+	}
+
+	c.failures++
+	opened := c.openedAt.IsZero() && c.failures >= ctx.Config.CircuitBreaker.FailureThreshold
+	if opened {
+		c.openedAt = time.Now()
+	}
+	failures := c.failures
+	circuitsMutex.Unlock()
+
+	if opened {
+		log.Printf("Circuit of %s opened after %d consecutive failures", dependency, failures)
+		notifier.Alert(ctx, notifier.SeverityError, alertKey(dependency),
+			fmt.Sprintf("%d consecutive calls to %s failed, scaling stopped until it recovers. Last error: %v", failures, dependency, err))
+	}
+}
+
+// OpenCircuits returns the dependencies of the autoscaler whose circuit is open, sorted by name
+func OpenCircuits(ctx *v1alpha1.Context) []string {
+	circuitsMutex.Lock()
+	defer circuitsMutex.Unlock()
+
+	var open []string
+	for _, dependency := range []string{DependencyGCP, DependencyElasticsearch} {
+		if c, ok := circuits[ctx.Config.Name+"/"+dependency]; ok && !c.openedAt.IsZero() {
+			open = append(open, dependency)
+		}
+	}
+	sort.Strings(open)
+	return open
+}
+
+// alertKey returns the key of the alert raised while the circuit of the dependency is open
+func alertKey(dependency string) string {
+	return notifier.AlertCircuitOpen + "/" + dependency
+}
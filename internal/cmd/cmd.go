@@ -1,7 +1,22 @@
 package cmd
 
 import (
+	"custom-vm-autoscaler/internal/cmd/config"
+	"custom-vm-autoscaler/internal/cmd/drain"
+	"custom-vm-autoscaler/internal/cmd/history"
+	"custom-vm-autoscaler/internal/cmd/pause"
+	"custom-vm-autoscaler/internal/cmd/plan"
+	"custom-vm-autoscaler/internal/cmd/resume"
 	"custom-vm-autoscaler/internal/cmd/run"
+	"custom-vm-autoscaler/internal/cmd/scaletomax"
+	"custom-vm-autoscaler/internal/cmd/schedule"
+	"custom-vm-autoscaler/internal/cmd/simulate"
+	"custom-vm-autoscaler/internal/cmd/status"
+	"custom-vm-autoscaler/internal/cmd/undrain"
+	"custom-vm-autoscaler/internal/cmd/validate"
+	"custom-vm-autoscaler/internal/cmd/version"
+	configfile "custom-vm-autoscaler/internal/config"
+	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -22,10 +37,33 @@ func NewRootCommand(name string) *cobra.Command {
 		Use:   name,
 		Short: descriptionShort,
 		Long:  strings.ReplaceAll(descriptionLong, "\t", ""),
+
+		// Override the values of the config read by any command, from the flags and the environment
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			sets, err := cmd.Flags().GetStringArray("set")
+			if err != nil {
+				return err
+			}
+			return configfile.SetOverrides(sets, os.Environ())
+		},
 	}
+	c.PersistentFlags().StringArray("set", nil, "Override a value of the config after reading it, as path=value (e.g. autoscaler.maxSize=20). Can be repeated")
 
 	c.AddCommand(
 		run.NewCommand(),
+		pause.NewCommand(),
+		resume.NewCommand(),
+		drain.NewCommand(),
+		undrain.NewCommand(),
+		scaletomax.NewCommand(),
+		status.NewCommand(),
+		history.NewCommand(),
+		validate.NewCommand(),
+		plan.NewCommand(),
+		simulate.NewCommand(),
+		schedule.NewCommand(),
+		config.NewCommand(),
+		version.NewCommand(),
 	)
 
 	return c
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	descriptionShort = `Manage the configuration file`
+	descriptionLong  = `
+	Manage the configuration file of the autoscaler`
+)
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "config",
+		DisableFlagsInUseLine: true,
+		Short:                 descriptionShort,
+		Long:                  strings.ReplaceAll(descriptionLong, "\t", ""),
+	}
+
+	cmd.AddCommand(
+		newInitCommand(),
+		newMigrateCommand(),
+		newSchemaCommand(),
+	)
+
+	return cmd
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"custom-vm-autoscaler/config/samples"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	initDescriptionShort = `Generate a sample configuration file`
+	initDescriptionLong  = `
+	Generate a complete and commented sample configuration file, to be completed with your values`
+)
+
+func newInitCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "init",
+		DisableFlagsInUseLine: true,
+		Short:                 initDescriptionShort,
+		Long:                  strings.ReplaceAll(initDescriptionLong, "\t", ""),
+
+		Run: RunInitCommand,
+	}
+
+	cmd.Flags().StringP("output", "o", "", "Path to write the sample config to. Printed when empty")
+	cmd.Flags().Bool("force", false, "Overwrite the output file when it exists")
+
+	return cmd
+}
+
+func RunInitCommand(cmd *cobra.Command, args []string) {
+
+	// Check the flags for this command
+	outputPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		log.Fatalf("Error getting output path: %v", err)
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		log.Fatalf("Error getting force flag: %v", err)
+	}
+
+	if outputPath == "" {
+		fmt.Print(string(samples.Autoscaler))
+		return
+	}
+
+	if _, err := os.Stat(outputPath); err == nil && !force {
+		log.Fatalf("File %s already exists, use --force to overwrite it", outputPath)
+	}
+	err = os.WriteFile(outputPath, samples.Autoscaler, 0o644)
+	if err != nil {
+		log.Fatalf("Error writing sample config: %v", err)
+	}
+	log.Printf("Sample config written in %s", outputPath)
+}
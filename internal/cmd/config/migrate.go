@@ -0,0 +1,84 @@
+package config
+
+import (
+	configfile "custom-vm-autoscaler/internal/config"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	migrateDescriptionShort = `Migrate the configuration file to a newer schema`
+	migrateDescriptionLong  = `
+	Migrate the configuration file to a newer version of its schema, defined by apiVersion.
+	Environment variables are not expanded, and comments are not kept`
+)
+
+func newMigrateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "migrate",
+		DisableFlagsInUseLine: true,
+		Short:                 migrateDescriptionShort,
+		Long:                  strings.ReplaceAll(migrateDescriptionLong, "\t", ""),
+
+		Run: RunMigrateCommand,
+	}
+
+	cmd.Flags().String("config", "autoscaler.yaml", "Path to the YAML config file")
+	cmd.Flags().String("to", configfile.LatestAPIVersion, "Version of the schema to migrate the config to")
+	cmd.Flags().StringP("output", "o", "", "Path to write the migrated config to. Printed when empty")
+
+	return cmd
+}
+
+func RunMigrateCommand(cmd *cobra.Command, args []string) {
+
+	// Check the flags for this command
+	configPath, err := cmd.Flags().GetString("config")
+	if err != nil {
+		log.Fatalf("Error getting configuration file path: %v", err)
+	}
+	to, err := cmd.Flags().GetString("to")
+	if err != nil {
+		log.Fatalf("Error getting target version: %v", err)
+	}
+	outputPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		log.Fatalf("Error getting output path: %v", err)
+	}
+
+	// Read the config without expanding the environment variables, so secrets are not written
+	fileBytes, err := os.ReadFile(configPath)
+	if err != nil {
+		log.Fatalf("Error reading configuration file: %v", err)
+	}
+	var rawConfig yaml.MapSlice
+	err = yaml.Unmarshal(fileBytes, &rawConfig)
+	if err != nil {
+		log.Fatalf("Error parsing configuration file: %v", err)
+	}
+
+	migratedConfig, err := configfile.Migrate(rawConfig, to)
+	if err != nil {
+		log.Fatalf("Error migrating configuration file: %v", err)
+	}
+	migratedBytes, err := yaml.Marshal(migratedConfig)
+	if err != nil {
+		log.Fatalf("Error encoding migrated configuration: %v", err)
+	}
+	migratedBytes = append([]byte("---\n"), migratedBytes...)
+
+	if outputPath == "" {
+		fmt.Print(string(migratedBytes))
+		return
+	}
+	err = os.WriteFile(outputPath, migratedBytes, 0o644)
+	if err != nil {
+		log.Fatalf("Error writing migrated configuration: %v", err)
+	}
+	log.Printf("Configuration migrated to %s in %s", to, outputPath)
+}
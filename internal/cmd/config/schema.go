@@ -0,0 +1,38 @@
+package config
+
+import (
+	configfile "custom-vm-autoscaler/internal/config"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	schemaDescriptionShort = `Print the JSON Schema of the configuration file`
+	schemaDescriptionLong  = `
+	Print the JSON Schema of the configuration file, so IDEs can validate and autocomplete it`
+)
+
+func newSchemaCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "schema",
+		DisableFlagsInUseLine: true,
+		Short:                 schemaDescriptionShort,
+		Long:                  strings.ReplaceAll(schemaDescriptionLong, "\t", ""),
+
+		Run: RunSchemaCommand,
+	}
+
+	return cmd
+}
+
+func RunSchemaCommand(cmd *cobra.Command, args []string) {
+	schema, err := json.MarshalIndent(configfile.Schema(), "", "  ")
+	if err != nil {
+		log.Fatalf("Error encoding JSON Schema: %v", err)
+	}
+	fmt.Println(string(schema))
+}
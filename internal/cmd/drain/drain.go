@@ -0,0 +1,80 @@
+package drain
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/config"
+	"custom-vm-autoscaler/internal/elasticsearch"
+
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	descriptionShort = `Drain a node of the target`
+	descriptionLong  = `
+	Drain the node of an instance from Elasticsearch, excluding it from the shard allocation
+	and waiting until its shards are relocated, without resizing the MIG. Useful before
+	performing a manual maintenance on the instance. Undrain it once the maintenance ends`
+)
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "drain <instance>",
+		DisableFlagsInUseLine: true,
+		Short:                 descriptionShort,
+		Long:                  strings.ReplaceAll(descriptionLong, "\t", ""),
+		Args:                  cobra.ExactArgs(1),
+
+		Run: RunCommand,
+	}
+
+	cmd.Flags().String("config", "autoscaler.yaml", "Path to the YAML config file, or its remote location (gs://, s3://, https://)")
+	cmd.Flags().String("autoscaler", "", "Name of the autoscaler owning the instance. Required when several autoscalers are defined")
+
+	return cmd
+}
+
+func RunCommand(cmd *cobra.Command, args []string) {
+	instanceName := args[0]
+
+	// Check the flags for this command
+	configPath, err := cmd.Flags().GetString("config")
+	if err != nil {
+		log.Fatalf("Error getting configuration file path: %v", err)
+	}
+	autoscalerName, err := cmd.Flags().GetString("autoscaler")
+	if err != nil {
+		log.Fatalf("Error getting autoscaler name: %v", err)
+	}
+
+	// Get and parse the config
+	configContent, err := config.ReadFile(configPath)
+	if err != nil {
+		log.Fatalf("Error parsing configuration file: %v", err)
+	}
+	autoscalers, err := config.GetAutoscalersByName(configContent, autoscalerName)
+	if err != nil {
+		log.Fatalf("Error getting autoscalers: %v", err)
+	}
+	if len(autoscalers) > 1 {
+		log.Fatalf("Several autoscalers are defined, choose the one owning the instance with --autoscaler")
+	}
+
+	// Stop waiting for the drain on SIGINT or SIGTERM. The node stays excluded until undrained
+	ctxRun, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ctx := &v1alpha1.Context{Config: &autoscalers[0], Parent: ctxRun}
+	log.Printf("Draining instance %s from elasticsearch", instanceName)
+	err = elasticsearch.DrainElasticsearchNode(ctx, instanceName)
+	if err != nil {
+		log.Fatalf("Error draining instance %s, undrain it to allocate shards on it again: %v", instanceName, err)
+	}
+	log.Printf("Instance %s drained, undrain it once the maintenance ends", instanceName)
+}
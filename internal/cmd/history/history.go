@@ -0,0 +1,153 @@
+package history
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/audit"
+	"custom-vm-autoscaler/internal/config"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	descriptionShort = `Show the history of scaling events`
+	descriptionLong  = `
+	Show the recent scaling events recorded in the audit log, filtered by time,
+	direction or outcome, in table or JSON format`
+
+	// Directions of the scaling events to show
+	directionUp   = "up"
+	directionDown = "down"
+
+	// Output formats
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "history",
+		DisableFlagsInUseLine: true,
+		Short:                 descriptionShort,
+		Long:                  strings.ReplaceAll(descriptionLong, "\t", ""),
+
+		Run: RunCommand,
+	}
+
+	cmd.Flags().String("config", "autoscaler.yaml", "Path to the YAML config file")
+	cmd.Flags().String("autoscaler", "", "Name of the autoscaler to show. Every autoscaler is shown when empty")
+	cmd.Flags().Duration("since", 24*time.Hour, "Show the events recorded during this time")
+	cmd.Flags().String("direction", "", "Show only the events scaling in this direction (up or down)")
+	cmd.Flags().Bool("failed-only", false, "Show only the failed events")
+	cmd.Flags().Bool("all", false, "Show the evaluations without scaling actions too")
+	cmd.Flags().StringP("output", "o", outputTable, "Output format (table or json)")
+
+	return cmd
+}
+
+func RunCommand(cmd *cobra.Command, args []string) {
+
+	// Check the flags for this command
+	configPath, err := cmd.Flags().GetString("config")
+	if err != nil {
+		log.Fatalf("Error getting configuration file path: %v", err)
+	}
+	autoscalerName, err := cmd.Flags().GetString("autoscaler")
+	if err != nil {
+		log.Fatalf("Error getting autoscaler name: %v", err)
+	}
+	since, err := cmd.Flags().GetDuration("since")
+	if err != nil {
+		log.Fatalf("Error getting since: %v", err)
+	}
+	direction, err := cmd.Flags().GetString("direction")
+	if err != nil {
+		log.Fatalf("Error getting direction: %v", err)
+	}
+	if direction != "" && direction != directionUp && direction != directionDown {
+		log.Fatalf("Invalid direction %s, expected %s or %s", direction, directionUp, directionDown)
+	}
+	failedOnly, err := cmd.Flags().GetBool("failed-only")
+	if err != nil {
+		log.Fatalf("Error getting failed-only: %v", err)
+	}
+	all, err := cmd.Flags().GetBool("all")
+	if err != nil {
+		log.Fatalf("Error getting all: %v", err)
+	}
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		log.Fatalf("Error getting output: %v", err)
+	}
+	if output != outputTable && output != outputJSON {
+		log.Fatalf("Invalid output %s, expected %s or %s", output, outputTable, outputJSON)
+	}
+
+	// Get and parse the config
+	configContent, err := config.ReadFile(configPath)
+	if err != nil {
+		log.Fatalf("Error parsing configuration file: %v", err)
+	}
+	if autoscalerName != "" {
+		_, err = config.GetAutoscalersByName(configContent, autoscalerName)
+		if err != nil {
+			log.Fatalf("Error getting autoscalers: %v", err)
+		}
+	}
+
+	err = audit.Setup(&configContent)
+	if err != nil {
+		log.Fatalf("Error configuring audit log: %v", err)
+	}
+	events, err := audit.Read(time.Now().Add(-since))
+	if err != nil {
+		log.Fatalf("Error reading audit log: %v", err)
+	}
+
+	// Keep only the events matching the filters
+	var filtered []v1alpha1.Decision
+	for _, event := range events {
+		switch {
+		case autoscalerName != "" && event.Autoscaler != autoscalerName:
+		case direction == directionUp && event.Action != v1alpha1.DecisionScaleUp:
+		case direction == directionDown && event.Action != v1alpha1.DecisionScaleDown:
+		case failedOnly && event.Outcome != v1alpha1.OutcomeFailed:
+		case !all && event.Action == v1alpha1.DecisionNone:
+		default:
+			filtered = append(filtered, event)
+		}
+	}
+
+	if output == outputJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(filtered)
+		if err != nil {
+			log.Fatalf("Error encoding events: %v", err)
+		}
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TIME\tAUTOSCALER\tACTION\tTRIGGER\tOUTCOME\tMIG\tSIZE\tINSTANCE\tDURATION\tDETAILS")
+	for _, event := range filtered {
+		details := event.Reason
+		if event.Error != "" {
+			details = event.Error
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%d -> %d\t%s\t%s\t%s\n",
+			event.Time.UTC().Format(time.RFC3339), event.Autoscaler, event.Action, event.Trigger, event.Outcome,
+			event.MIG, event.PreviousSize, event.Size, event.Instance,
+			(time.Duration(event.DurationMs) * time.Millisecond).String(), details)
+	}
+	err = writer.Flush()
+	if err != nil {
+		log.Fatalf("Error printing events: %v", err)
+	}
+}
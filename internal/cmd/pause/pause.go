@@ -0,0 +1,99 @@
+package pause
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/config"
+	"custom-vm-autoscaler/internal/state"
+
+	"log"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	descriptionShort = `Pause the autoscaler`
+	descriptionLong  = `
+	Pause the scaling decisions of the autoscaler, writing the pause to the state store.
+	Metrics are still evaluated and reported while paused. When a TTL is given,
+	the autoscaler resumes automatically once it expires`
+)
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "pause",
+		DisableFlagsInUseLine: true,
+		Short:                 descriptionShort,
+		Long:                  strings.ReplaceAll(descriptionLong, "\t", ""),
+
+		Run: RunCommand,
+	}
+
+	cmd.Flags().String("config", "autoscaler.yaml", "Path to the YAML config file")
+	cmd.Flags().String("autoscaler", "", "Name of the autoscaler to pause. Every autoscaler is paused when empty")
+	cmd.Flags().Duration("ttl", 0, "Time after which the autoscaler resumes automatically. Paused until resumed when 0")
+	cmd.Flags().String("reason", "", "Reason of the pause, shown in the logs")
+
+	return cmd
+}
+
+func RunCommand(cmd *cobra.Command, args []string) {
+
+	// Check the flags for this command
+	configPath, err := cmd.Flags().GetString("config")
+	if err != nil {
+		log.Fatalf("Error getting configuration file path: %v", err)
+	}
+	autoscalerName, err := cmd.Flags().GetString("autoscaler")
+	if err != nil {
+		log.Fatalf("Error getting autoscaler name: %v", err)
+	}
+	ttl, err := cmd.Flags().GetDuration("ttl")
+	if err != nil {
+		log.Fatalf("Error getting TTL: %v", err)
+	}
+	reason, err := cmd.Flags().GetString("reason")
+	if err != nil {
+		log.Fatalf("Error getting reason: %v", err)
+	}
+
+	// Get and parse the config
+	configContent, err := config.ReadFile(configPath)
+	if err != nil {
+		log.Fatalf("Error parsing configuration file: %v", err)
+	}
+
+	// The pause reaches the running autoscalers through the state store
+	if configContent.State.Backend == "" {
+		log.Fatalf("A state backend must be configured to pause the autoscaler")
+	}
+	err = state.Setup(&configContent)
+	if err != nil {
+		log.Fatalf("Error configuring state store: %v", err)
+	}
+
+	autoscalers, err := config.GetAutoscalersByName(configContent, autoscalerName)
+	if err != nil {
+		log.Fatalf("Error getting autoscalers: %v", err)
+	}
+
+	pause := &v1alpha1.Pause{
+		Reason:   reason,
+		PausedAt: time.Now(),
+	}
+	if ttl > 0 {
+		pause.Until = pause.PausedAt.Add(ttl)
+	}
+
+	for _, autoscalerConfig := range autoscalers {
+		ctx := &v1alpha1.Context{
+			Config: &autoscalerConfig,
+		}
+		err = state.SetPause(ctx, pause)
+		if err != nil {
+			log.Fatalf("Error pausing autoscaler %s: %v", ctx.Config.Name, err)
+		}
+		log.Printf("Paused autoscaler %s", ctx.Config.Name)
+	}
+}
@@ -0,0 +1,221 @@
+package plan
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/config"
+	"custom-vm-autoscaler/internal/decision"
+	"custom-vm-autoscaler/internal/google"
+	"custom-vm-autoscaler/internal/maintenance"
+	"custom-vm-autoscaler/internal/prometheus"
+	"custom-vm-autoscaler/internal/state"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	descriptionShort = `Show the decision the autoscaler would take`
+	descriptionLong  = `
+	Perform one evaluation of the conditions and show the metric values, the limits
+	applied, and the decision the autoscaler would take. Nothing is modified in GCP
+	nor in Elasticsearch`
+)
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "plan",
+		DisableFlagsInUseLine: true,
+		Short:                 descriptionShort,
+		Long:                  strings.ReplaceAll(descriptionLong, "\t", ""),
+
+		Run: RunCommand,
+	}
+
+	cmd.Flags().String("config", "autoscaler.yaml", "Path to the YAML config file, or its remote location (gs://, s3://, https://)")
+	cmd.Flags().String("autoscaler", "", "Name of the autoscaler to plan. Every autoscaler is planned when empty")
+
+	return cmd
+}
+
+func RunCommand(cmd *cobra.Command, args []string) {
+
+	// Check the flags for this command
+	configPath, err := cmd.Flags().GetString("config")
+	if err != nil {
+		log.Fatalf("Error getting configuration file path: %v", err)
+	}
+	autoscalerName, err := cmd.Flags().GetString("autoscaler")
+	if err != nil {
+		log.Fatalf("Error getting autoscaler name: %v", err)
+	}
+
+	// Get and parse the config
+	configContent, err := config.ReadFile(configPath)
+	if err != nil {
+		log.Fatalf("Error parsing configuration file: %v", err)
+	}
+	autoscalers, err := config.GetAutoscalersByName(configContent, autoscalerName)
+	if err != nil {
+		log.Fatalf("Error getting autoscalers: %v", err)
+	}
+
+	// The state is only read, to show the pauses and cooldowns in progress
+	err = state.Setup(&configContent)
+	if err != nil {
+		log.Fatalf("Error configuring state store: %v", err)
+	}
+
+	failed := false
+	for i, autoscalerConfig := range autoscalers {
+		if i > 0 {
+			fmt.Println()
+		}
+		ctx := &v1alpha1.Context{Config: &autoscalerConfig}
+		err = state.Load(ctx)
+		if err != nil {
+			log.Printf("Error loading state of autoscaler %s: %v", ctx.Config.Name, err)
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		err = printPlan(writer, ctx, time.Now().UTC())
+		if err != nil {
+			fmt.Fprintf(writer, "Error:\t%v\n", err)
+			failed = true
+		}
+		err = writer.Flush()
+		if err != nil {
+			log.Fatalf("Error printing plan: %v", err)
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// printPlan evaluates the conditions of the autoscaler as its main loop does, printing every step and the decision
+// it would take. Only reads from Prometheus and GCP are performed
+func printPlan(writer *tabwriter.Writer, ctx *v1alpha1.Context, now time.Time) error {
+	fmt.Fprintf(writer, "Autoscaler:\t%s\n", ctx.Config.Name)
+
+	// Pauses and cooldowns delay the evaluation, but the plan shows what would be done once they end
+	if pause := ctx.State.Pause; pause != nil && (pause.Until.IsZero() || now.Before(pause.Until)) {
+		until := "resumed"
+		if !pause.Until.IsZero() {
+			until = pause.Until.Format(time.RFC3339)
+		}
+		fmt.Fprintf(writer, "Paused:\tuntil %s (reason: %q)\n", until, pause.Reason)
+	}
+	if ctx.State.CooldownUntil.After(now) {
+		fmt.Fprintf(writer, "Cooldown:\tuntil %s\n", ctx.State.CooldownUntil.Format(time.RFC3339))
+	}
+
+	// Schedule and limits applied
+	limits := decision.GetLimits(ctx.Config, now)
+	schedule := "default limits"
+	if limits.Window >= 0 {
+		window := ctx.Config.Autoscaler.AdvancedCustomScalingConfiguration[limits.Window]
+		hours := window.HoursUTC
+		if hours == "" {
+			hours = "all day"
+		}
+		schedule = fmt.Sprintf("advancedCustomScalingConfiguration[%d] (days %s, hours %s)", limits.Window, window.Days, hours)
+	}
+	fmt.Fprintf(writer, "Schedule:\t%s\n", schedule)
+	fmt.Fprintf(writer, "Limits:\tmin size %d, max size %d, scale up threshold %d, scale down threshold %d\n",
+		limits.MinSize, limits.MaxSize, limits.ScaleUpThreshold, limits.ScaleDownThreshold)
+
+	maintenanceWindow, err := maintenance.GetActiveWindow(ctx)
+	if err != nil {
+		return fmt.Errorf("error checking maintenance windows: %v", err)
+	}
+	if maintenanceWindow != nil {
+		fmt.Fprintf(writer, "Maintenance window:\tdays %s, hours %s, mode %s\n", maintenanceWindow.Days, maintenanceWindow.HoursUTC, maintenanceWindow.Mode)
+	} else {
+		fmt.Fprintf(writer, "Maintenance window:\tnone\n")
+	}
+
+	// Current size of the MIGs
+	migSizes, totalSize, _, _, err := google.GetMIGSizes(ctx)
+	if err != nil {
+		return err
+	}
+	migNames := make([]string, 0, len(migSizes))
+	for name := range migSizes {
+		migNames = append(migNames, name)
+	}
+	sort.Strings(migNames)
+	for _, name := range migNames {
+		fmt.Fprintf(writer, "MIG %s:\t%d nodes\n", name, migSizes[name])
+	}
+	fmt.Fprintf(writer, "Current size:\t%d nodes\n", totalSize)
+	if totalSize < limits.MinSize {
+		fmt.Fprintf(writer, "Minimum size:\tbelow the minimum size, the MIGs would be scaled up to %d nodes first\n", limits.MinSize)
+	}
+
+	// Conditions
+	up, upCondition, upSamples, err := prometheus.GetFirstConditionMet(decision.UpConditions(ctx.Config), ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(writer, "Up condition:\t%s\n", up.Name)
+	fmt.Fprintf(writer, "\tmet: %t, samples: %s\n", upCondition, prometheus.FormatSamples(upSamples))
+
+	var down decision.Condition
+	var downCondition bool
+	if !upCondition {
+		var downSamples []v1alpha1.MetricSample
+		down, downCondition, downSamples, err = prometheus.GetFirstConditionMet(decision.DownConditions(ctx.Config), ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(writer, "Down condition:\t%s\n", down.Name)
+		fmt.Fprintf(writer, "\tmet: %t, samples: %s\n", downCondition, prometheus.FormatSamples(downSamples))
+	}
+
+	// Decision
+	switch {
+	case maintenanceWindow != nil && maintenanceWindow.Mode == maintenance.ModeBlock:
+		fmt.Fprintf(writer, "Decision:\tnone, the maintenance window blocks the scaling actions\n")
+
+	case upCondition:
+		plan, err := google.PlanScaleUp(ctx, up.ObservedStep(totalSize, limits.MaxSize, prometheus.SampleValues(upSamples)))
+		if err != nil {
+			return err
+		}
+		if plan.Reason != "" {
+			fmt.Fprintf(writer, "Decision:\tnone, up condition met but %s\n", plan.Reason)
+			break
+		}
+		fmt.Fprintf(writer, "Decision:\t%s MIG %s from %d to %d nodes\n", v1alpha1.DecisionScaleUp, plan.MIG, plan.PreviousSize, plan.Size)
+
+	case downCondition && maintenanceWindow != nil:
+		fmt.Fprintf(writer, "Decision:\tnone, the maintenance window only allows scaling up\n")
+
+	case downCondition:
+		plan, err := google.PlanScaleDown(ctx)
+		if err != nil {
+			return err
+		}
+		if plan.Reason != "" {
+			fmt.Fprintf(writer, "Decision:\tnone, down condition met but %s\n", plan.Reason)
+			break
+		}
+		fmt.Fprintf(writer, "Decision:\t%s MIG %s from %d to %d nodes\n", v1alpha1.DecisionScaleDown, plan.MIG, plan.PreviousSize, plan.Size)
+		fmt.Fprintf(writer, "Instance to remove:\t%s (selected randomly)\n", plan.Instance)
+		if down.Step > 1 {
+			fmt.Fprintf(writer, "Step:\t%d nodes, removed one by one while the minimum size allows it\n", down.Step)
+		}
+
+	default:
+		fmt.Fprintf(writer, "Decision:\tnone, no condition met\n")
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package resume
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/config"
+	"custom-vm-autoscaler/internal/state"
+
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	descriptionShort = `Resume the autoscaler`
+	descriptionLong  = `
+	Resume the scaling decisions of an autoscaler paused before`
+)
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "resume",
+		DisableFlagsInUseLine: true,
+		Short:                 descriptionShort,
+		Long:                  strings.ReplaceAll(descriptionLong, "\t", ""),
+
+		Run: RunCommand,
+	}
+
+	cmd.Flags().String("config", "autoscaler.yaml", "Path to the YAML config file")
+	cmd.Flags().String("autoscaler", "", "Name of the autoscaler to resume. Every autoscaler is resumed when empty")
+
+	return cmd
+}
+
+func RunCommand(cmd *cobra.Command, args []string) {
+
+	// Check the flags for this command
+	configPath, err := cmd.Flags().GetString("config")
+	if err != nil {
+		log.Fatalf("Error getting configuration file path: %v", err)
+	}
+	autoscalerName, err := cmd.Flags().GetString("autoscaler")
+	if err != nil {
+		log.Fatalf("Error getting autoscaler name: %v", err)
+	}
+
+	// Get and parse the config
+	configContent, err := config.ReadFile(configPath)
+	if err != nil {
+		log.Fatalf("Error parsing configuration file: %v", err)
+	}
+
+	if configContent.State.Backend == "" {
+		log.Fatalf("A state backend must be configured to resume the autoscaler")
+	}
+	err = state.Setup(&configContent)
+	if err != nil {
+		log.Fatalf("Error configuring state store: %v", err)
+	}
+
+	autoscalers, err := config.GetAutoscalersByName(configContent, autoscalerName)
+	if err != nil {
+		log.Fatalf("Error getting autoscalers: %v", err)
+	}
+
+	for _, autoscalerConfig := range autoscalers {
+		ctx := &v1alpha1.Context{
+			Config: &autoscalerConfig,
+		}
+		err = state.SetPause(ctx, nil)
+		if err != nil {
+			log.Fatalf("Error resuming autoscaler %s: %v", ctx.Config.Name, err)
+		}
+		log.Printf("Resumed autoscaler %s", ctx.Config.Name)
+	}
+}
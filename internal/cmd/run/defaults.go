@@ -1,8 +0,0 @@
-package run
-
-const (
-	defaultElasticsearchInsecureSkipVerify = false
-	defaultDebugMode                       = false
-	defaultElasticsearchDrainTimeoutSec    = 600
-	defaultScaleUpThreshold                = 1
-)
@@ -1,15 +1,33 @@
 package run
 
 import (
+	"context"
 	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/admin"
+	"custom-vm-autoscaler/internal/approval"
+	"custom-vm-autoscaler/internal/audit"
+	"custom-vm-autoscaler/internal/cmd/validate"
 	"custom-vm-autoscaler/internal/config"
-	"custom-vm-autoscaler/internal/google"
-	"custom-vm-autoscaler/internal/prometheus"
-	"custom-vm-autoscaler/internal/slack"
-	"fmt"
+	"custom-vm-autoscaler/internal/controlplane"
+	"custom-vm-autoscaler/internal/elasticsearch"
+	"custom-vm-autoscaler/internal/fake"
+	"custom-vm-autoscaler/internal/health"
+	"custom-vm-autoscaler/internal/leader"
+	"custom-vm-autoscaler/internal/logging"
+	"custom-vm-autoscaler/internal/metrics"
+	"custom-vm-autoscaler/internal/notifier"
+	"custom-vm-autoscaler/internal/trigger"
+	"custom-vm-autoscaler/internal/version"
+	"custom-vm-autoscaler/pkg/autoscaler"
 
+	"errors"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -18,7 +36,12 @@ import (
 const (
 	descriptionShort = `Run the autoscaler`
 	descriptionLong  = `
-	Run the autoscaler with custom config file`
+	Run the autoscaler with custom config file. With --once, a single evaluation is performed
+	and the process exits, so the autoscaler can be driven by a scheduler like cron or Cloud Scheduler`
+
+	// Exit codes of the executions with --once. Errors configuring the autoscalers exit with 1
+	exitCodeFailed      = 2
+	exitCodeInterrupted = 3
 )
 
 func NewCommand() *cobra.Command {
@@ -31,7 +54,13 @@ func NewCommand() *cobra.Command {
 		Run: RunCommand,
 	}
 
-	cmd.Flags().String("config", "autoscaler.yaml", "Path to the YAML config file")
+	cmd.Flags().String("config", "autoscaler.yaml", "Path to the YAML config file, or its remote location (gs://, s3://, https://)")
+	cmd.Flags().Duration("config-refresh-interval", time.Minute, "Interval to check if the remote config changed")
+	cmd.Flags().Bool("once", false, "Perform a single evaluation and scaling action, and exit")
+	cmd.Flags().String("backend", fake.BackendGCP, "Services the autoscaler talks to: gcp, or fake to use in-memory replacements of GCP, Elasticsearch and Prometheus")
+	cmd.Flags().Float64("chaos-elasticsearch-errors", 0, "Rate of the requests to the fake Elasticsearch failing, from 0 to 1")
+	cmd.Flags().Float64("chaos-gcp-errors", 0, "Rate of the calls modifying the fake MIGs failing, from 0 to 1")
+	cmd.Flags().Float64("chaos-drain-timeouts", 0, "Rate of the drains in the fake Elasticsearch timing out, from 0 to 1")
 
 	return cmd
 }
@@ -43,11 +72,43 @@ func RunCommand(cmd *cobra.Command, args []string) {
 	if err != nil {
 		log.Fatalf("Error getting configuration file path: %v", err)
 	}
-
-	// Configure application's context
-	ctx := v1alpha1.Context{
-		Config: &v1alpha1.ConfigSpec{},
+	refreshInterval, err := cmd.Flags().GetDuration("config-refresh-interval")
+	if err != nil {
+		log.Fatalf("Error getting config refresh interval: %v", err)
+	}
+	once, err := cmd.Flags().GetBool("once")
+	if err != nil {
+		log.Fatalf("Error getting once: %v", err)
+	}
+	backend, err := cmd.Flags().GetString("backend")
+	if err != nil {
+		log.Fatalf("Error getting backend: %v", err)
+	}
+	if backend != fake.BackendGCP && backend != fake.BackendFake {
+		log.Fatalf("Invalid backend %s, expected %s or %s", backend, fake.BackendGCP, fake.BackendFake)
 	}
+	var chaos fake.Chaos
+	chaos.ElasticsearchErrors, err = cmd.Flags().GetFloat64("chaos-elasticsearch-errors")
+	if err != nil {
+		log.Fatalf("Error getting chaos elasticsearch errors: %v", err)
+	}
+	chaos.GCPErrors, err = cmd.Flags().GetFloat64("chaos-gcp-errors")
+	if err != nil {
+		log.Fatalf("Error getting chaos GCP errors: %v", err)
+	}
+	chaos.DrainTimeouts, err = cmd.Flags().GetFloat64("chaos-drain-timeouts")
+	if err != nil {
+		log.Fatalf("Error getting chaos drain timeouts: %v", err)
+	}
+	err = chaos.Validate()
+	if err != nil {
+		log.Fatalf("Error configuring chaos: %v", err)
+	}
+	if chaos != (fake.Chaos{}) && backend != fake.BackendFake {
+		log.Fatalf("Chaos can only be injected with the %s backend", fake.BackendFake)
+	}
+
+	log.Printf("Starting custom-vm-autoscaler %s", version.String())
 
 	// Get and parse the config
 	configContent, err := config.ReadFile(configPath)
@@ -55,130 +116,234 @@ func RunCommand(cmd *cobra.Command, args []string) {
 		log.Fatalf("Error parsing configuration file: %v", err)
 	}
 
-	// Set the configuration inside the global context
-	ctx.Config = &configContent
+	// Export the logs to the sinks defined in the config, in addition to stderr
+	err = logging.Setup(&configContent)
+	if err != nil {
+		log.Fatalf("Error configuring log sinks: %v", err)
+	}
+	defer logging.Close()
 
-	// Load default values
-	if !ctx.Config.Target.Elasticsearch.SSLInsecureSkipVerify {
-		ctx.Config.Target.Elasticsearch.SSLInsecureSkipVerify = defaultElasticsearchInsecureSkipVerify
+	// Replace GCP, Elasticsearch and Prometheus with in-memory services, to exercise the autoscaler without them
+	var fakeBackend *fake.Backend
+	if backend == fake.BackendFake {
+		fakeBackend = fake.Start()
+		defer fakeBackend.Close()
+		fakeBackend.Apply(&configContent)
+		fakeBackend.SetChaos(chaos)
+		log.Printf("Using the fake backend. No condition is met until scaling actions are requested")
+		if chaos != (fake.Chaos{}) {
+			log.Printf("Injecting chaos: %.0f%% of Elasticsearch errors, %.0f%% of GCP errors, %.0f%% of drain timeouts",
+				chaos.ElasticsearchErrors*100, chaos.GCPErrors*100, chaos.DrainTimeouts*100)
+		}
 	}
-	if ctx.Config.Target.Elasticsearch.DrainTimeoutSec == 0 {
-		ctx.Config.Target.Elasticsearch.DrainTimeoutSec = defaultElasticsearchDrainTimeoutSec
+
+	// Start the leader election, so only the leader replica acts. Single executions are serialized by their scheduler
+	var elector *leader.Elector
+	if configContent.LeaderElection.Enabled && !once {
+		elector, err = leader.NewElector(&configContent)
+		if err != nil {
+			log.Fatalf("Error configuring leader election: %v", err)
+		}
+		go elector.Run()
 	}
-	if !ctx.Config.Autoscaler.DebugMode {
-		ctx.Config.Autoscaler.DebugMode = defaultDebugMode
+
+	// Build every autoscaler defined in the config, restoring their state
+	runners, err := autoscaler.New(configContent, autoscaler.WithElector(elector))
+	if err != nil {
+		log.Fatalf("Error configuring autoscalers: %v", err)
 	}
-	if ctx.Config.Autoscaler.ScaleUpThreshold == 0 {
-		ctx.Config.Autoscaler.ScaleUpThreshold = defaultScaleUpThreshold
+	if !once {
+		go audit.RunRetention()
 	}
 
-	// Main loop to monitor scaling conditions and manage the MIG
-	for {
+	var autoscalers []*v1alpha1.Context
+	approvalsRequired := false
+	for _, runner := range runners {
+		autoscalers = append(autoscalers, runner.Context())
+		approvalsRequired = approvalsRequired || runner.Context().Config.Autoscaler.ScaleDownApproval.Enabled ||
+			runner.Context().Config.Target.Elasticsearch.OnDrainTimeout == elasticsearch.DrainTimeoutPolicyApproval
+	}
 
-		// Check if the MIG is at its minimum size at least. If not, scale it up to minSize
-		err := google.CheckMIGMinimumSize(&ctx)
+	// Start the admin API to inspect and control the autoscalers at runtime
+	if configContent.Admin.Enabled && !once {
+		adminServer, err := admin.NewServer(&configContent, autoscalers, elector)
 		if err != nil {
-			log.Fatalf("Error checking minimum size for MIG nodes: %v", err)
-			if ctx.Config.Notifications.Slack.WebhookURL != "" {
-				message := fmt.Sprintf("Error checking minimum size for MIG nodes: %v", err)
-				err = slack.NotifySlack(message, ctx.Config.Notifications.Slack.WebhookURL)
-				if err != nil {
-					log.Printf("Error sending Slack notification: %v", err)
-				}
-			}
+			log.Fatalf("Error configuring admin API: %v", err)
 		}
+		go func() {
+			log.Fatalf("Error serving admin API: %v", adminServer.Run())
+		}()
+	}
+
+	// Start the health endpoints used by the health checks of the platform
+	if configContent.Health.Enabled && !once {
+		healthServer := health.NewServer(&configContent, autoscalers)
+		go func() {
+			log.Fatalf("Error serving health endpoints: %v", healthServer.Run())
+		}()
+	}
 
-		// Fetch the scale up condition from Prometheus
-		upCondition, err := prometheus.GetPrometheusCondition(ctx.Config.Metrics.Prometheus.UpCondition, &ctx)
+	// Start the endpoints receiving the scaling actions requested by ChatOps bots and runbooks
+	if configContent.Triggers.Enabled && !once {
+		triggerServer, err := trigger.NewServer(&configContent, autoscalers, elector)
 		if err != nil {
-			log.Printf("Error querying Prometheus: %v", err)
-			if ctx.Config.Notifications.Slack.WebhookURL != "" {
-				message := fmt.Sprintf("Error quering prometheus: %v", err)
-				err = slack.NotifySlack(message, ctx.Config.Notifications.Slack.WebhookURL)
-				if err != nil {
-					log.Printf("Error sending Slack notification: %v", err)
-				}
-			}
-			time.Sleep(time.Duration(ctx.Config.Autoscaler.RetryIntervalSec) * time.Second)
-			continue
+			log.Fatalf("Error configuring trigger endpoints: %v", err)
 		}
+		go func() {
+			log.Fatalf("Error serving trigger endpoints: %v", triggerServer.Run())
+		}()
+	}
 
-		// If the up condition is met, add a node to the MIG
-		if upCondition {
-			log.Printf("Up condition %s met: Trying to create a new node!", ctx.Config.Metrics.Prometheus.UpCondition)
-			currentSize, maxSize, err := google.AddNodeToMIG(&ctx)
-			if err != nil {
-				log.Printf("Error adding node to MIG: %v", err)
-				if ctx.Config.Notifications.Slack.WebhookURL != "" {
-					message := fmt.Sprintf("Error adding node to MIG: %v", err)
-					err = slack.NotifySlack(message, ctx.Config.Notifications.Slack.WebhookURL)
-					if err != nil {
-						log.Printf("Error sending Slack notification: %v", err)
-					}
-				}
-				time.Sleep(time.Duration(ctx.Config.Autoscaler.RetryIntervalSec) * time.Second)
-				continue
-			}
-			// Notify via Slack that a node has been added
-			if ctx.Config.Notifications.Slack.WebhookURL != "" && currentSize != -1 {
-				message := fmt.Sprintf("Added new node to MIG %s. Current size is %d nodes and the maximum nodes to create are %d", ctx.Config.Infrastructure.GCP.MIGName, currentSize, maxSize)
-				err = slack.NotifySlack(message, ctx.Config.Notifications.Slack.WebhookURL)
-				if err != nil {
-					log.Printf("Error sending Slack notification: %v", err)
-				}
-			}
-			// Sleep for the default cooldown period before checking the conditions again
-			time.Sleep(time.Duration(ctx.Config.Autoscaler.DefaultCooldownPeriodSec) * time.Second)
-			continue
+	// Start the gRPC API consumed programmatically by the orchestration tools
+	if configContent.ControlPlane.Enabled && !once {
+		controlPlaneServer, err := controlplane.NewServer(&configContent, autoscalers, elector)
+		if err != nil {
+			log.Fatalf("Error configuring control plane API: %v", err)
 		}
+		go func() {
+			log.Fatalf("Error serving control plane API: %v", controlPlaneServer.Run())
+		}()
+	}
 
-		// Fetch the scale down conditions from Prometheus
-		downCondition, err := prometheus.GetPrometheusCondition(ctx.Config.Metrics.Prometheus.DownCondition, &ctx)
+	// Start the endpoint receiving the answers to the scale down approvals from Slack
+	if approvalsRequired {
+		approvalServer, err := approval.NewServer(&configContent)
 		if err != nil {
-			log.Printf("Error querying Prometheus: %v", err)
-			if ctx.Config.Notifications.Slack.WebhookURL != "" {
-				message := fmt.Sprintf("Error quering prometheus: %v", err)
-				err = slack.NotifySlack(message, ctx.Config.Notifications.Slack.WebhookURL)
-				if err != nil {
-					log.Printf("Error sending Slack notification: %v", err)
-				}
-			}
-			time.Sleep(time.Duration(ctx.Config.Autoscaler.RetryIntervalSec) * time.Second)
-			continue
+			log.Fatalf("Error configuring approvals server: %v", err)
 		}
+		go func() {
+			log.Fatalf("Error serving approvals server: %v", approvalServer.Run())
+		}()
+	}
 
-		// If the down condition is met, remove a node from the MIG
-		if downCondition {
-			log.Printf("Down condition %s met. Trying to remove one node!", ctx.Config.Metrics.Prometheus.DownCondition)
-			currentSize, minSize, nodeRemoved, err := google.RemoveNodeFromMIG(&ctx)
-			if err != nil {
-				log.Printf("Error draining node from MIG: %v", err)
-				if ctx.Config.Notifications.Slack.WebhookURL != "" {
-					message := fmt.Sprintf("Error draining node from MIG: %v", err)
-					err = slack.NotifySlack(message, ctx.Config.Notifications.Slack.WebhookURL)
-					if err != nil {
-						log.Printf("Error sending Slack notification: %v", err)
-					}
-				}
-				time.Sleep(time.Duration(ctx.Config.Autoscaler.RetryIntervalSec) * time.Second)
-				continue
+	// Apply the changes of the remote config to the running autoscalers, keeping the last known good config when
+	// the changed one can not be parsed or is invalid. Both callbacks are called from the same goroutine
+	if config.IsRemote(configPath) && !once {
+		lastGood := time.Now()
+		go config.Watch(configPath, refreshInterval, func(newConfig v1alpha1.ConfigSpec) {
+			if errs := validate.Validate(newConfig); len(errs) > 0 {
+				rejectConfig(runners, lastGood, joinErrors(errs))
+				return
+			}
+			if fakeBackend != nil {
+				fakeBackend.Apply(&newConfig)
 			}
-			// Notify via Slack that a node has been removed
-			if ctx.Config.Notifications.Slack.WebhookURL != "" && nodeRemoved != "" {
-				message := fmt.Sprintf("Removed node %s from MIG %s. Current size is %d nodes and the minimum nodes to exist are %d", nodeRemoved, ctx.Config.Infrastructure.GCP.MIGName, currentSize, minSize)
-				err = slack.NotifySlack(message, ctx.Config.Notifications.Slack.WebhookURL)
-				if err != nil {
-					log.Printf("Error sending Slack notification: %v", err)
-				}
+			reloadAutoscalers(runners, newConfig)
+			lastGood = time.Now()
+		}, func(err error) {
+			rejectConfig(runners, lastGood, err)
+		})
+	}
+
+	// Run every autoscaler concurrently, until the process is asked to stop
+	ctxRun, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if once {
+		exitCode := runOnce(ctxRun, runners)
+		stop()
+
+		// Push the metrics, as the process exits before being scraped
+		if configContent.Pushgateway.URL != "" {
+			err = metrics.Push(&configContent, autoscalers)
+			if err != nil {
+				log.Printf("Error pushing metrics: %v", err)
 			}
-			// Sleep for the scaledown cooldown period before checking the conditions again
-			time.Sleep(time.Duration(ctx.Config.Autoscaler.ScaleDownCooldownPeriodSec) * time.Second)
+		}
+		logging.Close()
+		os.Exit(exitCode)
+	}
+
+	var wg sync.WaitGroup
+	for _, runner := range runners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runner.Run(ctxRun)
+		}()
+	}
+	wg.Wait()
+	log.Printf("Every autoscaler stopped")
+}
+
+// runOnce performs a single step of every autoscaler concurrently, returning the exit code of the process:
+// 0 when every step succeeded or was skipped by a cooldown, exitCodeFailed when any of them failed,
+// and exitCodeInterrupted when the process was asked to stop meanwhile
+func runOnce(ctxRun context.Context, runners []*autoscaler.Autoscaler) int {
+	errs := make([]error, len(runners))
+	var wg sync.WaitGroup
+	for i, runner := range runners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = runner.RunOnce(ctxRun)
+		}()
+	}
+	wg.Wait()
+
+	exitCode := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			log.Printf("Autoscaler %s evaluated", runners[i].Name())
+		case errors.Is(err, autoscaler.ErrCooldown):
+			log.Printf("Autoscaler %s skipped: %v", runners[i].Name(), err)
+		default:
+			log.Printf("Autoscaler %s failed: %v", runners[i].Name(), err)
+			exitCode = exitCodeFailed
+		}
+	}
+	if ctxRun.Err() != nil {
+		exitCode = exitCodeInterrupted
+	}
+	return exitCode
+}
+
+// reloadAutoscalers sends the changed config to every running autoscaler with the same name.
+// Adding or removing autoscalers, and the sections only read from the root of the config, require a restart
+func reloadAutoscalers(runners []*autoscaler.Autoscaler, newConfig v1alpha1.ConfigSpec) {
+	newAutoscalers := map[string]v1alpha1.ConfigSpec{}
+	for _, autoscalerConfig := range config.GetAutoscalers(newConfig) {
+		newAutoscalers[autoscalerConfig.Name] = autoscalerConfig
+	}
+
+	for _, runner := range runners {
+		autoscalerConfig, ok := newAutoscalers[runner.Name()]
+		if !ok {
+			log.Printf("Autoscaler %s removed from the config, restart to stop it", runner.Name())
 			continue
 		}
+		delete(newAutoscalers, runner.Name())
+
+		err := runner.Reload(autoscalerConfig)
+		if err != nil {
+			log.Printf("Error reloading autoscaler %s, keeping the previous config: %v", runner.Name(), err)
+			notifier.Alert(runner.Context(), notifier.SeverityError, notifier.AlertInvalidConfig,
+				fmt.Sprintf("Changed config is invalid, keeping the last known good one: %v", err))
+			continue
+		}
+		notifier.Resolve(runner.Context(), notifier.AlertInvalidConfig, "Changed config is valid and applied")
+	}
+
+	for name := range newAutoscalers {
+		log.Printf("Autoscaler %s added to the config, restart to start it", name)
+	}
+}
+
+// rejectConfig keeps the last known good config of every autoscaler when the changed one is invalid, alerting about it
+func rejectConfig(runners []*autoscaler.Autoscaler, lastGood time.Time, err error) {
+	message := fmt.Sprintf("Changed config is invalid, keeping the last known good one, loaded at %s: %v", lastGood.Format(time.RFC3339), err)
+	log.Print(message)
+	for _, runner := range runners {
+		notifier.Alert(runner.Context(), notifier.SeverityError, notifier.AlertInvalidConfig, message)
+	}
+}
 
-		// No scaling conditions met, so no changes to the MIG
-		log.Printf("No condition %s or %s met, keeping the same number of nodes!", ctx.Config.Metrics.Prometheus.UpCondition, ctx.Config.Metrics.Prometheus.DownCondition)
-		// Sleep for the default cooldown period before checking the conditions again
-		time.Sleep(time.Duration(ctx.Config.Autoscaler.DefaultCooldownPeriodSec) * time.Second)
+// joinErrors returns the errors of the validation in a single line
+func joinErrors(errs []error) error {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
 	}
+	return errors.New(strings.Join(messages, "; "))
 }
@@ -0,0 +1,135 @@
+package scaletomax
+
+import (
+	"custom-vm-autoscaler/internal/config"
+
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	descriptionShort = `Scale the autoscaler up to its maximum size right away`
+	descriptionLong  = `
+	Emergency action for incident response. Requests the running autoscaler, through its admin API,
+	to add every node missing up to the maximum size of the limits applied, ignoring the conditions
+	and cooldowns. The reason of the incident is required, and recorded in the audit log`
+)
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "scale-to-max",
+		DisableFlagsInUseLine: true,
+		Short:                 descriptionShort,
+		Long:                  strings.ReplaceAll(descriptionLong, "\t", ""),
+
+		Run: RunCommand,
+	}
+
+	cmd.Flags().String("config", "autoscaler.yaml", "Path to the YAML config file, or its remote location (gs://, s3://, https://)")
+	cmd.Flags().String("autoscaler", "", "Name of the autoscaler to scale. Required when several autoscalers are running")
+	cmd.Flags().String("reason", "", "Reason of the emergency scale up, recorded in the audit log")
+	cmd.Flags().String("url", "", "URL of the admin API. Derived from the admin address of the config when empty")
+
+	return cmd
+}
+
+func RunCommand(cmd *cobra.Command, args []string) {
+
+	// Check the flags for this command
+	configPath, err := cmd.Flags().GetString("config")
+	if err != nil {
+		log.Fatalf("Error getting configuration file path: %v", err)
+	}
+	autoscalerName, err := cmd.Flags().GetString("autoscaler")
+	if err != nil {
+		log.Fatalf("Error getting autoscaler name: %v", err)
+	}
+	reason, err := cmd.Flags().GetString("reason")
+	if err != nil {
+		log.Fatalf("Error getting reason: %v", err)
+	}
+	adminURL, err := cmd.Flags().GetString("url")
+	if err != nil {
+		log.Fatalf("Error getting admin API URL: %v", err)
+	}
+	if strings.TrimSpace(reason) == "" {
+		log.Fatalf("The reason of the emergency scale up is required")
+	}
+
+	// Get and parse the config
+	configContent, err := config.ReadFile(configPath)
+	if err != nil {
+		log.Fatalf("Error parsing configuration file: %v", err)
+	}
+
+	// The action reaches the leader of the running autoscalers through the admin API
+	if !configContent.Admin.Enabled {
+		log.Fatalf("The admin API must be enabled to scale the autoscaler to its maximum size")
+	}
+	if adminURL == "" {
+		adminURL = defaultAdminURL(configContent.Admin.Address)
+	}
+
+	endpoint, err := url.JoinPath(adminURL, "scale-to-max")
+	if err != nil {
+		log.Fatalf("Error building admin API URL: %v", err)
+	}
+	if autoscalerName != "" {
+		endpoint += "?autoscaler=" + url.QueryEscape(autoscalerName)
+	}
+
+	err = requestScaleToMax(endpoint, configContent.Admin.Token, reason)
+	if err != nil {
+		log.Fatalf("Error requesting the emergency scale up: %v", err)
+	}
+	log.Printf("Emergency scale up to the maximum size requested. Check the status or the history to know the result")
+}
+
+// defaultAdminURL returns the URL of the admin API listening on the given address, on the local host when it has none
+func defaultAdminURL(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "http://" + address
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// requestScaleToMax sends the emergency scale up to the admin API, which answers once it is enqueued
+func requestScaleToMax(endpoint, token, reason string) error {
+	body, err := json.Marshal(map[string]string{"reason": reason})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusAccepted {
+		message, _ := io.ReadAll(response.Body)
+		return fmt.Errorf("admin API answered %s: %s", response.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
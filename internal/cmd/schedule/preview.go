@@ -0,0 +1,136 @@
+package schedule
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/config"
+	"custom-vm-autoscaler/internal/decision"
+	"custom-vm-autoscaler/internal/maintenance"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	previewDescriptionShort = `Print the timeline of the scaling limits`
+	previewDescriptionLong  = `
+	Print the timeline of the minimum size, maximum size and scale up threshold applied
+	by the advanced custom scaling configuration, to verify the windows before deploying them`
+)
+
+func newPreviewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "preview",
+		DisableFlagsInUseLine: true,
+		Short:                 previewDescriptionShort,
+		Long:                  strings.ReplaceAll(previewDescriptionLong, "\t", ""),
+
+		Run: RunPreviewCommand,
+	}
+
+	cmd.Flags().String("config", "autoscaler.yaml", "Path to the YAML config file, or its remote location (gs://, s3://, https://)")
+	cmd.Flags().String("autoscaler", "", "Name of the autoscaler to preview. Every autoscaler is previewed when empty")
+	cmd.Flags().Int("days", 7, "Number of days to preview")
+	cmd.Flags().String("start", "", "Start of the preview in RFC3339 format. Now when empty")
+
+	return cmd
+}
+
+func RunPreviewCommand(cmd *cobra.Command, args []string) {
+
+	// Check the flags for this command
+	configPath, err := cmd.Flags().GetString("config")
+	if err != nil {
+		log.Fatalf("Error getting configuration file path: %v", err)
+	}
+	autoscalerName, err := cmd.Flags().GetString("autoscaler")
+	if err != nil {
+		log.Fatalf("Error getting autoscaler name: %v", err)
+	}
+	days, err := cmd.Flags().GetInt("days")
+	if err != nil {
+		log.Fatalf("Error getting days: %v", err)
+	}
+	if days <= 0 {
+		log.Fatalf("Invalid days %d, expected a positive number", days)
+	}
+	startFlag, err := cmd.Flags().GetString("start")
+	if err != nil {
+		log.Fatalf("Error getting start: %v", err)
+	}
+
+	start := time.Now().UTC().Truncate(time.Second)
+	if startFlag != "" {
+		start, err = time.Parse(time.RFC3339, startFlag)
+		if err != nil {
+			log.Fatalf("Error parsing start: %v", err)
+		}
+		start = start.UTC()
+	}
+	end := start.AddDate(0, 0, days)
+
+	// Get and parse the config
+	configContent, err := config.ReadFile(configPath)
+	if err != nil {
+		log.Fatalf("Error parsing configuration file: %v", err)
+	}
+	autoscalers, err := config.GetAutoscalersByName(configContent, autoscalerName)
+	if err != nil {
+		log.Fatalf("Error getting autoscalers: %v", err)
+	}
+
+	for i, autoscalerConfig := range autoscalers {
+		if i > 0 {
+			fmt.Println()
+		}
+		ctx := &v1alpha1.Context{Config: &autoscalerConfig}
+
+		// Invalid windows would stop the computation of the limits
+		for j, scalingConfig := range ctx.Config.Autoscaler.AdvancedCustomScalingConfiguration {
+			err = maintenance.ValidateWindow(scalingConfig.Days, scalingConfig.HoursUTC)
+			if err != nil {
+				log.Fatalf("Invalid advancedCustomScalingConfiguration[%d] of autoscaler %s: %v", j, ctx.Config.Name, err)
+			}
+		}
+
+		err = printTimeline(ctx, start, end)
+		if err != nil {
+			log.Fatalf("Error printing schedule: %v", err)
+		}
+	}
+}
+
+// printTimeline prints the periods between start and end where the same scaling limits are applied.
+// Limits are computed every second, as the windows are defined with that resolution
+func printTimeline(ctx *v1alpha1.Context, start, end time.Time) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "Autoscaler: %s\n", ctx.Config.Name)
+	fmt.Fprintln(writer, "FROM\tTO\tDAY\tMIN SIZE\tMAX SIZE\tSCALE UP THRESHOLD\tWINDOW")
+
+	printPeriod := func(from, to time.Time, limits decision.Limits) {
+		window := "default"
+		if limits.Window >= 0 {
+			window = fmt.Sprintf("advancedCustomScalingConfiguration[%d]", limits.Window)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%d\t%d\t%d\t%s\n", from.Format(time.RFC3339), to.Format(time.RFC3339),
+			from.Weekday(), limits.MinSize, limits.MaxSize, limits.ScaleUpThreshold, window)
+	}
+
+	periodStart := start
+	periodLimits := decision.GetLimits(ctx.Config, start)
+	for t := start.Add(time.Second); t.Before(end); t = t.Add(time.Second) {
+		limits := decision.GetLimits(ctx.Config, t)
+		if limits == periodLimits {
+			continue
+		}
+		printPeriod(periodStart, t, periodLimits)
+		periodStart, periodLimits = t, limits
+	}
+	printPeriod(periodStart, end, periodLimits)
+
+	return writer.Flush()
+}
@@ -0,0 +1,28 @@
+package schedule
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	descriptionShort = `Inspect the scaling schedule`
+	descriptionLong  = `
+	Inspect the scaling limits applied over time by the advanced custom scaling configuration`
+)
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "schedule",
+		DisableFlagsInUseLine: true,
+		Short:                 descriptionShort,
+		Long:                  strings.ReplaceAll(descriptionLong, "\t", ""),
+	}
+
+	cmd.AddCommand(
+		newPreviewCommand(),
+	)
+
+	return cmd
+}
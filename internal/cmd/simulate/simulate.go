@@ -0,0 +1,217 @@
+package simulate
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/config"
+	"custom-vm-autoscaler/internal/decision"
+	"custom-vm-autoscaler/internal/prometheus"
+	"custom-vm-autoscaler/internal/simulate"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	descriptionShort = `Replay historical metrics through the autoscaler`
+	descriptionLong  = `
+	Replay the scaling conditions over a past period, from Prometheus or a CSV file,
+	and report the scaling actions, node-hours and flapping the autoscaler would have
+	produced, to tune the thresholds offline. Nothing is modified in GCP`
+)
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "simulate",
+		DisableFlagsInUseLine: true,
+		Short:                 descriptionShort,
+		Long:                  strings.ReplaceAll(descriptionLong, "\t", ""),
+
+		Run: RunCommand,
+	}
+
+	cmd.Flags().String("config", "autoscaler.yaml", "Path to the YAML config file, or its remote location (gs://, s3://, https://)")
+	cmd.Flags().String("autoscaler", "", "Name of the autoscaler to simulate. Every autoscaler is simulated when empty")
+	cmd.Flags().String("csv", "", "CSV file with the columns time, up and down to replay, instead of querying Prometheus")
+	cmd.Flags().Duration("since", 24*time.Hour, "Replay the conditions during this time before the end")
+	cmd.Flags().String("end", "", "End of the replayed period in RFC3339 format. Now when empty")
+	cmd.Flags().Duration("step", time.Minute, "Resolution of the Prometheus range queries")
+	cmd.Flags().Int32("initial-size", 0, "Size of the MIGs at the start of the period. The minimum size when 0")
+	cmd.Flags().Duration("flap-window", 30*time.Minute, "Scaling actions reverting the previous one within this time are reported as flapping")
+
+	return cmd
+}
+
+func RunCommand(cmd *cobra.Command, args []string) {
+
+	// Check the flags for this command
+	configPath, err := cmd.Flags().GetString("config")
+	if err != nil {
+		log.Fatalf("Error getting configuration file path: %v", err)
+	}
+	autoscalerName, err := cmd.Flags().GetString("autoscaler")
+	if err != nil {
+		log.Fatalf("Error getting autoscaler name: %v", err)
+	}
+	csvPath, err := cmd.Flags().GetString("csv")
+	if err != nil {
+		log.Fatalf("Error getting CSV path: %v", err)
+	}
+	since, err := cmd.Flags().GetDuration("since")
+	if err != nil {
+		log.Fatalf("Error getting since: %v", err)
+	}
+	endFlag, err := cmd.Flags().GetString("end")
+	if err != nil {
+		log.Fatalf("Error getting end: %v", err)
+	}
+	step, err := cmd.Flags().GetDuration("step")
+	if err != nil {
+		log.Fatalf("Error getting step: %v", err)
+	}
+	initialSize, err := cmd.Flags().GetInt32("initial-size")
+	if err != nil {
+		log.Fatalf("Error getting initial size: %v", err)
+	}
+	flapWindow, err := cmd.Flags().GetDuration("flap-window")
+	if err != nil {
+		log.Fatalf("Error getting flap window: %v", err)
+	}
+
+	end := time.Now().UTC()
+	if endFlag != "" {
+		end, err = time.Parse(time.RFC3339, endFlag)
+		if err != nil {
+			log.Fatalf("Error parsing end: %v", err)
+		}
+	}
+	start := end.Add(-since)
+
+	// Get and parse the config
+	configContent, err := config.ReadFile(configPath)
+	if err != nil {
+		log.Fatalf("Error parsing configuration file: %v", err)
+	}
+	autoscalers, err := config.GetAutoscalersByName(configContent, autoscalerName)
+	if err != nil {
+		log.Fatalf("Error getting autoscalers: %v", err)
+	}
+
+	// The same CSV file is replayed for every autoscaler
+	var csvEvaluations []simulate.Evaluation
+	if csvPath != "" {
+		file, err := os.Open(csvPath)
+		if err != nil {
+			log.Fatalf("Error opening CSV file: %v", err)
+		}
+		csvEvaluations, err = simulate.ReadCSV(file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("Error reading CSV file: %v", err)
+		}
+	}
+
+	for i, autoscalerConfig := range autoscalers {
+		if i > 0 {
+			fmt.Println()
+		}
+		ctx := &v1alpha1.Context{Config: &autoscalerConfig}
+
+		evaluations := csvEvaluations
+		if csvPath == "" {
+			evaluations, err = getEvaluations(ctx, start, end, step)
+			if err != nil {
+				log.Fatalf("Error getting conditions of autoscaler %s: %v", ctx.Config.Name, err)
+			}
+		}
+
+		size := initialSize
+		if size == 0 && len(evaluations) > 0 {
+			size = decision.GetLimits(ctx.Config, evaluations[0].Time).MinSize
+		}
+		result, err := simulate.Run(ctx, evaluations, size, flapWindow)
+		if err != nil {
+			log.Fatalf("Error simulating autoscaler %s: %v", ctx.Config.Name, err)
+		}
+
+		err = printResult(ctx, result)
+		if err != nil {
+			log.Fatalf("Error printing simulation: %v", err)
+		}
+	}
+}
+
+// getEvaluations evaluates the up and down conditions of the autoscaler at every step of the period. At every step,
+// the first condition met by priority of each direction is taken
+func getEvaluations(ctx *v1alpha1.Context, start, end time.Time, step time.Duration) ([]simulate.Evaluation, error) {
+	var evaluations []simulate.Evaluation
+	for t := start; !t.After(end); t = t.Add(step) {
+		evaluations = append(evaluations, simulate.Evaluation{Time: t})
+	}
+
+	// Every query shares the same steps. Conditions are evaluated from the lowest priority, so the highest one prevails
+	for _, direction := range []struct {
+		conditions []decision.Condition
+		up         bool
+	}{
+		{conditions: decision.UpConditions(ctx.Config), up: true},
+		{conditions: decision.DownConditions(ctx.Config), up: false},
+	} {
+		for _, condition := range slices.Backward(direction.conditions) {
+			samples, err := prometheus.GetPrometheusConditionRange(condition.Query, ctx, start, end, step)
+			if err != nil {
+				return nil, fmt.Errorf("condition %s: %w", condition.Name, err)
+			}
+			for i, sample := range samples {
+				if i >= len(evaluations) || len(sample.Values) == 0 {
+					continue
+				}
+				if direction.up {
+					evaluations[i].UpCondition, evaluations[i].UpStep = true, condition.Step
+					evaluations[i].Up, evaluations[i].UpValues = condition, sample.Values
+				} else {
+					evaluations[i].DownCondition, evaluations[i].DownStep = true, condition.Step
+				}
+			}
+		}
+	}
+	return evaluations, nil
+}
+
+// printResult prints the scaling actions of the simulation and its summary
+func printResult(ctx *v1alpha1.Context, result simulate.Result) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(writer, "Autoscaler:\t%s\n", ctx.Config.Name)
+	fmt.Fprintf(writer, "Period:\t%s - %s\n", result.Start.Format(time.RFC3339), result.End.Format(time.RFC3339))
+	fmt.Fprintf(writer, "Evaluations:\t%d\n", result.Evaluations)
+	fmt.Fprintf(writer, "Scale ups:\t%d\n", result.ScaleUps)
+	fmt.Fprintf(writer, "Scale downs:\t%d\n", result.ScaleDowns)
+	fmt.Fprintf(writer, "Flaps:\t%d\n", result.Flaps)
+	fmt.Fprintf(writer, "Size:\tfrom %d to %d nodes\n", result.MinSize, result.MaxSize)
+	fmt.Fprintf(writer, "Node-hours:\t%.1f\n", result.NodeHours)
+	err := writer.Flush()
+	if err != nil {
+		return err
+	}
+	if len(result.Actions) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	writer = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TIME\tACTION\tSIZE\tFLAPPING")
+	for _, action := range result.Actions {
+		flapping := ""
+		if action.Flapping {
+			flapping = "yes"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%d -> %d\t%s\n", action.Time.Format(time.RFC3339), action.Action, action.PreviousSize, action.Size, flapping)
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,280 @@
+package status
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/audit"
+	"custom-vm-autoscaler/internal/config"
+	"custom-vm-autoscaler/internal/decision"
+	"custom-vm-autoscaler/internal/elasticsearch"
+	"custom-vm-autoscaler/internal/google"
+	"custom-vm-autoscaler/internal/state"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	descriptionShort = `Show the current status of the autoscaler`
+	descriptionLong  = `
+	Show the current size of the MIGs, the limits applied in the current schedule window,
+	the last scaling decision, the cooldown and pause in progress, the nodes excluded from
+	Elasticsearch and the health of the cluster, in table or JSON format`
+)
+
+// autoscalerStatus is the status shown for every autoscaler. Parts that can not be read carry their error
+type autoscalerStatus struct {
+	Name          string             `json:"name"`
+	MIGs          map[string]int32   `json:"migs,omitempty"`
+	CurrentSize   int32              `json:"currentSize"`
+	SizeError     string             `json:"sizeError,omitempty"`
+	Limits        limitsStatus       `json:"limits"`
+	LastDecision  *v1alpha1.Decision `json:"lastDecision,omitempty"`
+	DecisionError string             `json:"decisionError,omitempty"`
+	CooldownUntil time.Time          `json:"cooldownUntil,omitempty"`
+	Pause         *v1alpha1.Pause    `json:"pause,omitempty"`
+	StateError    string             `json:"stateError,omitempty"`
+
+	ExcludedNodes      []string                `json:"excludedNodes"`
+	ExcludedNodesError string                  `json:"excludedNodesError,omitempty"`
+	ClusterHealth      *v1alpha1.ClusterHealth `json:"clusterHealth,omitempty"`
+	ClusterHealthError string                  `json:"clusterHealthError,omitempty"`
+
+	// DrainEstimate is the duration of the last drains recorded in the state, compared with DrainTimeoutSec
+	DrainEstimate   elasticsearch.DrainEstimate `json:"drainEstimate"`
+	DrainTimeoutSec int                         `json:"drainTimeoutSec"`
+}
+
+// limitsStatus are the scaling limits applied in the current schedule window
+type limitsStatus struct {
+	MinSize            int32 `json:"minSize"`
+	MaxSize            int32 `json:"maxSize"`
+	ScaleUpThreshold   int32 `json:"scaleUpThreshold"`
+	ScaleDownThreshold int32 `json:"scaleDownThreshold"`
+
+	// Window is the index of the advanced custom scaling configuration applied, or -1 for the default limits
+	Window int `json:"window"`
+}
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "status",
+		DisableFlagsInUseLine: true,
+		Short:                 descriptionShort,
+		Long:                  strings.ReplaceAll(descriptionLong, "\t", ""),
+
+		Run: RunCommand,
+	}
+
+	cmd.Flags().String("config", "autoscaler.yaml", "Path to the YAML config file, or its remote location (gs://, s3://, https://)")
+	cmd.Flags().String("autoscaler", "", "Name of the autoscaler to show. Every autoscaler is shown when empty")
+	cmd.Flags().Bool("json", false, "Print the status in JSON format")
+
+	return cmd
+}
+
+func RunCommand(cmd *cobra.Command, args []string) {
+
+	// Check the flags for this command
+	configPath, err := cmd.Flags().GetString("config")
+	if err != nil {
+		log.Fatalf("Error getting configuration file path: %v", err)
+	}
+	autoscalerName, err := cmd.Flags().GetString("autoscaler")
+	if err != nil {
+		log.Fatalf("Error getting autoscaler name: %v", err)
+	}
+	outputJSON, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		log.Fatalf("Error getting json: %v", err)
+	}
+
+	// Get and parse the config
+	configContent, err := config.ReadFile(configPath)
+	if err != nil {
+		log.Fatalf("Error parsing configuration file: %v", err)
+	}
+	autoscalers, err := config.GetAutoscalersByName(configContent, autoscalerName)
+	if err != nil {
+		log.Fatalf("Error getting autoscalers: %v", err)
+	}
+
+	// The state and the audit log are only read
+	err = state.Setup(&configContent)
+	if err != nil {
+		log.Fatalf("Error configuring state store: %v", err)
+	}
+	err = audit.Setup(&configContent)
+	if err != nil {
+		log.Fatalf("Error configuring audit log: %v", err)
+	}
+	events, eventsErr := audit.Read(time.Time{})
+
+	statuses := make([]autoscalerStatus, 0, len(autoscalers))
+	for _, autoscalerConfig := range autoscalers {
+		ctx := &v1alpha1.Context{Config: &autoscalerConfig}
+		statuses = append(statuses, getStatus(ctx, events, eventsErr, time.Now().UTC()))
+	}
+
+	if outputJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(statuses)
+		if err != nil {
+			log.Fatalf("Error encoding status: %v", err)
+		}
+		return
+	}
+
+	for i, status := range statuses {
+		if i > 0 {
+			fmt.Println()
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		printStatus(writer, status)
+		err = writer.Flush()
+		if err != nil {
+			log.Fatalf("Error printing status: %v", err)
+		}
+	}
+}
+
+// getStatus reads the status of the autoscaler from GCP, Elasticsearch, the state store and the audit events
+func getStatus(ctx *v1alpha1.Context, events []v1alpha1.Decision, eventsErr error, now time.Time) autoscalerStatus {
+	status := autoscalerStatus{Name: ctx.Config.Name}
+
+	migSizes, currentSize, _, _, err := google.GetMIGSizes(ctx)
+	if err != nil {
+		status.SizeError = err.Error()
+	}
+	status.MIGs, status.CurrentSize = migSizes, currentSize
+
+	limits := decision.GetLimits(ctx.Config, now)
+	status.Limits = limitsStatus{
+		MinSize:            limits.MinSize,
+		MaxSize:            limits.MaxSize,
+		ScaleUpThreshold:   limits.ScaleUpThreshold,
+		ScaleDownThreshold: limits.ScaleDownThreshold,
+		Window:             limits.Window,
+	}
+
+	// The last scaling action, skipping the evaluations that did nothing
+	if eventsErr != nil {
+		status.DecisionError = eventsErr.Error()
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Autoscaler == ctx.Config.Name && events[i].Action != v1alpha1.DecisionNone {
+			status.LastDecision = &events[i]
+			break
+		}
+	}
+
+	err = state.Load(ctx)
+	if err != nil {
+		status.StateError = err.Error()
+	}
+	if ctx.State.CooldownUntil.After(now) {
+		status.CooldownUntil = ctx.State.CooldownUntil
+	}
+	if pause := ctx.State.Pause; pause != nil && (pause.Until.IsZero() || now.Before(pause.Until)) {
+		status.Pause = pause
+	}
+	status.DrainEstimate = elasticsearch.EstimateDrainDuration(ctx.State.DrainDurations)
+	status.DrainTimeoutSec = ctx.Config.Target.Elasticsearch.DrainTimeoutSec
+
+	status.ExcludedNodes, err = elasticsearch.GetExcludedNodes(ctx)
+	if err != nil {
+		status.ExcludedNodesError = err.Error()
+	}
+	health, err := elasticsearch.GetClusterHealth(ctx)
+	if err != nil {
+		status.ClusterHealthError = err.Error()
+	} else {
+		status.ClusterHealth = &health
+	}
+
+	return status
+}
+
+// printStatus prints the status of the autoscaler in a human-readable form
+func printStatus(writer *tabwriter.Writer, status autoscalerStatus) {
+	fmt.Fprintf(writer, "Autoscaler:\t%s\n", status.Name)
+
+	if status.SizeError != "" {
+		fmt.Fprintf(writer, "Current size:\terror: %s\n", status.SizeError)
+	} else {
+		migNames := make([]string, 0, len(status.MIGs))
+		for name := range status.MIGs {
+			migNames = append(migNames, name)
+		}
+		sort.Strings(migNames)
+		for _, name := range migNames {
+			fmt.Fprintf(writer, "MIG %s:\t%d nodes\n", name, status.MIGs[name])
+		}
+		fmt.Fprintf(writer, "Current size:\t%d nodes\n", status.CurrentSize)
+	}
+
+	schedule := "default limits"
+	if status.Limits.Window >= 0 {
+		schedule = fmt.Sprintf("advancedCustomScalingConfiguration[%d]", status.Limits.Window)
+	}
+	fmt.Fprintf(writer, "Limits:\tmin size %d, max size %d, scale up threshold %d, scale down threshold %d (%s)\n",
+		status.Limits.MinSize, status.Limits.MaxSize, status.Limits.ScaleUpThreshold, status.Limits.ScaleDownThreshold, schedule)
+
+	switch {
+	case status.LastDecision != nil:
+		decision := status.LastDecision
+		fmt.Fprintf(writer, "Last decision:\t%s MIG %s from %d to %d nodes at %s, %s\n", decision.Action, decision.MIG,
+			decision.PreviousSize, decision.Size, decision.Time.UTC().Format(time.RFC3339), decision.Outcome)
+	case status.DecisionError != "":
+		fmt.Fprintf(writer, "Last decision:\tunknown: %s\n", status.DecisionError)
+	default:
+		fmt.Fprintf(writer, "Last decision:\tnone recorded\n")
+	}
+
+	switch {
+	case status.StateError != "":
+		fmt.Fprintf(writer, "Cooldown:\terror: %s\n", status.StateError)
+	case !status.CooldownUntil.IsZero():
+		fmt.Fprintf(writer, "Cooldown:\tuntil %s\n", status.CooldownUntil.Format(time.RFC3339))
+	default:
+		fmt.Fprintf(writer, "Cooldown:\tnone\n")
+	}
+	if pause := status.Pause; pause != nil {
+		until := "resumed"
+		if !pause.Until.IsZero() {
+			until = pause.Until.Format(time.RFC3339)
+		}
+		fmt.Fprintf(writer, "Paused:\tuntil %s (reason: %q)\n", until, pause.Reason)
+	}
+
+	switch {
+	case status.ExcludedNodesError != "":
+		fmt.Fprintf(writer, "Excluded nodes:\terror: %s\n", status.ExcludedNodesError)
+	case len(status.ExcludedNodes) == 0:
+		fmt.Fprintf(writer, "Excluded nodes:\tnone\n")
+	default:
+		fmt.Fprintf(writer, "Excluded nodes:\t%s\n", strings.Join(status.ExcludedNodes, ", "))
+	}
+
+	if estimate := status.DrainEstimate; estimate.Drains > 0 {
+		fmt.Fprintf(writer, "Drain durations:\tp50 %s, p95 %s over the last %d drains (timeout %ds)\n",
+			estimate.P50.Round(time.Second), estimate.P95.Round(time.Second), estimate.Drains, status.DrainTimeoutSec)
+	} else {
+		fmt.Fprintf(writer, "Drain durations:\tnone recorded\n")
+	}
+
+	if status.ClusterHealthError != "" {
+		fmt.Fprintf(writer, "Cluster health:\terror: %s\n", status.ClusterHealthError)
+	} else {
+		health := status.ClusterHealth
+		fmt.Fprintf(writer, "Cluster health:\t%s (relocating %d, initializing %d, unassigned %d shards)\n",
+			health.Status, health.RelocatingShards, health.InitializingShards, health.UnassignedShards)
+	}
+}
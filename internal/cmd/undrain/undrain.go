@@ -0,0 +1,70 @@
+package undrain
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/config"
+	"custom-vm-autoscaler/internal/elasticsearch"
+
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	descriptionShort = `Undrain a node of the target`
+	descriptionLong  = `
+	Remove the exclusion of the node of an instance from the shard allocation of Elasticsearch,
+	so shards are allocated on it again. The MIG is not resized`
+)
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "undrain <instance>",
+		DisableFlagsInUseLine: true,
+		Short:                 descriptionShort,
+		Long:                  strings.ReplaceAll(descriptionLong, "\t", ""),
+		Args:                  cobra.ExactArgs(1),
+
+		Run: RunCommand,
+	}
+
+	cmd.Flags().String("config", "autoscaler.yaml", "Path to the YAML config file, or its remote location (gs://, s3://, https://)")
+	cmd.Flags().String("autoscaler", "", "Name of the autoscaler owning the instance. Required when several autoscalers are defined")
+
+	return cmd
+}
+
+func RunCommand(cmd *cobra.Command, args []string) {
+	instanceName := args[0]
+
+	// Check the flags for this command
+	configPath, err := cmd.Flags().GetString("config")
+	if err != nil {
+		log.Fatalf("Error getting configuration file path: %v", err)
+	}
+	autoscalerName, err := cmd.Flags().GetString("autoscaler")
+	if err != nil {
+		log.Fatalf("Error getting autoscaler name: %v", err)
+	}
+
+	// Get and parse the config
+	configContent, err := config.ReadFile(configPath)
+	if err != nil {
+		log.Fatalf("Error parsing configuration file: %v", err)
+	}
+	autoscalers, err := config.GetAutoscalersByName(configContent, autoscalerName)
+	if err != nil {
+		log.Fatalf("Error getting autoscalers: %v", err)
+	}
+	if len(autoscalers) > 1 {
+		log.Fatalf("Several autoscalers are defined, choose the one owning the instance with --autoscaler")
+	}
+
+	ctx := &v1alpha1.Context{Config: &autoscalers[0]}
+	err = elasticsearch.ClearElasticsearchClusterSettings(ctx, instanceName)
+	if err != nil {
+		log.Fatalf("Error undraining instance %s: %v", instanceName, err)
+	}
+	log.Printf("Instance %s undrained from elasticsearch", instanceName)
+}
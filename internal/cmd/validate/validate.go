@@ -0,0 +1,443 @@
+package validate
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/approval"
+	"custom-vm-autoscaler/internal/config"
+	"custom-vm-autoscaler/internal/decision"
+	"custom-vm-autoscaler/internal/elasticsearch"
+	"custom-vm-autoscaler/internal/google"
+	"custom-vm-autoscaler/internal/leader"
+	"custom-vm-autoscaler/internal/maintenance"
+	"custom-vm-autoscaler/internal/notifier"
+	"custom-vm-autoscaler/internal/prometheus"
+	"custom-vm-autoscaler/internal/proxy"
+	"custom-vm-autoscaler/internal/state"
+	"custom-vm-autoscaler/internal/tlsconfig"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	descriptionShort = `Validate the configuration file`
+	descriptionLong  = `
+	Validate the configuration file strictly, failing on unknown fields, missing required fields,
+	invalid PromQL queries, days and hours, and inconsistent sizes`
+)
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "validate",
+		DisableFlagsInUseLine: true,
+		Short:                 descriptionShort,
+		Long:                  strings.ReplaceAll(descriptionLong, "\t", ""),
+
+		Run: RunCommand,
+	}
+
+	cmd.Flags().String("config", "autoscaler.yaml", "Path to the YAML config file")
+	cmd.Flags().Bool("online", false, "Also execute the PromQL queries against the Prometheus servers")
+
+	return cmd
+}
+
+func RunCommand(cmd *cobra.Command, args []string) {
+
+	// Check the flags for this command
+	configPath, err := cmd.Flags().GetString("config")
+	if err != nil {
+		log.Fatalf("Error getting configuration file path: %v", err)
+	}
+	online, err := cmd.Flags().GetBool("online")
+	if err != nil {
+		log.Fatalf("Error getting online flag: %v", err)
+	}
+
+	// Parse the config failing on unknown fields, so typos are not silently ignored
+	configContent, err := config.ReadFile(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", configPath, err)
+		os.Exit(1)
+	}
+
+	errs := Validate(configContent)
+	if online {
+		errs = append(errs, validateQueriesOnline(configContent)...)
+	}
+	if len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", configPath, err)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s: configuration is valid\n", configPath)
+}
+
+// Validate checks the required fields and the formats of the config, returning every problem found
+func Validate(configContent v1alpha1.ConfigSpec) []error {
+	var errs []error
+	addError := func(format string, a ...any) {
+		errs = append(errs, fmt.Errorf(format, a...))
+	}
+
+	// Sections only read from the root of the config
+	if configContent.LeaderElection.Enabled && configContent.LeaderElection.Backend != leader.BackendGCS && configContent.LeaderElection.Backend != leader.BackendKubernetes {
+		addError("leaderElection.backend: expected %s or %s, got %q", leader.BackendGCS, leader.BackendKubernetes, configContent.LeaderElection.Backend)
+	}
+	if configContent.LeaderElection.Enabled && configContent.LeaderElection.RenewIntervalSec >= configContent.LeaderElection.LeaseDurationSec {
+		addError("leaderElection.renewIntervalSec: must be lower than leaseDurationSec (%d), got %d",
+			configContent.LeaderElection.LeaseDurationSec, configContent.LeaderElection.RenewIntervalSec)
+	}
+	switch configContent.State.Backend {
+	case "":
+	case state.BackendFile:
+		if configContent.State.File.Path == "" {
+			addError("state.file.path: required for the %s backend", state.BackendFile)
+		}
+	case state.BackendGCS:
+		if configContent.State.GCS.Bucket == "" {
+			addError("state.gcs.bucket: required for the %s backend", state.BackendGCS)
+		}
+	default:
+		addError("state.backend: expected %s or %s, got %q", state.BackendFile, state.BackendGCS, configContent.State.Backend)
+	}
+	if configContent.Admin.Enabled && configContent.Admin.Token == "" {
+		addError("admin.token: required when the admin API is enabled")
+	}
+	if configContent.Triggers.Enabled && configContent.Triggers.Token == "" {
+		addError("triggers.token: required when the trigger endpoints are enabled")
+	}
+	if configContent.ControlPlane.Enabled && configContent.ControlPlane.Token == "" {
+		addError("controlPlane.token: required when the control plane API is enabled")
+	}
+	if eventsObject := configContent.KubernetesEvents.Object; eventsObject.Name != "" && (eventsObject.APIVersion == "" || eventsObject.Kind == "") {
+		addError("kubernetesEvents.object: apiVersion and kind are required along with the name")
+	}
+
+	names := map[string]bool{}
+	for _, autoscaler := range config.GetAutoscalers(configContent) {
+		if names[autoscaler.Name] {
+			addError("autoscaler %s: name is duplicated", autoscaler.Name)
+		}
+		names[autoscaler.Name] = true
+
+		for _, err := range validateAutoscaler(autoscaler) {
+			errs = append(errs, fmt.Errorf("autoscaler %s: %w", autoscaler.Name, err))
+		}
+	}
+
+	return errs
+}
+
+// validateAutoscaler checks the config of a single autoscaler
+func validateAutoscaler(autoscaler v1alpha1.ConfigSpec) []error {
+	var errs []error
+	addError := func(format string, a ...any) {
+		errs = append(errs, fmt.Errorf(format, a...))
+	}
+
+	// Metrics
+	prometheusConfig := autoscaler.Metrics.Prometheus
+	if prometheusConfig.URL == "" {
+		addError("metrics.prometheus.url: required")
+	}
+	if prometheusConfig.UpCondition != "" || len(prometheusConfig.UpConditions) == 0 {
+		if err := prometheus.ValidateQuery(prometheusConfig.UpCondition); err != nil {
+			addError("metrics.prometheus.upCondition: %v", err)
+		}
+	}
+	if prometheusConfig.DownCondition != "" || len(prometheusConfig.DownConditions) == 0 {
+		if err := prometheus.ValidateQuery(prometheusConfig.DownCondition); err != nil {
+			addError("metrics.prometheus.downCondition: %v", err)
+		}
+	}
+	if prometheusConfig.WarmupExclusion.PeriodSec < 0 {
+		addError("metrics.prometheus.warmupExclusion.periodSec: must not be negative")
+	}
+	if prometheusConfig.WarmupExclusion.PeriodSec > 0 && autoscaler.Target.Elasticsearch.URL == "" {
+		addError("metrics.prometheus.warmupExclusion.periodSec: requires target.elasticsearch.url")
+	}
+	for _, direction := range []struct {
+		field      string
+		conditions []v1alpha1.ConditionSpec
+	}{
+		{field: "upConditions", conditions: prometheusConfig.UpConditions},
+		{field: "downConditions", conditions: prometheusConfig.DownConditions},
+	} {
+		field := direction.field
+		conditionNames := map[string]bool{}
+		for i, condition := range direction.conditions {
+			if condition.Name == "" {
+				addError("metrics.prometheus.%s[%d].name: required", field, i)
+			} else if conditionNames[condition.Name] {
+				addError("metrics.prometheus.%s[%d].name: %s is duplicated", field, i, condition.Name)
+			}
+			conditionNames[condition.Name] = true
+			if err := prometheus.ValidateQuery(condition.Query); err != nil {
+				addError("metrics.prometheus.%s[%d].query: %v", field, i, err)
+			}
+			if condition.Step < 0 {
+				addError("metrics.prometheus.%s[%d].step: must not be negative", field, i)
+			}
+			if condition.Threshold < 0 {
+				addError("metrics.prometheus.%s[%d].threshold: must not be negative", field, i)
+			}
+			if condition.MaxStep < 0 {
+				addError("metrics.prometheus.%s[%d].maxStep: must not be negative", field, i)
+			}
+			if field == "downConditions" && (condition.Threshold != 0 || condition.MaxStep != 0) {
+				addError("metrics.prometheus.%s[%d]: threshold and maxStep only apply to up conditions", field, i)
+			}
+		}
+	}
+	if prometheusConfig.CacheTTLSec < 0 {
+		addError("metrics.prometheus.cacheTtlSec: must not be negative")
+	}
+	if err := proxy.Validate(prometheusConfig.Proxy); err != nil {
+		addError("metrics.prometheus.proxy: %v", err)
+	}
+	if err := tlsconfig.Validate(prometheusConfig.TLSMinVersion, prometheusConfig.TLSMaxVersion, prometheusConfig.TLSCipherSuites); err != nil {
+		addError("metrics.prometheus: %v", err)
+	}
+
+	// Target
+	esConfig := autoscaler.Target.Elasticsearch
+	if err := tlsconfig.Validate(esConfig.TLSMinVersion, esConfig.TLSMaxVersion, esConfig.TLSCipherSuites); err != nil {
+		addError("target.elasticsearch: %v", err)
+	}
+	if esConfig.MaxRelocatingShards < 0 {
+		addError("target.elasticsearch.maxRelocatingShards: must not be negative")
+	}
+	if esConfig.MinHealthy.Nodes < 0 {
+		addError("target.elasticsearch.minHealthy.nodes: must not be negative")
+	}
+	if esConfig.MinHealthy.MaxHeapPercent > 100 {
+		addError("target.elasticsearch.minHealthy.maxHeapPercent: must not be greater than 100")
+	}
+	if err := proxy.Validate(esConfig.Proxy); err != nil {
+		addError("target.elasticsearch.proxy: %v", err)
+	}
+	switch esConfig.OnDrainTimeout {
+	case "", elasticsearch.DrainTimeoutPolicyRollback, elasticsearch.DrainTimeoutPolicyForce, elasticsearch.DrainTimeoutPolicyExtend:
+	case elasticsearch.DrainTimeoutPolicyApproval:
+		if autoscaler.Autoscaler.ScaleDownApproval.SlackWebhookURL == "" {
+			addError("target.elasticsearch.onDrainTimeout: %s requires autoscaler.scaleDownApproval.slackWebhookUrl", elasticsearch.DrainTimeoutPolicyApproval)
+		}
+	default:
+		addError("target.elasticsearch.onDrainTimeout: expected %s, %s, %s or %s, got %q", elasticsearch.DrainTimeoutPolicyRollback,
+			elasticsearch.DrainTimeoutPolicyForce, elasticsearch.DrainTimeoutPolicyExtend, elasticsearch.DrainTimeoutPolicyApproval, esConfig.OnDrainTimeout)
+	}
+	switch esConfig.SnapshotNodesPolicy {
+	case "", elasticsearch.SnapshotNodesPolicyDrain, elasticsearch.SnapshotNodesPolicyNoDrain:
+	default:
+		addError("target.elasticsearch.snapshotNodesPolicy: expected %s or %s, got %q", elasticsearch.SnapshotNodesPolicyDrain,
+			elasticsearch.SnapshotNodesPolicyNoDrain, esConfig.SnapshotNodesPolicy)
+	}
+	switch esConfig.ConcurrencyCheck.Policy {
+	case "", elasticsearch.ConcurrencyPolicyWarn, elasticsearch.ConcurrencyPolicyTune, elasticsearch.ConcurrencyPolicyDisabled:
+	default:
+		addError("target.elasticsearch.concurrencyCheck.policy: expected %s, %s or %s, got %q", elasticsearch.ConcurrencyPolicyWarn,
+			elasticsearch.ConcurrencyPolicyTune, elasticsearch.ConcurrencyPolicyDisabled, esConfig.ConcurrencyCheck.Policy)
+	}
+	if esConfig.ConcurrencyCheck.ShardRelocationSec < 0 {
+		addError("target.elasticsearch.concurrencyCheck.shardRelocationSec: must not be negative")
+	}
+	for i, pattern := range esConfig.IgnorableIndices.Patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			addError("target.elasticsearch.ignorableIndices.patterns[%d]: %v", i, err)
+		}
+	}
+	if esConfig.IgnorableIndices.TimeoutSec < 0 {
+		addError("target.elasticsearch.ignorableIndices.timeoutSec: must not be negative")
+	}
+	for i, pattern := range esConfig.IndexOperations.Patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			addError("target.elasticsearch.indexOperations.patterns[%d]: %v", i, err)
+		}
+	}
+	for i, spec := range esConfig.ReplicaSync {
+		if spec.Pattern == "" {
+			addError("target.elasticsearch.replicaSync[%d].pattern: required", i)
+		}
+		if spec.MinReplicas < 0 || spec.MaxReplicas < 0 {
+			addError("target.elasticsearch.replicaSync[%d]: replicas must not be negative", i)
+		}
+		if spec.MaxReplicas > 0 && spec.MinReplicas > spec.MaxReplicas {
+			addError("target.elasticsearch.replicaSync[%d]: minReplicas (%d) must not be greater than maxReplicas (%d)", i, spec.MinReplicas, spec.MaxReplicas)
+		}
+	}
+
+	// Infrastructure
+	gcp := autoscaler.Infrastructure.GCP
+	if gcp.ProjectID == "" {
+		addError("infrastructure.gcp.projectId: required")
+	}
+	if gcp.MIGName == "" && len(gcp.MIGs) == 0 && gcp.GKE.NodePool == "" {
+		addError("infrastructure.gcp.migName: required when no migs nor GKE node pool are defined")
+	}
+	if gcp.GKE != (v1alpha1.GKESpec{}) {
+		if gcp.GKE.Cluster == "" {
+			addError("infrastructure.gcp.gke.cluster: required")
+		}
+		if gcp.GKE.Location == "" {
+			addError("infrastructure.gcp.gke.location: required")
+		}
+		if gcp.GKE.NodePool == "" {
+			addError("infrastructure.gcp.gke.nodePool: required")
+		}
+		if gcp.MIGName != "" || len(gcp.MIGs) > 0 {
+			addError("infrastructure.gcp.gke: not compatible with migName and migs, the MIGs of the node pool are scaled")
+		}
+	}
+	if gcp.InstanceTemplate.Name == "" && gcp.InstanceTemplate.RollingReplace {
+		addError("infrastructure.gcp.instanceTemplate.name: required for rollingReplace")
+	}
+	if gcp.MIGName != "" && gcp.Zone == "" && gcp.Region == "" {
+		addError("infrastructure.gcp.zone: zone or region required for migName")
+	}
+	for i, mig := range gcp.MIGs {
+		if mig.Name == "" {
+			addError("infrastructure.gcp.migs[%d].name: required", i)
+		}
+		if mig.Zone == "" && mig.Region == "" && gcp.Zone == "" && gcp.Region == "" {
+			addError("infrastructure.gcp.migs[%d]: zone or region required", i)
+		}
+		if mig.MaxSize > 0 && mig.MinSize > mig.MaxSize {
+			addError("infrastructure.gcp.migs[%d]: minSize (%d) greater than maxSize (%d)", i, mig.MinSize, mig.MaxSize)
+		}
+	}
+	if gcp.ScaleDownAction != "" && gcp.ScaleDownAction != google.ScaleDownActionDelete && gcp.ScaleDownAction != google.ScaleDownActionAbandon {
+		addError("infrastructure.gcp.scaleDownAction: expected %s or %s, got %q", google.ScaleDownActionDelete, google.ScaleDownActionAbandon, gcp.ScaleDownAction)
+	}
+	if gcp.DeletionProtectionPolicy != "" && gcp.DeletionProtectionPolicy != google.DeletionProtectionPolicySkip && gcp.DeletionProtectionPolicy != google.DeletionProtectionPolicyStop {
+		addError("infrastructure.gcp.deletionProtectionPolicy: expected %s or %s, got %q", google.DeletionProtectionPolicySkip, google.DeletionProtectionPolicyStop, gcp.DeletionProtectionPolicy)
+	}
+	if gcp.DrainStateLabel != "" && !google.IsLabelKey(gcp.DrainStateLabel) {
+		addError("infrastructure.gcp.drainStateLabel: expected a lowercase label key of up to 63 characters starting with a letter, got %q", gcp.DrainStateLabel)
+	}
+	if gcp.MIGSelectionPolicy != "" && gcp.MIGSelectionPolicy != google.MIGSelectionPolicyWeighted && gcp.MIGSelectionPolicy != google.MIGSelectionPolicyRoundRobin {
+		addError("infrastructure.gcp.migSelectionPolicy: expected %s or %s, got %q", google.MIGSelectionPolicyWeighted, google.MIGSelectionPolicyRoundRobin, gcp.MIGSelectionPolicy)
+	}
+	if gcp.ImpersonateServiceAccount != "" && !strings.Contains(gcp.ImpersonateServiceAccount, "@") {
+		addError("infrastructure.gcp.impersonateServiceAccount: expected the email of a service account, got %q", gcp.ImpersonateServiceAccount)
+	}
+	wif := gcp.WorkloadIdentityFederation
+	if wif != (v1alpha1.WorkloadIdentityFederationSpec{}) {
+		if wif.Audience == "" {
+			addError("infrastructure.gcp.workloadIdentityFederation.audience: required")
+		}
+		if (wif.TokenFile == "") == (wif.TokenURL == "") {
+			addError("infrastructure.gcp.workloadIdentityFederation: exactly one of tokenFile or tokenUrl required")
+		}
+		if gcp.CredentialsFile != "" {
+			addError("infrastructure.gcp.workloadIdentityFederation: not compatible with credentialsFile")
+		}
+	}
+
+	// Autoscaler
+	scaling := autoscaler.Autoscaler
+	if scaling.MaxSize <= 0 {
+		addError("autoscaler.maxSize: must be greater than 0")
+	}
+	if scaling.EvaluationJitterSec < 0 {
+		addError("autoscaler.evaluationJitterSec: must not be negative")
+	}
+	if scaling.Quarantine.PeriodSec < 0 {
+		addError("autoscaler.quarantine.periodSec: must not be negative")
+	}
+	if scaling.MinSize < 0 {
+		addError("autoscaler.minSize: must not be negative")
+	}
+	if scaling.MinSize > scaling.MaxSize {
+		addError("autoscaler: minSize (%d) greater than maxSize (%d)", scaling.MinSize, scaling.MaxSize)
+	}
+	for i, advanced := range scaling.AdvancedCustomScalingConfiguration {
+		if err := maintenance.ValidateWindow(advanced.Days, advanced.HoursUTC); err != nil {
+			addError("autoscaler.advancedCustomScalingConfiguration[%d]: %v", i, err)
+		}
+		minSize, maxSize := advanced.MinSize, advanced.MaxSize
+		if minSize == 0 {
+			minSize = scaling.MinSize
+		}
+		if maxSize == 0 {
+			maxSize = scaling.MaxSize
+		}
+		if minSize > maxSize {
+			addError("autoscaler.advancedCustomScalingConfiguration[%d]: minSize (%d) greater than maxSize (%d)", i, minSize, maxSize)
+		}
+	}
+	for i, window := range scaling.MaintenanceWindows {
+		if err := maintenance.ValidateWindow(window.Days, window.HoursUTC); err != nil {
+			addError("autoscaler.maintenanceWindows[%d]: %v", i, err)
+		}
+		if window.Mode != "" && window.Mode != maintenance.ModeBlock && window.Mode != maintenance.ModeScaleUpOnly {
+			addError("autoscaler.maintenanceWindows[%d].mode: expected %s or %s, got %q", i, maintenance.ModeBlock, maintenance.ModeScaleUpOnly, window.Mode)
+		}
+	}
+
+	probe := scaling.StartupProbe
+	if probe.Type != "" && probe.Type != google.ProbeTypeHTTP && probe.Type != google.ProbeTypeTCP {
+		addError("autoscaler.startupProbe.type: expected %s or %s, got %q", google.ProbeTypeHTTP, google.ProbeTypeTCP, probe.Type)
+	}
+	if probe.Type != "" && (probe.Port <= 0 || probe.Port > 65535) {
+		addError("autoscaler.startupProbe.port: required when a type is defined")
+	}
+	if probe.Scheme != "" && probe.Scheme != "http" && probe.Scheme != "https" {
+		addError("autoscaler.startupProbe.scheme: expected http or https, got %q", probe.Scheme)
+	}
+	if scaling.ScaleUpSurge < 0 {
+		addError("autoscaler.scaleUpSurge: must be 0 or greater, got %d", scaling.ScaleUpSurge)
+	}
+	if scaling.ScaleUpSurge > 0 && probe.TimeoutSec <= 0 {
+		addError("autoscaler.scaleUpSurge: requires autoscaler.startupProbe.timeoutSec, waited for between the waves")
+	}
+
+	approvalSpec := scaling.ScaleDownApproval
+	if approvalSpec.Enabled {
+		if approvalSpec.SlackWebhookURL == "" {
+			addError("autoscaler.scaleDownApproval.slackWebhookUrl: required when the approval is enabled")
+		}
+		if approvalSpec.OnTimeout != "" && approvalSpec.OnTimeout != approval.OnTimeoutProceed && approvalSpec.OnTimeout != approval.OnTimeoutCancel {
+			addError("autoscaler.scaleDownApproval.onTimeout: expected %s or %s, got %q", approval.OnTimeoutProceed, approval.OnTimeoutCancel, approvalSpec.OnTimeout)
+		}
+		if approvalSpec.Days != "" {
+			if err := maintenance.ValidateWindow(approvalSpec.Days, approvalSpec.HoursUTC); err != nil {
+				addError("autoscaler.scaleDownApproval: %v", err)
+			}
+		}
+	}
+
+	// Notifications
+	if err := notifier.Validate(&autoscaler); err != nil {
+		addError("notifications: %v", err)
+	}
+
+	// Heartbeat
+	if err := proxy.Validate(autoscaler.Heartbeat.Proxy); err != nil {
+		addError("heartbeat.proxy: %v", err)
+	}
+
+	return errs
+}
+
+// validateQueriesOnline executes the conditions of every autoscaler against its Prometheus server
+func validateQueriesOnline(configContent v1alpha1.ConfigSpec) []error {
+	var errs []error
+	for _, autoscaler := range config.GetAutoscalers(configContent) {
+		ctx := &v1alpha1.Context{Config: &autoscaler}
+		conditions := append(decision.UpConditions(&autoscaler), decision.DownConditions(&autoscaler)...)
+		for _, condition := range conditions {
+			_, _, err := prometheus.GetPrometheusConditionValues(condition.Query, ctx)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("autoscaler %s: metrics.prometheus: condition %s: %v", autoscaler.Name, condition.Name, err))
+			}
+		}
+	}
+	return errs
+}
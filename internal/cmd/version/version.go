@@ -0,0 +1,57 @@
+package version
+
+import (
+	"custom-vm-autoscaler/internal/version"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	descriptionShort = `Show the version of the autoscaler`
+	descriptionLong  = `
+	Show the version, the git commit and the build date of the binary`
+)
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "version",
+		DisableFlagsInUseLine: true,
+		Short:                 descriptionShort,
+		Long:                  strings.ReplaceAll(descriptionLong, "\t", ""),
+
+		Run: RunCommand,
+	}
+
+	cmd.Flags().Bool("json", false, "Print the version in JSON format")
+
+	return cmd
+}
+
+func RunCommand(cmd *cobra.Command, args []string) {
+
+	// Check the flags for this command
+	outputJSON, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		log.Fatalf("Error getting json: %v", err)
+	}
+
+	info := version.Get()
+	if outputJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(info)
+		if err != nil {
+			log.Fatalf("Error encoding version: %v", err)
+		}
+		return
+	}
+
+	fmt.Printf("Version:     %s\n", info.Version)
+	fmt.Printf("Git commit:  %s\n", info.Commit)
+	fmt.Printf("Build date:  %s\n", info.BuildDate)
+}
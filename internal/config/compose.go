@@ -0,0 +1,260 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"custom-vm-autoscaler/api/v1alpha1"
+
+	"gopkg.in/yaml.v2"
+)
+
+// decodeDocuments decodes every YAML document of the content, separated by ---. Empty documents are skipped
+func decodeDocuments(content []byte) ([]yaml.MapSlice, error) {
+	var documents []yaml.MapSlice
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var document yaml.MapSlice
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			return documents, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(document) > 0 {
+			documents = append(documents, document)
+		}
+	}
+}
+
+// composeDocuments returns the first document of the config with the following ones appended to its autoscalers,
+// and the defaults block of the first document merged into every autoscaler. It returns false when the config is
+// a single document without defaults, so it is used as is
+func composeDocuments(documents []yaml.MapSlice) (yaml.MapSlice, bool, error) {
+	if len(documents) == 0 {
+		return yaml.MapSlice{}, false, nil
+	}
+
+	config := documents[0]
+	if len(documents) > 1 {
+		autoscalers, ok := getKey(config, "autoscalers").([]interface{})
+		if getKey(config, "autoscalers") != nil && !ok {
+			return nil, false, fmt.Errorf("autoscalers must be a list")
+		}
+		for _, document := range documents[1:] {
+			autoscalers = append(autoscalers, document)
+		}
+		config = setKey(config, "autoscalers", autoscalers)
+	}
+
+	defaults := getKey(config, "defaults")
+	if defaults == nil {
+		return config, len(documents) > 1, nil
+	}
+	defaultsMap, ok := defaults.(yaml.MapSlice)
+	if !ok {
+		return nil, false, fmt.Errorf("defaults must be a map")
+	}
+	autoscalers, ok := getKey(config, "autoscalers").([]interface{})
+	if !ok {
+		return nil, false, fmt.Errorf("defaults require a list of autoscalers")
+	}
+	for i, autoscaler := range autoscalers {
+		autoscalerMap, ok := autoscaler.(yaml.MapSlice)
+		if !ok {
+			return nil, false, fmt.Errorf("autoscalers[%d] must be a map", i)
+		}
+		autoscalers[i] = mergeMaps(defaultsMap, autoscalerMap)
+	}
+	return deleteKey(config, "defaults"), true, nil
+}
+
+// mergeMaps returns the base map with the values of the override, merging the nested maps present in both.
+// Lists and any other values of the override replace the ones of the base
+func mergeMaps(base, override yaml.MapSlice) yaml.MapSlice {
+	merged := make(yaml.MapSlice, 0, len(base)+len(override))
+	for _, item := range base {
+		merged = append(merged, yaml.MapItem{Key: item.Key, Value: copyValue(item.Value)})
+	}
+	for _, item := range override {
+		baseMap, baseIsMap := getKey(merged, fmt.Sprint(item.Key)).(yaml.MapSlice)
+		overrideMap, overrideIsMap := item.Value.(yaml.MapSlice)
+		if baseIsMap && overrideIsMap {
+			item.Value = mergeMaps(baseMap, overrideMap)
+		}
+		merged = setKeyInPlace(merged, item.Key, item.Value)
+	}
+	return merged
+}
+
+// copyValue copies the nested maps and lists of the value, so the defaults are not shared by the autoscalers
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		return mergeMaps(v, nil)
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	}
+	return value
+}
+
+// setKeyInPlace sets the value of the key in the YAML map, appending the new keys at the end
+func setKeyInPlace(m yaml.MapSlice, key interface{}, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if item.Key == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
+
+// deleteKey removes the key from the YAML map
+func deleteKey(m yaml.MapSlice, key string) yaml.MapSlice {
+	result := make(yaml.MapSlice, 0, len(m))
+	for _, item := range m {
+		if item.Key != key {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// includeFiles merges the files listed in the include key of the first document into it, in order, so the later
+// files override the earlier ones, and the first document overrides all of them. Relative paths are relative to the
+// config file. The included files are a single document, and can not include other files. It returns false when
+// nothing is included
+func includeFiles(configPath string, documents []yaml.MapSlice) ([]yaml.MapSlice, bool, error) {
+	if len(documents) == 0 || getKey(documents[0], "include") == nil {
+		return documents, false, nil
+	}
+	includes, ok := getKey(documents[0], "include").([]interface{})
+	if !ok {
+		return nil, false, fmt.Errorf("include must be a list of paths")
+	}
+
+	merged := yaml.MapSlice{}
+	for _, include := range includes {
+		includePath, ok := include.(string)
+		if !ok || includePath == "" {
+			return nil, false, fmt.Errorf("include must be a list of paths, got %v", include)
+		}
+		includePath, err := resolveInclude(configPath, includePath)
+		if err != nil {
+			return nil, false, err
+		}
+
+		document, err := readDocument(includePath)
+		if err != nil {
+			return nil, false, fmt.Errorf("error including %s: %w", includePath, err)
+		}
+		if getKey(document, "include") != nil {
+			return nil, false, fmt.Errorf("error including %s: included files can not include other files", includePath)
+		}
+		merged = mergeMaps(merged, document)
+	}
+
+	documents[0] = mergeMaps(merged, deleteKey(documents[0], "include"))
+	return documents, true, nil
+}
+
+// resolveInclude returns the location of the included file, relative to the directory of the config, or to its
+// remote location, unless absolute
+func resolveInclude(configPath string, includePath string) (string, error) {
+	if IsRemote(includePath) || filepath.IsAbs(includePath) {
+		return includePath, nil
+	}
+	if IsRemote(configPath) {
+		base, err := url.Parse(configPath)
+		if err != nil {
+			return "", err
+		}
+		reference, err := url.Parse(includePath)
+		if err != nil {
+			return "", err
+		}
+		return base.ResolveReference(reference).String(), nil
+	}
+	return filepath.Join(filepath.Dir(configPath), includePath), nil
+}
+
+// readDocument reads the included file, which must be a single YAML document
+func readDocument(includePath string) (yaml.MapSlice, error) {
+	fileBytes, err := readBytes(includePath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := decodeContent(fileBytes)
+	if err != nil {
+		return nil, err
+	}
+	documents, err := decodeDocuments(content)
+	if err != nil {
+		return nil, err
+	}
+	switch len(documents) {
+	case 0:
+		return yaml.MapSlice{}, nil
+	case 1:
+		return documents[0], nil
+	}
+	return nil, fmt.Errorf("expected a single document, got %d", len(documents))
+}
+
+// readDir reads the YAML files of the directory in lexical order. Their first documents are merged, the later files
+// overriding the earlier ones, and their other documents are appended as autoscalers. Every file can include others
+func readDir(dirPath string) (config v1alpha1.ConfigSpec, err error) {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return config, err
+	}
+
+	root := yaml.MapSlice{}
+	var autoscalers []yaml.MapSlice
+	files := 0
+	for _, entry := range entries {
+		extension := filepath.Ext(entry.Name())
+		if entry.IsDir() || (extension != ".yaml" && extension != ".yml") {
+			continue
+		}
+		files++
+
+		filePath := filepath.Join(dirPath, entry.Name())
+		fileBytes, err := os.ReadFile(filePath)
+		if err != nil {
+			return config, err
+		}
+		content, err := decodeContent(fileBytes)
+		if err != nil {
+			return config, fmt.Errorf("error reading %s: %w", filePath, err)
+		}
+		documents, err := decodeDocuments(content)
+		if err != nil {
+			return config, fmt.Errorf("error reading %s: %w", filePath, err)
+		}
+		documents, _, err = includeFiles(filePath, documents)
+		if err != nil {
+			return config, fmt.Errorf("error reading %s: %w", filePath, err)
+		}
+		if len(documents) == 0 {
+			continue
+		}
+		root = mergeMaps(root, documents[0])
+		autoscalers = append(autoscalers, documents[1:]...)
+	}
+	if files == 0 {
+		return config, fmt.Errorf("no YAML files found in the config directory %s", dirPath)
+	}
+
+	return parseDocuments(dirPath, append([]yaml.MapSlice{root}, autoscalers...), nil, true)
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestComposeDocuments(t *testing.T) {
+	content := `
+defaults:
+  autoscaler:
+    debugMode: true
+    cooldownPeriodSec: 60
+  infrastructure:
+    gcp:
+      projectId: shared
+autoscalers:
+  - name: hot
+    autoscaler:
+      cooldownPeriodSec: 120
+---
+name: warm
+infrastructure:
+  gcp:
+    zone: europe-west1-b
+`
+	want := `autoscalers:
+- autoscaler:
+    debugMode: true
+    cooldownPeriodSec: 120
+  infrastructure:
+    gcp:
+      projectId: shared
+  name: hot
+- autoscaler:
+    debugMode: true
+    cooldownPeriodSec: 60
+  infrastructure:
+    gcp:
+      projectId: shared
+      zone: europe-west1-b
+  name: warm
+`
+
+	documents, err := decodeDocuments([]byte(content))
+	if err != nil {
+		t.Fatalf("decodeDocuments() error = %v", err)
+	}
+	config, composed, err := composeDocuments(documents)
+	if err != nil || !composed {
+		t.Fatalf("composeDocuments() = %v, %v, want composed", composed, err)
+	}
+	got, err := yaml.Marshal(config)
+	if err != nil {
+		t.Fatalf("yaml.Marshal() error = %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("composeDocuments() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestIncludeFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"schedules.yaml": "autoscaler:\n  cooldownPeriodSec: 60\n  debugMode: true\n",
+		"secrets.yaml":   "autoscaler:\n  cooldownPeriodSec: 90\ntarget:\n  elasticsearch:\n    password: secret\n",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content := "include:\n  - schedules.yaml\n  - secrets.yaml\nautoscaler:\n  debugMode: false\n"
+	want := "autoscaler:\n  cooldownPeriodSec: 90\n  debugMode: false\ntarget:\n  elasticsearch:\n    password: secret\n"
+
+	documents, err := decodeDocuments([]byte(content))
+	if err != nil {
+		t.Fatalf("decodeDocuments() error = %v", err)
+	}
+	documents, included, err := includeFiles(filepath.Join(dir, "autoscaler.yaml"), documents)
+	if err != nil || !included {
+		t.Fatalf("includeFiles() = %v, %v, want included", included, err)
+	}
+	got, err := yaml.Marshal(documents[0])
+	if err != nil {
+		t.Fatalf("yaml.Marshal() error = %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("includeFiles() =\n%s\nwant\n%s", got, want)
+	}
+}
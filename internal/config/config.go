@@ -1,7 +1,12 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"os"
+	"regexp"
+	"time"
 
 	"custom-vm-autoscaler/api/v1alpha1"
 
@@ -20,19 +25,195 @@ func Unmarshal(bytes []byte) (config v1alpha1.ConfigSpec, err error) {
 	return config, err
 }
 
-// ReadFile TODO
+// anonymousStructType matches the description of the anonymous structs in the errors of the YAML decoder
+var anonymousStructType = regexp.MustCompile(` in type struct \{.*\}$`)
+
+// UnmarshalStrict parses the config like Unmarshal, but failing on unknown or duplicated fields
+func UnmarshalStrict(bytes []byte) (config v1alpha1.ConfigSpec, err error) {
+	err = yaml.UnmarshalStrict(bytes, &config)
+
+	// Remove the definition of the anonymous structs from the errors, as it is not useful for the users
+	var typeError *yaml.TypeError
+	if errors.As(err, &typeError) {
+		for i, message := range typeError.Errors {
+			typeError.Errors[i] = anonymousStructType.ReplaceAllString(message, "")
+		}
+	}
+	return config, err
+}
+
+// ReadFile reads the config strictly, failing on unknown or duplicated fields. The path can be a local file,
+// a local directory whose YAML files are merged in lexical order, or a remote location (gs://, s3://, https://).
+// Configs written for older versions of the schema are upgraded in memory to the latest one
 func ReadFile(filepath string) (config v1alpha1.ConfigSpec, err error) {
-	var fileBytes []byte
-	fileBytes, err = os.ReadFile(filepath)
+	if !IsRemote(filepath) {
+		info, err := os.Stat(filepath)
+		if err == nil && info.IsDir() {
+			return readDir(filepath)
+		}
+	}
+
+	fileBytes, err := readBytes(filepath)
 	if err != nil {
 		return config, err
 	}
 
-	// Expand environment variables present in the config
-	// This will cause expansion in the following way: field: "$FIELD" -> field: "value_of_field"
-	fileExpandedEnv := os.ExpandEnv(string(fileBytes))
+	return parse(filepath, fileBytes)
+}
 
-	config, err = Unmarshal([]byte(fileExpandedEnv))
+// readBytes reads the content of the local file or the remote location
+func readBytes(filepath string) ([]byte, error) {
+	if IsRemote(filepath) {
+		fileBytes, _, err := fetchRemote(filepath, "")
+		return fileBytes, err
+	}
+	return os.ReadFile(filepath)
+}
 
-	return config, err
+// Watch fetches the remote config periodically, calling onChange with the new config every time its ETag changes.
+// Configs that can not be fetched are logged and ignored, and the ones that can not be parsed are passed to onInvalid,
+// keeping the previous one
+func Watch(filepath string, interval time.Duration, onChange func(config v1alpha1.ConfigSpec), onInvalid func(err error)) {
+	_, etag, err := fetchRemote(filepath, "")
+	if err != nil {
+		log.Printf("Error fetching config %s: %v", filepath, err)
+	}
+
+	for {
+		time.Sleep(interval)
+
+		fileBytes, newETag, err := fetchRemote(filepath, etag)
+		if err != nil {
+			log.Printf("Error fetching config %s: %v", filepath, err)
+			continue
+		}
+		if fileBytes == nil {
+			continue
+		}
+		etag = newETag
+
+		config, err := parse(filepath, fileBytes)
+		if err != nil {
+			onInvalid(err)
+			continue
+		}
+		log.Printf("Config %s changed", filepath)
+		onChange(config)
+	}
+}
+
+// parse decodes the content of the config, decrypting it when encrypted with SOPS, expanding the environment
+// variables present in it, composing its includes, documents and defaults, upgrading it to the latest version of the
+// schema, applying the overrides, resolving the secrets and loading the default values.
+// This will cause expansion in the following way: field: "$FIELD" -> field: "value_of_field"
+func parse(filepath string, fileBytes []byte) (config v1alpha1.ConfigSpec, err error) {
+	fileExpandedEnv, err := decodeContent(fileBytes)
+	if err != nil {
+		return config, err
+	}
+	documents, err := decodeDocuments(fileExpandedEnv)
+	if err != nil {
+		return config, err
+	}
+	documents, included, err := includeFiles(filepath, documents)
+	if err != nil {
+		return config, err
+	}
+
+	return parseDocuments(filepath, documents, fileExpandedEnv, included)
+}
+
+// decodeContent decrypts the content of a config file when encrypted with SOPS, and expands the environment
+// variables present in it. SOPS is applied before expanding anything, so its integrity can be verified
+func decodeContent(fileBytes []byte) ([]byte, error) {
+	if isSOPSEncrypted(fileBytes) {
+		var err error
+		fileBytes, err = decryptSOPS(fileBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return []byte(os.ExpandEnv(string(fileBytes))), nil
+}
+
+// parseDocuments composes the documents of the config and decodes them strictly. The content is only encoded again
+// from the documents when they were composed or migrated, so the errors point to the lines of a single file otherwise
+func parseDocuments(filepath string, documents []yaml.MapSlice, content []byte, composed bool) (config v1alpha1.ConfigSpec, err error) {
+	rawConfig, composedDocuments, err := composeDocuments(documents)
+	if err != nil {
+		return config, err
+	}
+	composed = composed || composedDocuments
+
+	if version := GetAPIVersion(rawConfig); version != LatestAPIVersion {
+		rawConfig, err = Migrate(rawConfig, LatestAPIVersion)
+		if err != nil {
+			return config, err
+		}
+		log.Printf("Config %s uses apiVersion %s, upgrade it to %s running: config migrate --config %s", filepath, version, LatestAPIVersion, filepath)
+		composed = true
+	}
+	if len(overrides) > 0 {
+		rawConfig, err = applyOverrides(rawConfig, overrides)
+		if err != nil {
+			return config, fmt.Errorf("error overriding the config: %w", err)
+		}
+		composed = true
+	}
+	if composed {
+		content, err = yaml.Marshal(rawConfig)
+		if err != nil {
+			return config, err
+		}
+	}
+
+	config, err = UnmarshalStrict(content)
+	if err != nil {
+		return config, err
+	}
+
+	err = resolveSecrets(&config)
+	if err != nil {
+		return config, fmt.Errorf("error resolving secrets: %w", err)
+	}
+
+	Normalize(&config)
+	return config, nil
+}
+
+// GetAutoscalers returns the configuration of every autoscaler defined in the config.
+// When no list of autoscalers is defined, the config itself describes the only autoscaler
+func GetAutoscalers(config v1alpha1.ConfigSpec) []v1alpha1.ConfigSpec {
+	autoscalers := config.Autoscalers
+	if len(autoscalers) == 0 {
+		autoscalers = []v1alpha1.ConfigSpec{config}
+	}
+
+	// Name the autoscalers without name after their MIG
+	for i := range autoscalers {
+		if autoscalers[i].Name == "" {
+			autoscalers[i].Name = autoscalers[i].Infrastructure.GCP.MIGName
+		}
+		if autoscalers[i].Name == "" {
+			autoscalers[i].Name = fmt.Sprintf("autoscaler-%d", i)
+		}
+	}
+
+	return autoscalers
+}
+
+// GetAutoscalersByName returns the configuration of the autoscaler with the given name.
+// When the name is empty, every autoscaler is returned
+func GetAutoscalersByName(config v1alpha1.ConfigSpec, name string) ([]v1alpha1.ConfigSpec, error) {
+	autoscalers := GetAutoscalers(config)
+	if name == "" {
+		return autoscalers, nil
+	}
+
+	for _, autoscaler := range autoscalers {
+		if autoscaler.Name == name {
+			return []v1alpha1.ConfigSpec{autoscaler}, nil
+		}
+	}
+	return nil, fmt.Errorf("autoscaler %s not found in the config", name)
 }
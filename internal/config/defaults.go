@@ -0,0 +1,63 @@
+package config
+
+const (
+	defaultElasticsearchInsecureSkipVerify = false
+	defaultElasticsearchTLSMinVersion      = "1.2"
+	defaultPrometheusTLSMinVersion         = "1.2"
+	defaultDebugMode                       = false
+	defaultElasticsearchDrainTimeoutSec    = 600
+	defaultElasticsearchDrainPollSec       = 2
+	defaultElasticsearchDrainLockIndex     = "custom-vm-autoscaler-drain-locks"
+	defaultElasticsearchRequestTimeoutSec  = 30
+	defaultElasticsearchOnDrainTimeout     = "rollback"
+	defaultElasticsearchSnapshotNodes      = "drain"
+	defaultIgnorableIndicesTimeoutSec      = 300
+	defaultConcurrencyCheckPolicy          = "warn"
+	defaultShardRelocationSec              = 30
+	defaultReconciliationGraceSec          = 600
+	defaultMinHealthyMaxHeapPercent        = 85
+	defaultPrometheusTimeoutSec            = 10
+	defaultGCPOperationTimeoutSec          = 60
+	defaultGKEDrainTimeoutSec              = 600
+	defaultInstanceTemplateIntervalSec     = 600
+	defaultNotificationsTimeoutSec         = 10
+	defaultNotifyOnNoActionIntervalSec     = 3600
+	defaultHeartbeatTimeoutSec             = 10
+	defaultGCPRateLimitRequestsPerSecond   = 10
+	defaultGCPRateLimitBurst               = 20
+	defaultCooldownPeriodSec               = 60
+	defaultScaleDownCooldownPeriodSec      = 300
+	defaultRetryIntervalSec                = 30
+	defaultMaxRetryIntervalSec             = 300
+	defaultRetryMaxAttempts                = 3
+	defaultRetryInitialIntervalSec         = 1
+	defaultRetryMaxIntervalSec             = 30
+	defaultRetryMultiplier                 = 2
+	defaultCircuitBreakerFailureThreshold  = 5
+	defaultCircuitBreakerProbeIntervalSec  = 60
+	defaultAutohealingMaxHeapPercent       = 95
+	defaultAutohealingMaxDiskPercent       = 95
+	defaultAutohealingUnhealthyChecks      = 3
+	defaultScaleUpThreshold                = 1
+	defaultScaleDownAction                 = "delete"
+	defaultDeletionProtectionPolicy        = "skip"
+	defaultLeaseDurationSec                = 30
+	defaultRenewIntervalSec                = 10
+	defaultAdminAddress                    = ":8080"
+	defaultHealthAddress                   = ":8081"
+	defaultAlertRepeatedErrors             = 3
+	defaultAlertMaxSizeEvaluations         = 3
+	defaultApprovalsAddress                = ":8082"
+	defaultTriggersAddress                 = ":8083"
+	defaultControlPlaneAddress             = ":8084"
+	defaultScaleDownApprovalTimeoutSec     = 900
+	defaultScaleDownApprovalOnTimeout      = "cancel"
+	defaultWarmupJoinTimeoutSec            = 1800
+	defaultStartupProbePeriodSec           = 10
+	defaultStartupProbePath                = "/"
+	defaultStartupProbeScheme              = "http"
+	defaultCostCurrency                    = "USD"
+	defaultLogName                         = "custom-vm-autoscaler"
+	defaultPushgatewayJob                  = "custom-vm-autoscaler"
+	defaultStatsDPrefix                    = "custom_vm_autoscaler."
+)
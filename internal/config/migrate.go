@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// Versions of the config schema, from the oldest to the newest
+	APIVersionV1alpha1 = "v1alpha1"
+	APIVersionV1alpha2 = "v1alpha2"
+
+	// LatestAPIVersion is the version of the config schema read by the autoscaler
+	LatestAPIVersion = APIVersionV1alpha2
+)
+
+// migration upgrades the config from a version of the schema to the next one
+type migration struct {
+	from    string
+	to      string
+	migrate func(config yaml.MapSlice) yaml.MapSlice
+}
+
+// migrations are applied in order to upgrade the config to the latest version of the schema
+var migrations = []migration{
+	{from: APIVersionV1alpha1, to: APIVersionV1alpha2, migrate: migrateV1alpha1ToV1alpha2},
+}
+
+// GetAPIVersion returns the version of the schema of the config. Configs without it are considered v1alpha1
+func GetAPIVersion(config yaml.MapSlice) string {
+	if version, ok := getKey(config, "apiVersion").(string); ok && version != "" {
+		return version
+	}
+	return APIVersionV1alpha1
+}
+
+// Migrate upgrades the config to the given version of the schema, applying every migration in between
+func Migrate(config yaml.MapSlice, to string) (yaml.MapSlice, error) {
+	version := GetAPIVersion(config)
+	if !isKnownVersion(version) {
+		return nil, fmt.Errorf("unsupported apiVersion %s, expected %s or older", version, LatestAPIVersion)
+	}
+	if !isKnownVersion(to) {
+		return nil, fmt.Errorf("unsupported target apiVersion %s", to)
+	}
+
+	for _, m := range migrations {
+		if version == to {
+			break
+		}
+		if m.from != version {
+			continue
+		}
+		config = m.migrate(config)
+		config = setKey(config, "apiVersion", m.to)
+		version = m.to
+	}
+
+	if version != to {
+		return nil, fmt.Errorf("config can not be migrated from %s to %s", version, to)
+	}
+	return config, nil
+}
+
+// isKnownVersion checks if the version of the schema is supported
+func isKnownVersion(version string) bool {
+	if version == APIVersionV1alpha1 {
+		return true
+	}
+	for _, m := range migrations {
+		if m.to == version {
+			return true
+		}
+	}
+	return false
+}
+
+// migrateV1alpha1ToV1alpha2 renames scaledownCooldownPeriodSec to scaleDownCooldownPeriodSec,
+// in the root of the config, its defaults and every autoscaler
+func migrateV1alpha1ToV1alpha2(config yaml.MapSlice) yaml.MapSlice {
+	renameCooldown := func(autoscaler yaml.MapSlice) yaml.MapSlice {
+		section, ok := getKey(autoscaler, "autoscaler").(yaml.MapSlice)
+		if !ok {
+			return autoscaler
+		}
+		return setKey(autoscaler, "autoscaler", renameKey(section, "scaledownCooldownPeriodSec", "scaleDownCooldownPeriodSec"))
+	}
+
+	config = renameCooldown(config)
+	if defaults, ok := getKey(config, "defaults").(yaml.MapSlice); ok {
+		config = setKey(config, "defaults", renameCooldown(defaults))
+	}
+	if autoscalers, ok := getKey(config, "autoscalers").([]interface{}); ok {
+		for i, autoscaler := range autoscalers {
+			if autoscalerMap, ok := autoscaler.(yaml.MapSlice); ok {
+				autoscalers[i] = renameCooldown(autoscalerMap)
+			}
+		}
+	}
+	return config
+}
+
+// getKey returns the value of the key in the YAML map, or nil when missing
+func getKey(m yaml.MapSlice, key string) interface{} {
+	for _, item := range m {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+// setKey sets the value of the key in the YAML map. New keys are added at the beginning,
+// so the apiVersion is the first key of the migrated configs
+func setKey(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if item.Key == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(yaml.MapSlice{{Key: key, Value: value}}, m...)
+}
+
+// renameKey renames the key in the YAML map, keeping its position
+func renameKey(m yaml.MapSlice, from, to string) yaml.MapSlice {
+	for i, item := range m {
+		if item.Key == from {
+			m[i].Key = to
+		}
+	}
+	return m
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/gcpauth"
+)
+
+// Normalize loads the default values for the parameters not defined in the config, in its root and in every autoscaler,
+// so omitted fields never produce zero timeouts, sleeps or intervals
+func Normalize(config *v1alpha1.ConfigSpec) {
+
+	// Sections only read from the root of the config
+	if config.LeaderElection.LeaseDurationSec <= 0 {
+		config.LeaderElection.LeaseDurationSec = defaultLeaseDurationSec
+	}
+	if config.LeaderElection.RenewIntervalSec <= 0 {
+		config.LeaderElection.RenewIntervalSec = defaultRenewIntervalSec
+	}
+	if config.Admin.Address == "" {
+		config.Admin.Address = defaultAdminAddress
+	}
+	if config.Health.Address == "" {
+		config.Health.Address = defaultHealthAddress
+	}
+	if config.Approvals.Address == "" {
+		config.Approvals.Address = defaultApprovalsAddress
+	}
+	if config.Triggers.Address == "" {
+		config.Triggers.Address = defaultTriggersAddress
+	}
+	if config.ControlPlane.Address == "" {
+		config.ControlPlane.Address = defaultControlPlaneAddress
+	}
+	if config.GCPRateLimit.RequestsPerSecond <= 0 {
+		config.GCPRateLimit.RequestsPerSecond = defaultGCPRateLimitRequestsPerSecond
+	}
+	if config.GCPRateLimit.Burst <= 0 {
+		config.GCPRateLimit.Burst = defaultGCPRateLimitBurst
+	}
+	if config.Logging.CloudLogging.LogName == "" {
+		config.Logging.CloudLogging.LogName = defaultLogName
+	}
+	if config.Logging.Syslog.Tag == "" {
+		config.Logging.Syslog.Tag = defaultLogName
+	}
+	if config.Pushgateway.Job == "" {
+		config.Pushgateway.Job = defaultPushgatewayJob
+	}
+	if config.StatsD.Prefix == "" {
+		config.StatsD.Prefix = defaultStatsDPrefix
+	}
+
+	normalizeAutoscaler(config)
+	for i := range config.Autoscalers {
+		normalizeAutoscaler(&config.Autoscalers[i])
+	}
+}
+
+// normalizeAutoscaler loads the default values for the parameters of a single autoscaler not defined in the config
+func normalizeAutoscaler(config *v1alpha1.ConfigSpec) {
+	if config.Metrics.Prometheus.TimeoutSec <= 0 {
+		config.Metrics.Prometheus.TimeoutSec = defaultPrometheusTimeoutSec
+	}
+	if config.Metrics.Prometheus.TLSMinVersion == "" {
+		config.Metrics.Prometheus.TLSMinVersion = defaultPrometheusTLSMinVersion
+	}
+	if !config.Target.Elasticsearch.SSLInsecureSkipVerify {
+		config.Target.Elasticsearch.SSLInsecureSkipVerify = defaultElasticsearchInsecureSkipVerify
+	}
+	if config.Target.Elasticsearch.TLSMinVersion == "" {
+		config.Target.Elasticsearch.TLSMinVersion = defaultElasticsearchTLSMinVersion
+	}
+	if config.Target.Elasticsearch.DrainTimeoutSec <= 0 {
+		config.Target.Elasticsearch.DrainTimeoutSec = defaultElasticsearchDrainTimeoutSec
+	}
+	if config.Target.Elasticsearch.DrainPollIntervalSec <= 0 {
+		config.Target.Elasticsearch.DrainPollIntervalSec = defaultElasticsearchDrainPollSec
+	}
+	if config.Target.Elasticsearch.OnDrainTimeout == "" {
+		config.Target.Elasticsearch.OnDrainTimeout = defaultElasticsearchOnDrainTimeout
+	}
+	if config.Target.Elasticsearch.SnapshotNodesPolicy == "" {
+		config.Target.Elasticsearch.SnapshotNodesPolicy = defaultElasticsearchSnapshotNodes
+	}
+	if config.Target.Elasticsearch.ConcurrencyCheck.Policy == "" {
+		config.Target.Elasticsearch.ConcurrencyCheck.Policy = defaultConcurrencyCheckPolicy
+	}
+	if config.Target.Elasticsearch.ConcurrencyCheck.ShardRelocationSec <= 0 {
+		config.Target.Elasticsearch.ConcurrencyCheck.ShardRelocationSec = defaultShardRelocationSec
+	}
+	if config.Target.Elasticsearch.IgnorableIndices.TimeoutSec <= 0 {
+		config.Target.Elasticsearch.IgnorableIndices.TimeoutSec = defaultIgnorableIndicesTimeoutSec
+	}
+	if config.Target.Elasticsearch.DrainLockIndex == "" {
+		config.Target.Elasticsearch.DrainLockIndex = defaultElasticsearchDrainLockIndex
+	}
+	if config.Target.Elasticsearch.RequestTimeoutSec <= 0 {
+		config.Target.Elasticsearch.RequestTimeoutSec = defaultElasticsearchRequestTimeoutSec
+	}
+	if config.Target.Elasticsearch.MinHealthy.MaxHeapPercent <= 0 {
+		config.Target.Elasticsearch.MinHealthy.MaxHeapPercent = defaultMinHealthyMaxHeapPercent
+	}
+	if config.Target.Elasticsearch.Reconciliation.GraceSec <= 0 {
+		config.Target.Elasticsearch.Reconciliation.GraceSec = defaultReconciliationGraceSec
+	}
+	if config.Infrastructure.GCP.OperationTimeoutSec <= 0 {
+		config.Infrastructure.GCP.OperationTimeoutSec = defaultGCPOperationTimeoutSec
+	}
+	if config.Infrastructure.GCP.GKE.NodePool != "" && config.Infrastructure.GCP.GKE.DrainTimeoutSec <= 0 {
+		config.Infrastructure.GCP.GKE.DrainTimeoutSec = defaultGKEDrainTimeoutSec
+	}
+	if config.Infrastructure.GCP.InstanceTemplate.Name != "" && config.Infrastructure.GCP.InstanceTemplate.IntervalSec <= 0 {
+		config.Infrastructure.GCP.InstanceTemplate.IntervalSec = defaultInstanceTemplateIntervalSec
+	}
+	if config.Infrastructure.GCP.WorkloadIdentityFederation.Audience != "" && config.Infrastructure.GCP.WorkloadIdentityFederation.SubjectTokenType == "" {
+		config.Infrastructure.GCP.WorkloadIdentityFederation.SubjectTokenType = gcpauth.DefaultSubjectTokenType
+	}
+	if config.Notifications.TimeoutSec <= 0 {
+		config.Notifications.TimeoutSec = defaultNotificationsTimeoutSec
+	}
+	if config.Heartbeat.TimeoutSec <= 0 {
+		config.Heartbeat.TimeoutSec = defaultHeartbeatTimeoutSec
+	}
+	if config.Notifications.NotifyOnNoAction.IntervalSec <= 0 {
+		config.Notifications.NotifyOnNoAction.IntervalSec = defaultNotifyOnNoActionIntervalSec
+	}
+	if !config.Autoscaler.DebugMode {
+		config.Autoscaler.DebugMode = defaultDebugMode
+	}
+	// The debug mode skips the changes to both the infrastructure and the target
+	if config.Autoscaler.DebugMode {
+		config.Autoscaler.DryRunInfrastructure = true
+		config.Autoscaler.DryRunTarget = true
+	}
+	if config.Autoscaler.DefaultCooldownPeriodSec <= 0 {
+		config.Autoscaler.DefaultCooldownPeriodSec = defaultCooldownPeriodSec
+	}
+	// Conditions are evaluated as often as the cooldown after scaling when no interval is defined, as they used to
+	if config.Autoscaler.EvaluationIntervalSec <= 0 {
+		config.Autoscaler.EvaluationIntervalSec = config.Autoscaler.DefaultCooldownPeriodSec
+	}
+	if config.Autoscaler.ScaleDownCooldownPeriodSec <= 0 {
+		config.Autoscaler.ScaleDownCooldownPeriodSec = defaultScaleDownCooldownPeriodSec
+	}
+	if config.Autoscaler.RetryIntervalSec <= 0 {
+		config.Autoscaler.RetryIntervalSec = defaultRetryIntervalSec
+	}
+	if config.Autoscaler.MaxRetryIntervalSec <= 0 {
+		config.Autoscaler.MaxRetryIntervalSec = defaultMaxRetryIntervalSec
+	}
+	if config.Autoscaler.MaxRetryIntervalSec < config.Autoscaler.RetryIntervalSec {
+		config.Autoscaler.MaxRetryIntervalSec = config.Autoscaler.RetryIntervalSec
+	}
+	if config.Retry.MaxAttempts <= 0 {
+		config.Retry.MaxAttempts = defaultRetryMaxAttempts
+	}
+	if config.Retry.InitialIntervalSec <= 0 {
+		config.Retry.InitialIntervalSec = defaultRetryInitialIntervalSec
+	}
+	if config.Retry.MaxIntervalSec <= 0 {
+		config.Retry.MaxIntervalSec = defaultRetryMaxIntervalSec
+	}
+	if config.Retry.Multiplier < 1 {
+		config.Retry.Multiplier = defaultRetryMultiplier
+	}
+	if config.CircuitBreaker.FailureThreshold <= 0 {
+		config.CircuitBreaker.FailureThreshold = defaultCircuitBreakerFailureThreshold
+	}
+	if config.CircuitBreaker.ProbeIntervalSec <= 0 {
+		config.CircuitBreaker.ProbeIntervalSec = defaultCircuitBreakerProbeIntervalSec
+	}
+	if config.Autohealing.MaxHeapPercent <= 0 {
+		config.Autohealing.MaxHeapPercent = defaultAutohealingMaxHeapPercent
+	}
+	if config.Autohealing.MaxDiskPercent <= 0 {
+		config.Autohealing.MaxDiskPercent = defaultAutohealingMaxDiskPercent
+	}
+	if config.Autohealing.UnhealthyChecks <= 0 {
+		config.Autohealing.UnhealthyChecks = defaultAutohealingUnhealthyChecks
+	}
+	if config.Autoscaler.ScaleUpThreshold <= 0 {
+		config.Autoscaler.ScaleUpThreshold = defaultScaleUpThreshold
+	}
+	if config.Infrastructure.GCP.ScaleDownAction == "" {
+		config.Infrastructure.GCP.ScaleDownAction = defaultScaleDownAction
+	}
+	if config.Infrastructure.GCP.DeletionProtectionPolicy == "" {
+		config.Infrastructure.GCP.DeletionProtectionPolicy = defaultDeletionProtectionPolicy
+	}
+	if config.Notifications.Alerts.RepeatedErrors <= 0 {
+		config.Notifications.Alerts.RepeatedErrors = defaultAlertRepeatedErrors
+	}
+	if config.Notifications.Alerts.MaxSizeEvaluations <= 0 {
+		config.Notifications.Alerts.MaxSizeEvaluations = defaultAlertMaxSizeEvaluations
+	}
+	if config.Cost.Currency == "" {
+		config.Cost.Currency = defaultCostCurrency
+	}
+	if config.Autoscaler.ScaleDownApproval.TimeoutSec <= 0 {
+		config.Autoscaler.ScaleDownApproval.TimeoutSec = defaultScaleDownApprovalTimeoutSec
+	}
+	if config.Autoscaler.ScaleDownApproval.OnTimeout == "" {
+		config.Autoscaler.ScaleDownApproval.OnTimeout = defaultScaleDownApprovalOnTimeout
+	}
+	if config.Autoscaler.Warmup.JoinTimeoutSec <= 0 {
+		config.Autoscaler.Warmup.JoinTimeoutSec = defaultWarmupJoinTimeoutSec
+	}
+	if config.Autoscaler.StartupProbe.PeriodSec <= 0 {
+		config.Autoscaler.StartupProbe.PeriodSec = defaultStartupProbePeriodSec
+	}
+	if config.Autoscaler.StartupProbe.Path == "" {
+		config.Autoscaler.StartupProbe.Path = defaultStartupProbePath
+	}
+	if config.Autoscaler.StartupProbe.Scheme == "" {
+		config.Autoscaler.StartupProbe.Scheme = defaultStartupProbeScheme
+	}
+}
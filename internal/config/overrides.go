@@ -0,0 +1,188 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"custom-vm-autoscaler/api/v1alpha1"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// envOverridePrefix is the prefix of the environment variables overriding values of the config
+	envOverridePrefix = "AUTOSCALER_"
+
+	// envPathSeparator separates the keys of the path in the environment variables, like
+	// AUTOSCALER_AUTOSCALER__MAX_SIZE for autoscaler.maxSize. Variables without it are left for the expansion
+	// of the config
+	envPathSeparator = "__"
+)
+
+// Override is a value of the config set from the command line or the environment, replacing the one of the file
+type Override struct {
+	// Path are the keys to the value, with the index of the items in lists, like autoscalers.0.autoscaler.maxSize
+	Path  []string
+	Value interface{}
+
+	// Source is where the override comes from, to explain its errors
+	Source string
+}
+
+// overrides are applied to every config read after SetOverrides, including the remote configs reloaded
+var overrides []Override
+
+// SetOverrides parses the overrides of the --set flags, as path=value, and of the AUTOSCALER_ environment variables,
+// applied to every config read from now on. Values are parsed as YAML, and flags prevail over the environment
+func SetOverrides(flags []string, environ []string) error {
+	var parsed []Override
+
+	// Environment variables are sorted, so the overrides are applied in the same order every time
+	sort.Strings(environ)
+	for _, variable := range environ {
+		name, value, _ := strings.Cut(variable, "=")
+		if !strings.HasPrefix(name, envOverridePrefix) || !strings.Contains(name, envPathSeparator) {
+			continue
+		}
+		path, err := envPath(strings.TrimPrefix(name, envOverridePrefix))
+		if err != nil {
+			return fmt.Errorf("environment variable %s: %w", name, err)
+		}
+		override, err := newOverride(path, value, name)
+		if err != nil {
+			return err
+		}
+		parsed = append(parsed, override)
+	}
+
+	for _, flag := range flags {
+		key, value, found := strings.Cut(flag, "=")
+		if !found || key == "" {
+			return fmt.Errorf("--set %s: expected path=value", flag)
+		}
+		override, err := newOverride(strings.Split(key, "."), value, "--set "+key)
+		if err != nil {
+			return err
+		}
+		parsed = append(parsed, override)
+	}
+
+	overrides = parsed
+	return nil
+}
+
+// newOverride returns the override of the path, decoding its value as YAML so numbers, booleans and lists keep
+// their types
+func newOverride(path []string, value string, source string) (Override, error) {
+	var decoded interface{}
+	err := yaml.Unmarshal([]byte(value), &decoded)
+	if err != nil {
+		return Override{}, fmt.Errorf("%s: invalid value: %w", source, err)
+	}
+	return Override{Path: path, Value: decoded, Source: source}, nil
+}
+
+// envPath returns the keys of the config named by the environment variable, without its prefix. Every key is matched
+// against the fields of the config ignoring the case and the underscores, so AUTOSCALER__MAX_SIZE and
+// AUTOSCALER__MAXSIZE are both autoscaler.maxSize. The keys of maps are taken as is, in lower case
+func envPath(name string) ([]string, error) {
+	t := reflect.TypeOf(v1alpha1.ConfigSpec{})
+	var path []string
+	for _, segment := range strings.Split(name, envPathSeparator) {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			key, fieldType, ok := findYAMLField(t, segment)
+			if !ok {
+				return nil, fmt.Errorf("unknown key %s", segment)
+			}
+			path, t = append(path, key), fieldType
+		case reflect.Slice, reflect.Array:
+			if _, err := strconv.Atoi(segment); err != nil {
+				return nil, fmt.Errorf("%s is not the index of a list", segment)
+			}
+			path, t = append(path, segment), t.Elem()
+		case reflect.Map:
+			path, t = append(path, strings.ToLower(segment)), t.Elem()
+		default:
+			return nil, fmt.Errorf("%s has no key %s", strings.Join(path, "."), segment)
+		}
+	}
+	return path, nil
+}
+
+// findYAMLField returns the YAML key and the type of the field of the struct matching the segment, comparing them
+// in lower case and without underscores
+func findYAMLField(t reflect.Type, segment string) (string, reflect.Type, bool) {
+	normalized := strings.ToLower(strings.ReplaceAll(segment, "_", ""))
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		if strings.ToLower(name) == normalized {
+			return name, field.Type, true
+		}
+	}
+	return "", nil, false
+}
+
+// applyOverrides sets the values of the overrides in the raw config, creating the maps missing in their paths
+func applyOverrides(config yaml.MapSlice, overrides []Override) (yaml.MapSlice, error) {
+	for _, override := range overrides {
+		value, err := setPath(config, override.Path, override.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", override.Source, err)
+		}
+		config = value.(yaml.MapSlice)
+	}
+	return config, nil
+}
+
+// setPath returns the node with the value set at the path below it
+func setPath(node interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	key := path[0]
+
+	switch typed := node.(type) {
+	case nil:
+		child, err := setPath(nil, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		return yaml.MapSlice{{Key: key, Value: child}}, nil
+
+	case yaml.MapSlice:
+		child, err := setPath(getKey(typed, key), path[1:], value)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%w", key, err)
+		}
+		return setKeyInPlace(typed, key, child), nil
+
+	case []interface{}:
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index >= len(typed) {
+			return nil, fmt.Errorf("%s: not an index of the list of %d items", key, len(typed))
+		}
+		child, err := setPath(typed[index], path[1:], value)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%w", key, err)
+		}
+		typed[index] = child
+		return typed, nil
+
+	default:
+		return nil, fmt.Errorf("%s: the value is not a map nor a list", key)
+	}
+}
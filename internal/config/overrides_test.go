@@ -0,0 +1,105 @@
+package config
+
+import (
+	"slices"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestEnvPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "AUTOSCALER__MAX_SIZE", want: []string{"autoscaler", "maxSize"}},
+		{name: "AUTOSCALER__MAXSIZE", want: []string{"autoscaler", "maxSize"}},
+		{name: "METRICS__PROMETHEUS__HEADERS__X_SCOPE", want: []string{"metrics", "prometheus", "headers", "x_scope"}},
+		{name: "AUTOSCALERS__0__AUTOSCALER__MIN_SIZE", want: []string{"autoscalers", "0", "autoscaler", "minSize"}},
+		{name: "AUTOSCALER__UNKNOWN", wantErr: true},
+		{name: "AUTOSCALERS__FIRST__NAME", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := envPath(test.name)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("envPath() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("envPath() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSetOverrides(t *testing.T) {
+	defer func() { overrides = nil }()
+
+	err := SetOverrides([]string{"autoscaler.maxSize=20", "autoscaler.debugMode=false"},
+		[]string{"AUTOSCALER_AUTOSCALER__MAX_SIZE=15", "AUTOSCALER_TOKEN=secret", "INFRASTRUCTURE__GCP__PROJECT_ID=ignored"})
+	if err != nil {
+		t.Fatalf("SetOverrides() error = %v", err)
+	}
+
+	content := `
+autoscaler:
+  debugMode: true
+  maxSize: 10
+`
+	var raw yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(content), &raw); err != nil {
+		t.Fatalf("yaml.Unmarshal() error = %v", err)
+	}
+	raw, err = applyOverrides(raw, overrides)
+	if err != nil {
+		t.Fatalf("applyOverrides() error = %v", err)
+	}
+	got, err := yaml.Marshal(raw)
+	if err != nil {
+		t.Fatalf("yaml.Marshal() error = %v", err)
+	}
+	want := `autoscaler:
+  debugMode: false
+  maxSize: 20
+`
+	if string(got) != want {
+		t.Errorf("applyOverrides() = %s, want %s", got, want)
+	}
+
+	if err := SetOverrides([]string{"autoscaler.maxSize"}, nil); err == nil {
+		t.Errorf("SetOverrides() without value, want error")
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	content := `
+name: hot
+autoscalers:
+  - name: warm
+`
+	tests := []struct {
+		name     string
+		override Override
+		wantErr  bool
+	}{
+		{name: "missing maps", override: Override{Path: []string{"target", "elasticsearch", "maxRelocatingShards"}, Value: 2}},
+		{name: "item of a list", override: Override{Path: []string{"autoscalers", "0", "autoscaler", "maxSize"}, Value: 5}},
+		{name: "index out of the list", override: Override{Path: []string{"autoscalers", "1", "name"}, Value: "cold"}, wantErr: true},
+		{name: "key of a scalar", override: Override{Path: []string{"name", "first"}, Value: "cold"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var raw yaml.MapSlice
+			if err := yaml.Unmarshal([]byte(content), &raw); err != nil {
+				t.Fatalf("yaml.Unmarshal() error = %v", err)
+			}
+			_, err := applyOverrides(raw, []Override{test.override})
+			if (err != nil) != test.wantErr {
+				t.Errorf("applyOverrides() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	storage "google.golang.org/api/storage/v1"
+)
+
+const (
+	// Schemes of the remote locations supported for the config
+	schemeGCS   = "gs"
+	schemeS3    = "s3"
+	schemeHTTPS = "https"
+	schemeHTTP  = "http"
+
+	// remoteTimeout is the time allowed to fetch the config from a remote location
+	remoteTimeout = 30 * time.Second
+
+	// emptyPayloadHash is the SHA-256 of an empty body, signed in the S3 requests without body
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// IsRemote checks if the path of the config is a remote location: gs://, s3://, https:// or http://
+func IsRemote(path string) bool {
+	location, err := url.Parse(path)
+	if err != nil {
+		return false
+	}
+	switch location.Scheme {
+	case schemeGCS, schemeS3, schemeHTTPS, schemeHTTP:
+		return true
+	}
+	return false
+}
+
+// fetchRemote downloads the config from the remote location. When the ETag is the same as the given one,
+// the config has not changed and no content is returned
+func fetchRemote(path, etag string) (content []byte, newETag string, err error) {
+	location, err := url.Parse(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ctxConn, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	switch location.Scheme {
+	case schemeGCS:
+		return fetchGCS(ctxConn, location, etag)
+	case schemeS3:
+		return fetchS3(ctxConn, location, etag)
+	default:
+		req, err := http.NewRequestWithContext(ctxConn, http.MethodGet, path, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		return fetchHTTP(req, etag)
+	}
+}
+
+// fetchGCS downloads the config from a GCS object using the default credentials
+func fetchGCS(ctxConn context.Context, location *url.URL, etag string) ([]byte, string, error) {
+	service, err := storage.NewService(ctxConn)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	objectName := strings.TrimPrefix(location.Path, "/")
+	object, err := service.Objects.Get(location.Host, objectName).Context(ctxConn).Do()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get GCS object %s: %w", location, err)
+	}
+	if object.Etag == etag {
+		return nil, etag, nil
+	}
+
+	res, err := service.Objects.Get(location.Host, objectName).Generation(object.Generation).Context(ctxConn).Download()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download GCS object %s: %w", location, err)
+	}
+	defer res.Body.Close()
+
+	content, err := io.ReadAll(res.Body)
+	return content, object.Etag, err
+}
+
+// fetchS3 downloads the config from a S3 object. The request is signed with the credentials defined in the
+// environment variables AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN, and sent anonymously without them
+func fetchS3(ctxConn context.Context, location *url.URL, etag string) ([]byte, string, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	endpoint := fmt.Sprintf("https://%s.s3.%s.amazonaws.com%s", location.Host, region, encodeS3Path(location.Path))
+	req, err := http.NewRequestWithContext(ctxConn, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if accessKey := os.Getenv("AWS_ACCESS_KEY_ID"); accessKey != "" {
+		signS3Request(req, region, accessKey, os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN"), time.Now().UTC())
+	}
+	return fetchHTTP(req, etag)
+}
+
+// fetchHTTP executes the request, asking the server to answer without content when the ETag has not changed
+func fetchHTTP(req *http.Request, etag string) ([]byte, string, error) {
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified {
+		return nil, etag, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status %s fetching config from %s", res.Status, req.URL.Redacted())
+	}
+
+	content, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Servers without ETags are compared by content
+	newETag := res.Header.Get("ETag")
+	if newETag == "" {
+		sum := sha256.Sum256(content)
+		newETag = hex.EncodeToString(sum[:])
+		if newETag == etag {
+			return nil, etag, nil
+		}
+	}
+	return content, newETag, nil
+}
+
+// signS3Request signs the request with AWS Signature Version 4
+func signS3Request(req *http.Request, region, accessKey, secretKey, sessionToken string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": emptyPayloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if sessionToken != "" {
+		headers["x-amz-security-token"] = sessionToken
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalRequestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretKey), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the data with the key
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// encodeS3Path encodes every segment of the path of the object, as expected by the signature of S3
+func encodeS3Path(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		var encoded strings.Builder
+		for _, b := range []byte(segment) {
+			if ('A' <= b && b <= 'Z') || ('a' <= b && b <= 'z') || ('0' <= b && b <= '9') || b == '-' || b == '_' || b == '.' || b == '~' {
+				encoded.WriteByte(b)
+			} else {
+				fmt.Fprintf(&encoded, "%%%02X", b)
+			}
+		}
+		segments[i] = encoded.String()
+	}
+	return strings.Join(segments, "/")
+}
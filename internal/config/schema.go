@@ -0,0 +1,90 @@
+package config
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"reflect"
+	"strings"
+)
+
+// jsonSchemaDraft is the version of JSON Schema of the generated schema
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema returns the JSON Schema of the config, generated from ConfigSpec, so IDEs can validate the configs.
+// Unknown fields are not allowed, as the config is parsed strictly
+func Schema() map[string]interface{} {
+	configType := reflect.TypeOf(v1alpha1.ConfigSpec{})
+	definitions := map[string]interface{}{configType.Name(): true}
+	definitions[configType.Name()] = schemaForStruct(configType, definitions)
+
+	// The root is the config itself, which is also defined to be referenced by the list of autoscalers
+	schema := copySchema(definitions[configType.Name()].(map[string]interface{}))
+	schema["$schema"] = jsonSchemaDraft
+	schema["title"] = "custom-vm-autoscaler config " + LatestAPIVersion
+	schema["$defs"] = definitions
+	return schema
+}
+
+// schemaForType returns the schema of the Go type. Anonymous structs are inlined, and named structs are added
+// to the definitions and referenced, so recursive types like the list of autoscalers are supported.
+// Definitions being generated are marked with true until they are complete
+func schemaForType(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaForType(t.Elem(), definitions)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaForType(t.Elem(), definitions)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaForType(t.Elem(), definitions)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return schemaForStruct(t, definitions)
+		}
+		if _, ok := definitions[t.Name()]; !ok {
+			definitions[t.Name()] = true
+			definitions[t.Name()] = schemaForStruct(t, definitions)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// schemaForStruct returns the schema of the struct, with a property for every field decoded from the YAML
+func schemaForStruct(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		properties[name] = schemaForType(field.Type, definitions)
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// copySchema returns a shallow copy of the schema, so the root can be extended without changing its definition
+func copySchema(schema map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		copied[key] = value
+	}
+	return copied
+}
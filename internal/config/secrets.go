@@ -0,0 +1,129 @@
+package config
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/gcpauth"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	secretmanager "google.golang.org/api/secretmanager/v1"
+)
+
+const (
+	// secretManagerScheme prefixes the values of the config referencing a secret of GCP Secret Manager
+	secretManagerScheme = "gcpsm://"
+
+	// secretsCacheTTL is the time a secret is reused before being accessed again
+	secretsCacheTTL = 5 * time.Minute
+)
+
+// cachedSecret is a secret accessed before, and when it was accessed
+type cachedSecret struct {
+	value     string
+	fetchedAt time.Time
+}
+
+var (
+	// secretsCache holds the secrets accessed, by reference
+	secretsCache = map[string]cachedSecret{}
+
+	// secretsCacheMutex serializes the accesses to the cached secrets
+	secretsCacheMutex sync.Mutex
+)
+
+// resolveSecrets replaces the values of the config referencing a secret, e.g. gcpsm://projects/x/secrets/y/versions/latest,
+// with the value of the secret. They are accessed with the same credentials as the Compute client of every autoscaler
+func resolveSecrets(config *v1alpha1.ConfigSpec) error {
+	for i := range config.Autoscalers {
+		err := resolveSecretsInValue(reflect.ValueOf(&config.Autoscalers[i]).Elem(), config.Autoscalers[i].Infrastructure.GCP)
+		if err != nil {
+			return fmt.Errorf("autoscaler %s: %w", config.Autoscalers[i].Name, err)
+		}
+	}
+	return resolveSecretsInValue(reflect.ValueOf(config).Elem(), config.Infrastructure.GCP)
+}
+
+// resolveSecretsInValue walks the value replacing the strings referencing a secret
+func resolveSecretsInValue(value reflect.Value, gcp v1alpha1.GCPSpec) error {
+	switch value.Kind() {
+	case reflect.String:
+		if !strings.HasPrefix(value.String(), secretManagerScheme) || !value.CanSet() {
+			return nil
+		}
+		secret, err := accessSecret(value.String(), gcp)
+		if err != nil {
+			return err
+		}
+		value.SetString(secret)
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			err := resolveSecretsInValue(value.Field(i), gcp)
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			err := resolveSecretsInValue(value.Index(i), gcp)
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if value.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		for _, key := range value.MapKeys() {
+			reference := value.MapIndex(key).String()
+			if !strings.HasPrefix(reference, secretManagerScheme) {
+				continue
+			}
+			secret, err := accessSecret(reference, gcp)
+			if err != nil {
+				return err
+			}
+			value.SetMapIndex(key, reflect.ValueOf(secret))
+		}
+	}
+	return nil
+}
+
+// accessSecret returns the value of the referenced secret version, cached for a while
+func accessSecret(reference string, gcp v1alpha1.GCPSpec) (string, error) {
+	secretsCacheMutex.Lock()
+	defer secretsCacheMutex.Unlock()
+
+	if cached, ok := secretsCache[reference]; ok && time.Since(cached.fetchedAt) < secretsCacheTTL {
+		return cached.value, nil
+	}
+
+	ctxConn, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	opts, err := gcpauth.ClientOptions(gcp)
+	if err != nil {
+		return "", err
+	}
+	service, err := secretmanager.NewService(ctxConn, opts...)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Secret Manager client: %w", err)
+	}
+
+	name := strings.TrimPrefix(reference, secretManagerScheme)
+	res, err := service.Projects.Secrets.Versions.Access(name).Context(ctxConn).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to access secret %s: %w", name, err)
+	}
+	data, err := base64.StdEncoding.DecodeString(res.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode secret %s: %w", name, err)
+	}
+
+	secretsCache[reference] = cachedSecret{value: string(data), fetchedAt: time.Now()}
+	return string(data), nil
+}
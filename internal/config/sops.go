@@ -0,0 +1,58 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// sopsBinary is the command executed to decrypt the configs encrypted with SOPS
+	sopsBinary = "sops"
+
+	// sopsTimeout bounds the decryption, so a hanging SOPS (e.g. waiting for a KMS) does not block the startup
+	// nor the reloads of the config forever
+	sopsTimeout = time.Minute
+)
+
+// isSOPSEncrypted checks if the config was encrypted with SOPS, which adds its metadata in the sops key
+func isSOPSEncrypted(fileBytes []byte) bool {
+	var rawConfig yaml.MapSlice
+	err := yaml.Unmarshal(fileBytes, &rawConfig)
+	if err != nil {
+		return false
+	}
+
+	metadata, ok := getKey(rawConfig, "sops").(yaml.MapSlice)
+	return ok && getKey(metadata, "mac") != nil
+}
+
+// decryptSOPS decrypts the config with the SOPS binary, which reads the keys (age, GCP KMS, AWS KMS...)
+// from its usual environment variables, e.g. SOPS_AGE_KEY_FILE
+func decryptSOPS(fileBytes []byte) ([]byte, error) {
+	if _, err := exec.LookPath(sopsBinary); err != nil {
+		return nil, fmt.Errorf("config is encrypted with SOPS, but the %s binary was not found: %w", sopsBinary, err)
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(context.Background(), sopsTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctxTimeout, sopsBinary, "--decrypt", "--input-type", "yaml", "--output-type", "yaml", "/dev/stdin")
+	cmd.Stdin = bytes.NewReader(fileBytes)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if ctxTimeout.Err() != nil {
+		return nil, fmt.Errorf("failed to decrypt config with SOPS: timeout after %s", sopsTimeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt config with SOPS: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,77 @@
+package controlplane
+
+import (
+	controlplanev1 "custom-vm-autoscaler/api/controlplane/v1"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// toTimestamp converts the time to its message, leaving zero times unset
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// toDecision converts the decision to its message. The samples of the metrics are left out, their values are kept
+func toDecision(decision v1alpha1.Decision) *controlplanev1.Decision {
+	return &controlplanev1.Decision{
+		Time:            toTimestamp(decision.Time),
+		Autoscaler:      decision.Autoscaler,
+		Action:          decision.Action,
+		Trigger:         decision.Trigger,
+		Outcome:         decision.Outcome,
+		Reason:          decision.Reason,
+		Condition:       decision.Condition,
+		MetricValues:    decision.MetricValues,
+		Mig:             decision.MIG,
+		Instance:        decision.Instance,
+		PreviousSize:    decision.PreviousSize,
+		Size:            decision.Size,
+		DurationMs:      decision.DurationMs,
+		Error:           decision.Error,
+		HourlyCostDelta: decision.HourlyCostDelta,
+	}
+}
+
+// toPause converts the pause to its message, nil when not paused
+func toPause(pause *v1alpha1.Pause) *controlplanev1.Pause {
+	if pause == nil {
+		return nil
+	}
+	return &controlplanev1.Pause{
+		Reason:   pause.Reason,
+		PausedAt: toTimestamp(pause.PausedAt),
+		Until:    toTimestamp(pause.Until),
+	}
+}
+
+// toOperation converts the operation in flight to its message, nil when there is none
+func toOperation(operation *v1alpha1.Operation) *controlplanev1.Operation {
+	if operation == nil {
+		return nil
+	}
+	return &controlplanev1.Operation{
+		Type:      operation.Type,
+		Phase:     operation.Phase,
+		Mig:       operation.MIG,
+		Instance:  operation.Instance,
+		StartedAt: toTimestamp(operation.StartedAt),
+	}
+}
+
+// toDrainProgress converts the drain in progress to its message, nil when there is none
+func toDrainProgress(drain *v1alpha1.DrainProgress) *controlplanev1.DrainProgress {
+	if drain == nil {
+		return nil
+	}
+	return &controlplanev1.DrainProgress{
+		Node:            drain.Node,
+		StartedAt:       toTimestamp(drain.StartedAt),
+		InitialShards:   int32(drain.InitialShards),
+		RemainingShards: int32(drain.RemainingShards),
+	}
+}
@@ -0,0 +1,54 @@
+package controlplane
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"sync"
+)
+
+// subscriberBuffer is the number of decisions queued for a subscriber not reading them yet. A subscriber falling
+// further behind is dropped, so a slow client never blocks the autoscalers
+const subscriberBuffer = 100
+
+var (
+	// subscribers receive the decisions of every autoscaler in the process as they are recorded
+	subscribers = map[chan v1alpha1.Decision]struct{}{}
+
+	// subscribersMutex guards the subscribers, shared by every autoscaler in the process
+	subscribersMutex sync.Mutex
+)
+
+// RecordDecision sends the decision to the subscribers of the stream of decisions. The channel of the subscribers
+// whose queue is full is closed, as they would miss decisions otherwise
+func RecordDecision(decision v1alpha1.Decision) {
+	subscribersMutex.Lock()
+	defer subscribersMutex.Unlock()
+
+	for decisions := range subscribers {
+		select {
+		case decisions <- decision:
+		default:
+			delete(subscribers, decisions)
+			close(decisions)
+		}
+	}
+}
+
+// subscribe returns the channel receiving the decisions recorded from now on, and the function to stop receiving
+// them. The channel is closed when the subscriber falls behind
+func subscribe() (<-chan v1alpha1.Decision, func()) {
+	decisions := make(chan v1alpha1.Decision, subscriberBuffer)
+
+	subscribersMutex.Lock()
+	subscribers[decisions] = struct{}{}
+	subscribersMutex.Unlock()
+
+	return decisions, func() {
+		subscribersMutex.Lock()
+		defer subscribersMutex.Unlock()
+
+		if _, ok := subscribers[decisions]; ok {
+			delete(subscribers, decisions)
+			close(decisions)
+		}
+	}
+}
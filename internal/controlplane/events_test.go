@@ -0,0 +1,57 @@
+package controlplane
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"testing"
+)
+
+func TestRecordDecision(t *testing.T) {
+	decisions, unsubscribe := subscribe()
+	defer unsubscribe()
+
+	RecordDecision(v1alpha1.Decision{Autoscaler: "es", Action: v1alpha1.DecisionScaleUp})
+	select {
+	case decision := <-decisions:
+		if decision.Autoscaler != "es" || decision.Action != v1alpha1.DecisionScaleUp {
+			t.Errorf("received %+v, want the scale up of es", decision)
+		}
+	default:
+		t.Fatal("the decision recorded was not received")
+	}
+}
+
+func TestRecordDecisionDropsSlowSubscribers(t *testing.T) {
+	slow, unsubscribeSlow := subscribe()
+	defer unsubscribeSlow()
+	fast, unsubscribeFast := subscribe()
+	defer unsubscribeFast()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		RecordDecision(v1alpha1.Decision{Autoscaler: "es", Action: v1alpha1.DecisionNone})
+		if i < subscriberBuffer {
+			<-fast
+		}
+	}
+
+	received := 0
+	for range slow {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("slow subscriber received %d decisions before being dropped, want %d", received, subscriberBuffer)
+	}
+	if _, ok := <-fast; !ok {
+		t.Error("fast subscriber was dropped")
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	decisions, unsubscribe := subscribe()
+	unsubscribe()
+	unsubscribe()
+
+	RecordDecision(v1alpha1.Decision{Autoscaler: "es", Action: v1alpha1.DecisionScaleDown})
+	if _, ok := <-decisions; ok {
+		t.Error("decision received after unsubscribing")
+	}
+}
@@ -0,0 +1,314 @@
+package controlplane
+
+import (
+	"context"
+	"crypto/subtle"
+	controlplanev1 "custom-vm-autoscaler/api/controlplane/v1"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/google"
+	"custom-vm-autoscaler/internal/leader"
+	"custom-vm-autoscaler/internal/state"
+	"custom-vm-autoscaler/internal/version"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Server exposes the gRPC API to inspect and control the autoscalers programmatically, as the admin API does, and
+// streams their decisions as they are taken
+type Server struct {
+	controlplanev1.UnimplementedControlPlaneServer
+
+	address     string
+	token       string
+	autoscalers []*v1alpha1.Context
+	elector     *leader.Elector
+}
+
+// NewServer creates the control plane API for the autoscalers, configured from the root of the config
+func NewServer(config *v1alpha1.ConfigSpec, autoscalers []*v1alpha1.Context, elector *leader.Elector) (*Server, error) {
+	if config.ControlPlane.Token == "" {
+		return nil, fmt.Errorf("token is required for the control plane API")
+	}
+
+	return &Server{
+		address:     config.ControlPlane.Address,
+		token:       config.ControlPlane.Token,
+		autoscalers: autoscalers,
+		elector:     elector,
+	}, nil
+}
+
+// Run serves the control plane API until it fails
+func (s *Server) Run() error {
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return err
+	}
+
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctxCall context.Context, request interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			err := s.authenticate(ctxCall)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctxCall, request)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			err := s.authenticate(stream.Context())
+			if err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	)
+	controlplanev1.RegisterControlPlaneServer(server, s)
+
+	log.Printf("Starting control plane API on %s", s.address)
+	return server.Serve(listener)
+}
+
+// authenticate rejects the calls without the bearer token configured in the authorization metadata
+func (s *Server) authenticate(ctxCall context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctxCall)
+	values := md.Get("authorization")
+	if len(values) != 1 || subtle.ConstantTimeCompare([]byte(values[0]), []byte("Bearer "+s.token)) != 1 {
+		return status.Error(codes.Unauthenticated, "invalid or missing bearer token")
+	}
+	return nil
+}
+
+// selectAutoscalers returns the autoscaler named, or all of them when the name is empty
+func (s *Server) selectAutoscalers(name string) ([]*v1alpha1.Context, error) {
+	if name == "" {
+		return s.autoscalers, nil
+	}
+
+	for _, ctx := range s.autoscalers {
+		if ctx.Config.Name == name {
+			return []*v1alpha1.Context{ctx}, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "autoscaler %s not found", name)
+}
+
+// GetStatus returns the status of the autoscalers selected, as the status endpoint of the admin API does
+func (s *Server) GetStatus(_ context.Context, request *controlplanev1.GetStatusRequest) (*controlplanev1.GetStatusResponse, error) {
+	autoscalers, err := s.selectAutoscalers(request.GetAutoscaler())
+	if err != nil {
+		return nil, err
+	}
+
+	response := &controlplanev1.GetStatusResponse{}
+	for _, ctx := range autoscalers {
+		autoscalerStatus := &controlplanev1.AutoscalerStatus{
+			Name:    ctx.Config.Name,
+			Leader:  s.elector.IsLeader(),
+			Version: version.String(),
+		}
+
+		migSizes, currentSize, minSize, maxSize, err := google.GetMIGSizes(ctx)
+		if err != nil {
+			autoscalerStatus.SizeError = err.Error()
+		}
+		autoscalerStatus.Migs, autoscalerStatus.CurrentSize, autoscalerStatus.MinSize, autoscalerStatus.MaxSize = migSizes, currentSize, minSize, maxSize
+
+		nextScaling, err := state.GetNextScaling(ctx, time.Now())
+		if err != nil {
+			autoscalerStatus.NextScalingError = err.Error()
+		}
+		autoscalerStatus.ScaleUpAt = toTimestamp(nextScaling.ScaleUpAt)
+		autoscalerStatus.ScaleDownAt = toTimestamp(nextScaling.ScaleDownAt)
+		autoscalerStatus.NextScalingReason = nextScaling.Reason
+
+		ctx.Mutex.Lock()
+		if ctx.LastDecision != nil {
+			autoscalerStatus.LastDecision = toDecision(*ctx.LastDecision)
+		}
+		autoscalerStatus.CooldownRemainingSec = int32(max(0, time.Until(ctx.State.CooldownUntil).Seconds()))
+		autoscalerStatus.Pause = toPause(ctx.State.Pause)
+		autoscalerStatus.InFlightOperation = toOperation(ctx.State.InFlightOperation)
+		autoscalerStatus.Drain = toDrainProgress(ctx.Drain)
+		ctx.Mutex.Unlock()
+
+		response.Autoscalers = append(response.Autoscalers, autoscalerStatus)
+	}
+
+	return response, nil
+}
+
+// history returns the last scaling actions executed by the autoscalers, oldest first
+func history(autoscalers []*v1alpha1.Context) []v1alpha1.Decision {
+	var decisions []v1alpha1.Decision
+	for _, ctx := range autoscalers {
+		ctx.Mutex.Lock()
+		decisions = append(decisions, ctx.History...)
+		ctx.Mutex.Unlock()
+	}
+
+	slices.SortStableFunc(decisions, func(a, b v1alpha1.Decision) int {
+		return a.Time.Compare(b.Time)
+	})
+	return decisions
+}
+
+// GetHistory returns the last scaling actions executed by the autoscalers selected, oldest first
+func (s *Server) GetHistory(_ context.Context, request *controlplanev1.GetHistoryRequest) (*controlplanev1.GetHistoryResponse, error) {
+	autoscalers, err := s.selectAutoscalers(request.GetAutoscaler())
+	if err != nil {
+		return nil, err
+	}
+
+	response := &controlplanev1.GetHistoryResponse{}
+	for _, decision := range history(autoscalers) {
+		response.Decisions = append(response.Decisions, toDecision(decision))
+	}
+	return response, nil
+}
+
+// Pause pauses the autoscalers selected, until resumed or for the TTL requested
+func (s *Server) Pause(_ context.Context, request *controlplanev1.PauseRequest) (*controlplanev1.PauseResponse, error) {
+	autoscalers, err := s.selectAutoscalers(request.GetAutoscaler())
+	if err != nil {
+		return nil, err
+	}
+
+	pause := &v1alpha1.Pause{
+		Reason:   request.GetReason(),
+		PausedAt: time.Now(),
+	}
+	if request.GetTtlSec() > 0 {
+		pause.Until = pause.PausedAt.Add(time.Duration(request.GetTtlSec()) * time.Second)
+	}
+
+	for _, ctx := range autoscalers {
+		err = state.SetPause(ctx, pause)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "error pausing autoscaler %s: %v", ctx.Config.Name, err)
+		}
+		log.Printf("Paused autoscaler %s from the control plane API", ctx.Config.Name)
+	}
+
+	return &controlplanev1.PauseResponse{}, nil
+}
+
+// Resume resumes the autoscalers selected
+func (s *Server) Resume(_ context.Context, request *controlplanev1.ResumeRequest) (*controlplanev1.ResumeResponse, error) {
+	autoscalers, err := s.selectAutoscalers(request.GetAutoscaler())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ctx := range autoscalers {
+		err = state.SetPause(ctx, nil)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "error resuming autoscaler %s: %v", ctx.Config.Name, err)
+		}
+		log.Printf("Resumed autoscaler %s from the control plane API", ctx.Config.Name)
+	}
+
+	return &controlplanev1.ResumeResponse{}, nil
+}
+
+// Scale enqueues the scaling action in the autoscaler selected, which executes it asynchronously. The emergency
+// scale up to the maximum size requires the reason of the incident
+func (s *Server) Scale(_ context.Context, request *controlplanev1.ScaleRequest) (*controlplanev1.ScaleResponse, error) {
+	actionRequest := v1alpha1.ActionRequest{Reason: "Requested from the control plane API"}
+	if request.GetReason() != "" {
+		actionRequest.Reason += ": " + request.GetReason()
+	}
+	switch request.GetAction() {
+	case controlplanev1.ScaleAction_SCALE_ACTION_SCALE_UP:
+		actionRequest.Action = v1alpha1.DecisionScaleUp
+	case controlplanev1.ScaleAction_SCALE_ACTION_SCALE_DOWN:
+		actionRequest.Action = v1alpha1.DecisionScaleDown
+	case controlplanev1.ScaleAction_SCALE_ACTION_SCALE_TO_MAX:
+		if strings.TrimSpace(request.GetReason()) == "" {
+			return nil, status.Error(codes.InvalidArgument, "the reason of the emergency scale up is required")
+		}
+		actionRequest = v1alpha1.ActionRequest{
+			Action: v1alpha1.ActionScaleToMax,
+			Reason: "Emergency requested from the control plane API: " + request.GetReason(),
+		}
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown scaling action %s", request.GetAction())
+	}
+
+	autoscalers, err := s.selectAutoscalers(request.GetAutoscaler())
+	if err != nil {
+		return nil, err
+	}
+	if len(autoscalers) != 1 {
+		return nil, status.Error(codes.InvalidArgument, "the autoscaler is required when several autoscalers are running")
+	}
+	if !s.elector.IsLeader() {
+		return nil, status.Error(codes.Unavailable, "this replica is not the leader")
+	}
+
+	ctx := autoscalers[0]
+	select {
+	case ctx.Requests <- actionRequest:
+		log.Printf("Requested %s for autoscaler %s from the control plane API. %s", actionRequest.Action, ctx.Config.Name, actionRequest.Reason)
+		return &controlplanev1.ScaleResponse{}, nil
+	default:
+		return nil, status.Error(codes.Aborted, "another scaling action is already pending")
+	}
+}
+
+// WatchDecisions streams the decisions of the autoscalers selected until the call is cancelled. Clients falling
+// behind get a ResourceExhausted error, and may call again including the history to catch up
+func (s *Server) WatchDecisions(request *controlplanev1.WatchDecisionsRequest, stream grpc.ServerStreamingServer[controlplanev1.Decision]) error {
+	autoscalers, err := s.selectAutoscalers(request.GetAutoscaler())
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool, len(autoscalers))
+	for _, ctx := range autoscalers {
+		names[ctx.Config.Name] = true
+	}
+
+	// Subscribe before reading the history, so no decision is missed in between. The decisions already sent with
+	// the history are skipped afterwards
+	decisions, unsubscribe := subscribe()
+	defer unsubscribe()
+
+	sent := map[string]time.Time{}
+	if request.GetIncludeHistory() {
+		for _, decision := range history(autoscalers) {
+			err = stream.Send(toDecision(decision))
+			if err != nil {
+				return err
+			}
+			sent[decision.Autoscaler] = decision.Time
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case decision, ok := <-decisions:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "the decisions were not read fast enough, some were dropped")
+			}
+			if !names[decision.Autoscaler] || !decision.Time.After(sent[decision.Autoscaler]) {
+				continue
+			}
+			if decision.Action == v1alpha1.DecisionNone && !request.GetIncludeIdle() {
+				continue
+			}
+			err = stream.Send(toDecision(decision))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
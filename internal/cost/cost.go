@@ -0,0 +1,77 @@
+package cost
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"errors"
+	"fmt"
+)
+
+// hoursPerMonth is the average number of hours in a month, used by Google Cloud to estimate monthly prices
+const hoursPerMonth = 730
+
+// ErrBudgetExceeded is returned when scaling up would exceed the monthly budget of the autoscaler
+var ErrBudgetExceeded = errors.New("monthly budget exceeded")
+
+// defaultPrices are the on-demand hourly prices in USD of common machine types in us-central1.
+// They can be overridden, or completed with other machine types, in the config
+var defaultPrices = map[string]float64{
+	"e2-standard-2":  0.067006,
+	"e2-standard-4":  0.134012,
+	"e2-standard-8":  0.268024,
+	"e2-standard-16": 0.536048,
+	"e2-standard-32": 1.072096,
+	"e2-highmem-2":   0.090416,
+	"e2-highmem-4":   0.180832,
+	"e2-highmem-8":   0.361664,
+	"e2-highmem-16":  0.723328,
+	"n1-standard-1":  0.0475,
+	"n1-standard-2":  0.095,
+	"n1-standard-4":  0.19,
+	"n1-standard-8":  0.38,
+	"n1-standard-16": 0.76,
+	"n2-standard-2":  0.097118,
+	"n2-standard-4":  0.194236,
+	"n2-standard-8":  0.388472,
+	"n2-standard-16": 0.776944,
+	"n2-standard-32": 1.553888,
+	"n2-highmem-2":   0.131014,
+	"n2-highmem-4":   0.262028,
+	"n2-highmem-8":   0.524056,
+	"n2-highmem-16":  1.048112,
+	"n2-highmem-32":  2.096224,
+}
+
+// GetHourlyPrice returns the hourly price of the machine type, looking first in the prices defined in the config
+func GetHourlyPrice(ctx *v1alpha1.Context, machineType string) (float64, error) {
+	if price, ok := ctx.Config.Cost.Prices[machineType]; ok {
+		return price, nil
+	}
+	if price, ok := defaultPrices[machineType]; ok {
+		return price, nil
+	}
+	return 0, fmt.Errorf("unknown price for machine type %s, define it in cost.prices", machineType)
+}
+
+// CheckBudget checks that the monthly cost estimated from the hourly cost does not exceed the monthly budget, if any
+func CheckBudget(ctx *v1alpha1.Context, hourlyCost float64) error {
+	budget := ctx.Config.Cost.MonthlyBudget
+	if budget <= 0 {
+		return nil
+	}
+
+	monthlyCost := hourlyCost * hoursPerMonth
+	if monthlyCost > budget {
+		return fmt.Errorf("%w: estimated monthly cost would be %s, over the budget of %s", ErrBudgetExceeded, Format(ctx, monthlyCost), Format(ctx, budget))
+	}
+	return nil
+}
+
+// Format returns the amount with the currency of the autoscaler
+func Format(ctx *v1alpha1.Context, amount float64) string {
+	return fmt.Sprintf("%.2f %s", amount, ctx.Config.Cost.Currency)
+}
+
+// FormatHourlyDelta returns the difference of the hourly cost with its sign and the currency of the autoscaler
+func FormatHourlyDelta(ctx *v1alpha1.Context, delta float64) string {
+	return fmt.Sprintf("%+.4f %s/h", delta, ctx.Config.Cost.Currency)
+}
@@ -0,0 +1,66 @@
+package decision
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"math"
+	"slices"
+	"sort"
+)
+
+// Condition is a scaling condition of the autoscaler, with the nodes added or removed when it is met.
+// Step is 0 when the default step of the limits applies
+type Condition struct {
+	Name     string
+	Query    string
+	Step     int32
+	Priority int
+
+	// Threshold sizes the step from the values of the condition when it is not 0, up to MaxStep when it is not 0
+	Threshold float64
+	MaxStep   int32
+}
+
+// UpConditions returns the up conditions of the autoscaler in the order they are evaluated
+func UpConditions(config *v1alpha1.ConfigSpec) []Condition {
+	return sortConditions(config.Metrics.Prometheus.UpConditions, config.Metrics.Prometheus.UpCondition)
+}
+
+// DownConditions returns the down conditions of the autoscaler in the order they are evaluated
+func DownConditions(config *v1alpha1.ConfigSpec) []Condition {
+	return sortConditions(config.Metrics.Prometheus.DownConditions, config.Metrics.Prometheus.DownCondition)
+}
+
+// sortConditions sorts the named conditions by priority, highest first, keeping the order of the config
+// between equal priorities. The single condition, if any, is named after its query and has priority 0
+func sortConditions(specs []v1alpha1.ConditionSpec, query string) []Condition {
+	conditions := make([]Condition, 0, len(specs)+1)
+	for _, spec := range specs {
+		conditions = append(conditions, Condition{Name: spec.Name, Query: spec.Query, Step: int32(spec.Step), Priority: spec.Priority,
+			Threshold: spec.Threshold, MaxStep: int32(spec.MaxStep)})
+	}
+	if query != "" {
+		conditions = append(conditions, Condition{Name: query, Query: query})
+	}
+	sort.SliceStable(conditions, func(i, j int) bool { return conditions[i].Priority > conditions[j].Priority })
+	return conditions
+}
+
+// ObservedStep returns the nodes added by the condition met with the given values. Conditions with a threshold add
+// ceil(overload ratio * size) nodes, the overload ratio being how far the highest value exceeds the threshold relative
+// to it, so big spikes are absorbed in a single action. The step is at least one node, and is bounded by MaxStep and
+// by the room left up to maxSize. Conditions without threshold, or without values, return their fixed Step
+func (c Condition) ObservedStep(size, maxSize int32, values []float64) int32 {
+	if c.Threshold <= 0 || len(values) == 0 {
+		return c.Step
+	}
+	// The overload is rounded first, so float errors like 0.9/0.6 do not add a node
+	overload := math.Round((slices.Max(values)-c.Threshold)/c.Threshold*1e6) / 1e6
+	step := int32(max(math.Ceil(overload*float64(size)), 1))
+	if c.MaxStep > 0 {
+		step = min(step, c.MaxStep)
+	}
+	if room := maxSize - size; room > 0 {
+		step = min(step, room)
+	}
+	return step
+}
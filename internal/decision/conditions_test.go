@@ -0,0 +1,109 @@
+package decision
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"slices"
+	"testing"
+)
+
+func TestConditions(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		specs     []v1alpha1.ConditionSpec
+		wantNames []string
+	}{
+		{
+			name:      "single condition",
+			query:     "high_load",
+			wantNames: []string{"high_load"},
+		},
+		{
+			name:  "named conditions by priority",
+			query: "",
+			specs: []v1alpha1.ConditionSpec{
+				{Name: "cpu", Query: "high_cpu", Priority: 1},
+				{Name: "queue", Query: "long_queue", Priority: 10},
+				{Name: "heap", Query: "high_heap", Priority: 1},
+			},
+			wantNames: []string{"queue", "cpu", "heap"},
+		},
+		{
+			name:  "single condition after the named ones with the same priority",
+			query: "high_load",
+			specs: []v1alpha1.ConditionSpec{
+				{Name: "fallback", Query: "any_load", Priority: -1},
+				{Name: "cpu", Query: "high_cpu"},
+				{Name: "queue", Query: "long_queue", Priority: 10},
+			},
+			wantNames: []string{"queue", "cpu", "high_load", "fallback"},
+		},
+		{
+			name:      "no conditions",
+			wantNames: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &v1alpha1.ConfigSpec{}
+			config.Metrics.Prometheus.UpCondition = test.query
+			config.Metrics.Prometheus.UpConditions = test.specs
+			config.Metrics.Prometheus.DownCondition = test.query
+			config.Metrics.Prometheus.DownConditions = test.specs
+
+			for _, conditions := range [][]Condition{UpConditions(config), DownConditions(config)} {
+				names := []string{}
+				for _, condition := range conditions {
+					names = append(names, condition.Name)
+				}
+				if !slices.Equal(names, test.wantNames) {
+					t.Errorf("got conditions %v, want %v", names, test.wantNames)
+				}
+			}
+		})
+	}
+}
+
+func TestConditionSteps(t *testing.T) {
+	config := &v1alpha1.ConfigSpec{}
+	config.Metrics.Prometheus.UpCondition = "high_load"
+	config.Metrics.Prometheus.UpConditions = []v1alpha1.ConditionSpec{{Name: "spike", Query: "spike", Step: 4, Priority: 1}}
+
+	got := UpConditions(config)
+	want := []Condition{
+		{Name: "spike", Query: "spike", Step: 4, Priority: 1},
+		{Name: "high_load", Query: "high_load"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got conditions %+v, want %+v", got, want)
+	}
+}
+
+func TestObservedStep(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+		size      int32
+		maxSize   int32
+		values    []float64
+		want      int32
+	}{
+		{name: "without threshold", condition: Condition{Step: 2}, size: 10, maxSize: 30, values: []float64{0.9}, want: 2},
+		{name: "without values", condition: Condition{Step: 2, Threshold: 0.6}, size: 10, maxSize: 30, want: 2},
+		{name: "proportional to the overload", condition: Condition{Threshold: 0.6}, size: 10, maxSize: 30, values: []float64{0.7, 0.9}, want: 5},
+		{name: "at least one node", condition: Condition{Threshold: 0.6}, size: 10, maxSize: 30, values: []float64{0.6}, want: 1},
+		{name: "bounded by the maximum step", condition: Condition{Threshold: 0.6, MaxStep: 3}, size: 10, maxSize: 30, values: []float64{0.9}, want: 3},
+		{name: "bounded by the maximum size", condition: Condition{Threshold: 0.6}, size: 10, maxSize: 12, values: []float64{0.9}, want: 2},
+		{name: "at the maximum size", condition: Condition{Threshold: 0.6}, size: 10, maxSize: 10, values: []float64{0.9}, want: 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.condition.ObservedStep(test.size, test.maxSize, test.values)
+			if got != test.want {
+				t.Errorf("ObservedStep() = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
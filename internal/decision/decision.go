@@ -0,0 +1,140 @@
+package decision
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/maintenance"
+	"fmt"
+	"time"
+)
+
+const (
+	// Triggers of the decisions taken by the autoscaler
+	TriggerCondition   = "condition"
+	TriggerManual      = "manual"
+	TriggerPause       = "pause"
+	TriggerMaintenance = "maintenance"
+	TriggerCircuit     = "circuit-breaker"
+	TriggerWarmup      = "warmup"
+	TriggerRelocation  = "relocation"
+	TriggerReplication = "replication"
+	TriggerEmergency   = "emergency"
+	TriggerQuarantine  = "quarantine"
+	TriggerLimits      = "limits"
+	TriggerInterlock   = "interlock"
+
+	TriggerIndexOperations = "index-operations"
+	TriggerMinHealthy      = "min-healthy"
+)
+
+// Input is everything the decision is taken from, gathered by the caller from the state, the maintenance windows,
+// Prometheus and the target before deciding
+type Input struct {
+	Now time.Time
+
+	// Pause is the pause of the autoscaler, if any. Expired pauses are ignored
+	Pause *v1alpha1.Pause
+
+	// MaintenanceWindow is the maintenance window in progress, if any
+	MaintenanceWindow *v1alpha1.MaintenanceWindowSpec
+
+	UpCondition   bool
+	DownCondition bool
+
+	// DownConditionName names the down condition met in the reasons deferring it. The downCondition of the config
+	// is named when it is empty
+	DownConditionName string
+
+	// Guard defers the scale downs when its reason is set, like while the new nodes are warming up
+	Guard Guard
+
+	// Size is the current size of all the MIGs, and Limits the scaling limits applied to it, with the steps
+	// of the conditions met
+	Size   int32
+	Limits Limits
+}
+
+// Guard is the reason deferring the scale downs, with the trigger recorded in the decision
+type Guard struct {
+	Trigger string
+	Reason  string
+}
+
+// Result is the decision taken
+type Result struct {
+	// Action is v1alpha1.DecisionScaleUp, v1alpha1.DecisionScaleDown or v1alpha1.DecisionNone
+	Action  string
+	Trigger string
+
+	// Reason explains why no scaling action is taken. It is empty for the scaling actions
+	Reason string
+
+	// CooldownSec is the time to wait before the next evaluation: the cooldown after the scaling action,
+	// or the evaluation interval when nothing is done
+	CooldownSec int
+}
+
+// Decide takes the scaling decision of the autoscaler, without side effects. The pause prevails over the maintenance
+// windows, and these over the conditions. The up condition prevails over the down one, which is only acted on when
+// no maintenance window nor guard defers it. The scaling actions beyond the maximum or minimum size are not taken
+func Decide(config *v1alpha1.ConfigSpec, input Input) Result {
+	idle := Result{Action: v1alpha1.DecisionNone, CooldownSec: config.Autoscaler.EvaluationIntervalSec}
+	downCondition := input.DownConditionName
+	if downCondition == "" {
+		downCondition = config.Metrics.Prometheus.DownCondition
+	}
+
+	if pause := input.Pause; pause != nil && (pause.Until.IsZero() || input.Now.Before(pause.Until)) {
+		until := "resumed"
+		if !pause.Until.IsZero() {
+			until = pause.Until.Format(time.RFC3339)
+		}
+		idle.Trigger = TriggerPause
+		idle.Reason = fmt.Sprintf("Autoscaler paused until %s (reason: %q)", until, pause.Reason)
+		return idle
+	}
+
+	window := input.MaintenanceWindow
+	if window != nil && window.Mode != maintenance.ModeScaleUpOnly {
+		idle.Trigger = TriggerMaintenance
+		idle.Reason = fmt.Sprintf("Maintenance window on days %s and hours %s in progress", window.Days, window.HoursUTC)
+		return idle
+	}
+
+	_, scaleUpAllowed := ScaleUpSize(input.Size, input.Limits)
+
+	switch {
+	case input.UpCondition && !scaleUpAllowed:
+		idle.Trigger = TriggerLimits
+		idle.Reason = fmt.Sprintf("Up condition met, but adding %d nodes to the %d existing exceeds the maximum size %d",
+			input.Limits.ScaleUpThreshold, input.Size, input.Limits.MaxSize)
+		return idle
+
+	case input.UpCondition:
+		return Result{Action: v1alpha1.DecisionScaleUp, Trigger: TriggerCondition, CooldownSec: config.Autoscaler.DefaultCooldownPeriodSec}
+
+	case input.DownCondition && window != nil:
+		idle.Trigger = TriggerMaintenance
+		idle.Reason = fmt.Sprintf("Down condition %s met, but the maintenance window on days %s and hours %s only allows scaling up",
+			downCondition, window.Days, window.HoursUTC)
+		return idle
+
+	case input.DownCondition && input.Guard.Reason != "":
+		idle.Trigger = input.Guard.Trigger
+		idle.Reason = fmt.Sprintf("Down condition %s met, but %s", downCondition, input.Guard.Reason)
+		return idle
+
+	// The nodes of a step are removed one by one until the minimum size is reached
+	case input.DownCondition && input.Size <= input.Limits.MinSize:
+		idle.Trigger = TriggerLimits
+		idle.Reason = fmt.Sprintf("Down condition %s met, but the %d existing nodes are at the minimum size %d",
+			downCondition, input.Size, input.Limits.MinSize)
+		return idle
+
+	case input.DownCondition:
+		return Result{Action: v1alpha1.DecisionScaleDown, Trigger: TriggerCondition, CooldownSec: config.Autoscaler.ScaleDownCooldownPeriodSec}
+	}
+
+	idle.Trigger = TriggerCondition
+	idle.Reason = "no condition met"
+	return idle
+}
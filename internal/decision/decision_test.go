@@ -0,0 +1,142 @@
+package decision
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/maintenance"
+	"testing"
+	"time"
+)
+
+func TestDecide(t *testing.T) {
+	config := newConfig(t, `
+  evaluationIntervalSec: 60
+  defaultCooldownPeriodSec: 300
+  scaleDownCooldownPeriodSec: 600
+`)
+	config.Metrics.Prometheus.DownCondition = "low_load"
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	blockWindow := &v1alpha1.MaintenanceWindowSpec{Days: "1", HoursUTC: "10:00:00-14:00:00", Mode: maintenance.ModeBlock}
+	scaleUpOnlyWindow := &v1alpha1.MaintenanceWindowSpec{Days: "1", Mode: maintenance.ModeScaleUpOnly}
+	warmup := Guard{Trigger: TriggerWarmup, Reason: "the new nodes are warming up"}
+	limits := Limits{MinSize: 2, MaxSize: 6, ScaleUpThreshold: 2, ScaleDownThreshold: 1, Window: -1}
+
+	tests := []struct {
+		name  string
+		input Input
+		want  Result
+	}{
+		{
+			name:  "no condition met",
+			input: Input{Now: now},
+			want:  Result{Action: v1alpha1.DecisionNone, Trigger: TriggerCondition, Reason: "no condition met", CooldownSec: 60},
+		},
+		{
+			name:  "up condition met",
+			input: Input{Now: now, UpCondition: true},
+			want:  Result{Action: v1alpha1.DecisionScaleUp, Trigger: TriggerCondition, CooldownSec: 300},
+		},
+		{
+			name:  "down condition met",
+			input: Input{Now: now, DownCondition: true, Size: 4, Limits: limits},
+			want:  Result{Action: v1alpha1.DecisionScaleDown, Trigger: TriggerCondition, CooldownSec: 600},
+		},
+		{
+			name:  "up condition prevails over the down one",
+			input: Input{Now: now, UpCondition: true, DownCondition: true},
+			want:  Result{Action: v1alpha1.DecisionScaleUp, Trigger: TriggerCondition, CooldownSec: 300},
+		},
+		{
+			name:  "up condition met up to the maximum size",
+			input: Input{Now: now, UpCondition: true, Size: 4, Limits: limits},
+			want:  Result{Action: v1alpha1.DecisionScaleUp, Trigger: TriggerCondition, CooldownSec: 300},
+		},
+		{
+			name:  "up condition met beyond the maximum size",
+			input: Input{Now: now, UpCondition: true, Size: 5, Limits: limits},
+			want: Result{Action: v1alpha1.DecisionNone, Trigger: TriggerLimits,
+				Reason: "Up condition met, but adding 2 nodes to the 5 existing exceeds the maximum size 6", CooldownSec: 60},
+		},
+		{
+			name:  "up condition met beyond the maximum size with the step of the condition",
+			input: Input{Now: now, UpCondition: true, Size: 4, Limits: limits.WithUpStep(3)},
+			want: Result{Action: v1alpha1.DecisionNone, Trigger: TriggerLimits,
+				Reason: "Up condition met, but adding 3 nodes to the 4 existing exceeds the maximum size 6", CooldownSec: 60},
+		},
+		{
+			name:  "down condition met above the minimum size with a bigger step",
+			input: Input{Now: now, DownCondition: true, Size: 3, Limits: limits.WithDownStep(2)},
+			want:  Result{Action: v1alpha1.DecisionScaleDown, Trigger: TriggerCondition, CooldownSec: 600},
+		},
+		{
+			name:  "down condition met at the minimum size",
+			input: Input{Now: now, DownCondition: true, Size: 2, Limits: limits},
+			want: Result{Action: v1alpha1.DecisionNone, Trigger: TriggerLimits,
+				Reason: "Down condition low_load met, but the 2 existing nodes are at the minimum size 2", CooldownSec: 60},
+		},
+		{
+			name:  "maximum size ignored without up condition",
+			input: Input{Now: now, Size: 6, Limits: limits},
+			want:  Result{Action: v1alpha1.DecisionNone, Trigger: TriggerCondition, Reason: "no condition met", CooldownSec: 60},
+		},
+		{
+			name:  "paused until resumed",
+			input: Input{Now: now, Pause: &v1alpha1.Pause{Reason: "migration"}, UpCondition: true},
+			want: Result{Action: v1alpha1.DecisionNone, Trigger: TriggerPause,
+				Reason: `Autoscaler paused until resumed (reason: "migration")`, CooldownSec: 60},
+		},
+		{
+			name:  "paused until a time",
+			input: Input{Now: now, Pause: &v1alpha1.Pause{Until: now.Add(time.Hour)}, MaintenanceWindow: blockWindow},
+			want: Result{Action: v1alpha1.DecisionNone, Trigger: TriggerPause,
+				Reason: `Autoscaler paused until 2024-01-01T13:00:00Z (reason: "")`, CooldownSec: 60},
+		},
+		{
+			name:  "expired pause",
+			input: Input{Now: now, Pause: &v1alpha1.Pause{Until: now.Add(-time.Hour)}, DownCondition: true, Size: 4, Limits: limits},
+			want:  Result{Action: v1alpha1.DecisionScaleDown, Trigger: TriggerCondition, CooldownSec: 600},
+		},
+		{
+			name:  "block maintenance window",
+			input: Input{Now: now, MaintenanceWindow: blockWindow, UpCondition: true},
+			want: Result{Action: v1alpha1.DecisionNone, Trigger: TriggerMaintenance,
+				Reason: "Maintenance window on days 1 and hours 10:00:00-14:00:00 in progress", CooldownSec: 60},
+		},
+		{
+			name:  "scale up during a scale-up-only maintenance window",
+			input: Input{Now: now, MaintenanceWindow: scaleUpOnlyWindow, UpCondition: true},
+			want:  Result{Action: v1alpha1.DecisionScaleUp, Trigger: TriggerCondition, CooldownSec: 300},
+		},
+		{
+			name:  "scale down during a scale-up-only maintenance window",
+			input: Input{Now: now, MaintenanceWindow: scaleUpOnlyWindow, DownCondition: true, Guard: warmup},
+			want: Result{Action: v1alpha1.DecisionNone, Trigger: TriggerMaintenance,
+				Reason: "Down condition low_load met, but the maintenance window on days 1 and hours  only allows scaling up", CooldownSec: 60},
+		},
+		{
+			name:  "scale down deferred by a guard",
+			input: Input{Now: now, DownCondition: true, Guard: warmup},
+			want: Result{Action: v1alpha1.DecisionNone, Trigger: TriggerWarmup,
+				Reason: "Down condition low_load met, but the new nodes are warming up", CooldownSec: 60},
+		},
+		{
+			name:  "named down condition deferred by a guard",
+			input: Input{Now: now, DownCondition: true, DownConditionName: "idle", Guard: warmup},
+			want: Result{Action: v1alpha1.DecisionNone, Trigger: TriggerWarmup,
+				Reason: "Down condition idle met, but the new nodes are warming up", CooldownSec: 60},
+		},
+		{
+			name:  "guard ignored without down condition",
+			input: Input{Now: now, Guard: warmup},
+			want:  Result{Action: v1alpha1.DecisionNone, Trigger: TriggerCondition, Reason: "no condition met", CooldownSec: 60},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Decide(config, test.input)
+			if got != test.want {
+				t.Errorf("Decide() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
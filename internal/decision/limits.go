@@ -0,0 +1,119 @@
+package decision
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits are the sizes and thresholds applied by the autoscaler at a given moment
+type Limits struct {
+	MinSize            int32
+	MaxSize            int32
+	ScaleUpThreshold   int32
+	ScaleDownThreshold int32
+
+	// Window is the index of the advanced custom scaling configuration applied, or -1 when the default limits apply
+	Window int
+}
+
+// GetLimits returns the scaling limits applied at the given time, from the first advanced custom
+// scaling configuration matching it, or the default ones of the autoscaler.
+// Windows with invalid hours, rejected by the validation of the config, apply the default limits
+func GetLimits(config *v1alpha1.ConfigSpec, currentTime time.Time) Limits {
+	currentWeekday := int(currentTime.Weekday())
+	defaultLimits := Limits{
+		MinSize:            int32(config.Autoscaler.MinSize),
+		MaxSize:            int32(config.Autoscaler.MaxSize),
+		ScaleUpThreshold:   int32(config.Autoscaler.ScaleUpThreshold),
+		ScaleDownThreshold: 1,
+		Window:             -1,
+	}
+
+	for i, scalingConfig := range config.Autoscaler.AdvancedCustomScalingConfiguration {
+
+		// Set default values if not provided
+		if scalingConfig.ScaleUpThreshold == 0 {
+			scalingConfig.ScaleUpThreshold = config.Autoscaler.ScaleUpThreshold
+		}
+		if scalingConfig.MinSize == 0 {
+			scalingConfig.MinSize = config.Autoscaler.MinSize
+		}
+		if scalingConfig.MaxSize == 0 {
+			scalingConfig.MaxSize = config.Autoscaler.MaxSize
+		}
+		windowLimits := Limits{
+			MinSize:            int32(scalingConfig.MinSize),
+			MaxSize:            int32(scalingConfig.MaxSize),
+			ScaleUpThreshold:   int32(scalingConfig.ScaleUpThreshold),
+			ScaleDownThreshold: defaultLimits.ScaleDownThreshold,
+			Window:             i,
+		}
+
+		// Check if current day is within the critical period days
+		criticalPeriodDays := strings.Split(scalingConfig.Days, ",")
+		for _, criticalPeriodDay := range criticalPeriodDays {
+			if strings.TrimSpace(criticalPeriodDay) != strconv.Itoa(currentWeekday) {
+				continue
+			}
+
+			// If no hours are provided, assume critical period is for the entire day
+			if scalingConfig.HoursUTC == "" {
+				return windowLimits
+			}
+
+			criticalPeriodHours := strings.Split(scalingConfig.HoursUTC, "-")
+			if len(criticalPeriodHours) != 2 {
+				return defaultLimits
+			}
+			startHour, err := time.Parse("15:04:05", criticalPeriodHours[0])
+			if err != nil {
+				return defaultLimits
+			}
+			endHour, err := time.Parse("15:04:05", criticalPeriodHours[1])
+			if err != nil {
+				return defaultLimits
+			}
+
+			// Adjust start and end times to match the current date
+			startTime := time.Date(currentTime.Year(), currentTime.Month(), currentTime.Day(), startHour.Hour(), startHour.Minute(), startHour.Second(), 0, currentTime.Location())
+			endTime := time.Date(currentTime.Year(), currentTime.Month(), currentTime.Day(), endHour.Hour(), endHour.Minute(), endHour.Second(), 0, currentTime.Location())
+
+			// Check if current time is within the critical period
+			if currentTime.After(startTime) && currentTime.Before(endTime) {
+				return windowLimits
+			}
+		}
+	}
+
+	return defaultLimits
+}
+
+// ScaleUpSize returns the size of the MIGs after scaling up from the given size, and whether it is within the maximum
+func ScaleUpSize(size int32, limits Limits) (int32, bool) {
+	desiredSize := size + limits.ScaleUpThreshold
+	return desiredSize, desiredSize <= limits.MaxSize
+}
+
+// ScaleDownSize returns the size of the MIGs after scaling down from the given size, and whether it is within the minimum
+func ScaleDownSize(size int32, limits Limits) (int32, bool) {
+	desiredSize := size - limits.ScaleDownThreshold
+	return desiredSize, desiredSize >= limits.MinSize
+}
+
+// WithUpStep returns the limits adding the given number of nodes when scaling up, or the same limits when it is 0
+func (l Limits) WithUpStep(step int32) Limits {
+	if step > 0 {
+		l.ScaleUpThreshold = step
+	}
+	return l
+}
+
+// WithDownStep returns the limits removing the given number of nodes when scaling down, or the same limits when it is 0
+func (l Limits) WithDownStep(step int32) Limits {
+	if step > 0 {
+		l.ScaleDownThreshold = step
+	}
+	return l
+}
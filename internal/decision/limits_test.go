@@ -0,0 +1,118 @@
+package decision
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// newConfig parses the autoscaler section of a config, as written in the config file
+func newConfig(t *testing.T, autoscaler string) *v1alpha1.ConfigSpec {
+	t.Helper()
+	var config v1alpha1.ConfigSpec
+	err := yaml.UnmarshalStrict([]byte("autoscaler:\n"+autoscaler), &config)
+	if err != nil {
+		t.Fatalf("error parsing config: %v", err)
+	}
+	return &config
+}
+
+func TestGetLimits(t *testing.T) {
+	config := newConfig(t, `
+  minSize: 1
+  maxSize: 10
+  scaleUpThreshold: 1
+  advancedCustomScalingConfiguration:
+    - days: "1,2"
+      hoursUTC: "08:00:00-20:00:00"
+      minSize: 3
+      maxSize: 20
+      scaleUpThreshold: 2
+    - days: "6"
+      minSize: 5
+    - days: "0"
+      hoursUTC: "invalid"
+      minSize: 7
+`)
+	defaults := Limits{MinSize: 1, MaxSize: 10, ScaleUpThreshold: 1, ScaleDownThreshold: 1, Window: -1}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want Limits
+	}{
+		{
+			name: "window with hours",
+			now:  time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), // Monday
+			want: Limits{MinSize: 3, MaxSize: 20, ScaleUpThreshold: 2, ScaleDownThreshold: 1, Window: 0},
+		},
+		{
+			name: "day of the window out of its hours",
+			now:  time.Date(2024, 1, 2, 21, 0, 0, 0, time.UTC), // Tuesday
+			want: defaults,
+		},
+		{
+			name: "window bounds are excluded",
+			now:  time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC),
+			want: defaults,
+		},
+		{
+			name: "whole day window inheriting the missing values",
+			now:  time.Date(2024, 1, 6, 3, 0, 0, 0, time.UTC), // Saturday
+			want: Limits{MinSize: 5, MaxSize: 10, ScaleUpThreshold: 1, ScaleDownThreshold: 1, Window: 1},
+		},
+		{
+			name: "window with invalid hours",
+			now:  time.Date(2024, 1, 7, 12, 0, 0, 0, time.UTC), // Sunday
+			want: defaults,
+		},
+		{
+			name: "day without windows",
+			now:  time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC), // Wednesday
+			want: defaults,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := GetLimits(config, test.now)
+			if got != test.want {
+				t.Errorf("GetLimits() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestScaleSizes(t *testing.T) {
+	limits := Limits{MinSize: 2, MaxSize: 6, ScaleUpThreshold: 2, ScaleDownThreshold: 1}
+
+	tests := []struct {
+		name        string
+		scale       func(int32, Limits) (int32, bool)
+		size        int32
+		wantSize    int32
+		wantAllowed bool
+	}{
+		{name: "scale up", scale: ScaleUpSize, size: 3, wantSize: 5, wantAllowed: true},
+		{name: "scale up to the maximum", scale: ScaleUpSize, size: 4, wantSize: 6, wantAllowed: true},
+		{name: "scale up over the maximum", scale: ScaleUpSize, size: 5, wantSize: 7, wantAllowed: false},
+		{name: "scale down", scale: ScaleDownSize, size: 4, wantSize: 3, wantAllowed: true},
+		{name: "scale down to the minimum", scale: ScaleDownSize, size: 3, wantSize: 2, wantAllowed: true},
+		{name: "scale down under the minimum", scale: ScaleDownSize, size: 2, wantSize: 1, wantAllowed: false},
+		{name: "scale up with the step of a condition", scale: func(size int32, l Limits) (int32, bool) { return ScaleUpSize(size, l.WithUpStep(3)) }, size: 3, wantSize: 6, wantAllowed: true},
+		{name: "scale up without step", scale: func(size int32, l Limits) (int32, bool) { return ScaleUpSize(size, l.WithUpStep(0)) }, size: 3, wantSize: 5, wantAllowed: true},
+		{name: "scale down with the step of a condition", scale: func(size int32, l Limits) (int32, bool) { return ScaleDownSize(size, l.WithDownStep(2)) }, size: 5, wantSize: 3, wantAllowed: true},
+		{name: "scale down with a step under the minimum", scale: func(size int32, l Limits) (int32, bool) { return ScaleDownSize(size, l.WithDownStep(2)) }, size: 3, wantSize: 1, wantAllowed: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			size, allowed := test.scale(test.size, limits)
+			if size != test.wantSize || allowed != test.wantAllowed {
+				t.Errorf("got (%d, %t), want (%d, %t)", size, allowed, test.wantSize, test.wantAllowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package dryrun
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// maxActions is the number of actions skipped in a dry run kept by every autoscaler, shown by the status endpoint
+	maxActions = 50
+
+	// Modules skipping their actions in a dry run
+	ModuleGCP           = "gcp"
+	ModuleGKE           = "gke"
+	ModuleElasticsearch = "elasticsearch"
+	ModuleHooks         = "hooks"
+)
+
+// Record logs the action skipped in a dry run, prefixed with [DRY-RUN], and keeps it in the context of the
+// autoscaler, so the plan of what it would have done can be reviewed
+func Record(ctx *v1alpha1.Context, module string, format string, args ...any) {
+	action := v1alpha1.DryRunAction{
+		Time:   time.Now().UTC(),
+		Module: module,
+		Action: fmt.Sprintf(format, args...),
+	}
+	log.Printf("[DRY-RUN] %s: %s", module, action.Action)
+
+	ctx.Mutex.Lock()
+	defer ctx.Mutex.Unlock()
+	ctx.DryRunActions = append(ctx.DryRunActions, action)
+	if len(ctx.DryRunActions) > maxActions {
+		ctx.DryRunActions = ctx.DryRunActions[len(ctx.DryRunActions)-maxActions:]
+	}
+}
@@ -0,0 +1,321 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/breaker"
+	"custom-vm-autoscaler/internal/dryrun"
+	"custom-vm-autoscaler/internal/notifier"
+	"custom-vm-autoscaler/internal/retry"
+	"custom-vm-autoscaler/internal/state"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+const (
+	// ConcurrencyPolicyWarn warns when the recovery concurrency is too low to drain a node in drainTimeoutSec
+	ConcurrencyPolicyWarn = "warn"
+
+	// ConcurrencyPolicyTune raises the recovery concurrency while a node is drained when it is too low, restoring it
+	// when the drain finishes
+	ConcurrencyPolicyTune = "tune"
+
+	// ConcurrencyPolicyDisabled does not check the recovery concurrency
+	ConcurrencyPolicyDisabled = "disabled"
+)
+
+const (
+	// clusterConcurrentRebalanceSetting limits the shards relocated at the same time in the whole cluster
+	clusterConcurrentRebalanceSetting = "cluster.routing.allocation.cluster_concurrent_rebalance"
+
+	// nodeConcurrentRecoveriesSetting limits the shards recovered at the same time from or to every node
+	nodeConcurrentRecoveriesSetting = "cluster.routing.allocation.node_concurrent_recoveries"
+
+	// excludeNameSetting lists the nodes excluded from the allocation, being drained
+	excludeNameSetting = "cluster.routing.allocation.exclude._name"
+
+	// defaultConcurrentRecoveries is the value of both settings in Elasticsearch when they are not set
+	defaultConcurrentRecoveries = 2
+)
+
+const (
+	// Layers of the cluster settings. Transient settings take precedence over the persistent ones
+	layerPersistent = "persistent"
+	layerTransient  = "transient"
+)
+
+// flatClusterSettings is the response of GET _cluster/settings with flat settings and their defaults
+type flatClusterSettings struct {
+	Persistent map[string]interface{} `json:"persistent"`
+	Transient  map[string]interface{} `json:"transient"`
+	Defaults   map[string]interface{} `json:"defaults"`
+}
+
+// effectiveSetting returns the value of the integer setting applied by the cluster: transient first, then persistent,
+// then its default
+func effectiveSetting(settings flatClusterSettings, name string) int {
+	for _, values := range []map[string]interface{}{settings.Transient, settings.Persistent, settings.Defaults} {
+		value, ok := values[name].(string)
+		if !ok {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err == nil {
+			return parsed
+		}
+	}
+	return defaultConcurrentRecoveries
+}
+
+// settingLayer returns the layer applying the setting, transient when set in it and persistent otherwise, and the
+// value of the setting in that layer, nil when not set
+func settingLayer(settings flatClusterSettings, name string) (string, *string) {
+	if value, ok := settings.Transient[name].(string); ok {
+		return layerTransient, ptr(value)
+	}
+	if value, ok := settings.Persistent[name].(string); ok {
+		return layerPersistent, ptr(value)
+	}
+	return layerPersistent, nil
+}
+
+// tuningAllowed returns whether the drain of the node may raise the recovery concurrency. Only one drain at a time
+// raises it, so no drain takes the values raised by another as the ones to restore: the one holding the first drain
+// slot or, without drain lock, the drain of the only node excluded from the allocation
+func tuningAllowed(settings flatClusterSettings, slot *drainSlot, nodeName string) bool {
+	if slot != nil {
+		return slot.ID == drainSlotID(0)
+	}
+
+	_, excluded := settingLayer(settings, excludeNameSetting)
+	if excluded == nil {
+		return true
+	}
+	for _, name := range strings.Split(*excluded, ",") {
+		if name != "" && name != nodeName {
+			return false
+		}
+	}
+	return true
+}
+
+// requiredConcurrency returns the shards that must be relocated at the same time to drain the shards in the timeout
+func requiredConcurrency(shards int, shardRelocation time.Duration, timeout time.Duration) int {
+	if timeout <= 0 {
+		return 1
+	}
+	required := (time.Duration(shards)*shardRelocation + timeout - 1) / timeout
+	return max(int(required), 1)
+}
+
+// estimateDrain returns the time needed to relocate the shards, relocating them in waves of the concurrency given
+func estimateDrain(shards int, concurrency int, shardRelocation time.Duration) time.Duration {
+	concurrency = max(concurrency, 1)
+	waves := (shards + concurrency - 1) / concurrency
+	return time.Duration(waves) * shardRelocation
+}
+
+// checkRecoveryConcurrency checks that cluster_concurrent_rebalance and node_concurrent_recoveries let the shards
+// of the node be relocated in drainTimeoutSec, warning or raising them temporarily following the concurrency policy.
+// The settings are only raised by one drain at a time, and the values raised are saved in the state until restored.
+// It returns the function restoring the settings raised, to call when the drain finishes
+func checkRecoveryConcurrency(ctx *v1alpha1.Context, es *elasticsearch.Client, nodeName string, slot *drainSlot) (func(), error) {
+	spec := ctx.Config.Target.Elasticsearch.ConcurrencyCheck
+	restore := func() {}
+	if spec.Policy == ConcurrencyPolicyDisabled {
+		return restore, nil
+	}
+
+	var shards []v1alpha1.ShardInfo
+	err := retryCall(ctx, "Elasticsearch shards request", func() error {
+		var err error
+		shards, err = getShards(ctx, es)
+		return err
+	})
+	if err != nil {
+		return restore, err
+	}
+	nodeShards := 0
+	for _, shard := range shards {
+		if shard.Node == nodeName {
+			nodeShards++
+		}
+	}
+
+	var settings flatClusterSettings
+	err = retryCall(ctx, "Elasticsearch cluster settings request", func() error {
+		var err error
+		settings, err = getFlatClusterSettings(ctx, es)
+		return err
+	})
+	if err != nil {
+		return restore, err
+	}
+
+	rebalance := effectiveSetting(settings, clusterConcurrentRebalanceSetting)
+	recoveries := effectiveSetting(settings, nodeConcurrentRecoveriesSetting)
+	shardRelocation := time.Duration(spec.ShardRelocationSec) * time.Second
+	drainTimeout := time.Duration(ctx.Config.Target.Elasticsearch.DrainTimeoutSec) * time.Second
+	required := requiredConcurrency(nodeShards, shardRelocation, drainTimeout)
+	if min(rebalance, recoveries) >= required {
+		return restore, nil
+	}
+
+	estimate := estimateDrain(nodeShards, min(rebalance, recoveries), shardRelocation)
+	if spec.Policy != ConcurrencyPolicyTune || !tuningAllowed(settings, slot, nodeName) {
+		if spec.Policy == ConcurrencyPolicyTune {
+			log.Printf("Not raising the recovery concurrency to drain node %s, as another drain in flight may have raised it", nodeName)
+		}
+		log.Printf("Draining the %d shards of node %s would take %s, over drainTimeoutSec, with cluster_concurrent_rebalance %d and node_concurrent_recoveries %d. At least %d are needed",
+			nodeShards, nodeName, estimate, rebalance, recoveries, required)
+		notifier.NotifyDetailed(ctx, notifier.Notification{
+			Severity: notifier.SeverityWarning,
+			Event:    notifier.EventScaleDown,
+			Message:  fmt.Sprintf("Recovery concurrency too low to drain instance %s in %s, estimated %s", nodeName, drainTimeout, estimate),
+			Fields: []notifier.Field{
+				{Name: "Shards", Value: strconv.Itoa(nodeShards)},
+				{Name: "cluster_concurrent_rebalance", Value: strconv.Itoa(rebalance)},
+				{Name: "node_concurrent_recoveries", Value: strconv.Itoa(recoveries)},
+				{Name: "Required", Value: strconv.Itoa(required)},
+			},
+			Thread: DrainThread(nodeName),
+		})
+		return restore, nil
+	}
+
+	// Raise the settings below the concurrency required in the layer applying them, keeping their values to restore
+	// them. The values are saved in the state before raising them, so they are restored after a crash too
+	raised := map[string]map[string]*string{}
+	previous := map[string]map[string]*string{}
+	for name, value := range map[string]int{clusterConcurrentRebalanceSetting: rebalance, nodeConcurrentRecoveriesSetting: recoveries} {
+		if value >= required {
+			continue
+		}
+		layer, current := settingLayer(settings, name)
+		if raised[layer] == nil {
+			raised[layer], previous[layer] = map[string]*string{}, map[string]*string{}
+		}
+		raised[layer][name] = ptr(strconv.Itoa(required))
+		previous[layer][name] = current
+	}
+	setRaisedSettings(ctx, previous)
+	err = retryCall(ctx, "Elasticsearch cluster settings update", func() error {
+		return putClusterSettings(ctx.ConnContext(), ctx, es, raised)
+	})
+	if err != nil {
+		setRaisedSettings(ctx, nil)
+		return restore, fmt.Errorf("failed to raise the recovery concurrency: %w", err)
+	}
+	log.Printf("Raised the recovery concurrency to %d while draining the %d shards of node %s, estimated %s before", required, nodeShards, nodeName, estimate)
+
+	restore = func() {
+		err := restoreSettings(ctx, es, previous)
+		if err != nil {
+			log.Printf("Error restoring the recovery concurrency after draining node %s, it is restored on the next start: %v", nodeName, err)
+			return
+		}
+		log.Printf("Restored the recovery concurrency after draining node %s", nodeName)
+	}
+	return restore, nil
+}
+
+// setRaisedSettings saves in the state the values of the settings raised, to restore them after a crash, or clears
+// them when nil. Nothing is saved in a dry run of the target, as nothing is raised
+func setRaisedSettings(ctx *v1alpha1.Context, previous map[string]map[string]*string) {
+	if ctx.Config.Autoscaler.DryRunTarget {
+		return
+	}
+	ctx.Mutex.Lock()
+	ctx.State.RaisedSettings = previous
+	ctx.Mutex.Unlock()
+	state.Save(ctx)
+}
+
+// restoreSettings sets the settings raised back to their previous values, clearing them from the state. They are
+// restored even when the autoscaler is being stopped, so they are not left raised
+func restoreSettings(ctx *v1alpha1.Context, es *elasticsearch.Client, previous map[string]map[string]*string) error {
+	ctxConn := context.WithoutCancel(ctx.ConnContext())
+	err := retry.Do(ctxConn, retry.NewPolicy(ctx.Config), "Elasticsearch cluster settings update", func() error {
+		return putClusterSettings(ctxConn, ctx, es, previous)
+	})
+	breaker.Record(ctx, breaker.DependencyElasticsearch, err)
+	if err != nil {
+		return err
+	}
+	setRaisedSettings(ctx, nil)
+	return nil
+}
+
+// RestoreRaisedSettings restores the cluster settings raised by a drain interrupted by a crash, saved in the state
+func RestoreRaisedSettings(ctx *v1alpha1.Context) error {
+	ctx.Mutex.Lock()
+	previous := ctx.State.RaisedSettings
+	ctx.Mutex.Unlock()
+	if previous == nil {
+		return nil
+	}
+
+	es, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+	return restoreSettings(ctx, es, previous)
+}
+
+// getFlatClusterSettings returns the cluster settings, including their defaults, with flat names
+func getFlatClusterSettings(ctx *v1alpha1.Context, es *elasticsearch.Client) (flatClusterSettings, error) {
+	var settings flatClusterSettings
+	res, err := es.Cluster.GetSettings(
+		es.Cluster.GetSettings.WithIncludeDefaults(true),
+		es.Cluster.GetSettings.WithFlatSettings(true),
+		es.Cluster.GetSettings.WithContext(ctx.ConnContext()),
+	)
+	if err != nil {
+		return settings, fmt.Errorf("failed to get cluster settings: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return settings, fmt.Errorf("error getting cluster settings: %s", res.String())
+	}
+	if err := json.NewDecoder(res.Body).Decode(&settings); err != nil {
+		return settings, fmt.Errorf("failed to decode cluster settings response: %w", err)
+	}
+	return settings, nil
+}
+
+// putClusterSettings sets the cluster settings, by layer and name. Nil values reset the settings to their defaults
+func putClusterSettings(ctxConn context.Context, ctx *v1alpha1.Context, es *elasticsearch.Client, values map[string]map[string]*string) error {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("failed to marshal settings to JSON: %w", err)
+	}
+
+	if ctx.Config.Autoscaler.DryRunTarget {
+		dryrun.Record(ctx, dryrun.ModuleElasticsearch, "PUT _cluster/settings %s", string(data))
+		return nil
+	}
+
+	res, err := es.Cluster.PutSettings(bytes.NewReader(data), es.Cluster.PutSettings.WithContext(ctxConn))
+	if err != nil {
+		return fmt.Errorf("failed to update cluster settings: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("error updating cluster settings: %s", res.String())
+	}
+	return nil
+}
+
+// ptr returns a pointer to the value
+func ptr[T any](value T) *T {
+	return &value
+}
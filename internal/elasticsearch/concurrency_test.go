@@ -0,0 +1,141 @@
+package elasticsearch
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEffectiveSetting(t *testing.T) {
+	settings := flatClusterSettings{
+		Persistent: map[string]interface{}{clusterConcurrentRebalanceSetting: "4", nodeConcurrentRecoveriesSetting: "3"},
+		Transient:  map[string]interface{}{nodeConcurrentRecoveriesSetting: "5"},
+		Defaults:   map[string]interface{}{clusterConcurrentRebalanceSetting: "2", nodeConcurrentRecoveriesSetting: "2"},
+	}
+
+	if got := effectiveSetting(settings, clusterConcurrentRebalanceSetting); got != 4 {
+		t.Errorf("effectiveSetting(rebalance) = %d, want 4", got)
+	}
+	if got := effectiveSetting(settings, nodeConcurrentRecoveriesSetting); got != 5 {
+		t.Errorf("effectiveSetting(recoveries) = %d, want 5", got)
+	}
+	if got := effectiveSetting(flatClusterSettings{}, nodeConcurrentRecoveriesSetting); got != defaultConcurrentRecoveries {
+		t.Errorf("effectiveSetting(missing) = %d, want %d", got, defaultConcurrentRecoveries)
+	}
+}
+
+func TestRequiredConcurrency(t *testing.T) {
+	tests := []struct {
+		name    string
+		shards  int
+		timeout time.Duration
+		want    int
+	}{
+		{name: "no shards", shards: 0, timeout: 10 * time.Minute, want: 1},
+		{name: "fits in the timeout", shards: 20, timeout: 10 * time.Minute, want: 1},
+		{name: "over the timeout", shards: 50, timeout: 10 * time.Minute, want: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := requiredConcurrency(test.shards, 30*time.Second, test.timeout)
+			if got != test.want {
+				t.Errorf("requiredConcurrency() = %d, want %d", got, test.want)
+			}
+			if estimate := estimateDrain(test.shards, got, 30*time.Second); estimate > test.timeout {
+				t.Errorf("estimateDrain() = %s, over the timeout %s", estimate, test.timeout)
+			}
+		})
+	}
+}
+
+func TestSettingLayer(t *testing.T) {
+	settings := flatClusterSettings{
+		Persistent: map[string]interface{}{clusterConcurrentRebalanceSetting: "4", nodeConcurrentRecoveriesSetting: "3"},
+		Transient:  map[string]interface{}{nodeConcurrentRecoveriesSetting: "5"},
+	}
+
+	if layer, value := settingLayer(settings, nodeConcurrentRecoveriesSetting); layer != layerTransient || value == nil || *value != "5" {
+		t.Errorf("settingLayer(recoveries) = %s, %v, want the transient 5", layer, value)
+	}
+	if layer, value := settingLayer(settings, clusterConcurrentRebalanceSetting); layer != layerPersistent || value == nil || *value != "4" {
+		t.Errorf("settingLayer(rebalance) = %s, %v, want the persistent 4", layer, value)
+	}
+	if layer, value := settingLayer(flatClusterSettings{}, clusterConcurrentRebalanceSetting); layer != layerPersistent || value != nil {
+		t.Errorf("settingLayer(missing) = %s, %v, want the persistent layer unset", layer, value)
+	}
+}
+
+func TestTuningAllowed(t *testing.T) {
+	excluded := func(names string) flatClusterSettings {
+		return flatClusterSettings{Transient: map[string]interface{}{excludeNameSetting: names}}
+	}
+
+	tests := []struct {
+		name     string
+		settings flatClusterSettings
+		slot     *drainSlot
+		want     bool
+	}{
+		{name: "first drain slot", settings: excluded("node-1,node-2"), slot: &drainSlot{ID: drainSlotID(0)}, want: true},
+		{name: "other drain slot", settings: excluded("node-1"), slot: &drainSlot{ID: drainSlotID(1)}, want: false},
+		{name: "only node excluded", settings: excluded("node-1"), want: true},
+		{name: "nothing excluded", settings: flatClusterSettings{}, want: true},
+		{name: "another node excluded", settings: excluded("node-2,node-1"), want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := tuningAllowed(test.settings, test.slot, "node-1"); got != test.want {
+				t.Errorf("tuningAllowed() = %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRestoreRaisedSettings(t *testing.T) {
+	var mutex sync.Mutex
+	var restored map[string]map[string]*string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		mutex.Lock()
+		defer mutex.Unlock()
+		json.NewDecoder(r.Body).Decode(&restored)
+		json.NewEncoder(w).Encode(map[string]any{"acknowledged": true})
+	}))
+	defer server.Close()
+
+	// The settings are restored even when the autoscaler is being stopped
+	ctxRun, cancel := context.WithCancel(context.Background())
+	cancel()
+	ctx := &v1alpha1.Context{Config: &v1alpha1.ConfigSpec{}, Parent: ctxRun}
+	ctx.Config.Target.Elasticsearch.URL = server.URL
+	ctx.Config.Target.Elasticsearch.RequestTimeoutSec = 5
+	ctx.Config.Target.Elasticsearch.TLSMinVersion = "1.2"
+	ctx.State.RaisedSettings = map[string]map[string]*string{
+		layerTransient:  {nodeConcurrentRecoveriesSetting: ptr("5")},
+		layerPersistent: {clusterConcurrentRebalanceSetting: nil},
+	}
+
+	err := RestoreRaisedSettings(ctx)
+	if err != nil {
+		t.Fatalf("RestoreRaisedSettings() error = %v", err)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if value := restored[layerTransient][nodeConcurrentRecoveriesSetting]; value == nil || *value != "5" {
+		t.Errorf("transient recoveries restored to %v, want 5", value)
+	}
+	if value, ok := restored[layerPersistent][clusterConcurrentRebalanceSetting]; !ok || value != nil {
+		t.Errorf("persistent rebalance restored to %v, want it reset", value)
+	}
+	if ctx.State.RaisedSettings != nil {
+		t.Errorf("raised settings kept in the state after being restored: %v", ctx.State.RaisedSettings)
+	}
+}
@@ -0,0 +1,86 @@
+package elasticsearch
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/notifier"
+	"custom-vm-autoscaler/internal/state"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	// maxDrainDurations is the number of drains kept in the state to estimate their duration
+	maxDrainDurations = 50
+
+	// minDrainDurations is the number of drains needed before comparing their duration with the drain timeout
+	minDrainDurations = 5
+)
+
+// DrainEstimate is the median and the 95th percentile of the duration of the drains recorded
+type DrainEstimate struct {
+	Drains int           `json:"drains"`
+	P50    time.Duration `json:"p50"`
+	P95    time.Duration `json:"p95"`
+}
+
+// EstimateDrainDuration returns the median and the 95th percentile of the durations, using the nearest rank
+func EstimateDrainDuration(durations []v1alpha1.DrainDuration) DrainEstimate {
+	if len(durations) == 0 {
+		return DrainEstimate{}
+	}
+
+	seconds := make([]float64, 0, len(durations))
+	for _, duration := range durations {
+		seconds = append(seconds, duration.DurationSec)
+	}
+	slices.Sort(seconds)
+
+	percentile := func(p float64) time.Duration {
+		rank := int(math.Ceil(p*float64(len(seconds)))) - 1
+		return time.Duration(seconds[max(rank, 0)] * float64(time.Second))
+	}
+	return DrainEstimate{Drains: len(seconds), P50: percentile(0.5), P95: percentile(0.95)}
+}
+
+// GetDrainEstimate returns the estimate of the duration of the drains recorded in the state of the autoscaler
+func GetDrainEstimate(ctx *v1alpha1.Context) DrainEstimate {
+	ctx.Mutex.Lock()
+	durations := slices.Clone(ctx.State.DrainDurations)
+	ctx.Mutex.Unlock()
+	return EstimateDrainDuration(durations)
+}
+
+// recordDrainDuration persists the duration of the drain of the node in the state, keeping the last drains only.
+// It alerts when drainTimeoutSec is below the 95th percentile of the drains recorded, as many drains would time out
+func recordDrainDuration(ctx *v1alpha1.Context, nodeName string, shards int, duration time.Duration) {
+	ctx.Mutex.Lock()
+	durations := append(ctx.State.DrainDurations, v1alpha1.DrainDuration{
+		Node:        nodeName,
+		Shards:      shards,
+		DurationSec: duration.Seconds(),
+		FinishedAt:  time.Now().UTC(),
+	})
+	if len(durations) > maxDrainDurations {
+		durations = durations[len(durations)-maxDrainDurations:]
+	}
+	ctx.State.DrainDurations = durations
+	durations = slices.Clone(durations)
+	ctx.Mutex.Unlock()
+	state.Save(ctx)
+
+	estimate := EstimateDrainDuration(durations)
+	if estimate.Drains < minDrainDurations {
+		return
+	}
+	drainTimeout := time.Duration(ctx.Config.Target.Elasticsearch.DrainTimeoutSec) * time.Second
+	if drainTimeout < estimate.P95 {
+		notifier.Alert(ctx, notifier.SeverityWarning, notifier.AlertDrainTimeoutLow,
+			fmt.Sprintf("drainTimeoutSec (%s) is below the 95th percentile of the last %d drains (%s, median %s)",
+				drainTimeout, estimate.Drains, estimate.P95.Round(time.Second), estimate.P50.Round(time.Second)))
+		return
+	}
+	notifier.Resolve(ctx, notifier.AlertDrainTimeoutLow,
+		fmt.Sprintf("drainTimeoutSec (%s) is above the 95th percentile of the last %d drains (%s) again", drainTimeout, estimate.Drains, estimate.P95.Round(time.Second)))
+}
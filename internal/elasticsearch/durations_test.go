@@ -0,0 +1,44 @@
+package elasticsearch
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"testing"
+	"time"
+)
+
+func TestEstimateDrainDuration(t *testing.T) {
+	var durations []v1alpha1.DrainDuration
+	for i := 1; i <= 20; i++ {
+		durations = append(durations, v1alpha1.DrainDuration{Node: "node", DurationSec: float64(i * 10)})
+	}
+
+	tests := []struct {
+		name      string
+		durations []v1alpha1.DrainDuration
+		want      DrainEstimate
+	}{
+		{
+			name:      "no drains",
+			durations: nil,
+			want:      DrainEstimate{},
+		},
+		{
+			name:      "single drain",
+			durations: durations[:1],
+			want:      DrainEstimate{Drains: 1, P50: 10 * time.Second, P95: 10 * time.Second},
+		},
+		{
+			name:      "nearest rank",
+			durations: durations,
+			want:      DrainEstimate{Drains: 20, P50: 100 * time.Second, P95: 190 * time.Second},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := EstimateDrainDuration(test.durations); got != test.want {
+				t.Errorf("EstimateDrainDuration() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
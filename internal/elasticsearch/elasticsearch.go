@@ -3,13 +3,20 @@ package elasticsearch
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"custom-vm-autoscaler/api/v1alpha1"
-	"custom-vm-autoscaler/internal/slack"
+	"custom-vm-autoscaler/internal/approval"
+	"custom-vm-autoscaler/internal/breaker"
+	"custom-vm-autoscaler/internal/dryrun"
+	"custom-vm-autoscaler/internal/kubeevents"
+	"custom-vm-autoscaler/internal/notifier"
+	"custom-vm-autoscaler/internal/proxy"
+	"custom-vm-autoscaler/internal/retry"
+	"custom-vm-autoscaler/internal/tlsconfig"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"regexp"
 	"strings"
@@ -18,42 +25,108 @@ import (
 	"github.com/elastic/go-elasticsearch/v8"
 )
 
+// drainProgressInterval is the time between the notifications of the progress of a drain
+const drainProgressInterval = time.Minute
+
+const (
+	// DrainTimeoutPolicyRollback includes the node again in the allocations when its drain times out, failing the removal
+	DrainTimeoutPolicyRollback = "rollback"
+
+	// DrainTimeoutPolicyForce removes the node anyway when its drain times out, with the shards remaining
+	DrainTimeoutPolicyForce = "force"
+
+	// DrainTimeoutPolicyExtend waits for the drain once more when it times out, rolling it back when it times out again
+	DrainTimeoutPolicyExtend = "extend"
+
+	// DrainTimeoutPolicyApproval asks on Slack for the approval of removing the node anyway when its drain times out,
+	// rolling it back when it is not approved
+	DrainTimeoutPolicyApproval = "approval"
+)
+
 // DrainElasticsearchNode drains an Elasticsearch node and performs a controlled shutdown.
+// Nodes without data roles are not drained, only their requests in flight are waited for.
 // elasticURL: The URL of the Elasticsearch cluster.
 // nodeName: The name of the node to shut down.
 // username: The username for basic authentication.
 // password: The password for basic authentication.
 func DrainElasticsearchNode(ctx *v1alpha1.Context, nodeName string) error {
 
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: ctx.Config.Target.Elasticsearch.SSLInsecureSkipVerify,
-			MinVersion:         tls.VersionTLS13,
-		},
+	// Creates new client
+	es, err := newClient(ctx)
+	if err != nil {
+		return err
 	}
 
-	// Create elasticsearch config for connection
-	cfg := elasticsearch.Config{
-		Addresses: []string{ctx.Config.Target.Elasticsearch.URL},
-		Username:  ctx.Config.Target.Elasticsearch.User,
-		Password:  ctx.Config.Target.Elasticsearch.Password,
-		Transport: tr,
+	// Nodes without data roles hold no shards, so only their requests in flight are waited for, without drain slot
+	stateless, err := isStatelessNode(ctx, nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to get the roles of node %s: %w", nodeName, err)
+	}
+	if stateless {
+		notifier.NotifyDetailed(ctx, notifier.Notification{
+			Severity: notifier.SeverityInfo,
+			Event:    notifier.EventScaleDown,
+			Message:  fmt.Sprintf("Draining instance %s from elasticsearch, holding no data", nodeName),
+			Thread:   DrainThread(nodeName),
+			Progress: true,
+		})
+		if !ctx.Config.Autoscaler.DryRunTarget {
+			err = waitForInFlightRequests(ctx, es, nodeName)
+			if err != nil {
+				return fmt.Errorf("failed while waiting for the requests in flight: %w", err)
+			}
+		}
+		return nil
 	}
 
-	// Creates new client
-	es, err := elasticsearch.NewClient(cfg)
+	// Nodes holding only searchable snapshots can be removed without drain, as the snapshot repository backs their data
+	if ctx.Config.Target.Elasticsearch.SnapshotNodesPolicy == SnapshotNodesPolicyNoDrain {
+		snapshotOnly, err := isSnapshotOnlyNode(ctx, es, nodeName)
+		if err != nil {
+			return fmt.Errorf("failed to get the shards of node %s: %w", nodeName, err)
+		}
+		if snapshotOnly {
+			return removeSnapshotNode(ctx, es, nodeName)
+		}
+	}
+
+	// Wait for a free drain slot when the concurrent drains are limited cluster-wide
+	slot, err := acquireDrainLock(ctx, es, nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to acquire drain lock: %w", err)
+	}
+	defer releaseDrainLock(ctx, es, slot)
+
+	// Renew the slot while the drain lasts, as it may be extended or wait for an approval past its expiration
+	stopRenewal := keepDrainLock(ctx, es, slot, drainLockRenewInterval)
+	defer stopRenewal()
+
+	// Check that the recovery concurrency lets the shards of the node relocate in drainTimeoutSec
+	restoreConcurrency, err := checkRecoveryConcurrency(ctx, es, nodeName, slot)
 	if err != nil {
-		return fmt.Errorf("failed to create Elasticsearch client: %w", err)
+		return fmt.Errorf("failed to check the recovery concurrency: %w", err)
 	}
+	defer restoreConcurrency()
 
 	// Exclude the node IP from routing allocations
-	err = updateClusterSettings(ctx, es, nodeName)
+	err = retryCall(ctx, "Elasticsearch cluster settings update", func() error {
+		return updateClusterSettings(ctx, es, nodeName)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to update cluster settings: %w", err)
 	}
 
+	// Open the thread of the drain, where its progress and result are notified
+	notifier.NotifyDetailed(ctx, notifier.Notification{
+		Severity: notifier.SeverityInfo,
+		Event:    notifier.EventScaleDown,
+		Message:  fmt.Sprintf("Draining instance %s from elasticsearch", nodeName),
+		Thread:   DrainThread(nodeName),
+		Progress: true,
+	})
+
 	// Wait until the node is removed from the cluster
-	if !ctx.Config.Autoscaler.DebugMode {
+	if !ctx.Config.Autoscaler.DryRunTarget {
 		err = waitForNodeRemoval(ctx, es, nodeName)
 		if err != nil {
 			return fmt.Errorf("failed while waiting for node removal: %w", err)
@@ -63,11 +136,53 @@ func DrainElasticsearchNode(ctx *v1alpha1.Context, nodeName string) error {
 	return nil
 }
 
+// newClient creates the Elasticsearch client for the cluster defined in the config
+func newClient(ctx *v1alpha1.Context) (*elasticsearch.Client, error) {
+	// The request timeout bounds connecting to the cluster and waiting for its responses,
+	// so an unresponsive node does not block the autoscaler forever
+	timeout := time.Duration(ctx.Config.Target.Elasticsearch.RequestTimeoutSec) * time.Second
+	proxyFunc, err := proxy.Func(ctx.Config.Target.Elasticsearch.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	esConfig := ctx.Config.Target.Elasticsearch
+	tlsConfig, err := tlsconfig.New(esConfig.TLSMinVersion, esConfig.TLSMaxVersion, esConfig.TLSCipherSuites, esConfig.SSLInsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+	tr := &http.Transport{
+		Proxy:                 proxyFunc,
+		DialContext:           (&net.Dialer{Timeout: timeout}).DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		TLSClientConfig:       tlsConfig,
+	}
+
+	// Create elasticsearch config for connection
+	cfg := elasticsearch.Config{
+		Addresses: []string{ctx.Config.Target.Elasticsearch.URL},
+		Username:  ctx.Config.Target.Elasticsearch.User,
+		Password:  ctx.Config.Target.Elasticsearch.Password,
+		Transport: tr,
+	}
+
+	es, err := elasticsearch.NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Elasticsearch client: %w", err)
+	}
+	return es, nil
+}
+
+// DrainThread returns the thread grouping the notifications of the drain of the node
+func DrainThread(nodeName string) string {
+	return "drain/" + nodeName
+}
+
 // updateClusterSettings updates the cluster settings to exclude a specific node IP.
 func updateClusterSettings(ctx *v1alpha1.Context, es *elasticsearch.Client, nodeName string) error {
 
 	// Get current cluster settings
-	res, err := es.Cluster.GetSettings()
+	res, err := es.Cluster.GetSettings(es.Cluster.GetSettings.WithContext(ctx.ConnContext()))
 	if err != nil {
 		return fmt.Errorf("failed to get current cluster settings: %w", err)
 	}
@@ -93,8 +208,8 @@ func updateClusterSettings(ctx *v1alpha1.Context, es *elasticsearch.Client, node
 			}
 		}
 	}
-	if ctx.Config.Autoscaler.DebugMode {
-		log.Printf("Debug mode enabled. Current nodes in exclude settings elasticsearch: %s", string(currentExcludes))
+	if ctx.Config.Autoscaler.DryRunTarget {
+		log.Printf("Dry run of the target enabled. Current nodes in exclude settings elasticsearch: %s", string(currentExcludes))
 	}
 
 	if ok && currentExcludes != "" {
@@ -123,14 +238,14 @@ func updateClusterSettings(ctx *v1alpha1.Context, es *elasticsearch.Client, node
 		return fmt.Errorf("failed to marshal settings to JSON: %w", err)
 	}
 
-	if ctx.Config.Autoscaler.DebugMode {
-		log.Printf("Debug mode enabled. Skipping PUT _cluster/settings command. Command to execute: %s", string(data))
+	if ctx.Config.Autoscaler.DryRunTarget {
+		dryrun.Record(ctx, dryrun.ModuleElasticsearch, "PUT _cluster/settings %s", string(data))
 	}
 
 	// Execute PUT _cluster/settings command
-	if !ctx.Config.Autoscaler.DebugMode {
+	if !ctx.Config.Autoscaler.DryRunTarget {
 		req := bytes.NewReader(data)
-		res, err = es.Cluster.PutSettings(req)
+		res, err = es.Cluster.PutSettings(req, es.Cluster.PutSettings.WithContext(ctx.ConnContext()))
 		if err != nil {
 			return fmt.Errorf("failed to update cluster settings: %w", err)
 		}
@@ -144,7 +259,8 @@ func updateClusterSettings(ctx *v1alpha1.Context, es *elasticsearch.Client, node
 	return nil
 }
 
-// waitForNodeRemoval waits for the node to be removed from the cluster.
+// waitForNodeRemoval waits for the node to be removed from the cluster. When the drain times out, the node is removed
+// anyway, waited for once more, or included again in the allocations, following the drain timeout policy
 func waitForNodeRemoval(ctx *v1alpha1.Context, es *elasticsearch.Client, nodeName string) error {
 
 	// Prepare regex to match shards with
@@ -153,21 +269,54 @@ func waitForNodeRemoval(ctx *v1alpha1.Context, es *elasticsearch.Client, nodeNam
 		log.Fatalf("Error compiling regex: %v", err)
 	}
 
-	// Create a context with timeout
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), time.Duration(ctx.Config.Target.Elasticsearch.DrainTimeoutSec)*time.Second)
-	defer cancel()
+	// Notify the progress of the drain periodically
+	startTime := time.Now()
+	lastProgress := startTime
+	remainingShards, initialShards := 0, -1
+	extended := false
+
+	// Expose the progress of the drain while it lasts
+	defer setDrainProgress(ctx, nil)
+
+	// The drain times out once the deadline is reached, postponed once by the extend policy
+	drainTimeout := time.Duration(ctx.Config.Target.Elasticsearch.DrainTimeoutSec) * time.Second
+	deadline := time.Now().Add(drainTimeout)
 
 	for {
 
-		// Check if context is done for timeout
-		select {
-		case <-ctxWithTimeout.Done():
-			if ctx.Config.Notifications.Slack.WebhookURL != "" {
-				message := fmt.Sprintf("Timeout draining instance %s in elasticsearch. Timeout reached in %d seconds", nodeName, ctx.Config.Target.Elasticsearch.DrainTimeoutSec)
-				err = slack.NotifySlack(message, ctx.Config.Notifications.Slack.WebhookURL)
-				if err != nil {
-					log.Printf("Error sending Slack notification: %v", err)
-				}
+		// The drain is left as is when the autoscaler is stopped, so its in-flight operation is recovered on the next start
+		if ctx.ConnContext().Err() != nil {
+			return fmt.Errorf("draining node %s interrupted: %w", nodeName, ctx.ConnContext().Err())
+		}
+
+		// Check if the drain timed out
+		if !time.Now().Before(deadline) {
+			if ctx.Config.Target.Elasticsearch.OnDrainTimeout == DrainTimeoutPolicyExtend && !extended {
+				extended = true
+				log.Printf("Timeout draining node %s with %d shards remaining, waiting %d seconds more", nodeName, remainingShards, ctx.Config.Target.Elasticsearch.DrainTimeoutSec)
+				notifier.NotifyDetailed(ctx, notifier.Notification{
+					Severity: notifier.SeverityWarning,
+					Event:    notifier.EventScaleDown,
+					Message: fmt.Sprintf("Timeout draining instance %s from elasticsearch with %d shards remaining, extending it once by %d seconds",
+						nodeName, remainingShards, ctx.Config.Target.Elasticsearch.DrainTimeoutSec),
+					Thread: DrainThread(nodeName),
+				})
+				deadline = time.Now().Add(drainTimeout)
+				continue
+			}
+			notifier.Alert(ctx, notifier.SeverityError, notifier.AlertDrainTimeout, fmt.Sprintf("Timeout draining instance %s in elasticsearch. Timeout reached in %d seconds", nodeName, ctx.Config.Target.Elasticsearch.DrainTimeoutSec))
+			kubeevents.Emit(ctx.Config.Name, kubeevents.TypeWarning, kubeevents.ReasonDrainTimeout,
+				fmt.Sprintf("Autoscaler %s timed out draining instance %s from elasticsearch, with %d shards remaining", ctx.Config.Name, nodeName, remainingShards))
+
+			if forceRemoval(ctx, nodeName, remainingShards) {
+				log.Printf("Removing node %s anyway with %d shards remaining", nodeName, remainingShards)
+				notifier.NotifyDetailed(ctx, notifier.Notification{
+					Severity: notifier.SeverityWarning,
+					Event:    notifier.EventScaleDown,
+					Message:  fmt.Sprintf("Removing instance %s anyway after its drain timed out, with %d shards remaining", nodeName, remainingShards),
+					Thread:   DrainThread(nodeName),
+				})
+				return nil
 			}
 
 			// Add node again to the cluster settings
@@ -176,79 +325,289 @@ func waitForNodeRemoval(ctx *v1alpha1.Context, es *elasticsearch.Client, nodeNam
 				return fmt.Errorf("error clearing cluster settings: %w", err)
 			}
 
-			return fmt.Errorf("timeout trying to remove node from cluster settings in elasticsearch: %v", ctxWithTimeout.Err())
-		default:
-			// Get _cat/shards to check if nodeName has any shard inside
-			res, err := es.Cat.Shards(
-				es.Cat.Shards.WithFormat("json"),
-				es.Cat.Shards.WithV(true),
-			)
-			if err != nil {
-				return fmt.Errorf("failed to get shards information: %w", err)
-			}
-			defer res.Body.Close()
+			return fmt.Errorf("timeout trying to remove node from cluster settings in elasticsearch: %v", context.DeadlineExceeded)
+		}
 
-			// Get response
-			body, err := io.ReadAll(res.Body)
-			if err != nil || string(body) == "" {
-				return fmt.Errorf("error reading response body: %w", err)
-			}
+		// Stop waiting when the leadership is lost, leaving the drain to be recovered by the new leader
+		err = ctx.CheckLeadership()
+		if err != nil {
+			return fmt.Errorf("draining node %s interrupted: %w", nodeName, err)
+		}
 
-			// Parse response in JSON
-			var shards []v1alpha1.ShardInfo
-			err = json.Unmarshal([]byte(string(body)), &shards)
-			if err != nil {
-				return fmt.Errorf("error deserializing JSON: %w", err)
-			}
+		// Get _cat/shards to check if nodeName has any shard inside, retrying the transient failures
+		var shards []v1alpha1.ShardInfo
+		err = retryCall(ctx, "Elasticsearch shards request", func() error {
+			shards, err = getShards(ctx, es)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		// Check if nodeName has any shards inside it
+		remaining := remainingIndices(shards, re)
+		remainingShards = countShards(remaining)
+
+		if initialShards < 0 {
+			initialShards = remainingShards
+		}
+		setDrainProgress(ctx, &v1alpha1.DrainProgress{Node: nodeName, StartedAt: startTime,
+			InitialShards: initialShards, RemainingShards: remainingShards})
+
+		// If there are not any shard inside it, it is ready to delete
+		if remainingShards == 0 {
+			log.Printf("node %s is fully empty and ready to delete", nodeName)
+			recordDrainDuration(ctx, nodeName, initialShards, time.Since(startTime))
+			notifier.Resolve(ctx, notifier.AlertDrainTimeout, fmt.Sprintf("Instance %s drained successfully from elasticsearch, drains are not timing out anymore", nodeName))
+			return nil
+		}
+
+		// Shards of the ignorable indices do not block the removal past their timeout
+		ignorable := ctx.Config.Target.Elasticsearch.IgnorableIndices
+		if len(ignorable.Patterns) > 0 && time.Since(startTime) >= time.Duration(ignorable.TimeoutSec)*time.Second &&
+			onlyIgnorableIndices(remaining, ignorable.Patterns) {
+			log.Printf("node %s only holds %d shards of ignorable indices, ready to delete: %s", nodeName, remainingShards, formatIndices(remaining))
+			recordDrainDuration(ctx, nodeName, initialShards, time.Since(startTime))
+			notifier.Resolve(ctx, notifier.AlertDrainTimeout, fmt.Sprintf("Instance %s drained successfully from elasticsearch, drains are not timing out anymore", nodeName))
+			notifier.NotifyDetailed(ctx, notifier.Notification{
+				Severity: notifier.SeverityInfo,
+				Event:    notifier.EventScaleDown,
+				Message:  fmt.Sprintf("Removing instance %s with %d shards of ignorable indices remaining", nodeName, remainingShards),
+				Fields: []notifier.Field{
+					{Name: "Indices remaining", Value: formatIndices(remaining)},
+				},
+				Thread: DrainThread(nodeName),
+			})
+			return nil
+		}
+
+		if time.Since(lastProgress) >= drainProgressInterval {
+			lastProgress = time.Now()
+			log.Printf("Draining node %s, %d shards remaining in: %s", nodeName, remainingShards, formatIndices(remaining))
+			notifier.NotifyDetailed(ctx, notifier.Notification{
+				Severity: notifier.SeverityInfo,
+				Event:    notifier.EventScaleDown,
+				Message:  fmt.Sprintf("Draining instance %s from elasticsearch, %d shards remaining", nodeName, remainingShards),
+				Fields: []notifier.Field{
+					{Name: "Shards remaining", Value: fmt.Sprintf("%d", remainingShards)},
+					{Name: "Indices remaining", Value: formatIndices(remaining)},
+					{Name: "Elapsed", Value: time.Since(startTime).Round(time.Second).String()},
+				},
+				Thread:   DrainThread(nodeName),
+				Progress: true,
+			})
+		}
+
+		// Sleep a brief period before next check to avoid excessive requests, waking up at the deadline at the latest.
+		// The timeout is checked on the next iteration
+		pollInterval := time.Duration(ctx.Config.Target.Elasticsearch.DrainPollIntervalSec) * time.Second
+		_ = retry.Sleep(ctx.ConnContext(), min(pollInterval, time.Until(deadline)))
+
+	}
+
+}
+
+// setDrainProgress exposes the progress of the drain in progress, or clears it when nil
+func setDrainProgress(ctx *v1alpha1.Context, progress *v1alpha1.DrainProgress) {
+	ctx.Mutex.Lock()
+	defer ctx.Mutex.Unlock()
+	ctx.Drain = progress
+}
+
+// forceRemoval returns true when the node must be removed anyway after its drain timed out, following the drain timeout
+// policy. With the approval policy, it waits for the answer on Slack
+func forceRemoval(ctx *v1alpha1.Context, nodeName string, remainingShards int) bool {
+	switch ctx.Config.Target.Elasticsearch.OnDrainTimeout {
+	case DrainTimeoutPolicyForce:
+		return true
+	case DrainTimeoutPolicyApproval:
+		err := approval.RequestForcedRemoval(ctx, nodeName, remainingShards)
+		if err != nil {
+			log.Printf("Removal of node %s with %d shards remaining not approved, rolling back its drain: %v", nodeName, remainingShards, err)
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// retryCall executes a request to Elasticsearch, retrying it when it fails.
+// The final result is recorded in the circuit breaker of Elasticsearch
+func retryCall(ctx *v1alpha1.Context, name string, call func() error) error {
+	err := retry.Do(ctx.ConnContext(), retry.NewPolicy(ctx.Config), name, call)
+	breaker.Record(ctx, breaker.DependencyElasticsearch, err)
+	return err
+}
+
+// CheckCluster checks that the Elasticsearch cluster is reachable requesting its health
+func CheckCluster(ctx *v1alpha1.Context) error {
+	es, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	return retryCall(ctx, "Elasticsearch cluster health request", func() error {
+		res, err := es.Cluster.Health(es.Cluster.Health.WithContext(ctx.ConnContext()))
+		if err != nil {
+			return fmt.Errorf("failed to get cluster health: %w", err)
+		}
+		defer res.Body.Close()
+
+		if res.IsError() {
+			return fmt.Errorf("error getting cluster health: %s", res.String())
+		}
+		return nil
+	})
+}
 
-			// Check if nodeName has any shards inside it
-			nodeFound := false
-			for _, shard := range shards {
-				if re.MatchString(shard.Node) {
-					nodeFound = true
+// GetClusterHealth returns the health of the Elasticsearch cluster, with the shards being relocated, initialized or unassigned
+func GetClusterHealth(ctx *v1alpha1.Context) (v1alpha1.ClusterHealth, error) {
+	var health v1alpha1.ClusterHealth
+	es, err := newClient(ctx)
+	if err != nil {
+		return health, err
+	}
+
+	err = retryCall(ctx, "Elasticsearch cluster health request", func() error {
+		res, err := es.Cluster.Health(es.Cluster.Health.WithContext(ctx.ConnContext()))
+		if err != nil {
+			return fmt.Errorf("failed to get cluster health: %w", err)
+		}
+		defer res.Body.Close()
+
+		if res.IsError() {
+			return fmt.Errorf("error getting cluster health: %s", res.String())
+		}
+		return json.NewDecoder(res.Body).Decode(&health)
+	})
+	return health, err
+}
+
+// GetExcludedNodes returns the names of the nodes excluded from the shard allocation, as drained nodes are
+func GetExcludedNodes(ctx *v1alpha1.Context) ([]string, error) {
+	es, err := newClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var settings v1alpha1.ElasticsearchSettings
+	err = retryCall(ctx, "Elasticsearch cluster settings request", func() error {
+		res, err := es.Cluster.GetSettings(es.Cluster.GetSettings.WithContext(ctx.ConnContext()))
+		if err != nil {
+			return fmt.Errorf("failed to get current cluster settings: %w", err)
+		}
+		defer res.Body.Close()
+
+		if res.IsError() {
+			return fmt.Errorf("error getting cluster settings: %s", res.String())
+		}
+		return json.NewDecoder(res.Body).Decode(&settings)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var excluded []string
+	if cluster, ok := settings.Persistent["cluster"].(map[string]interface{}); ok {
+		if routing, ok := cluster["routing"].(map[string]interface{}); ok {
+			if allocation, ok := routing["allocation"].(map[string]interface{}); ok {
+				if exclude, ok := allocation["exclude"].(map[string]interface{}); ok {
+					if names, ok := exclude["_name"].(string); ok && names != "" {
+						excluded = strings.Split(names, ",")
+					}
 				}
 			}
+		}
+	}
+	return excluded, nil
+}
 
-			// If nodeFound is false, there are not any shard inside it. It is ready to delete
-			if !nodeFound {
-				log.Printf("node %s is fully empty and ready to delete", nodeName)
-				return nil
-			}
+// dataRoles are the abbreviations of the roles of the nodes holding data in _cat/nodes:
+// data, content, hot, warm, cold and frozen
+const dataRoles = "dshwcf"
 
-			// Sleep a brief period before next check to avoid excessive requests
-			time.Sleep(2 * time.Second)
+// GetNodes returns the nodes that have joined the Elasticsearch cluster
+func GetNodes(ctx *v1alpha1.Context) ([]v1alpha1.NodeInfo, error) {
+	es, err := newClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []v1alpha1.NodeInfo
+	err = retryCall(ctx, "Elasticsearch nodes request", func() error {
+		res, err := es.Cat.Nodes(
+			es.Cat.Nodes.WithContext(ctx.ConnContext()),
+			es.Cat.Nodes.WithFormat("json"),
+			es.Cat.Nodes.WithH("name", "node.role", "heap.percent", "disk.used_percent"),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to get nodes: %w", err)
 		}
+		defer res.Body.Close()
 
-	}
+		if res.IsError() {
+			return fmt.Errorf("error getting nodes: %s", res.String())
+		}
+		return json.NewDecoder(res.Body).Decode(&nodes)
+	})
+	return nodes, err
+}
 
+// IsDataNode returns true when the node holds data
+func IsDataNode(node v1alpha1.NodeInfo) bool {
+	return strings.ContainsAny(node.NodeRole, dataRoles)
 }
 
-// clearClusterSettings removes the node exclusion from cluster settings.
-func ClearElasticsearchClusterSettings(ctx *v1alpha1.Context, nodeName string) error {
+// getShards returns the shards of the cluster and the nodes where they are allocated
+func getShards(ctx *v1alpha1.Context, es *elasticsearch.Client) ([]v1alpha1.ShardInfo, error) {
+	res, err := es.Cat.Shards(
+		es.Cat.Shards.WithContext(ctx.ConnContext()),
+		es.Cat.Shards.WithFormat("json"),
+		es.Cat.Shards.WithV(true),
+		es.Cat.Shards.WithBytes("b"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shards information: %w", err)
+	}
+	defer res.Body.Close()
 
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: ctx.Config.Target.Elasticsearch.SSLInsecureSkipVerify,
-			MinVersion:         tls.VersionTLS13,
-		},
+	if res.IsError() {
+		return nil, fmt.Errorf("error getting shards information: %s", res.String())
 	}
 
-	// Configure elasticsearch connection
-	cfg := elasticsearch.Config{
-		Addresses: []string{ctx.Config.Target.Elasticsearch.URL},
-		Username:  ctx.Config.Target.Elasticsearch.User,
-		Password:  ctx.Config.Target.Elasticsearch.Password,
-		Transport: tr,
+	// Get response
+	body, err := io.ReadAll(res.Body)
+	if err != nil || string(body) == "" {
+		return nil, fmt.Errorf("error reading response body: %w", err)
 	}
 
+	// Parse response in JSON
+	var shards []v1alpha1.ShardInfo
+	err = json.Unmarshal(body, &shards)
+	if err != nil {
+		return nil, fmt.Errorf("error deserializing JSON: %w", err)
+	}
+	return shards, nil
+}
+
+// ClearElasticsearchClusterSettings removes the node exclusion from cluster settings, retrying it when it fails
+func ClearElasticsearchClusterSettings(ctx *v1alpha1.Context, nodeName string) error {
+	return retryCall(ctx, "Elasticsearch cluster settings cleanup", func() error {
+		return clearClusterSettings(ctx, nodeName)
+	})
+}
+
+// clearClusterSettings removes the node exclusion from cluster settings.
+func clearClusterSettings(ctx *v1alpha1.Context, nodeName string) error {
+
 	// Create elastic client
-	es, err := elasticsearch.NewClient(cfg)
+	es, err := newClient(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create Elasticsearch client: %w", err)
+		return err
 	}
 
 	// Get current cluster settings
-	res, err := es.Cluster.GetSettings()
+	res, err := es.Cluster.GetSettings(es.Cluster.GetSettings.WithContext(ctx.ConnContext()))
 	if err != nil {
 		return fmt.Errorf("failed to get current cluster settings: %w", err)
 	}
@@ -274,8 +633,8 @@ func ClearElasticsearchClusterSettings(ctx *v1alpha1.Context, nodeName string) e
 			}
 		}
 	}
-	if ctx.Config.Autoscaler.DebugMode {
-		log.Printf("Debug mode enabled. Current nodes in exclude settings elasticsearch: %s", string(currentExcludes))
+	if ctx.Config.Autoscaler.DryRunTarget {
+		log.Printf("Dry run of the target enabled. Current nodes in exclude settings elasticsearch: %s", string(currentExcludes))
 	}
 
 	if !ok || currentExcludes == "" {
@@ -313,14 +672,14 @@ func ClearElasticsearchClusterSettings(ctx *v1alpha1.Context, nodeName string) e
 		return fmt.Errorf("failed to marshal settings to JSON: %w", err)
 	}
 
-	if ctx.Config.Autoscaler.DebugMode {
-		log.Printf("Debug mode enabled. Skipping PUT _cluster/settings command. Command to execute: %s", string(data))
+	if ctx.Config.Autoscaler.DryRunTarget {
+		dryrun.Record(ctx, dryrun.ModuleElasticsearch, "PUT _cluster/settings %s", string(data))
 	}
 
 	// Execute PUT _cluster/settings
-	if !ctx.Config.Autoscaler.DebugMode {
+	if !ctx.Config.Autoscaler.DryRunTarget {
 		req := bytes.NewReader(data)
-		res, err = es.Cluster.PutSettings(req)
+		res, err = es.Cluster.PutSettings(req, es.Cluster.PutSettings.WithContext(ctx.ConnContext()))
 		if err != nil {
 			return fmt.Errorf("failed to update cluster settings: %w", err)
 		}
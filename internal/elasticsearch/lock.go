@@ -0,0 +1,290 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/retry"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+const (
+	// drainLockRetryInterval is the time to wait before trying again to get a free drain slot
+	drainLockRetryInterval = 10 * time.Second
+
+	// drainLockExpirationMargin is added to the drain timeout to calculate the expiration of the slots,
+	// so slots held by crashed autoscalers are released eventually
+	drainLockExpirationMargin = 5 * time.Minute
+
+	// drainLockRenewInterval is the time between the renewals of the expiration of a slot while its drain lasts
+	drainLockRenewInterval = time.Minute
+)
+
+// drainLock is the document stored in elasticsearch for every drain slot in use
+type drainLock struct {
+	Holder    string `json:"holder"`
+	Node      string `json:"node"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// drainLockDocument is the response of elasticsearch when getting a drain slot
+type drainLockDocument struct {
+	SeqNo       int       `json:"_seq_no"`
+	PrimaryTerm int       `json:"_primary_term"`
+	Source      drainLock `json:"_source"`
+}
+
+// drainSlot is a drain slot taken, with the version of its document written, so it is only renewed and released
+// while nobody else has taken it over
+type drainSlot struct {
+	ID          string
+	SeqNo       int `json:"_seq_no"`
+	PrimaryTerm int `json:"_primary_term"`
+
+	// Lock is the content of the document written, rewritten with a later expiration when renewed
+	Lock drainLock `json:"-"`
+}
+
+// acquireDrainLock waits until one of the maxConcurrentDrains slots shared by every autoscaler targeting
+// the cluster is free, and takes it. It returns the slot taken, to be released later.
+// No slot is returned when the drain lock is disabled.
+func acquireDrainLock(ctx *v1alpha1.Context, es *elasticsearch.Client, nodeName string) (*drainSlot, error) {
+	maxConcurrentDrains := ctx.Config.Target.Elasticsearch.MaxConcurrentDrains
+	if maxConcurrentDrains <= 0 || ctx.Config.Autoscaler.DryRunTarget {
+		return nil, nil
+	}
+
+	hostname, _ := os.Hostname()
+	drainTimeout := time.Duration(ctx.Config.Target.Elasticsearch.DrainTimeoutSec) * time.Second
+	deadline := time.Now().Add(drainTimeout)
+
+	for {
+		lock := drainLock{
+			Holder:    fmt.Sprintf("%s/%s", hostname, ctx.Config.Name),
+			Node:      nodeName,
+			ExpiresAt: drainLockExpiration(ctx),
+		}
+
+		for slot := 0; slot < maxConcurrentDrains; slot++ {
+			slotID := drainSlotID(slot)
+			acquired, err := tryAcquireDrainSlot(ctx, es, slotID, lock)
+			if err != nil {
+				return nil, err
+			}
+			if acquired != nil {
+				acquired.Lock = lock
+				log.Printf("Acquired drain slot %s for node %s", slotID, nodeName)
+				return acquired, nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout waiting for a free drain slot: %d concurrent drains already in flight", maxConcurrentDrains)
+		}
+
+		log.Printf("All the %d drain slots are in use, waiting for a free one to drain node %s", maxConcurrentDrains, nodeName)
+		err := retry.Sleep(ctx.ConnContext(), drainLockRetryInterval)
+		if err != nil {
+			return nil, fmt.Errorf("waiting for a free drain slot interrupted: %w", err)
+		}
+	}
+}
+
+// drainSlotID returns the ID of the document of the drain slot
+func drainSlotID(slot int) string {
+	return fmt.Sprintf("drain-slot-%d", slot)
+}
+
+// tryAcquireDrainSlot creates the document of the slot, or overwrites it when the holder let it expire.
+// It returns the slot when taken
+func tryAcquireDrainSlot(ctx *v1alpha1.Context, es *elasticsearch.Client, slotID string, lock drainLock) (*drainSlot, error) {
+	index := ctx.Config.Target.Elasticsearch.DrainLockIndex
+
+	data, err := json.Marshal(lock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal drain lock to JSON: %w", err)
+	}
+
+	// Create the slot document, failing if somebody else holds it
+	res, err := es.Create(index, slotID, bytes.NewReader(data), es.Create.WithContext(ctx.ConnContext()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create drain lock: %w", err)
+	}
+	defer res.Body.Close()
+
+	if !res.IsError() {
+		return decodeDrainSlot(res.Body, slotID)
+	}
+	if res.StatusCode != http.StatusConflict {
+		return nil, fmt.Errorf("error creating drain lock: %s", res.String())
+	}
+
+	// The slot is in use, so check if its holder let it expire
+	res, err = es.Get(index, slotID, es.Get.WithContext(ctx.ConnContext()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get drain lock: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		// Released in the meantime, it will be tried again in the next round
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("error getting drain lock: %s", res.String())
+	}
+
+	var current drainLockDocument
+	if err := json.NewDecoder(res.Body).Decode(&current); err != nil {
+		return nil, fmt.Errorf("failed to decode drain lock response: %w", err)
+	}
+	if time.Now().UnixMilli() < current.Source.ExpiresAt {
+		return nil, nil
+	}
+
+	// Take over the expired slot. It fails if somebody else took it first
+	res, err = es.Index(index, bytes.NewReader(data),
+		es.Index.WithContext(ctx.ConnContext()),
+		es.Index.WithDocumentID(slotID),
+		es.Index.WithIfSeqNo(current.SeqNo),
+		es.Index.WithIfPrimaryTerm(current.PrimaryTerm),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to take over drain lock: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusConflict {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("error taking over drain lock: %s", res.String())
+	}
+
+	log.Printf("Drain slot %s held by %s for node %s expired, taking it over", slotID, current.Source.Holder, current.Source.Node)
+	return decodeDrainSlot(res.Body, slotID)
+}
+
+// decodeDrainSlot reads the version of the slot document from the response of writing it
+func decodeDrainSlot(body io.Reader, slotID string) (*drainSlot, error) {
+	slot := drainSlot{ID: slotID}
+	if err := json.NewDecoder(body).Decode(&slot); err != nil {
+		return nil, fmt.Errorf("failed to decode drain lock response: %w", err)
+	}
+	return &slot, nil
+}
+
+// drainLockExpiration returns the expiration of a slot taken or renewed now, covering a whole drain timeout
+func drainLockExpiration(ctx *v1alpha1.Context) int64 {
+	drainTimeout := time.Duration(ctx.Config.Target.Elasticsearch.DrainTimeoutSec) * time.Second
+	return time.Now().Add(drainTimeout + drainLockExpirationMargin).UnixMilli()
+}
+
+// renewDrainLock postpones the expiration of the slot, so drains extended or waiting for an approval past the first
+// expiration keep it. The document is only rewritten while it is the version written by us, and the new version is
+// kept in the slot
+func renewDrainLock(ctx *v1alpha1.Context, es *elasticsearch.Client, slot *drainSlot) error {
+	lock := slot.Lock
+	lock.ExpiresAt = drainLockExpiration(ctx)
+	data, err := json.Marshal(lock)
+	if err != nil {
+		return fmt.Errorf("failed to marshal drain lock to JSON: %w", err)
+	}
+
+	res, err := es.Index(ctx.Config.Target.Elasticsearch.DrainLockIndex, bytes.NewReader(data),
+		es.Index.WithContext(ctx.ConnContext()),
+		es.Index.WithDocumentID(slot.ID),
+		es.Index.WithIfSeqNo(slot.SeqNo),
+		es.Index.WithIfPrimaryTerm(slot.PrimaryTerm),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to renew drain lock: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusConflict || res.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("drain slot %s was taken over by another holder", slot.ID)
+	}
+	if res.IsError() {
+		return fmt.Errorf("error renewing drain lock: %s", res.String())
+	}
+
+	renewed, err := decodeDrainSlot(res.Body, slot.ID)
+	if err != nil {
+		return err
+	}
+	slot.SeqNo, slot.PrimaryTerm, slot.Lock = renewed.SeqNo, renewed.PrimaryTerm, lock
+	return nil
+}
+
+// keepDrainLock renews the slot every interval until the returned function is called, which waits for the renewal
+// in progress, if any, so the slot can be released afterwards. Errors are logged, as the drain must go on
+func keepDrainLock(ctx *v1alpha1.Context, es *elasticsearch.Client, slot *drainSlot, interval time.Duration) func() {
+	if slot == nil {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ctx.ConnContext().Done():
+				return
+			case <-ticker.C:
+				err := renewDrainLock(ctx, es, slot)
+				if err != nil {
+					log.Printf("Error renewing drain slot %s: %v", slot.ID, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// releaseDrainLock deletes the document of the slot, so other autoscalers can use it. It is only deleted while
+// it is the version written when taking it, as a slot taken over by somebody else after expiring is not ours anymore.
+// It is released even when the autoscaler is being stopped
+func releaseDrainLock(ctx *v1alpha1.Context, es *elasticsearch.Client, slot *drainSlot) {
+	if slot == nil {
+		return
+	}
+
+	res, err := es.Delete(ctx.Config.Target.Elasticsearch.DrainLockIndex, slot.ID,
+		es.Delete.WithContext(context.WithoutCancel(ctx.ConnContext())),
+		es.Delete.WithIfSeqNo(slot.SeqNo),
+		es.Delete.WithIfPrimaryTerm(slot.PrimaryTerm),
+	)
+	if err != nil {
+		log.Printf("Error releasing drain slot %s: %v", slot.ID, err)
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusConflict || res.StatusCode == http.StatusNotFound {
+		log.Printf("Drain slot %s was taken over by another holder after expiring, leaving it", slot.ID)
+		return
+	}
+	if res.IsError() {
+		log.Printf("Error releasing drain slot %s: %s", slot.ID, res.String())
+		return
+	}
+	log.Printf("Released drain slot %s", slot.ID)
+}
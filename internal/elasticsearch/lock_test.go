@@ -0,0 +1,172 @@
+package elasticsearch
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// slotServer serves a single drain slot document with optimistic concurrency control
+type slotServer struct {
+	mutex  sync.Mutex
+	exists bool
+	seqNo  int
+	source json.RawMessage
+}
+
+// matchesVersion returns whether the request is conditioned to the current version of the document
+func (s *slotServer) matchesVersion(r *http.Request) bool {
+	return r.URL.Query().Get("if_seq_no") == strconv.Itoa(s.seqNo) && r.URL.Query().Get("if_primary_term") == "1"
+}
+
+func (s *slotServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch {
+	case r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/_create/"):
+		if s.exists {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		s.exists = true
+		s.seqNo++
+		s.source, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"_seq_no": s.seqNo, "_primary_term": 1})
+
+	case r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/_doc/"):
+		if !s.exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"_seq_no": s.seqNo, "_primary_term": 1, "_source": s.source})
+
+	case r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/_doc/"):
+		if !s.exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !s.matchesVersion(r) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		s.seqNo++
+		s.source, _ = io.ReadAll(r.Body)
+		json.NewEncoder(w).Encode(map[string]any{"_seq_no": s.seqNo, "_primary_term": 1})
+
+	case r.Method == http.MethodDelete:
+		if !s.exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !s.matchesVersion(r) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		s.exists = false
+		json.NewEncoder(w).Encode(map[string]any{"result": "deleted"})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// newSlotClient starts a slotServer, returning it with the context and the client of an autoscaler using it
+func newSlotClient(t *testing.T) (*slotServer, *v1alpha1.Context, *elasticsearch.Client) {
+	t.Helper()
+	server := &slotServer{}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	ctx := &v1alpha1.Context{Config: &v1alpha1.ConfigSpec{}}
+	ctx.Config.Target.Elasticsearch.URL = httpServer.URL
+	ctx.Config.Target.Elasticsearch.DrainLockIndex = "drain-locks"
+	ctx.Config.Target.Elasticsearch.RequestTimeoutSec = 5
+	ctx.Config.Target.Elasticsearch.TLSMinVersion = "1.2"
+	es, err := newClient(ctx)
+	if err != nil {
+		t.Fatalf("newClient() error = %v", err)
+	}
+	return server, ctx, es
+}
+
+func TestReleaseDrainLockOnlyDeletesOwnSlot(t *testing.T) {
+	server, ctx, es := newSlotClient(t)
+
+	slot, err := tryAcquireDrainSlot(ctx, es, "drain-slot-0", drainLock{Holder: "test", Node: "node-1"})
+	if err != nil || slot == nil {
+		t.Fatalf("tryAcquireDrainSlot() = %v, %v, want a slot", slot, err)
+	}
+
+	// Another holder takes the slot over after it expired, so releasing ours must not free it
+	server.mutex.Lock()
+	server.seqNo++
+	server.mutex.Unlock()
+	releaseDrainLock(ctx, es, slot)
+	if !server.exists {
+		t.Fatalf("slot taken over by another holder released")
+	}
+
+	// Released while it is still ours
+	server.mutex.Lock()
+	slot.SeqNo = server.seqNo
+	server.mutex.Unlock()
+	releaseDrainLock(ctx, es, slot)
+	if server.exists {
+		t.Errorf("own slot not released")
+	}
+}
+
+func TestKeepDrainLockPastExpiration(t *testing.T) {
+	server, ctx, es := newSlotClient(t)
+	expiration := 100 * time.Millisecond
+	other := drainLock{Holder: "other", Node: "node-2", ExpiresAt: time.Now().Add(time.Hour).UnixMilli()}
+
+	// Without renewals, the slot is taken over once expired
+	lock := drainLock{Holder: "test", Node: "node-1", ExpiresAt: time.Now().Add(expiration).UnixMilli()}
+	slot, err := tryAcquireDrainSlot(ctx, es, "drain-slot-0", lock)
+	if err != nil || slot == nil {
+		t.Fatalf("tryAcquireDrainSlot() = %v, %v, want a slot", slot, err)
+	}
+	time.Sleep(2 * expiration)
+	taken, err := tryAcquireDrainSlot(ctx, es, "drain-slot-0", other)
+	if err != nil || taken == nil {
+		t.Fatalf("tryAcquireDrainSlot() of an expired slot = %v, %v, want it taken over", taken, err)
+	}
+	releaseDrainLock(ctx, es, taken)
+
+	// With renewals, the drain keeps its slot past the first expiration
+	lock.ExpiresAt = time.Now().Add(expiration).UnixMilli()
+	slot, err = tryAcquireDrainSlot(ctx, es, "drain-slot-0", lock)
+	if err != nil || slot == nil {
+		t.Fatalf("tryAcquireDrainSlot() = %v, %v, want a slot", slot, err)
+	}
+	slot.Lock = lock
+	stopRenewal := keepDrainLock(ctx, es, slot, expiration/5)
+	time.Sleep(2 * expiration)
+	taken, err = tryAcquireDrainSlot(ctx, es, "drain-slot-0", other)
+	if err != nil || taken != nil {
+		t.Fatalf("tryAcquireDrainSlot() of a renewed slot = %v, %v, want it kept", taken, err)
+	}
+
+	// The renewed slot is still released by its holder
+	stopRenewal()
+	releaseDrainLock(ctx, es, slot)
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.exists {
+		t.Errorf("renewed slot not released")
+	}
+}
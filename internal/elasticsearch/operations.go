@@ -0,0 +1,86 @@
+package elasticsearch
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// indexOperationActions are the actions of the force merges and the rollovers, whose I/O competes with the drains
+const indexOperationActions = "indices:admin/forcemerge*,indices:admin/rollover*"
+
+// bracketsRegex extracts the lists between brackets of the descriptions of the tasks, like the indices force merged
+var bracketsRegex = regexp.MustCompile(`\[([^\]]*)\]`)
+
+// GetIndexOperations returns the force merges and rollovers running on the indices matching the patterns of
+// target.elasticsearch.indexOperations, described by their action and the description of their task
+func GetIndexOperations(ctx *v1alpha1.Context) ([]string, error) {
+	spec := ctx.Config.Target.Elasticsearch.IndexOperations
+	if len(spec.Patterns) == 0 {
+		return nil, nil
+	}
+
+	es, err := newClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks tasksResponse
+	err = retryCall(ctx, "Elasticsearch tasks request", func() error {
+		res, err := es.Tasks.List(
+			es.Tasks.List.WithContext(ctx.ConnContext()),
+			es.Tasks.List.WithActions(indexOperationActions),
+			es.Tasks.List.WithDetailed(true),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to get tasks: %w", err)
+		}
+		defer res.Body.Close()
+
+		if res.IsError() {
+			return fmt.Errorf("error getting tasks: %s", res.String())
+		}
+		return json.NewDecoder(res.Body).Decode(&tasks)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matchIndexOperations(tasks, spec.Patterns, spec.MatchUnknownIndices), nil
+}
+
+// matchIndexOperations returns the tasks whose description names any index matching the patterns: the indices force
+// merged, or the alias or data stream rolled over. Tasks without any name in their description, like some rollovers,
+// can not be told apart, so they only match when matchUnknown is set
+func matchIndexOperations(tasks tasksResponse, patterns []string, matchUnknown bool) []string {
+	var operations []string
+	for _, node := range tasks.Nodes {
+		for _, task := range node.Tasks {
+			var indices []string
+			for _, match := range bracketsRegex.FindAllStringSubmatch(task.Description, -1) {
+				for _, index := range strings.Split(match[1], ",") {
+					indices = append(indices, strings.TrimSpace(index))
+				}
+			}
+			if (len(indices) == 0 && matchUnknown) || matchesAnyPattern(indices, patterns) {
+				operations = append(operations, strings.TrimSpace(task.Action+" "+task.Description))
+			}
+		}
+	}
+	return operations
+}
+
+// matchesAnyPattern returns true when any of the names matches any of the wildcard patterns
+func matchesAnyPattern(names []string, patterns []string) bool {
+	for _, name := range names {
+		for _, pattern := range patterns {
+			matched, err := path.Match(pattern, name)
+			if err == nil && matched {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestMatchIndexOperations(t *testing.T) {
+	var tasks tasksResponse
+	err := json.Unmarshal([]byte(`{"nodes": {"node-1": {"tasks": {
+		"node-1:1": {"action": "indices:admin/forcemerge", "description": "Force-merge indices [logs-2024.01.01, logs-2024.01.02], maxSegments[1], onlyExpungeDeletes[false], flush[true]"},
+		"node-1:2": {"action": "indices:admin/forcemerge", "description": "Force-merge indices [metrics-2024.01.01], maxSegments[1], onlyExpungeDeletes[false], flush[true]"},
+		"node-1:3": {"action": "indices:admin/rollover", "description": "rollover [logs-app]"},
+		"node-1:4": {"action": "indices:admin/rollover", "description": ""}
+	}}}}`), &tasks)
+	if err != nil {
+		t.Fatalf("failed to decode tasks: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		patterns     []string
+		matchUnknown bool
+		want         []string
+	}{
+		{
+			name:     "matching pattern",
+			patterns: []string{"logs-*"},
+			want: []string{
+				"indices:admin/forcemerge Force-merge indices [logs-2024.01.01, logs-2024.01.02], maxSegments[1], onlyExpungeDeletes[false], flush[true]",
+				"indices:admin/rollover rollover [logs-app]",
+			},
+		},
+		{
+			name:     "no matching pattern",
+			patterns: []string{"traces-*"},
+		},
+		{
+			name:         "unknown indices matched",
+			patterns:     []string{"traces-*"},
+			matchUnknown: true,
+			want:         []string{"indices:admin/rollover"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := matchIndexOperations(tasks, test.patterns, test.matchUnknown)
+			slices.Sort(got)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("matchIndexOperations() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package elasticsearch
+
+import (
+	"cmp"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxIndicesLogged is the number of indices, the largest first, listed in the progress of a drain
+const maxIndicesLogged = 10
+
+// indexShards are the shards of an index remaining on a node being drained, and their size
+type indexShards struct {
+	Index  string
+	Shards int
+	Bytes  int64
+}
+
+// remainingIndices groups by index the shards held by the node, sorted by size, the largest first.
+// The sizes are read in bytes, so shards of _cat/shards without store, like the unassigned ones, count as empty
+func remainingIndices(shards []v1alpha1.ShardInfo, node *regexp.Regexp) []indexShards {
+	byIndex := map[string]*indexShards{}
+	for _, shard := range shards {
+		if !node.MatchString(shard.Node) {
+			continue
+		}
+		remaining, ok := byIndex[shard.Index]
+		if !ok {
+			remaining = &indexShards{Index: shard.Index}
+			byIndex[shard.Index] = remaining
+		}
+		remaining.Shards++
+		size, _ := strconv.ParseInt(shard.Store, 10, 64)
+		remaining.Bytes += size
+	}
+
+	indices := make([]indexShards, 0, len(byIndex))
+	for _, remaining := range byIndex {
+		indices = append(indices, *remaining)
+	}
+	slices.SortFunc(indices, func(a, b indexShards) int {
+		return cmp.Or(cmp.Compare(b.Bytes, a.Bytes), strings.Compare(a.Index, b.Index))
+	})
+	return indices
+}
+
+// countShards returns the number of shards of the indices
+func countShards(indices []indexShards) int {
+	count := 0
+	for _, index := range indices {
+		count += index.Shards
+	}
+	return count
+}
+
+// onlyIgnorableIndices returns true when every index remaining matches any of the patterns
+func onlyIgnorableIndices(indices []indexShards, patterns []string) bool {
+	for _, index := range indices {
+		ignorable := slices.ContainsFunc(patterns, func(pattern string) bool {
+			matched, _ := path.Match(pattern, index.Index)
+			return matched
+		})
+		if !ignorable {
+			return false
+		}
+	}
+	return true
+}
+
+// formatIndices describes the largest indices remaining, with their shards and size
+func formatIndices(indices []indexShards) string {
+	described := make([]string, 0, min(len(indices), maxIndicesLogged))
+	for _, index := range indices[:min(len(indices), maxIndicesLogged)] {
+		described = append(described, fmt.Sprintf("%s (%d shards, %s)", index.Index, index.Shards, formatBytes(index.Bytes)))
+	}
+	if len(indices) > maxIndicesLogged {
+		described = append(described, fmt.Sprintf("%d more", len(indices)-maxIndicesLogged))
+	}
+	return strings.Join(described, ", ")
+}
+
+// formatBytes returns the size in the largest binary unit below it, like _cat/shards does
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%db", bytes)
+	}
+	value, units := float64(bytes), []string{"kb", "mb", "gb", "tb", "pb"}
+	i := -1
+	for value >= unit && i < len(units)-1 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f%s", value, units[i])
+}
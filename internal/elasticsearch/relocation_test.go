@@ -0,0 +1,71 @@
+package elasticsearch
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestRemainingIndices(t *testing.T) {
+	shards := []v1alpha1.ShardInfo{
+		{Index: "logs-2024.01.01", Shard: "0", Store: "2048", Node: "node-1"},
+		{Index: "logs-2024.01.01", Shard: "1", Store: "3072", Node: "node-1"},
+		{Index: ".kibana_task_manager", Shard: "0", Store: "512", Node: "node-1"},
+		{Index: "logs-2024.01.01", Shard: "2", Store: "4096", Node: "node-2"},
+		{Index: ".tasks", Shard: "0", Node: "node-1"},
+	}
+
+	got := remainingIndices(shards, regexp.MustCompile("node-1"))
+	want := []indexShards{
+		{Index: "logs-2024.01.01", Shards: 2, Bytes: 5120},
+		{Index: ".kibana_task_manager", Shards: 1, Bytes: 512},
+		{Index: ".tasks", Shards: 1, Bytes: 0},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("remainingIndices() = %v, want %v", got, want)
+	}
+	if countShards(got) != 4 {
+		t.Errorf("countShards() = %d, want 4", countShards(got))
+	}
+	if described := formatIndices(got); described != "logs-2024.01.01 (2 shards, 5.0kb), .kibana_task_manager (1 shards, 512b), .tasks (1 shards, 0b)" {
+		t.Errorf("formatIndices() = %q", described)
+	}
+}
+
+func TestOnlyIgnorableIndices(t *testing.T) {
+	patterns := []string{".kibana*", ".tasks"}
+
+	tests := []struct {
+		name    string
+		indices []indexShards
+		want    bool
+	}{
+		{name: "only ignorable", indices: []indexShards{{Index: ".kibana_task_manager"}, {Index: ".tasks"}}, want: true},
+		{name: "not ignorable", indices: []indexShards{{Index: ".kibana_task_manager"}, {Index: "logs-2024.01.01"}}, want: false},
+		{name: "none", want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := onlyIgnorableIndices(test.indices, patterns)
+			if got != test.want {
+				t.Errorf("onlyIgnorableIndices() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:                      "0b",
+		1023:                   "1023b",
+		1536:                   "1.5kb",
+		5 * 1024 * 1024 * 1024: "5.0gb",
+	}
+	for bytes, want := range tests {
+		if got := formatBytes(bytes); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", bytes, got, want)
+		}
+	}
+}
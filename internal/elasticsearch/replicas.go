@@ -0,0 +1,65 @@
+package elasticsearch
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/dryrun"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// DesiredReplicas returns the replicas of the indices matching the pattern with the given data nodes: one copy
+// of every shard in each node, within the bounds of the pattern
+func DesiredReplicas(spec v1alpha1.ReplicaSyncSpec, dataNodes int32) int {
+	replicas := max(int(dataNodes)-1, spec.MinReplicas, 0)
+	if spec.MaxReplicas > 0 {
+		replicas = min(replicas, spec.MaxReplicas)
+	}
+	return replicas
+}
+
+// SyncReplicas sets the replicas of the indices matching every pattern of replicaSync, following the data nodes
+// reached by a scaling action, so they do not wait for the next run of any external replica manager
+func SyncReplicas(ctx *v1alpha1.Context, dataNodes int32) error {
+	specs := ctx.Config.Target.Elasticsearch.ReplicaSync
+	if len(specs) == 0 || ctx.Config.Target.Elasticsearch.URL == "" {
+		return nil
+	}
+
+	es, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, spec := range specs {
+		replicas := DesiredReplicas(spec, dataNodes)
+		settings := fmt.Sprintf(`{"index":{"number_of_replicas":%d}}`, replicas)
+		if ctx.Config.Autoscaler.DryRunTarget {
+			dryrun.Record(ctx, dryrun.ModuleElasticsearch, "PUT %s/_settings %s", spec.Pattern, settings)
+			continue
+		}
+
+		err = retryCall(ctx, "Elasticsearch index settings update", func() error {
+			res, err := es.Indices.PutSettings(
+				strings.NewReader(settings),
+				es.Indices.PutSettings.WithIndex(spec.Pattern),
+				es.Indices.PutSettings.WithAllowNoIndices(true),
+				es.Indices.PutSettings.WithContext(ctx.ConnContext()),
+			)
+			if err != nil {
+				return fmt.Errorf("failed to update index settings: %w", err)
+			}
+			defer res.Body.Close()
+
+			if res.IsError() {
+				return fmt.Errorf("error updating index settings: %s", res.String())
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to set the replicas of the indices matching %s: %w", spec.Pattern, err)
+		}
+		log.Printf("Set %d replicas in the indices matching %s, following the %d data nodes", replicas, spec.Pattern, dataNodes)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package elasticsearch
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"testing"
+)
+
+func TestDesiredReplicas(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      v1alpha1.ReplicaSyncSpec
+		dataNodes int32
+		want      int
+	}{
+		{
+			name:      "one copy in each node",
+			spec:      v1alpha1.ReplicaSyncSpec{Pattern: "logs-*"},
+			dataNodes: 4,
+			want:      3,
+		},
+		{
+			name:      "above the maximum",
+			spec:      v1alpha1.ReplicaSyncSpec{Pattern: "logs-*", MaxReplicas: 2},
+			dataNodes: 6,
+			want:      2,
+		},
+		{
+			name:      "below the minimum",
+			spec:      v1alpha1.ReplicaSyncSpec{Pattern: "logs-*", MinReplicas: 1, MaxReplicas: 2},
+			dataNodes: 1,
+			want:      1,
+		},
+		{
+			name:      "no data nodes",
+			spec:      v1alpha1.ReplicaSyncSpec{Pattern: "logs-*"},
+			dataNodes: 0,
+			want:      0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := DesiredReplicas(test.spec, test.dataNodes); got != test.want {
+				t.Errorf("DesiredReplicas() = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
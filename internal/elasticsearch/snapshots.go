@@ -0,0 +1,121 @@
+package elasticsearch
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/notifier"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+const (
+	// SnapshotNodesPolicyDrain drains the nodes holding only searchable snapshots like any other data node
+	SnapshotNodesPolicyDrain = "drain"
+
+	// SnapshotNodesPolicyNoDrain removes the nodes holding only searchable snapshots without waiting for their shards
+	// to relocate, as their data is backed by the snapshot repository
+	SnapshotNodesPolicyNoDrain = "no-drain"
+)
+
+// snapshotStoreType is the index.store.type of the indices mounted from a snapshot, fully or partially
+const snapshotStoreType = "snapshot"
+
+// indexSettingsResponse is the response of GET _settings with flat settings, by index
+type indexSettingsResponse map[string]struct {
+	Settings map[string]interface{} `json:"settings"`
+}
+
+// isSnapshotOnlyNode returns true when every shard held by the node belongs to a searchable snapshot index.
+// Nodes holding no shard are not considered snapshot-only, so their removal follows the usual drain
+func isSnapshotOnlyNode(ctx *v1alpha1.Context, es *elasticsearch.Client, nodeName string) (bool, error) {
+	var shards []v1alpha1.ShardInfo
+	err := retryCall(ctx, "Elasticsearch shards request", func() error {
+		var err error
+		shards, err = getShards(ctx, es)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	var snapshotIndices map[string]bool
+	err = retryCall(ctx, "Elasticsearch index settings request", func() error {
+		var err error
+		snapshotIndices, err = getSnapshotIndices(ctx, es)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return holdsOnlySnapshots(shards, snapshotIndices, nodeName), nil
+}
+
+// removeSnapshotNode excludes the node holding only searchable snapshots from the allocations, so its shards are
+// recovered from the snapshot repository elsewhere, without waiting for them to relocate nor taking a drain slot
+func removeSnapshotNode(ctx *v1alpha1.Context, es *elasticsearch.Client, nodeName string) error {
+	err := retryCall(ctx, "Elasticsearch cluster settings update", func() error {
+		return updateClusterSettings(ctx, es, nodeName)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update cluster settings: %w", err)
+	}
+
+	log.Printf("Node %s holds only searchable snapshots, removing it without drain", nodeName)
+	notifier.NotifyDetailed(ctx, notifier.Notification{
+		Severity: notifier.SeverityInfo,
+		Event:    notifier.EventScaleDown,
+		Message:  fmt.Sprintf("Removing instance %s from elasticsearch without drain, holding only searchable snapshots", nodeName),
+		Thread:   DrainThread(nodeName),
+		Progress: true,
+	})
+	return nil
+}
+
+// holdsOnlySnapshots returns true when the node holds at least one shard, and all of them belong to the indices given
+func holdsOnlySnapshots(shards []v1alpha1.ShardInfo, snapshotIndices map[string]bool, nodeName string) bool {
+	held := 0
+	for _, shard := range shards {
+		if shard.Node != nodeName {
+			continue
+		}
+		if !snapshotIndices[shard.Index] {
+			return false
+		}
+		held++
+	}
+	return held > 0
+}
+
+// getSnapshotIndices returns the indices of the cluster mounted from a snapshot, including the hidden ones
+// of the frozen tier
+func getSnapshotIndices(ctx *v1alpha1.Context, es *elasticsearch.Client) (map[string]bool, error) {
+	res, err := es.Indices.GetSettings(
+		es.Indices.GetSettings.WithName("index.store.type"),
+		es.Indices.GetSettings.WithFlatSettings(true),
+		es.Indices.GetSettings.WithExpandWildcards("all"),
+		es.Indices.GetSettings.WithContext(ctx.ConnContext()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index settings: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("error getting index settings: %s", res.String())
+	}
+
+	var settings indexSettingsResponse
+	if err := json.NewDecoder(res.Body).Decode(&settings); err != nil {
+		return nil, fmt.Errorf("failed to decode index settings response: %w", err)
+	}
+
+	indices := map[string]bool{}
+	for index, spec := range settings {
+		if storeType, ok := spec.Settings["index.store.type"].(string); ok && storeType == snapshotStoreType {
+			indices[index] = true
+		}
+	}
+	return indices, nil
+}
@@ -0,0 +1,37 @@
+package elasticsearch
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"testing"
+)
+
+func TestHoldsOnlySnapshots(t *testing.T) {
+	shards := []v1alpha1.ShardInfo{
+		{Index: "partial-logs-2024.01.01", Shard: "0", Node: "frozen-1"},
+		{Index: "partial-logs-2024.01.02", Shard: "0", Node: "frozen-1"},
+		{Index: "partial-logs-2024.01.01", Shard: "0", Node: "frozen-2"},
+		{Index: "logs-2024.01.03", Shard: "0", Node: "frozen-2"},
+		{Index: "logs-2024.01.03", Shard: "0", Node: "hot-1"},
+	}
+	snapshotIndices := map[string]bool{"partial-logs-2024.01.01": true, "partial-logs-2024.01.02": true}
+
+	tests := []struct {
+		name string
+		node string
+		want bool
+	}{
+		{name: "only snapshot shards", node: "frozen-1", want: true},
+		{name: "mixed shards", node: "frozen-2", want: false},
+		{name: "no snapshot shards", node: "hot-1", want: false},
+		{name: "no shards", node: "empty-1", want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := holdsOnlySnapshots(shards, snapshotIndices, test.node)
+			if got != test.want {
+				t.Errorf("holdsOnlySnapshots() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package elasticsearch
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/notifier"
+	"custom-vm-autoscaler/internal/retry"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// inFlightActions are the actions of the tasks still running on a stateless node, like searches and bulk requests
+// coordinated by it
+const inFlightActions = "indices:*"
+
+// tasksResponse is the response of _tasks, grouping the tasks by node
+type tasksResponse struct {
+	Nodes map[string]struct {
+		Tasks map[string]struct {
+			Action      string `json:"action"`
+			Description string `json:"description,omitempty"`
+		} `json:"tasks"`
+	} `json:"nodes"`
+}
+
+// isStatelessNode returns true when the node has joined the cluster without any data role, like coordinating and
+// ingest-only nodes. Nodes not in the cluster are not considered stateless, so their drain is awaited as usual
+func isStatelessNode(ctx *v1alpha1.Context, nodeName string) (bool, error) {
+	nodes, err := GetNodes(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, node := range nodes {
+		if node.Name == nodeName {
+			return !IsDataNode(node), nil
+		}
+	}
+	return false, nil
+}
+
+// waitForInFlightRequests waits until the stateless node has no requests in flight, up to drainTimeoutSec.
+// The node is removed anyway after that, as it holds no shards and the clients retry the requests interrupted
+func waitForInFlightRequests(ctx *v1alpha1.Context, es *elasticsearch.Client, nodeName string) error {
+	deadline := time.Now().Add(time.Duration(ctx.Config.Target.Elasticsearch.DrainTimeoutSec) * time.Second)
+	for {
+		err := ctx.CheckLeadership()
+		if err != nil {
+			return fmt.Errorf("draining node %s interrupted: %w", nodeName, err)
+		}
+
+		var inFlight int
+		err = retryCall(ctx, "Elasticsearch tasks request", func() error {
+			inFlight, err = countInFlightRequests(ctx, es, nodeName)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if inFlight == 0 {
+			log.Printf("Node %s holds no data and has no requests in flight, ready to delete", nodeName)
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			log.Printf("Node %s still has %d requests in flight after %d seconds, removing it anyway", nodeName, inFlight, ctx.Config.Target.Elasticsearch.DrainTimeoutSec)
+			notifier.NotifyDetailed(ctx, notifier.Notification{
+				Severity: notifier.SeverityWarning,
+				Event:    notifier.EventScaleDown,
+				Message:  fmt.Sprintf("Removing instance %s with %d requests in flight, as it holds no data", nodeName, inFlight),
+				Thread:   DrainThread(nodeName),
+			})
+			return nil
+		}
+		err = retry.Sleep(ctx.ConnContext(), time.Duration(ctx.Config.Target.Elasticsearch.DrainPollIntervalSec)*time.Second)
+		if err != nil {
+			return fmt.Errorf("draining node %s interrupted: %w", nodeName, err)
+		}
+	}
+}
+
+// countInFlightRequests returns the number of indices tasks running on the node
+func countInFlightRequests(ctx *v1alpha1.Context, es *elasticsearch.Client, nodeName string) (int, error) {
+	res, err := es.Tasks.List(
+		es.Tasks.List.WithContext(ctx.ConnContext()),
+		es.Tasks.List.WithNodes(nodeName),
+		es.Tasks.List.WithActions(inFlightActions),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get tasks: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return 0, fmt.Errorf("error getting tasks: %s", res.String())
+	}
+
+	var tasks tasksResponse
+	err = json.NewDecoder(res.Body).Decode(&tasks)
+	if err != nil {
+		return 0, fmt.Errorf("error decoding tasks: %w", err)
+	}
+
+	inFlight := 0
+	for _, node := range tasks.Nodes {
+		inFlight += len(node.Tasks)
+	}
+	return inFlight, nil
+}
@@ -0,0 +1,62 @@
+package elasticsearch
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// nodeUptime is a node of _cat/nodes with its uptime in seconds
+type nodeUptime struct {
+	Name   string `json:"name"`
+	Uptime string `json:"uptime"`
+}
+
+// GetWarmingNodes returns the names of the nodes of the Elasticsearch cluster started less than the given period ago
+func GetWarmingNodes(ctx *v1alpha1.Context, period time.Duration) ([]string, error) {
+	es, err := newClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []nodeUptime
+	err = retryCall(ctx, "Elasticsearch nodes uptime request", func() error {
+		res, err := es.Cat.Nodes(
+			es.Cat.Nodes.WithContext(ctx.ConnContext()),
+			es.Cat.Nodes.WithFormat("json"),
+			es.Cat.Nodes.WithH("name", "uptime"),
+			es.Cat.Nodes.WithTime("s"),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to get nodes: %w", err)
+		}
+		defer res.Body.Close()
+
+		if res.IsError() {
+			return fmt.Errorf("error getting nodes: %s", res.String())
+		}
+		return json.NewDecoder(res.Body).Decode(&nodes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return warmingNodes(nodes, period), nil
+}
+
+// warmingNodes returns the names of the nodes whose uptime is below the period. Nodes without a valid uptime are
+// considered warm, so they are never excluded by mistake
+func warmingNodes(nodes []nodeUptime, period time.Duration) []string {
+	var warming []string
+	for _, node := range nodes {
+		uptime, err := strconv.ParseInt(node.Uptime, 10, 64)
+		if err != nil {
+			continue
+		}
+		if time.Duration(uptime)*time.Second < period {
+			warming = append(warming, node.Name)
+		}
+	}
+	return warming
+}
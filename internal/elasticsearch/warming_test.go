@@ -0,0 +1,22 @@
+package elasticsearch
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWarmingNodes(t *testing.T) {
+	nodes := []nodeUptime{
+		{Name: "es-1", Uptime: "86400"},
+		{Name: "es-2", Uptime: "120"},
+		{Name: "es-3", Uptime: "600"},
+		{Name: "es-4", Uptime: ""},
+	}
+
+	got := warmingNodes(nodes, 10*time.Minute)
+	want := []string{"es-2"}
+	if !slices.Equal(got, want) {
+		t.Errorf("warmingNodes() = %v, want %v", got, want)
+	}
+}
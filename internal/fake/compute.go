@@ -0,0 +1,366 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// instanceURLFormat is the URL of the instances returned by the Compute API, as GCP returns it
+const instanceURLFormat = "https://www.googleapis.com/compute/v1/projects/%s/zones/%s/instances/%s"
+
+// Compute serves the subset of the Compute API used by the autoscaler, keeping the MIGs in memory.
+// MIGs are created empty the first time they are requested, and their instances are running as soon as they are created,
+// unless they are set to stay booting
+type Compute struct {
+	mutex     sync.Mutex
+	migs      map[string]*managedInstanceGroup
+	instances map[string]*instance
+	created   int
+
+	// booting keeps the instances created in STAGING, so they never become ready
+	booting bool
+
+	// errorRate is the probability of failing the calls modifying the MIGs or their instances
+	errorRate float64
+
+	// OnCreate and OnRemove are called when an instance is added to or removed from a MIG
+	OnCreate func(name string)
+	OnRemove func(name string)
+}
+
+// managedInstanceGroup is a MIG served by the fake Compute API
+type managedInstanceGroup struct {
+	name      string
+	template  string
+	instances []string
+
+	// autoscaler is the URL of the GCE autoscaler attached to the MIG, if any
+	autoscaler string
+}
+
+// instance is an instance created by a MIG of the fake Compute API
+type instance struct {
+	url                string
+	ip                 string
+	status             string
+	template           string
+	deletionProtection bool
+	labels             map[string]string
+
+	// labelsVersion is the fingerprint of the labels, changed every time they are set
+	labelsVersion int
+}
+
+// NewCompute creates a fake Compute API without MIGs
+func NewCompute() *Compute {
+	return &Compute{
+		migs:      map[string]*managedInstanceGroup{},
+		instances: map[string]*instance{},
+	}
+}
+
+// Size returns the target size of the MIG living in the given zone or region
+func (c *Compute) Size(location string, name string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.getMIG(location, name).instances)
+}
+
+// Instances returns the names of the instances of the MIG living in the given zone or region
+func (c *Compute) Instances(location string, name string) []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	names := make([]string, 0, len(c.getMIG(location, name).instances))
+	for _, url := range c.getMIG(location, name).instances {
+		names = append(names, url[strings.LastIndex(url, "/")+1:])
+	}
+	return names
+}
+
+// SetDeletionProtection enables or disables the deletion protection of the instance
+func (c *Compute) SetDeletionProtection(name string, enabled bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if instance, ok := c.instances[name]; ok {
+		instance.deletionProtection = enabled
+	}
+}
+
+// SetAutoscaler attaches a GCE autoscaler with the given name to the MIG living in the given zone or region, or
+// detaches it when the name is empty
+func (c *Compute) SetAutoscaler(location string, name string, autoscaler string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	mig := c.getMIG(location, name)
+	mig.autoscaler = ""
+	if autoscaler != "" {
+		mig.autoscaler = fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/fake-project/zones/%s/autoscalers/%s", location, autoscaler)
+	}
+}
+
+// Labels returns the labels of the instance
+func (c *Compute) Labels(name string) map[string]string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if instance, ok := c.instances[name]; ok {
+		return maps.Clone(instance.labels)
+	}
+	return nil
+}
+
+// SetBooting keeps the instances created from now on booting, never running, so they do not pass the startup probe
+func (c *Compute) SetBooting(booting bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.booting = booting
+}
+
+// SetErrorRate sets the probability, from 0 to 1, of failing the calls modifying the MIGs or their instances,
+// like resizing them, with an internal error
+func (c *Compute) SetErrorRate(rate float64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.errorRate = rate
+}
+
+// ServeHTTP serves the requests to the instance group managers and instances of any project:
+// /compute/v1/projects/<project>/{zones,regions}/<location>/instanceGroupManagers/<name>[/<action>]
+// /compute/v1/projects/<project>/zones/<zone>/instances/<name>[/<action>]
+func (c *Compute) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/compute/v1/projects/"), "/")
+	if len(parts) < 5 || (parts[1] != "zones" && parts[1] != "regions") {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown resource %s", r.URL.Path))
+		return
+	}
+	project, location, resource, name := parts[0], parts[2], parts[3], parts[4]
+	action := ""
+	if len(parts) > 5 {
+		action = parts[5]
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Fail the calls modifying the resources randomly, when chaos is injected
+	if action != "" && action != "listManagedInstances" && rand.Float64() < c.errorRate {
+		log.Printf("Chaos: failing %s of %s %s", action, resource, name)
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("chaos injected in %s of %s", action, name))
+		return
+	}
+
+	switch resource {
+	case "instanceGroupManagers":
+		c.serveMIG(w, r, project, parts[1] == "regions", location, name, action)
+	case "instances":
+		c.serveInstance(w, r, name, action)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown resource %s", resource))
+	}
+}
+
+// serveMIG serves the requests to a MIG
+func (c *Compute) serveMIG(w http.ResponseWriter, r *http.Request, project string, regional bool, location string, name string, action string) {
+	mig := c.getMIG(location, name)
+
+	switch action {
+	case "":
+		status := map[string]any{"isStable": true}
+		if mig.autoscaler != "" {
+			status["autoscaler"] = mig.autoscaler
+		}
+		writeJSON(w, map[string]any{"name": mig.name, "targetSize": len(mig.instances), "instanceTemplate": mig.template, "status": status})
+
+	case "resize":
+		size, err := strconv.Atoi(r.URL.Query().Get("size"))
+		if err != nil || size < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid size %q", r.URL.Query().Get("size")))
+			return
+		}
+		for len(mig.instances) < size {
+			c.created++
+			instanceName := fmt.Sprintf("%s-%04d", mig.name, c.created)
+			zone := location
+			if regional {
+				zone = location + "-" + string(rune('a'+c.created%3))
+			}
+			status := "RUNNING"
+			if c.booting {
+				status = "STAGING"
+			}
+			c.instances[instanceName] = &instance{
+				url:      fmt.Sprintf(instanceURLFormat, project, zone, instanceName),
+				ip:       fmt.Sprintf("10.0.%d.%d", c.created/256, c.created%256),
+				status:   status,
+				template: mig.template,
+			}
+			mig.instances = append(mig.instances, c.instances[instanceName].url)
+			c.notify(c.OnCreate, instanceName)
+		}
+		for len(mig.instances) > size {
+			c.remove(mig, mig.instances[len(mig.instances)-1], true)
+		}
+		writeOperation(w, c.created)
+
+	case "listManagedInstances":
+		managedInstances := make([]map[string]any, 0, len(mig.instances))
+		for _, url := range mig.instances {
+			instance := c.instances[url[strings.LastIndex(url, "/")+1:]]
+			managedInstances = append(managedInstances, map[string]any{
+				"instance":       url,
+				"instanceStatus": instance.status,
+				"currentAction":  "NONE",
+				"version":        map[string]any{"instanceTemplate": instance.template},
+			})
+		}
+		writeJSON(w, map[string]any{"managedInstances": managedInstances})
+
+	case "deleteInstances", "abandonInstances":
+		var request struct {
+			Instances []string `json:"instances"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+			return
+		}
+		for _, url := range request.Instances {
+			if !slices.Contains(mig.instances, url) {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("instance %s is not managed by %s", url, mig.name))
+				return
+			}
+		}
+		for _, url := range request.Instances {
+			c.remove(mig, url, action == "deleteInstances")
+		}
+		writeOperation(w, c.created)
+
+	// Recreated instances are created from the current template of the MIG
+	case "recreateInstances":
+		var request struct {
+			Instances []string `json:"instances"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+			return
+		}
+		for _, url := range request.Instances {
+			if instance, ok := c.instances[url[strings.LastIndex(url, "/")+1:]]; ok {
+				instance.template = mig.template
+			}
+		}
+		writeOperation(w, c.created)
+
+	case "setInstanceTemplate":
+		var request struct {
+			InstanceTemplate string `json:"instanceTemplate"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+			return
+		}
+		mig.template = request.InstanceTemplate
+		writeOperation(w, c.created)
+
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown action %s", action))
+	}
+}
+
+// serveInstance serves the requests to an instance
+func (c *Compute) serveInstance(w http.ResponseWriter, r *http.Request, name string, action string) {
+	instance, ok := c.instances[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("instance %s not found", name))
+		return
+	}
+
+	switch action {
+	case "":
+		writeJSON(w, map[string]any{
+			"name":               name,
+			"status":             instance.status,
+			"deletionProtection": instance.deletionProtection,
+			"networkInterfaces":  []map[string]any{{"networkIP": instance.ip}},
+			"labels":             instance.labels,
+			"labelFingerprint":   strconv.Itoa(instance.labelsVersion),
+		})
+	case "stop":
+		instance.status = "TERMINATED"
+		writeOperation(w, c.created)
+
+	// Labels are only set with the fingerprint of the current ones, as GCP requires
+	case "setLabels":
+		var request struct {
+			Labels           map[string]string `json:"labels"`
+			LabelFingerprint string            `json:"labelFingerprint"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+			return
+		}
+		if request.LabelFingerprint != strconv.Itoa(instance.labelsVersion) {
+			writeError(w, http.StatusPreconditionFailed, fmt.Sprintf("labels of instance %s changed", name))
+			return
+		}
+		instance.labels = request.Labels
+		instance.labelsVersion++
+		writeOperation(w, c.created)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown action %s", action))
+	}
+}
+
+// getMIG returns the MIG with the given name, creating it empty when it does not exist yet
+func (c *Compute) getMIG(location string, name string) *managedInstanceGroup {
+	key := location + "/" + name
+	if _, ok := c.migs[key]; !ok {
+		c.migs[key] = &managedInstanceGroup{name: name}
+	}
+	return c.migs[key]
+}
+
+// remove removes the instance from the MIG. Deleted instances are forgotten, while abandoned ones keep existing
+func (c *Compute) remove(mig *managedInstanceGroup, url string, deleted bool) {
+	mig.instances = slices.DeleteFunc(mig.instances, func(current string) bool { return current == url })
+	name := url[strings.LastIndex(url, "/")+1:]
+	if deleted {
+		delete(c.instances, name)
+		c.notify(c.OnRemove, name)
+	}
+}
+
+// notify calls the callback with the name of the instance, if any
+func (c *Compute) notify(callback func(string), name string) {
+	if callback != nil {
+		callback(name)
+	}
+}
+
+// writeOperation writes a finished operation, as the autoscaler does not wait for them
+func writeOperation(w http.ResponseWriter, id int) {
+	writeJSON(w, map[string]any{"kind": "compute#operation", "name": fmt.Sprintf("operation-%d", id), "status": "DONE"})
+}
+
+// writeJSON writes the response encoded in JSON
+func writeJSON(w http.ResponseWriter, response any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// writeError writes an error in the format of the Google APIs
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": code, "message": message}})
+}
@@ -0,0 +1,361 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// excludeSetting is the cluster setting excluding the nodes from the allocation of shards, set by the drains
+const excludeSetting = "cluster.routing.allocation.exclude._name"
+
+// Elasticsearch serves the subset of the Elasticsearch API used by the autoscaler, keeping the cluster in memory.
+// Every node joins with the same number of shards, and the shards of the excluded nodes are relocated to the rest
+// once the drain delay passes
+type Elasticsearch struct {
+	mutex    sync.Mutex
+	nodes    []*node
+	excluded []string
+
+	shardsPerNode int
+	drainDelay    time.Duration
+	stuck         map[string]bool
+
+	// errorRate is the probability of failing any request, and stuckRate the one of a drain never finishing
+	errorRate float64
+	stuckRate float64
+
+	// documents are the documents stored by path, like the drain locks
+	documents map[string]*document
+	seqNo     int
+
+	// replicas are the replicas set in the indices, by pattern
+	replicas map[string]int
+}
+
+// document is a document stored in the fake Elasticsearch cluster
+type document struct {
+	source json.RawMessage
+	seqNo  int
+}
+
+// node is a node of the fake Elasticsearch cluster, with the roles abbreviated as in _cat/nodes
+type node struct {
+	name       string
+	roles      string
+	shards     int
+	excludedAt time.Time
+
+	// stuck is set when chaos makes the current drain of the node never finish
+	stuck bool
+}
+
+// NewElasticsearch creates a fake Elasticsearch cluster without nodes, where the drains take the given delay
+func NewElasticsearch(shardsPerNode int, drainDelay time.Duration) *Elasticsearch {
+	return &Elasticsearch{
+		shardsPerNode: shardsPerNode,
+		drainDelay:    drainDelay,
+		stuck:         map[string]bool{},
+		documents:     map[string]*document{},
+		replicas:      map[string]int{},
+	}
+}
+
+// AddNode joins a data node to the cluster, allocating its shards
+func (e *Elasticsearch) AddNode(name string) {
+	e.AddNodeWithRoles(name, "dim")
+}
+
+// AddNodeWithRoles joins a node with the roles abbreviated as in _cat/nodes to the cluster, allocating its shards
+// when it is a data node
+func (e *Elasticsearch) AddNodeWithRoles(name string, roles string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	shards := 0
+	if strings.ContainsAny(roles, "dshwcf") {
+		shards = e.shardsPerNode
+	}
+	e.nodes = append(e.nodes, &node{name: name, roles: roles, shards: shards})
+}
+
+// RemoveNode removes the node from the cluster, relocating its shards to the rest of the nodes
+func (e *Elasticsearch) RemoveNode(name string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	index := slices.IndexFunc(e.nodes, func(n *node) bool { return n.name == name })
+	if index == -1 {
+		return
+	}
+	removed := e.nodes[index]
+	e.nodes = slices.Delete(e.nodes, index, index+1)
+	e.relocate(removed)
+}
+
+// SetDrainDelay sets the time the shards of the excluded nodes take to be relocated
+func (e *Elasticsearch) SetDrainDelay(drainDelay time.Duration) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.drainDelay = drainDelay
+}
+
+// SetStuck makes the shards of the node never leave it when it is drained, so the drain times out
+func (e *Elasticsearch) SetStuck(name string, stuck bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.stuck[name] = stuck
+}
+
+// SetErrorRate sets the probability, from 0 to 1, of failing any request with an internal error
+func (e *Elasticsearch) SetErrorRate(rate float64) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.errorRate = rate
+}
+
+// SetStuckRate sets the probability, from 0 to 1, of the shards of a node never leaving it when it is excluded,
+// so its drain times out. It is decided every time the node is excluded
+func (e *Elasticsearch) SetStuckRate(rate float64) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.stuckRate = rate
+}
+
+// Exclude excludes the nodes from the allocation of shards, as a drain interrupted by a crash leaves them
+func (e *Elasticsearch) Exclude(names ...string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	value := strings.Join(names, ",")
+	e.exclude(&value)
+}
+
+// Excluded returns the names of the nodes excluded from the allocation of shards
+func (e *Elasticsearch) Excluded() []string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return slices.Clone(e.excluded)
+}
+
+// Replicas returns the replicas set in the indices matching the pattern, and whether they were set
+func (e *Elasticsearch) Replicas(pattern string) (int, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	replicas, ok := e.replicas[pattern]
+	return replicas, ok
+}
+
+// Shards returns the number of shards allocated in the node
+func (e *Elasticsearch) Shards(name string) int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.drain()
+	for _, n := range e.nodes {
+		if n.name == name {
+			return n.shards
+		}
+	}
+	return 0
+}
+
+// ServeHTTP serves the requests to the cluster health, settings, nodes and shards, and to the documents
+func (e *Elasticsearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The client refuses the responses without this header
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.drain()
+
+	// Fail the requests randomly, when chaos is injected
+	if rand.Float64() < e.errorRate {
+		log.Printf("Chaos: failing Elasticsearch request %s %s", r.Method, r.URL.Path)
+		writeESError(w, http.StatusInternalServerError, fmt.Sprintf("chaos injected in %s %s", r.Method, r.URL.Path))
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/_cluster/health":
+		relocating := 0
+		for _, n := range e.nodes {
+			if !n.excludedAt.IsZero() {
+				relocating += n.shards
+			}
+		}
+		writeJSON(w, map[string]any{"status": "green", "relocating_shards": relocating, "initializing_shards": 0, "unassigned_shards": 0})
+
+	case r.URL.Path == "/_cluster/settings" && r.Method == http.MethodGet:
+		persistent := map[string]any{}
+		if len(e.excluded) > 0 {
+			persistent["cluster"] = map[string]any{"routing": map[string]any{"allocation": map[string]any{
+				"exclude": map[string]any{"_name": strings.Join(e.excluded, ",")},
+			}}}
+		}
+		writeJSON(w, map[string]any{"persistent": persistent, "transient": map[string]any{}})
+
+	case r.URL.Path == "/_cluster/settings" && r.Method == http.MethodPut:
+		var settings struct {
+			Persistent map[string]*string `json:"persistent"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&settings)
+		if err != nil {
+			writeESError(w, http.StatusBadRequest, fmt.Sprintf("invalid settings: %v", err))
+			return
+		}
+		if value, ok := settings.Persistent[excludeSetting]; ok {
+			e.exclude(value)
+		}
+		writeJSON(w, map[string]any{"acknowledged": true})
+
+	case r.URL.Path == "/_cat/nodes":
+		nodes := make([]map[string]string, 0, len(e.nodes))
+		for _, n := range e.nodes {
+			nodes = append(nodes, map[string]string{"name": n.name, "node.role": n.roles, "heap.percent": "50", "disk.used_percent": "50"})
+		}
+		writeJSON(w, nodes)
+
+	case r.URL.Path == "/_tasks":
+		writeJSON(w, map[string]any{"nodes": map[string]any{}})
+
+	case r.URL.Path == "/_cat/shards":
+		shards := []map[string]string{}
+		for _, n := range e.nodes {
+			for i := range n.shards {
+				shards = append(shards, map[string]string{"index": "fake", "shard": strconv.Itoa(i), "prirep": "p", "state": "STARTED", "node": n.name})
+			}
+		}
+		writeJSON(w, shards)
+
+	case strings.Contains(r.URL.Path, "/_settings") && r.Method == http.MethodGet:
+		writeJSON(w, map[string]any{"fake": map[string]any{"settings": map[string]any{}}})
+
+	case strings.HasSuffix(r.URL.Path, "/_settings") && r.Method == http.MethodPut:
+		var settings struct {
+			Index struct {
+				NumberOfReplicas *int `json:"number_of_replicas"`
+			} `json:"index"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&settings)
+		if err != nil {
+			writeESError(w, http.StatusBadRequest, fmt.Sprintf("invalid settings: %v", err))
+			return
+		}
+		if settings.Index.NumberOfReplicas != nil {
+			e.replicas[strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), "/_settings")] = *settings.Index.NumberOfReplicas
+		}
+		writeJSON(w, map[string]any{"acknowledged": true})
+
+	case strings.Contains(r.URL.Path, "/_create/") || strings.Contains(r.URL.Path, "/_doc/"):
+		e.serveDocument(w, r)
+
+	default:
+		writeESError(w, http.StatusNotFound, fmt.Sprintf("unknown endpoint %s %s", r.Method, r.URL.Path))
+	}
+}
+
+// serveDocument serves the creation, indexing, retrieval and deletion of a document, with optimistic concurrency control:
+// /<index>/_create/<id> and /<index>/_doc/<id>
+func (e *Elasticsearch) serveDocument(w http.ResponseWriter, r *http.Request) {
+	create := strings.Contains(r.URL.Path, "/_create/")
+	key := strings.Replace(r.URL.Path, "/_create/", "/_doc/", 1)
+	current, exists := e.documents[key]
+
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			writeESError(w, http.StatusNotFound, fmt.Sprintf("document %s not found", key))
+			return
+		}
+		writeJSON(w, map[string]any{"found": true, "_seq_no": current.seqNo, "_primary_term": 1, "_source": current.source})
+
+	case http.MethodPut, http.MethodPost:
+		if create && exists {
+			writeESError(w, http.StatusConflict, fmt.Sprintf("document %s already exists", key))
+			return
+		}
+		if seqNo := r.URL.Query().Get("if_seq_no"); seqNo != "" && (!exists || seqNo != strconv.Itoa(current.seqNo)) {
+			writeESError(w, http.StatusConflict, fmt.Sprintf("document %s was modified", key))
+			return
+		}
+		var source json.RawMessage
+		err := json.NewDecoder(r.Body).Decode(&source)
+		if err != nil {
+			writeESError(w, http.StatusBadRequest, fmt.Sprintf("invalid document: %v", err))
+			return
+		}
+		e.seqNo++
+		e.documents[key] = &document{source: source, seqNo: e.seqNo}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, map[string]any{"result": "created", "_seq_no": e.seqNo, "_primary_term": 1})
+
+	case http.MethodDelete:
+		if !exists {
+			writeESError(w, http.StatusNotFound, fmt.Sprintf("document %s not found", key))
+			return
+		}
+		if seqNo := r.URL.Query().Get("if_seq_no"); seqNo != "" && seqNo != strconv.Itoa(current.seqNo) {
+			writeESError(w, http.StatusConflict, fmt.Sprintf("document %s was modified", key))
+			return
+		}
+		delete(e.documents, key)
+		writeJSON(w, map[string]any{"result": "deleted"})
+
+	default:
+		writeESError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+	}
+}
+
+// exclude replaces the nodes excluded from the allocation of shards. A nil value clears the setting
+func (e *Elasticsearch) exclude(value *string) {
+	e.excluded = nil
+	if value != nil && *value != "" {
+		e.excluded = strings.Split(*value, ",")
+	}
+	for _, n := range e.nodes {
+		switch {
+		case !slices.Contains(e.excluded, n.name):
+			n.excludedAt = time.Time{}
+			n.stuck = false
+		case n.excludedAt.IsZero():
+			n.excludedAt = time.Now()
+			n.stuck = rand.Float64() < e.stuckRate
+			if n.stuck {
+				log.Printf("Chaos: the drain of node %s will never finish", n.name)
+			}
+		}
+	}
+}
+
+// drain relocates the shards of the excluded nodes whose drain delay passed, unless they are stuck
+func (e *Elasticsearch) drain() {
+	for _, n := range e.nodes {
+		if n.excludedAt.IsZero() || n.shards == 0 || e.stuck[n.name] || n.stuck || time.Since(n.excludedAt) < e.drainDelay {
+			continue
+		}
+		e.relocate(n)
+	}
+}
+
+// relocate moves the shards of the node to the first node not excluded. They are lost when there is none
+func (e *Elasticsearch) relocate(from *node) {
+	for _, n := range e.nodes {
+		if n != from && !slices.Contains(e.excluded, n.name) {
+			n.shards += from.shards
+			break
+		}
+	}
+	from.shards = 0
+}
+
+// writeESError writes an error in the format of Elasticsearch
+func writeESError(w http.ResponseWriter, code int, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"type": "fake_exception", "reason": reason}, "status": code})
+}
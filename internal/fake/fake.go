@@ -0,0 +1,113 @@
+// Package fake serves in-memory replacements of the Compute API, Elasticsearch and Prometheus, so the autoscaler
+// can be executed end to end without GCP credentials nor real clusters, like in CI. The real clients of the
+// autoscaler talk to them over HTTP, so the drains, timeouts and rollbacks behave as they do in production
+package fake
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/google"
+	"fmt"
+	"net/http/httptest"
+	"time"
+)
+
+const (
+	// BackendFake is the backend of the commands served by this package, instead of GCP
+	BackendFake = "fake"
+
+	// BackendGCP is the default backend of the commands, talking to the real services
+	BackendGCP = "gcp"
+
+	// Defaults of the fake Elasticsearch cluster
+	defaultShardsPerNode = 2
+	defaultDrainDelay    = 5 * time.Second
+)
+
+// Chaos are the probabilities, from 0 to 1, of the failures injected in the fake services, to exercise
+// the error handling and rollback paths of the autoscaler
+type Chaos struct {
+	// ElasticsearchErrors fails the requests to Elasticsearch with internal errors
+	ElasticsearchErrors float64
+
+	// GCPErrors fails the calls modifying the MIGs, like resizing them, with internal errors
+	GCPErrors float64
+
+	// DrainTimeouts makes the drains of the nodes never finish, so they time out and are rolled back
+	DrainTimeouts float64
+}
+
+// Validate checks that every probability is between 0 and 1
+func (c Chaos) Validate() error {
+	for name, rate := range map[string]float64{
+		"elasticsearch errors": c.ElasticsearchErrors,
+		"GCP errors":           c.GCPErrors,
+		"drain timeouts":       c.DrainTimeouts,
+	} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("invalid rate of %s %g, expected a value between 0 and 1", name, rate)
+		}
+	}
+	return nil
+}
+
+// Backend groups the fake services. The instances created in the MIGs join the Elasticsearch cluster,
+// and leave it when they are deleted
+type Backend struct {
+	Compute       *Compute
+	Elasticsearch *Elasticsearch
+	Prometheus    *Prometheus
+
+	computeServer       *httptest.Server
+	elasticsearchServer *httptest.Server
+	prometheusServer    *httptest.Server
+}
+
+// Start serves the fake services in local ports and sends the calls to the Compute API to them
+func Start() *Backend {
+	backend := &Backend{
+		Compute:       NewCompute(),
+		Elasticsearch: NewElasticsearch(defaultShardsPerNode, defaultDrainDelay),
+		Prometheus:    NewPrometheus(),
+	}
+	backend.Compute.OnCreate = backend.Elasticsearch.AddNode
+	backend.Compute.OnRemove = backend.Elasticsearch.RemoveNode
+
+	backend.computeServer = httptest.NewServer(backend.Compute)
+	backend.elasticsearchServer = httptest.NewServer(backend.Elasticsearch)
+	backend.prometheusServer = httptest.NewServer(backend.Prometheus)
+	google.SetEndpoint(backend.computeServer.URL)
+	return backend
+}
+
+// Apply points the config, and the one of every autoscaler defined in it, to the fake Elasticsearch and Prometheus.
+// Credentials are removed, as the fake services do not need them
+func (b *Backend) Apply(config *v1alpha1.ConfigSpec) {
+	config.Infrastructure.GCP.CredentialsFile = ""
+	config.Infrastructure.GCP.ImpersonateServiceAccount = ""
+	config.Infrastructure.GCP.WorkloadIdentityFederation = v1alpha1.WorkloadIdentityFederationSpec{}
+	config.Metrics.Prometheus.URL = b.prometheusServer.URL
+	if config.Target.Elasticsearch.URL != "" {
+		config.Target.Elasticsearch.URL = b.elasticsearchServer.URL
+		config.Target.Elasticsearch.User = ""
+		config.Target.Elasticsearch.Password = ""
+	}
+
+	for i := range config.Autoscalers {
+		b.Apply(&config.Autoscalers[i])
+	}
+}
+
+// SetChaos injects failures in the fake services with the given probabilities
+func (b *Backend) SetChaos(chaos Chaos) {
+	b.Elasticsearch.SetErrorRate(chaos.ElasticsearchErrors)
+	b.Elasticsearch.SetStuckRate(chaos.DrainTimeouts)
+	b.Compute.SetErrorRate(chaos.GCPErrors)
+}
+
+// Close stops serving the fake services, sending the calls to the Compute API to GCP again
+func (b *Backend) Close() {
+	google.SetEndpoint("")
+	b.computeServer.Close()
+	b.elasticsearchServer.Close()
+	b.prometheusServer.Close()
+}
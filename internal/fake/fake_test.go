@@ -0,0 +1,264 @@
+package fake_test
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/fake"
+	"custom-vm-autoscaler/pkg/autoscaler"
+	"custom-vm-autoscaler/pkg/provider"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const testConfig = `
+name: "fake"
+metrics:
+  prometheus:
+    upCondition: "up_condition"
+    downCondition: "down_condition"
+infrastructure:
+  gcp:
+    projectId: "fake-project"
+    zone: "europe-west1-b"
+    migName: "fake-mig"
+    scaleDownAction: "abandon"
+    drainStateLabel: "autoscaler-state"
+target:
+  elasticsearch:
+    url: "https://localhost:9200"
+    drainTimeoutSec: 2
+    drainPollIntervalSec: 1
+    maxConcurrentDrains: 1
+    drainLockIndex: "drain-locks"
+retry:
+  maxAttempts: 2
+  initialIntervalSec: 1
+autoscaler:
+  minSize: 1
+  maxSize: 3
+  scaleUpThreshold: 1
+`
+
+// newAutoscaler starts the fake backend, and creates an autoscaler using it with two nodes in its MIG
+func newAutoscaler(t *testing.T) (*fake.Backend, *v1alpha1.Context) {
+	t.Helper()
+	backend := fake.Start()
+	t.Cleanup(backend.Close)
+	backend.Elasticsearch.SetDrainDelay(0)
+
+	var config v1alpha1.ConfigSpec
+	err := yaml.UnmarshalStrict([]byte(testConfig), &config)
+	if err != nil {
+		t.Fatalf("error parsing config: %v", err)
+	}
+	backend.Apply(&config)
+
+	autoscalers, err := autoscaler.New(config)
+	if err != nil {
+		t.Fatalf("error creating autoscaler: %v", err)
+	}
+	ctx := autoscalers[0].Context()
+
+	for range 2 {
+		_, _, _, _, err = provider.AddNode(ctx)
+		if err != nil {
+			t.Fatalf("error adding node: %v", err)
+		}
+	}
+	return backend, ctx
+}
+
+func TestScaleUp(t *testing.T) {
+	backend, ctx := newAutoscaler(t)
+
+	mig, previousSize, size, maxSize, err := provider.AddNode(ctx)
+	if err != nil {
+		t.Fatalf("error adding node: %v", err)
+	}
+	if mig != "fake-mig" || previousSize != 2 || size != 3 || maxSize != 3 {
+		t.Errorf("AddNode() = (%s, %d, %d, %d), want (fake-mig, 2, 3, 3)", mig, previousSize, size, maxSize)
+	}
+
+	// Every instance joins the Elasticsearch cluster
+	nodes, err := provider.InstanceNames(ctx, "fake-mig")
+	if err != nil {
+		t.Fatalf("error listing instances: %v", err)
+	}
+	for _, node := range backend.Compute.Instances("europe-west1-b", "fake-mig") {
+		if backend.Elasticsearch.Shards(node) == 0 {
+			t.Errorf("instance %s has not joined the cluster", node)
+		}
+	}
+	if len(nodes) != 3 {
+		t.Errorf("got %d instances, want 3", len(nodes))
+	}
+
+	// The maximum size is not exceeded
+	_, _, size, _, err = provider.AddNode(ctx)
+	if err != nil || size != -1 {
+		t.Errorf("AddNode() over the maximum size = (%d, %v), want (-1, nil)", size, err)
+	}
+}
+
+func TestScaleDownDrainsNode(t *testing.T) {
+	backend, ctx := newAutoscaler(t)
+
+	_, previousSize, size, _, instance, err := provider.RemoveNode(ctx)
+	if err != nil {
+		t.Fatalf("error removing node: %v", err)
+	}
+	if previousSize != 2 || size != 1 {
+		t.Errorf("RemoveNode() sizes = (%d, %d), want (2, 1)", previousSize, size)
+	}
+	if slices.Contains(backend.Compute.Instances("europe-west1-b", "fake-mig"), instance) {
+		t.Errorf("instance %s is still in the MIG", instance)
+	}
+
+	// Abandoned instances are drained, and their exclusion is cleared once they left the MIG
+	if shards := backend.Elasticsearch.Shards(instance); shards != 0 {
+		t.Errorf("instance %s still holds %d shards", instance, shards)
+	}
+	if excluded := backend.Elasticsearch.Excluded(); len(excluded) != 0 {
+		t.Errorf("excluded nodes = %v, want none", excluded)
+	}
+
+	// The drain state label is removed once the instance is abandoned
+	if labels := backend.Compute.Labels(instance); labels["autoscaler-state"] != "" {
+		t.Errorf("instance %s labels = %v, want no drain state", instance, labels)
+	}
+}
+
+func TestDrainTimeoutRollsBack(t *testing.T) {
+	backend, ctx := newAutoscaler(t)
+	instances := backend.Compute.Instances("europe-west1-b", "fake-mig")
+	for _, instance := range instances {
+		backend.Elasticsearch.SetStuck(instance, true)
+	}
+
+	start := time.Now()
+	_, _, _, _, _, err := provider.RemoveNode(ctx)
+	if err == nil {
+		t.Fatalf("RemoveNode() succeeded, want a drain timeout")
+	}
+	if elapsed := time.Since(start); elapsed < 2*time.Second {
+		t.Errorf("RemoveNode() failed after %s, before the drain timeout: %v", elapsed, err)
+	}
+
+	// The node is allocated shards again, and kept in the MIG
+	if excluded := backend.Elasticsearch.Excluded(); len(excluded) != 0 {
+		t.Errorf("excluded nodes = %v, want none", excluded)
+	}
+	if size := backend.Compute.Size("europe-west1-b", "fake-mig"); size != len(instances) {
+		t.Errorf("MIG size = %d, want %d", size, len(instances))
+	}
+	for _, instance := range instances {
+		if labels := backend.Compute.Labels(instance); labels["autoscaler-state"] != "" {
+			t.Errorf("instance %s labels = %v, want no drain state", instance, labels)
+		}
+	}
+}
+
+func TestInterlockWithGCEAutoscaler(t *testing.T) {
+	backend, ctx := newAutoscaler(t)
+	backend.Compute.SetAutoscaler("europe-west1-b", "fake-mig", "fake-autoscaler")
+
+	// Neither scale ups nor scale downs are done while a GCE autoscaler is attached
+	_, _, _, _, err := provider.AddNode(ctx)
+	if !errors.Is(err, provider.ErrMIGInterlocked) {
+		t.Errorf("AddNode() = %v, want %v", err, provider.ErrMIGInterlocked)
+	}
+	_, _, _, _, _, err = provider.RemoveNode(ctx)
+	if !errors.Is(err, provider.ErrMIGInterlocked) {
+		t.Errorf("RemoveNode() = %v, want %v", err, provider.ErrMIGInterlocked)
+	}
+	if size := backend.Compute.Size("europe-west1-b", "fake-mig"); size != 2 {
+		t.Errorf("MIG size = %d, want 2", size)
+	}
+
+	// Scaling resumes once it is detached
+	backend.Compute.SetAutoscaler("europe-west1-b", "fake-mig", "")
+	_, _, size, _, err := provider.AddNode(ctx)
+	if err != nil || size != 3 {
+		t.Errorf("AddNode() = (%d, %v), want (3, nil)", size, err)
+	}
+}
+
+func TestChaos(t *testing.T) {
+	tests := []struct {
+		name  string
+		chaos fake.Chaos
+		scale func(ctx *v1alpha1.Context) error
+	}{
+		{
+			name:  "GCP errors fail the scale up",
+			chaos: fake.Chaos{GCPErrors: 1},
+			scale: func(ctx *v1alpha1.Context) error {
+				_, _, _, _, err := provider.AddNode(ctx)
+				return err
+			},
+		},
+		{
+			name:  "drain timeouts roll back the scale down",
+			chaos: fake.Chaos{DrainTimeouts: 1},
+			scale: func(ctx *v1alpha1.Context) error {
+				_, _, _, _, _, err := provider.RemoveNode(ctx)
+				return err
+			},
+		},
+		{
+			name:  "Elasticsearch errors fail the scale down",
+			chaos: fake.Chaos{ElasticsearchErrors: 1},
+			scale: func(ctx *v1alpha1.Context) error {
+				_, _, _, _, _, err := provider.RemoveNode(ctx)
+				return err
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backend, ctx := newAutoscaler(t)
+			instances := backend.Compute.Instances("europe-west1-b", "fake-mig")
+			backend.SetChaos(test.chaos)
+
+			err := test.scale(ctx)
+			if err == nil {
+				t.Fatalf("scaling succeeded, want an error")
+			}
+
+			// Nothing is left half done: the MIG keeps its instances, and none of them is excluded
+			backend.SetChaos(fake.Chaos{})
+			if got := backend.Compute.Instances("europe-west1-b", "fake-mig"); !slices.Equal(got, instances) {
+				t.Errorf("instances = %v, want %v", got, instances)
+			}
+			if excluded := backend.Elasticsearch.Excluded(); len(excluded) != 0 {
+				t.Errorf("excluded nodes = %v, want none", excluded)
+			}
+		})
+	}
+}
+
+func TestChaosValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		chaos   fake.Chaos
+		wantErr bool
+	}{
+		{name: "no chaos", chaos: fake.Chaos{}},
+		{name: "every failure", chaos: fake.Chaos{ElasticsearchErrors: 1, GCPErrors: 0.5, DrainTimeouts: 0.1}},
+		{name: "negative rate", chaos: fake.Chaos{GCPErrors: -0.1}, wantErr: true},
+		{name: "rate over 1", chaos: fake.Chaos{DrainTimeouts: 1.5}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.chaos.Validate()
+			if (err != nil) != test.wantErr {
+				t.Errorf("Validate() = %v, want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Prometheus serves the instant queries of the scaling conditions. Queries are not met until they are set
+type Prometheus struct {
+	mutex      sync.Mutex
+	conditions map[string]bool
+}
+
+// NewPrometheus creates a fake Prometheus where no condition is met
+func NewPrometheus() *Prometheus {
+	return &Prometheus{conditions: map[string]bool{}}
+}
+
+// SetCondition sets whether the query returns a sample, meeting the condition
+func (p *Prometheus) SetCondition(query string, met bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.conditions[query] = met
+}
+
+// ServeHTTP serves the instant queries, returning a sample for the conditions met and none for the rest
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/v1/query" {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, map[string]any{"status": "error", "errorType": "not_found", "error": "unknown endpoint " + r.URL.Path})
+		return
+	}
+
+	p.mutex.Lock()
+	met := p.conditions[r.FormValue("query")]
+	p.mutex.Unlock()
+
+	result := []map[string]any{}
+	if met {
+		now := strconv.FormatFloat(float64(time.Now().UnixMilli())/1000, 'f', 3, 64)
+		result = append(result, map[string]any{"metric": map[string]string{"source": "fake"}, "value": []any{json.Number(now), "1"}})
+	}
+	writeJSON(w, map[string]any{"status": "success", "data": map[string]any{"resultType": "vector", "result": result}})
+}
@@ -0,0 +1,110 @@
+// Package gcpauth builds the credentials of the calls to GCP from the config: a credentials file,
+// the Workload Identity Federation with an external identity provider, or the default credentials,
+// optionally impersonating a service account
+package gcpauth
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+)
+
+const (
+	// DefaultSubjectTokenType is the type of the tokens of the external identity providers, when not set
+	DefaultSubjectTokenType = "urn:ietf:params:oauth:token-type:jwt"
+
+	// Endpoints exchanging the external tokens and generating the tokens of the impersonated service accounts
+	stsTokenURL            = "https://sts.googleapis.com/v1/token"
+	impersonationURLFormat = "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/%s:generateAccessToken"
+
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+var (
+	// tokenSources holds the token sources of the impersonated service accounts, so their tokens are reused
+	// by every client until they expire
+	tokenSources = map[string]oauth2.TokenSource{}
+
+	// tokenSourcesMutex serializes the accesses to the token sources
+	tokenSourcesMutex sync.Mutex
+)
+
+// ClientOptions returns the options authenticating the clients of the Google APIs with the given config
+func ClientOptions(gcp v1alpha1.GCPSpec) ([]option.ClientOption, error) {
+	wif := gcp.WorkloadIdentityFederation
+
+	// Exchange the tokens of the external identity provider, impersonating the service account in the same step
+	if wif.Audience != "" {
+		credentials, err := externalAccountCredentials(gcp)
+		if err != nil {
+			return nil, err
+		}
+		return []option.ClientOption{option.WithCredentialsJSON(credentials)}, nil
+	}
+
+	var opts []option.ClientOption
+	if gcp.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(gcp.CredentialsFile))
+	}
+	if gcp.ImpersonateServiceAccount == "" {
+		return opts, nil
+	}
+
+	// Impersonate the service account with the credentials of the file, or the default ones
+	tokenSourcesMutex.Lock()
+	defer tokenSourcesMutex.Unlock()
+
+	key := gcp.CredentialsFile + "/" + gcp.ImpersonateServiceAccount
+	tokenSource, ok := tokenSources[key]
+	if !ok {
+		var err error
+		tokenSource, err = impersonate.CredentialsTokenSource(context.Background(), impersonate.CredentialsConfig{
+			TargetPrincipal: gcp.ImpersonateServiceAccount,
+			Scopes:          []string{cloudPlatformScope},
+		}, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to impersonate service account %s: %w", gcp.ImpersonateServiceAccount, err)
+		}
+		tokenSources[key] = tokenSource
+	}
+	return []option.ClientOption{option.WithTokenSource(tokenSource)}, nil
+}
+
+// externalAccountCredentials returns the external account credentials of the Workload Identity Federation, in JSON,
+// as the gcloud CLI generates them
+func externalAccountCredentials(gcp v1alpha1.GCPSpec) ([]byte, error) {
+	wif := gcp.WorkloadIdentityFederation
+
+	credentialSource := map[string]any{}
+	switch {
+	case wif.TokenFile != "":
+		credentialSource["file"] = wif.TokenFile
+	case wif.TokenURL != "":
+		credentialSource["url"] = wif.TokenURL
+	default:
+		return nil, fmt.Errorf("workload identity federation requires a tokenFile or a tokenUrl")
+	}
+
+	subjectTokenType := wif.SubjectTokenType
+	if subjectTokenType == "" {
+		subjectTokenType = DefaultSubjectTokenType
+	}
+
+	credentials := map[string]any{
+		"type":               "external_account",
+		"audience":           wif.Audience,
+		"subject_token_type": subjectTokenType,
+		"token_url":          stsTokenURL,
+		"credential_source":  credentialSource,
+	}
+	if gcp.ImpersonateServiceAccount != "" {
+		credentials["service_account_impersonation_url"] = fmt.Sprintf(impersonationURLFormat, gcp.ImpersonateServiceAccount)
+	}
+	return json.Marshal(credentials)
+}
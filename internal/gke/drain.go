@@ -0,0 +1,217 @@
+package gke
+
+import (
+	"bytes"
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/dryrun"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// drainPollInterval is the time between the checks of the pods left in a node being drained
+	drainPollInterval = 5 * time.Second
+
+	// mirrorPodAnnotation marks the static pods of the kubelet, which can not be evicted
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+)
+
+// pod is the part of a Kubernetes pod read to drain its node
+type pod struct {
+	Metadata struct {
+		Name            string            `json:"name"`
+		Namespace       string            `json:"namespace"`
+		Annotations     map[string]string `json:"annotations"`
+		OwnerReferences []struct {
+			Kind string `json:"kind"`
+		} `json:"ownerReferences"`
+	} `json:"metadata"`
+	Status struct {
+		Phase string `json:"phase"`
+	} `json:"status"`
+}
+
+// kubernetesClient calls the API server of the cluster of the node pool
+type kubernetesClient struct {
+	client  *http.Client
+	address string
+}
+
+// DrainNode cordons the Kubernetes node of the instance and evicts its pods, waiting until they are gone.
+// The pods of DaemonSets, the mirror pods and the finished ones are left. Evictions blocked by
+// PodDisruptionBudgets are retried until drainTimeoutSec
+func DrainNode(ctx *v1alpha1.Context, nodeName string) error {
+	if ctx.Config.Autoscaler.DryRunInfrastructure {
+		dryrun.Record(ctx, dryrun.ModuleGKE, "cordon Kubernetes node %s and evict its pods", nodeName)
+		return nil
+	}
+	ctxConn := ctx.ConnContext()
+
+	client, err := newClient(ctxConn, ctx)
+	if err != nil {
+		return err
+	}
+
+	err = client.setUnschedulable(ctxConn, nodeName, true)
+	if err != nil {
+		return fmt.Errorf("failed to cordon node %s: %v", nodeName, err)
+	}
+	log.Printf("Cordoned Kubernetes node %s", nodeName)
+
+	timeout := time.Duration(ctx.Config.Infrastructure.GCP.GKE.DrainTimeoutSec) * time.Second
+	deadline := time.Now().Add(timeout)
+	for {
+		pods, err := client.getEvictablePods(ctxConn, nodeName)
+		if err != nil {
+			return fmt.Errorf("failed to get pods of node %s: %v", nodeName, err)
+		}
+		if len(pods) == 0 {
+			log.Printf("Kubernetes node %s drained successfully", nodeName)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout after %s draining node %s, %d pods left", timeout, nodeName, len(pods))
+		}
+
+		for _, p := range pods {
+			err = client.evict(ctxConn, p)
+			if err != nil {
+				return fmt.Errorf("failed to evict pod %s/%s: %v", p.Metadata.Namespace, p.Metadata.Name, err)
+			}
+		}
+
+		select {
+		case <-time.After(drainPollInterval):
+		case <-ctxConn.Done():
+			return ctxConn.Err()
+		}
+	}
+}
+
+// UncordonNode makes the Kubernetes node of the instance schedulable again, when its removal is rolled back
+func UncordonNode(ctx *v1alpha1.Context, nodeName string) error {
+	if ctx.Config.Autoscaler.DryRunInfrastructure {
+		dryrun.Record(ctx, dryrun.ModuleGKE, "uncordon Kubernetes node %s", nodeName)
+		return nil
+	}
+	ctxConn := ctx.ConnContext()
+
+	client, err := newClient(ctxConn, ctx)
+	if err != nil {
+		return err
+	}
+	err = client.setUnschedulable(ctxConn, nodeName, false)
+	if err != nil {
+		return fmt.Errorf("failed to uncordon node %s: %v", nodeName, err)
+	}
+	log.Printf("Uncordoned Kubernetes node %s", nodeName)
+	return nil
+}
+
+// newClient creates the client of the API server of the cluster of the node pool
+func newClient(ctxConn context.Context, ctx *v1alpha1.Context) (*kubernetesClient, error) {
+	client, address, err := newKubernetesClient(ctxConn, ctx.Config.Infrastructure.GCP)
+	if err != nil {
+		return nil, err
+	}
+	return &kubernetesClient{client: client, address: address}, nil
+}
+
+// setUnschedulable cordons or uncordons the node
+func (c *kubernetesClient) setUnschedulable(ctxConn context.Context, nodeName string, unschedulable bool) error {
+	patch := map[string]any{"spec": map[string]any{"unschedulable": unschedulable}}
+	_, err := c.call(ctxConn, http.MethodPatch, "/api/v1/nodes/"+url.PathEscape(nodeName), "application/merge-patch+json", patch, nil)
+	return err
+}
+
+// getEvictablePods returns the pods of the node that must be evicted to drain it
+func (c *kubernetesClient) getEvictablePods(ctxConn context.Context, nodeName string) ([]pod, error) {
+	var list struct {
+		Items []pod `json:"items"`
+	}
+	path := "/api/v1/pods?fieldSelector=" + url.QueryEscape("spec.nodeName="+nodeName)
+	_, err := c.call(ctxConn, http.MethodGet, path, "", nil, &list)
+	if err != nil {
+		return nil, err
+	}
+
+	var pods []pod
+	for _, p := range list.Items {
+		if p.Status.Phase == "Succeeded" || p.Status.Phase == "Failed" {
+			continue
+		}
+		if _, ok := p.Metadata.Annotations[mirrorPodAnnotation]; ok {
+			continue
+		}
+		daemonSet := false
+		for _, owner := range p.Metadata.OwnerReferences {
+			daemonSet = daemonSet || owner.Kind == "DaemonSet"
+		}
+		if !daemonSet {
+			pods = append(pods, p)
+		}
+	}
+	return pods, nil
+}
+
+// evict requests the eviction of the pod. Evictions refused by a PodDisruptionBudget, and the pods already
+// gone, are not errors, as the pods are checked again until the node is drained
+func (c *kubernetesClient) evict(ctxConn context.Context, p pod) error {
+	eviction := map[string]any{
+		"apiVersion": "policy/v1",
+		"kind":       "Eviction",
+		"metadata":   map[string]string{"name": p.Metadata.Name, "namespace": p.Metadata.Namespace},
+	}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/eviction", url.PathEscape(p.Metadata.Namespace), url.PathEscape(p.Metadata.Name))
+	statusCode, err := c.call(ctxConn, http.MethodPost, path, "application/json", eviction, nil)
+	if statusCode == http.StatusTooManyRequests || statusCode == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+// call sends the request to the API server, decoding the response into out when given.
+// It returns the status code of the response, and an error when it is not successful
+func (c *kubernetesClient) call(ctxConn context.Context, method, path, contentType string, body, out any) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctxConn, method, c.address+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, fmt.Errorf("API server answered %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	if out != nil {
+		err = json.NewDecoder(resp.Body).Decode(out)
+		if err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode response: %v", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
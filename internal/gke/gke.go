@@ -0,0 +1,151 @@
+// Package gke reads the MIGs of the GKE node pool scaled by the autoscaler, and cordons and drains the
+// Kubernetes nodes of its instances before they are removed
+package gke
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/gcpauth"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	container "google.golang.org/api/container/v1"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+const (
+	// migsTTL is how long the MIGs of a node pool are reused before reading them again, as upgrades recreate them
+	migsTTL = 5 * time.Minute
+
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// cachedMIGs are the MIGs of a node pool read from the GKE API
+type cachedMIGs struct {
+	migs      []v1alpha1.MIGSpec
+	expiresAt time.Time
+}
+
+var (
+	// nodePoolMIGs holds the MIGs of every node pool by its full name
+	nodePoolMIGs = map[string]cachedMIGs{}
+
+	// nodePoolMIGsMutex serializes the accesses to the MIGs of the node pools
+	nodePoolMIGsMutex sync.Mutex
+)
+
+// Enabled returns true when the autoscaler scales a GKE node pool instead of the configured MIGs
+func Enabled(gcp v1alpha1.GCPSpec) bool {
+	return gcp.GKE.NodePool != ""
+}
+
+// ResolveMIGs reads the MIGs of the node pool from the GKE API, unless they were read recently
+func ResolveMIGs(ctxConn context.Context, gcp v1alpha1.GCPSpec) ([]v1alpha1.MIGSpec, error) {
+	nodePoolMIGsMutex.Lock()
+	defer nodePoolMIGsMutex.Unlock()
+
+	name := nodePoolName(gcp)
+	if cached, ok := nodePoolMIGs[name]; ok && time.Now().Before(cached.expiresAt) {
+		return cached.migs, nil
+	}
+
+	service, err := newContainerService(ctxConn, gcp)
+	if err != nil {
+		return nil, err
+	}
+	nodePool, err := service.Projects.Locations.Clusters.NodePools.Get(name).Context(ctxConn).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node pool %s: %v", gcp.GKE.NodePool, err)
+	}
+	if len(nodePool.InstanceGroupUrls) == 0 {
+		return nil, fmt.Errorf("node pool %s has no instance groups", gcp.GKE.NodePool)
+	}
+
+	// The URLs name the instance groups of the MIGs, which have the same name and zone
+	migs := make([]v1alpha1.MIGSpec, 0, len(nodePool.InstanceGroupUrls))
+	for _, url := range nodePool.InstanceGroupUrls {
+		parts := strings.Split(url, "/")
+		if len(parts) < 4 || parts[len(parts)-4] != "zones" {
+			return nil, fmt.Errorf("unexpected instance group url %q of node pool %s", url, gcp.GKE.NodePool)
+		}
+		migs = append(migs, v1alpha1.MIGSpec{Name: parts[len(parts)-1], Zone: parts[len(parts)-3]})
+	}
+
+	nodePoolMIGs[name] = cachedMIGs{migs: migs, expiresAt: time.Now().Add(migsTTL)}
+	return migs, nil
+}
+
+// CachedMIGs returns the MIGs of the node pool last read by ResolveMIGs, or none when they were never read
+func CachedMIGs(gcp v1alpha1.GCPSpec) []v1alpha1.MIGSpec {
+	nodePoolMIGsMutex.Lock()
+	defer nodePoolMIGsMutex.Unlock()
+
+	return nodePoolMIGs[nodePoolName(gcp)].migs
+}
+
+// clusterName returns the full name of the cluster in the GKE API
+func clusterName(gcp v1alpha1.GCPSpec) string {
+	return fmt.Sprintf("projects/%s/locations/%s/clusters/%s", gcp.ProjectID, gcp.GKE.Location, gcp.GKE.Cluster)
+}
+
+// nodePoolName returns the full name of the node pool in the GKE API
+func nodePoolName(gcp v1alpha1.GCPSpec) string {
+	return clusterName(gcp) + "/nodePools/" + gcp.GKE.NodePool
+}
+
+// newContainerService creates the client of the GKE API authenticated as the config defines
+func newContainerService(ctxConn context.Context, gcp v1alpha1.GCPSpec) (*container.Service, error) {
+	opts, err := gcpauth.ClientOptions(gcp)
+	if err != nil {
+		return nil, err
+	}
+	service, err := container.NewService(ctxConn, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GKE client: %v", err)
+	}
+	return service, nil
+}
+
+// newKubernetesClient returns the HTTP client of the API server of the cluster, trusting its CA and authenticated
+// with the GCP credentials, and the address of the API server
+func newKubernetesClient(ctxConn context.Context, gcp v1alpha1.GCPSpec) (*http.Client, string, error) {
+	service, err := newContainerService(ctxConn, gcp)
+	if err != nil {
+		return nil, "", err
+	}
+	cluster, err := service.Projects.Locations.Clusters.Get(clusterName(gcp)).Context(ctxConn).Do()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get cluster %s: %v", gcp.GKE.Cluster, err)
+	}
+	if cluster.MasterAuth == nil {
+		return nil, "", fmt.Errorf("cluster %s has no CA certificate", gcp.GKE.Cluster)
+	}
+
+	ca, err := base64.StdEncoding.DecodeString(cluster.MasterAuth.ClusterCaCertificate)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid CA certificate of cluster %s: %v", gcp.GKE.Cluster, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, "", fmt.Errorf("invalid CA certificate of cluster %s", gcp.GKE.Cluster)
+	}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+
+	opts, err := gcpauth.ClientOptions(gcp)
+	if err != nil {
+		return nil, "", err
+	}
+	transport, err := htransport.NewTransport(ctxConn, base, append(opts, option.WithScopes(cloudPlatformScope))...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to authenticate to cluster %s: %v", gcp.GKE.Cluster, err)
+	}
+	return &http.Client{Transport: transport, Timeout: 30 * time.Second}, "https://" + cluster.Endpoint, nil
+}
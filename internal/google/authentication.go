@@ -3,22 +3,33 @@ package google
 import (
 	"context"
 	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/gcpauth"
 
 	"google.golang.org/api/option"
 )
 
-// createComputeClient creates a Google Cloud Compute client with an optional credentials file.
+// endpoint is the address of the Compute API replacing the one of GCP, like the one of the fake backend
+var endpoint string
+
+// SetEndpoint sends the calls to the Compute API to the given address without authenticating them, instead of GCP
+func SetEndpoint(address string) {
+	endpoint = address
+}
+
+// createComputeClient creates a Google Cloud Compute client authenticated as the config defines.
 // The function is generic and works for any type of client (T).
-// If GCP CredentialsFile is set, the specified credentials file is used.
-// Otherwise, the default credentials are used.
+// The credentials file or the Workload Identity Federation are used when set, impersonating the
+// configured service account if any. Otherwise, the default credentials are used.
 func createComputeClient[T any](ctxConn context.Context, ctx *v1alpha1.Context, clientFunc func(context.Context, ...option.ClientOption) (*T, error)) (*T, error) {
 
-	// Get the path to the credentials file from the environment variable
-	if ctx.Config.Infrastructure.GCP.CredentialsFile != "" {
-		// If the credentials file is specified, use it
-		return clientFunc(ctxConn, option.WithCredentialsFile(ctx.Config.Infrastructure.GCP.CredentialsFile))
+	// Use the replacement of the Compute API when set
+	if endpoint != "" {
+		return clientFunc(ctxConn, option.WithEndpoint(endpoint), option.WithoutAuthentication())
 	}
 
-	// Use default credentials if no file is specified
-	return clientFunc(ctxConn)
+	opts, err := gcpauth.ClientOptions(ctx.Config.Infrastructure.GCP)
+	if err != nil {
+		return nil, err
+	}
+	return clientFunc(ctxConn, opts...)
 }
@@ -0,0 +1,348 @@
+package google
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/breaker"
+	"custom-vm-autoscaler/internal/gke"
+	"custom-vm-autoscaler/internal/retry"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/api/iterator"
+)
+
+// migClient groups the Compute clients needed to manage zonal and regional Managed Instance Groups (MIG),
+// so the rest of the package does not need to care about the kind of MIG being managed.
+type migClient struct {
+	zonal     *compute.InstanceGroupManagersClient
+	regional  *compute.RegionInstanceGroupManagersClient
+	instances *compute.InstancesClient
+}
+
+// newMIGClient creates the Compute clients for zonal and regional MIGs
+func newMIGClient(ctxConn context.Context, ctx *v1alpha1.Context) (*migClient, error) {
+	// Read the MIGs of the GKE node pool, returned by getMIGs from now on
+	if gke.Enabled(ctx.Config.Infrastructure.GCP) {
+		_, err := gke.ResolveMIGs(ctxConn, ctx.Config.Infrastructure.GCP)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	zonal, err := createComputeClient(ctxConn, ctx, compute.NewInstanceGroupManagersRESTClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Instance Group Managers client: %v", err)
+	}
+
+	regional, err := createComputeClient(ctxConn, ctx, compute.NewRegionInstanceGroupManagersRESTClient)
+	if err != nil {
+		zonal.Close()
+		return nil, fmt.Errorf("failed to create Region Instance Group Managers client: %v", err)
+	}
+
+	instances, err := createComputeClient(ctxConn, ctx, compute.NewInstancesRESTClient)
+	if err != nil {
+		zonal.Close()
+		regional.Close()
+		return nil, fmt.Errorf("failed to create Instances client: %v", err)
+	}
+
+	return &migClient{zonal: zonal, regional: regional, instances: instances}, nil
+}
+
+// Close closes the underlying Compute clients
+func (c *migClient) Close() error {
+	return errors.Join(c.zonal.Close(), c.regional.Close(), c.instances.Close())
+}
+
+// isRegional returns true when the MIG is a regional one
+func isRegional(mig v1alpha1.MIGSpec) bool {
+	return mig.Region != ""
+}
+
+// getMIGLocation returns the zone, or the region for regional MIGs, where the MIG lives
+func getMIGLocation(mig v1alpha1.MIGSpec) string {
+	if isRegional(mig) {
+		return mig.Region
+	}
+	return mig.Zone
+}
+
+// get retrieves the details of the MIG
+func (c *migClient) get(ctxConn context.Context, ctx *v1alpha1.Context, mig v1alpha1.MIGSpec) (*computepb.InstanceGroupManager, error) {
+	var instanceGroupManager *computepb.InstanceGroupManager
+	err := retryCall(ctxConn, ctx, "GCP MIG get", func(ctxCall context.Context) (err error) {
+		if isRegional(mig) {
+			instanceGroupManager, err = c.regional.Get(ctxCall, &computepb.GetRegionInstanceGroupManagerRequest{
+				Project:              ctx.Config.Infrastructure.GCP.ProjectID,
+				Region:               mig.Region,
+				InstanceGroupManager: mig.Name,
+			})
+			return err
+		}
+
+		instanceGroupManager, err = c.zonal.Get(ctxCall, &computepb.GetInstanceGroupManagerRequest{
+			Project:              ctx.Config.Infrastructure.GCP.ProjectID,
+			Zone:                 mig.Zone,
+			InstanceGroupManager: mig.Name,
+		})
+		return err
+	})
+	return instanceGroupManager, err
+}
+
+// resize sets the target size of the MIG. Setting the same size again is harmless, so it is retried when it fails.
+// Errors with a known cause, like an exceeded quota, are returned as an OperationError
+func (c *migClient) resize(ctxConn context.Context, ctx *v1alpha1.Context, mig v1alpha1.MIGSpec, size int32) error {
+	defer invalidateInstances(ctx, mig)
+	err := retryCall(ctxConn, ctx, "GCP MIG resize", func(ctxCall context.Context) error {
+		return c.resizeOnce(ctxCall, ctx, mig, size)
+	})
+	return parseOperationError(err)
+}
+
+// resizeOnce sets the target size of the MIG, without retrying it
+func (c *migClient) resizeOnce(ctxConn context.Context, ctx *v1alpha1.Context, mig v1alpha1.MIGSpec, size int32) error {
+	var err error
+	if isRegional(mig) {
+		_, err = c.regional.Resize(ctxConn, &computepb.ResizeRegionInstanceGroupManagerRequest{
+			Project:              ctx.Config.Infrastructure.GCP.ProjectID,
+			Region:               mig.Region,
+			InstanceGroupManager: mig.Name,
+			Size:                 size,
+		})
+		return err
+	}
+
+	_, err = c.zonal.Resize(ctxConn, &computepb.ResizeInstanceGroupManagerRequest{
+		Project:              ctx.Config.Infrastructure.GCP.ProjectID,
+		Zone:                 mig.Zone,
+		InstanceGroupManager: mig.Name,
+		Size:                 size,
+	})
+	return err
+}
+
+// deleteInstances deletes the given instances from the MIG, reducing its target size
+func (c *migClient) deleteInstances(ctxConn context.Context, ctx *v1alpha1.Context, mig v1alpha1.MIGSpec, instanceURLs []string) error {
+	ctxCall, cancel, err := callContext(ctxConn, ctx)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	defer invalidateInstances(ctx, mig)
+
+	if isRegional(mig) {
+		_, err = c.regional.DeleteInstances(ctxCall, &computepb.DeleteInstancesRegionInstanceGroupManagerRequest{
+			Project:              ctx.Config.Infrastructure.GCP.ProjectID,
+			Region:               mig.Region,
+			InstanceGroupManager: mig.Name,
+			RegionInstanceGroupManagersDeleteInstancesRequestResource: &computepb.RegionInstanceGroupManagersDeleteInstancesRequest{
+				Instances: instanceURLs,
+			},
+		})
+		return err
+	}
+
+	_, err = c.zonal.DeleteInstances(ctxCall, &computepb.DeleteInstancesInstanceGroupManagerRequest{
+		Project:              ctx.Config.Infrastructure.GCP.ProjectID,
+		Zone:                 mig.Zone,
+		InstanceGroupManager: mig.Name,
+		InstanceGroupManagersDeleteInstancesRequestResource: &computepb.InstanceGroupManagersDeleteInstancesRequest{
+			Instances: instanceURLs,
+		},
+	})
+	return err
+}
+
+// listManagedInstances retrieves the instances managed by the MIG
+func (c *migClient) listManagedInstances(ctxConn context.Context, ctx *v1alpha1.Context, mig v1alpha1.MIGSpec) ([]*computepb.ManagedInstance, error) {
+	var instances []*computepb.ManagedInstance
+	err := retryCall(ctxConn, ctx, "GCP MIG instances list", func(ctxCall context.Context) (err error) {
+		instances, err = c.listManagedInstancesOnce(ctxCall, ctx, mig)
+		return err
+	})
+	return instances, err
+}
+
+// listPageSize is the number of managed instances requested per page, the maximum allowed by the API
+const listPageSize = 500
+
+// listManagedInstancesOnce retrieves the instances managed by the MIG, without retrying it. Every page is a call to the
+// API, so the pages after the first one wait for the rate limit too
+func (c *migClient) listManagedInstancesOnce(ctxConn context.Context, ctx *v1alpha1.Context, mig v1alpha1.MIGSpec) ([]*computepb.ManagedInstance, error) {
+	maxResults := uint32(listPageSize)
+	var it *compute.ManagedInstanceIterator
+	if isRegional(mig) {
+		it = c.regional.ListManagedInstances(ctxConn, &computepb.ListManagedInstancesRegionInstanceGroupManagersRequest{
+			Project:              ctx.Config.Infrastructure.GCP.ProjectID,
+			Region:               mig.Region,
+			InstanceGroupManager: mig.Name,
+			MaxResults:           &maxResults,
+		})
+	} else {
+		it = c.zonal.ListManagedInstances(ctxConn, &computepb.ListManagedInstancesInstanceGroupManagersRequest{
+			Project:              ctx.Config.Infrastructure.GCP.ProjectID,
+			Zone:                 mig.Zone,
+			InstanceGroupManager: mig.Name,
+			MaxResults:           &maxResults,
+		})
+	}
+
+	var instances []*computepb.ManagedInstance
+	for {
+		// The next item is in a new page when the current one is consumed and there are more
+		if len(instances) > 0 && it.PageInfo().Remaining() == 0 && it.PageInfo().Token != "" {
+			err := limiter.Wait(ctxConn)
+			if err != nil {
+				return nil, fmt.Errorf("error waiting for the rate limit of the GCP API: %w", err)
+			}
+		}
+		instance, err := it.Next()
+		if err == iterator.Done {
+			break // End of iteration
+		}
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+// abandonInstances removes the given instances from the MIG, reducing its target size, without deleting them
+func (c *migClient) abandonInstances(ctxConn context.Context, ctx *v1alpha1.Context, mig v1alpha1.MIGSpec, instanceURLs []string) error {
+	ctxCall, cancel, err := callContext(ctxConn, ctx)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	defer invalidateInstances(ctx, mig)
+
+	if isRegional(mig) {
+		_, err = c.regional.AbandonInstances(ctxCall, &computepb.AbandonInstancesRegionInstanceGroupManagerRequest{
+			Project:              ctx.Config.Infrastructure.GCP.ProjectID,
+			Region:               mig.Region,
+			InstanceGroupManager: mig.Name,
+			RegionInstanceGroupManagersAbandonInstancesRequestResource: &computepb.RegionInstanceGroupManagersAbandonInstancesRequest{
+				Instances: instanceURLs,
+			},
+		})
+		return err
+	}
+
+	_, err = c.zonal.AbandonInstances(ctxCall, &computepb.AbandonInstancesInstanceGroupManagerRequest{
+		Project:              ctx.Config.Infrastructure.GCP.ProjectID,
+		Zone:                 mig.Zone,
+		InstanceGroupManager: mig.Name,
+		InstanceGroupManagersAbandonInstancesRequestResource: &computepb.InstanceGroupManagersAbandonInstancesRequest{
+			Instances: instanceURLs,
+		},
+	})
+	return err
+}
+
+// recreateInstances recreates the given instances of the MIG from its instance template, keeping their names
+func (c *migClient) recreateInstances(ctxConn context.Context, ctx *v1alpha1.Context, mig v1alpha1.MIGSpec, instanceURLs []string) error {
+	ctxCall, cancel, err := callContext(ctxConn, ctx)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	defer invalidateInstances(ctx, mig)
+
+	if isRegional(mig) {
+		_, err = c.regional.RecreateInstances(ctxCall, &computepb.RecreateInstancesRegionInstanceGroupManagerRequest{
+			Project:              ctx.Config.Infrastructure.GCP.ProjectID,
+			Region:               mig.Region,
+			InstanceGroupManager: mig.Name,
+			RegionInstanceGroupManagersRecreateRequestResource: &computepb.RegionInstanceGroupManagersRecreateRequest{
+				Instances: instanceURLs,
+			},
+		})
+		return err
+	}
+
+	_, err = c.zonal.RecreateInstances(ctxCall, &computepb.RecreateInstancesInstanceGroupManagerRequest{
+		Project:              ctx.Config.Infrastructure.GCP.ProjectID,
+		Zone:                 mig.Zone,
+		InstanceGroupManager: mig.Name,
+		InstanceGroupManagersRecreateInstancesRequestResource: &computepb.InstanceGroupManagersRecreateInstancesRequest{
+			Instances: instanceURLs,
+		},
+	})
+	return err
+}
+
+// isDeletionProtected returns true when the given instance has deletion protection enabled
+func (c *migClient) isDeletionProtected(ctxConn context.Context, ctx *v1alpha1.Context, instanceURL string) (bool, error) {
+	var instance *computepb.Instance
+	err := retryCall(ctxConn, ctx, "GCP instance get", func(ctxCall context.Context) (err error) {
+		instance, err = c.instances.Get(ctxCall, &computepb.GetInstanceRequest{
+			Project:  ctx.Config.Infrastructure.GCP.ProjectID,
+			Zone:     getZoneFromURL(instanceURL),
+			Instance: getInstanceNameFromURL(instanceURL),
+		})
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return instance.GetDeletionProtection(), nil
+}
+
+// stopInstance stops the given instance
+func (c *migClient) stopInstance(ctxConn context.Context, ctx *v1alpha1.Context, instanceURL string) error {
+	return retryCall(ctxConn, ctx, "GCP instance stop", func(ctxCall context.Context) error {
+		_, err := c.instances.Stop(ctxCall, &computepb.StopInstanceRequest{
+			Project:  ctx.Config.Infrastructure.GCP.ProjectID,
+			Zone:     getZoneFromURL(instanceURL),
+			Instance: getInstanceNameFromURL(instanceURL),
+		})
+		return err
+	})
+}
+
+// callContext returns the context of a single call to the GCP API, bounded by the operation timeout,
+// once the rate limit shared by every autoscaler allows it
+func callContext(ctxConn context.Context, ctx *v1alpha1.Context) (context.Context, context.CancelFunc, error) {
+	ctxCall, cancel := context.WithTimeout(ctxConn, time.Duration(ctx.Config.Infrastructure.GCP.OperationTimeoutSec)*time.Second)
+	err := limiter.Wait(ctxCall)
+	if err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("error waiting for the rate limit of the GCP API: %w", err)
+	}
+	return ctxCall, cancel, nil
+}
+
+// retryCall executes an idempotent call to the GCP API, retrying it when it fails with a transient error.
+// Client errors (4xx), except the ones caused by rate limits, are not retried. The final result is recorded
+// in the circuit breaker of GCP
+func retryCall(ctxConn context.Context, ctx *v1alpha1.Context, name string, call func(ctxCall context.Context) error) error {
+	err := retry.Do(ctxConn, retry.NewPolicy(ctx.Config), name, func() error {
+		ctxCall, cancel, err := callContext(ctxConn, ctx)
+		if err != nil {
+			return err
+		}
+		defer cancel()
+		err = call(ctxCall)
+		if apiError, ok := apierror.FromError(err); ok {
+			code := apiError.HTTPCode()
+			if code >= 400 && code < 500 && code != http.StatusTooManyRequests {
+				return retry.Permanent(err)
+			}
+		}
+		return err
+	})
+	breaker.Record(ctx, breaker.DependencyGCP, err)
+	return err
+}
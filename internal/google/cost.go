@@ -0,0 +1,134 @@
+package google
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/cost"
+	"fmt"
+	"strings"
+	"sync"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+var (
+	// machineTypes holds the machine type of every instance template already read, by URL.
+	// Instance templates are immutable, so they never need to be read again
+	machineTypes = map[string]string{}
+
+	// machineTypesMutex serializes the accesses to the machine types, shared by every autoscaler in the process
+	machineTypesMutex sync.Mutex
+)
+
+// GetMIGHourlyPrice returns the estimated hourly price of one instance of the MIG
+func GetMIGHourlyPrice(ctx *v1alpha1.Context, migName string) (float64, error) {
+	ctxConn := ctx.ConnContext()
+
+	client, err := newMIGClient(ctxConn, ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	for _, mig := range getMIGs(ctx) {
+		if mig.Name == migName {
+			return getMIGHourlyPrice(ctxConn, client, ctx, mig)
+		}
+	}
+	return 0, fmt.Errorf("MIG %s not found in the config", migName)
+}
+
+// checkScaleUpBudget checks that adding the instances to the MIGs does not exceed the monthly budget
+func checkScaleUpBudget(ctxConn context.Context, client *migClient, ctx *v1alpha1.Context, migs []v1alpha1.MIGSpec, sizes []int32, added []int32) error {
+	if !ctx.Config.Cost.Enabled || ctx.Config.Cost.MonthlyBudget <= 0 {
+		return nil
+	}
+
+	var hourlyCost float64
+	for i, mig := range migs {
+		price, err := getMIGHourlyPrice(ctxConn, client, ctx, mig)
+		if err != nil {
+			return err
+		}
+		hourlyCost += price * float64(sizes[i]+added[i])
+	}
+
+	return cost.CheckBudget(ctx, hourlyCost)
+}
+
+// getMIGHourlyPrice returns the estimated hourly price of one instance of the MIG, from the machine type of its template
+func getMIGHourlyPrice(ctxConn context.Context, client *migClient, ctx *v1alpha1.Context, mig v1alpha1.MIGSpec) (float64, error) {
+	instanceGroupManager, err := client.get(ctxConn, ctx, mig)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get MIG %s: %v", mig.Name, err)
+	}
+
+	machineType, err := getMachineType(ctxConn, ctx, instanceGroupManager.GetInstanceTemplate())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get machine type of MIG %s: %v", mig.Name, err)
+	}
+
+	return cost.GetHourlyPrice(ctx, machineType)
+}
+
+// getMachineType returns the machine type defined in the instance template, global or regional
+func getMachineType(ctxConn context.Context, ctx *v1alpha1.Context, templateURL string) (string, error) {
+	machineTypesMutex.Lock()
+	machineType, ok := machineTypes[templateURL]
+	machineTypesMutex.Unlock()
+	if ok {
+		return machineType, nil
+	}
+
+	ctxCall, cancel, err := callContext(ctxConn, ctx)
+	if err != nil {
+		return "", err
+	}
+	defer cancel()
+
+	var template *computepb.InstanceTemplate
+	parts := strings.Split(templateURL, "/")
+	name := parts[len(parts)-1]
+
+	// Regional templates are referenced as .../regions/<region>/instanceTemplates/<name>
+	if len(parts) >= 4 && parts[len(parts)-4] == "regions" {
+		client, err := createComputeClient(ctxConn, ctx, compute.NewRegionInstanceTemplatesRESTClient)
+		if err != nil {
+			return "", fmt.Errorf("failed to create Region Instance Templates client: %v", err)
+		}
+		defer client.Close()
+
+		template, err = client.Get(ctxCall, &computepb.GetRegionInstanceTemplateRequest{
+			Project:          ctx.Config.Infrastructure.GCP.ProjectID,
+			Region:           parts[len(parts)-3],
+			InstanceTemplate: name,
+		})
+		if err != nil {
+			return "", err
+		}
+	} else {
+		client, err := createComputeClient(ctxConn, ctx, compute.NewInstanceTemplatesRESTClient)
+		if err != nil {
+			return "", fmt.Errorf("failed to create Instance Templates client: %v", err)
+		}
+		defer client.Close()
+
+		template, err = client.Get(ctxCall, &computepb.GetInstanceTemplateRequest{
+			Project:          ctx.Config.Infrastructure.GCP.ProjectID,
+			InstanceTemplate: name,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// The machine type may be a name or a URL
+	machineTypeParts := strings.Split(template.GetProperties().GetMachineType(), "/")
+	machineType = machineTypeParts[len(machineTypeParts)-1]
+
+	machineTypesMutex.Lock()
+	machineTypes[templateURL] = machineType
+	machineTypesMutex.Unlock()
+	return machineType, nil
+}
@@ -0,0 +1,134 @@
+package google
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"regexp"
+
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/dryrun"
+	"custom-vm-autoscaler/internal/state"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/googleapis/gax-go/v2/apierror"
+)
+
+// DrainStateDraining is the value of the drain state label of the instances being drained
+const DrainStateDraining = "draining"
+
+// labelKeyPattern matches the keys of the labels accepted by GCP
+var labelKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+
+// IsLabelKey returns whether the key can be the key of a label of GCP resources
+func IsLabelKey(key string) bool {
+	return labelKeyPattern.MatchString(key)
+}
+
+// drainStateLabels returns the labels of the instance with the key set to the value, or without the key when the
+// value is empty, and whether they changed. The labels given are not modified
+func drainStateLabels(labels map[string]string, key string, value string) (map[string]string, bool) {
+	current, ok := labels[key]
+	if (value == "" && !ok) || (value != "" && ok && current == value) {
+		return labels, false
+	}
+
+	updated := maps.Clone(labels)
+	if updated == nil {
+		updated = map[string]string{}
+	}
+	if value == "" {
+		delete(updated, key)
+	} else {
+		updated[key] = value
+	}
+	return updated, true
+}
+
+// setDrainState sets the drain state label of the instance to the value, or removes it when the value is empty.
+// The labels are read and set in the same attempt, as setting them requires the fingerprint of the current ones
+func (c *migClient) setDrainState(ctxConn context.Context, ctx *v1alpha1.Context, zone string, instanceName string, value string) error {
+	key := ctx.Config.Infrastructure.GCP.DrainStateLabel
+	if key == "" {
+		return nil
+	}
+	if ctx.Config.Autoscaler.DryRunInfrastructure {
+		if value == "" {
+			dryrun.Record(ctx, dryrun.ModuleGCP, "remove label %s from instance %s", key, instanceName)
+		} else {
+			dryrun.Record(ctx, dryrun.ModuleGCP, "set label %s=%s in instance %s", key, value, instanceName)
+		}
+		return nil
+	}
+
+	return retryCall(ctxConn, ctx, "GCP instance set labels", func(ctxCall context.Context) error {
+		instance, err := c.instances.Get(ctxCall, &computepb.GetInstanceRequest{
+			Project:  ctx.Config.Infrastructure.GCP.ProjectID,
+			Zone:     zone,
+			Instance: instanceName,
+		})
+		if err != nil {
+			return err
+		}
+		labels, changed := drainStateLabels(instance.GetLabels(), key, value)
+		if !changed {
+			return nil
+		}
+
+		// Setting the labels is a call of its own for the rate limit
+		err = limiter.Wait(ctxCall)
+		if err != nil {
+			return fmt.Errorf("error waiting for the rate limit of the GCP API: %w", err)
+		}
+		_, err = c.instances.SetLabels(ctxCall, &computepb.SetLabelsInstanceRequest{
+			Project:  ctx.Config.Infrastructure.GCP.ProjectID,
+			Zone:     zone,
+			Instance: instanceName,
+			InstancesSetLabelsRequestResource: &computepb.InstancesSetLabelsRequest{
+				Labels:           labels,
+				LabelFingerprint: instance.LabelFingerprint,
+			},
+		})
+		return err
+	})
+}
+
+// markDraining labels the instance as draining. Errors are logged, as the label must not stop the scale down
+func (c *migClient) markDraining(ctxConn context.Context, ctx *v1alpha1.Context, instanceURL string) {
+	err := c.setDrainState(ctxConn, ctx, getZoneFromURL(instanceURL), getInstanceNameFromURL(instanceURL), DrainStateDraining)
+	if err != nil {
+		log.Printf("Error labelling instance %s as draining: %v", getInstanceNameFromURL(instanceURL), err)
+	}
+}
+
+// unmarkDraining removes the drain state label of the instance. Errors are logged, as the label must not stop the
+// scale down
+func (c *migClient) unmarkDraining(ctxConn context.Context, ctx *v1alpha1.Context, instanceURL string) {
+	err := c.setDrainState(ctxConn, ctx, getZoneFromURL(instanceURL), getInstanceNameFromURL(instanceURL), "")
+	if err != nil {
+		log.Printf("Error removing the drain state label of instance %s: %v", getInstanceNameFromURL(instanceURL), err)
+	}
+}
+
+// ClearDrainState removes the drain state label of the instance of the scale down interrupted by a crash. Instances
+// being deleted, already gone, or whose zone was not recorded are left as they are
+func ClearDrainState(ctx *v1alpha1.Context, operation v1alpha1.Operation) error {
+	if ctx.Config.Infrastructure.GCP.DrainStateLabel == "" || operation.Zone == "" || operation.Phase == state.PhaseDeleting {
+		return nil
+	}
+
+	ctxConn := ctx.ConnContext()
+	client, err := newMIGClient(ctxConn, ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	err = client.setDrainState(ctxConn, ctx, operation.Zone, operation.Instance, "")
+	if apiError, ok := apierror.FromError(err); ok && apiError.HTTPCode() == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,83 @@
+package google
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestDrainStateLabels(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		value       string
+		want        map[string]string
+		wantChanged bool
+	}{
+		{
+			name:        "label set in an instance without labels",
+			value:       DrainStateDraining,
+			want:        map[string]string{"autoscaler-state": "draining"},
+			wantChanged: true,
+		},
+		{
+			name:        "label set keeping the other labels",
+			labels:      map[string]string{"env": "prod"},
+			value:       DrainStateDraining,
+			want:        map[string]string{"env": "prod", "autoscaler-state": "draining"},
+			wantChanged: true,
+		},
+		{
+			name:   "label already set",
+			labels: map[string]string{"autoscaler-state": "draining"},
+			value:  DrainStateDraining,
+			want:   map[string]string{"autoscaler-state": "draining"},
+		},
+		{
+			name:        "label removed keeping the other labels",
+			labels:      map[string]string{"env": "prod", "autoscaler-state": "draining"},
+			want:        map[string]string{"env": "prod"},
+			wantChanged: true,
+		},
+		{
+			name:   "label already removed",
+			labels: map[string]string{"env": "prod"},
+			want:   map[string]string{"env": "prod"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := maps.Clone(tt.labels)
+			got, changed := drainStateLabels(tt.labels, "autoscaler-state", tt.value)
+			if changed != tt.wantChanged {
+				t.Errorf("drainStateLabels() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if len(got) != len(tt.want) || !maps.Equal(got, tt.want) {
+				t.Errorf("drainStateLabels() = %v, want %v", got, tt.want)
+			}
+			if !maps.Equal(tt.labels, original) {
+				t.Errorf("drainStateLabels() modified the labels given to %v", tt.labels)
+			}
+		})
+	}
+}
+
+func TestIsLabelKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{key: "autoscaler-state", want: true},
+		{key: "autoscaler_state2", want: true},
+		{key: "Autoscaler-State", want: false},
+		{key: "2-state", want: false},
+		{key: "autoscaler.state", want: false},
+		{key: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsLabelKey(tt.key); got != tt.want {
+			t.Errorf("IsLabelKey(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
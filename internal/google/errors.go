@@ -0,0 +1,109 @@
+package google
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// CauseQuota is the cause of the operations failing because a quota of the project or the region is exceeded
+	CauseQuota = "quota"
+
+	// CauseStockout is the cause of the operations failing because the zone has no resources for the machine type
+	CauseStockout = "stockout"
+
+	// CauseIPExhaustion is the cause of the operations failing because the subnetwork has no IP addresses left
+	CauseIPExhaustion = "ip-exhaustion"
+)
+
+// causeDescriptions describe the causes of the operation errors in the notifications
+var causeDescriptions = map[string]string{
+	CauseQuota:        "quota exceeded",
+	CauseStockout:     "zone stockout",
+	CauseIPExhaustion: "IP addresses exhausted",
+}
+
+// OperationError is a failed GCP operation, or a failed creation of an instance by a MIG, whose cause is known
+type OperationError struct {
+	Cause   string
+	Code    string
+	Message string
+
+	// Instance is the name of the instance whose creation failed, if any
+	Instance string
+
+	err error
+}
+
+// Error describes the cause of the operation error before its code and message
+func (e *OperationError) Error() string {
+	description := fmt.Sprintf("%s (%s): %s", causeDescriptions[e.Cause], e.Code, e.Message)
+	if e.Instance != "" {
+		description = fmt.Sprintf("creating instance %s failed with %s", e.Instance, description)
+	}
+	return description
+}
+
+// Unwrap returns the error returned by the GCP API, if any
+func (e *OperationError) Unwrap() error {
+	return e.err
+}
+
+// getCause returns the cause of the error code returned by GCP, or an empty string when it is not known
+func getCause(code string) string {
+	code = strings.ToUpper(code)
+	switch {
+	case strings.Contains(code, "QUOTA"):
+		return CauseQuota
+	case strings.Contains(code, "RESOURCE_POOL_EXHAUSTED") || strings.Contains(code, "STOCKOUT"):
+		return CauseStockout
+	case strings.Contains(code, "IP_SPACE_EXHAUSTED"):
+		return CauseIPExhaustion
+	}
+	return ""
+}
+
+// parseOperationError returns the error returned by the GCP API as an OperationError when one of its reasons
+// has a known cause, or the same error otherwise
+func parseOperationError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var reasons [][2]string
+	if apiError, ok := apierror.FromError(err); ok && apiError.Reason() != "" {
+		reasons = append(reasons, [2]string{apiError.Reason(), apiError.Error()})
+	}
+	var googleError *googleapi.Error
+	if errors.As(err, &googleError) {
+		for _, item := range googleError.Errors {
+			reasons = append(reasons, [2]string{item.Reason, item.Message})
+		}
+	}
+	for _, reason := range reasons {
+		if cause := getCause(reason[0]); cause != "" {
+			return &OperationError{Cause: cause, Code: reason[0], Message: reason[1], err: err}
+		}
+	}
+	return err
+}
+
+// getCreationError returns the error of the last attempt of the MIG to create the instance when its cause is known
+func getCreationError(instance *computepb.ManagedInstance) *OperationError {
+	attemptError := instance.GetLastAttempt().GetErrors()
+	cause := getCause(attemptError.GetCode())
+	if cause == "" {
+		return nil
+	}
+	return &OperationError{
+		Cause:    cause,
+		Code:     attemptError.GetCode(),
+		Message:  attemptError.GetMessage(),
+		Instance: getInstanceNameFromURL(instance.GetInstance()),
+	}
+}
@@ -0,0 +1,132 @@
+package google
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/dryrun"
+	"custom-vm-autoscaler/internal/state"
+	"fmt"
+	"log"
+	"slices"
+)
+
+// removingActions are the current actions of the managed instances already leaving the MIG
+var removingActions = []string{"DELETING", "ABANDONING"}
+
+// operationApplied returns whether the scaling action of the operation already took effect, from the target size of
+// its MIG and the current action of its instances by name. Scale ups took effect when the MIG reached the expected
+// size, and scale downs when the instance is not in the MIG anymore, or is leaving it
+func operationApplied(operation v1alpha1.Operation, targetSize int32, instanceActions map[string]string) bool {
+	if operation.Type == state.OperationScaleUp {
+		return targetSize >= operation.ExpectedSize
+	}
+	action, ok := instanceActions[operation.Instance]
+	return !ok || slices.Contains(removingActions, action)
+}
+
+// checkOperationApplied reads the MIG of the operation to know whether its scaling action already took effect
+func checkOperationApplied(ctxConn context.Context, client *migClient, ctx *v1alpha1.Context, mig v1alpha1.MIGSpec, operation v1alpha1.Operation) (bool, error) {
+	targetSize, err := getMIGTargetSize(ctxConn, client, ctx, mig)
+	if err != nil {
+		return false, err
+	}
+	instances, err := client.listManagedInstances(ctxConn, ctx, mig)
+	if err != nil {
+		return false, fmt.Errorf("failed to list managed instances: %v", err)
+	}
+	instanceActions := make(map[string]string, len(instances))
+	for _, instance := range instances {
+		instanceActions[getInstanceNameFromURL(instance.GetInstance())] = instance.GetCurrentAction()
+	}
+	return operationApplied(operation, targetSize, instanceActions), nil
+}
+
+// getOperationMIG returns the MIG of the operation from the config
+func getOperationMIG(ctx *v1alpha1.Context, operation v1alpha1.Operation) (v1alpha1.MIGSpec, error) {
+	for _, mig := range getMIGs(ctx) {
+		if mig.Name == operation.MIG {
+			return mig, nil
+		}
+	}
+	return v1alpha1.MIGSpec{}, fmt.Errorf("MIG %s of operation %s not found in the config", operation.MIG, operation.Key)
+}
+
+// OperationApplied returns whether the scaling action of the operation interrupted by a crash already took effect
+// in its MIG, so it is not issued twice
+func OperationApplied(ctx *v1alpha1.Context, operation v1alpha1.Operation) (bool, error) {
+	ctxConn := ctx.ConnContext()
+	client, err := newMIGClient(ctxConn, ctx)
+	if err != nil {
+		return false, err
+	}
+	defer client.Close()
+
+	mig, err := getOperationMIG(ctx, operation)
+	if err != nil {
+		return false, err
+	}
+	return checkOperationApplied(ctxConn, client, ctx, mig, operation)
+}
+
+// ResumeScaleDown issues again the removal of the instance of the scale down interrupted by a crash, following its
+// phase: deleting, abandoning or parking. It must only be called when the removal did not take effect
+func ResumeScaleDown(ctx *v1alpha1.Context, operation v1alpha1.Operation) error {
+	ctxConn := ctx.ConnContext()
+	client, err := newMIGClient(ctxConn, ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	mig, err := getOperationMIG(ctx, operation)
+	if err != nil {
+		return err
+	}
+	instanceURLs, err := getMIGInstanceNames(ctxConn, client, ctx, mig)
+	if err != nil {
+		return err
+	}
+	index := slices.IndexFunc(instanceURLs, func(instanceURL string) bool {
+		return getInstanceNameFromURL(instanceURL) == operation.Instance
+	})
+	if index == -1 {
+		return nil
+	}
+	instanceURL := instanceURLs[index]
+
+	if ctx.Config.Autoscaler.DryRunInfrastructure {
+		dryrun.Record(ctx, dryrun.ModuleGCP, "resume %s of instance %s from MIG %s", operation.Phase, operation.Instance, mig.Name)
+		return nil
+	}
+
+	switch operation.Phase {
+	case state.PhaseDeleting:
+		err = client.deleteInstances(ctxConn, ctx, mig, []string{instanceURL})
+	case state.PhaseAbandoning, state.PhaseParking:
+		err = client.abandonInstances(ctxConn, ctx, mig, []string{instanceURL})
+		if err == nil && operation.Phase == state.PhaseParking {
+			err = client.stopInstance(ctxConn, ctx, instanceURL)
+		}
+	default:
+		return fmt.Errorf("operation %s can not be resumed in phase %s", operation.Key, operation.Phase)
+	}
+	if err != nil {
+		return fmt.Errorf("error resuming the %s of instance %s: %v", operation.Phase, operation.Instance, err)
+	}
+	log.Printf("Resumed the %s of instance %s from MIG %s, interrupted before taking effect", operation.Phase, operation.Instance, mig.Name)
+	return nil
+}
+
+// appliedDespiteError returns whether the scaling action of the operation took effect although its call failed, like
+// when the response was lost or timed out, so it is considered done instead of failing and being issued again
+func appliedDespiteError(ctxConn context.Context, client *migClient, ctx *v1alpha1.Context, mig v1alpha1.MIGSpec, operation v1alpha1.Operation, callErr error) bool {
+	applied, err := checkOperationApplied(ctxConn, client, ctx, mig, operation)
+	if err != nil {
+		log.Printf("Error checking whether the %s of MIG %s took effect: %v", operation.Type, mig.Name, err)
+		return false
+	}
+	if applied {
+		log.Printf("The %s of MIG %s took effect despite the error, so it is not issued again: %v", operation.Type, mig.Name, callErr)
+	}
+	return applied
+}
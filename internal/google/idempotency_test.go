@@ -0,0 +1,35 @@
+package google
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/state"
+	"testing"
+)
+
+func TestOperationApplied(t *testing.T) {
+	scaleUp := v1alpha1.Operation{Type: state.OperationScaleUp, MIG: "mig-a", PreviousSize: 3, ExpectedSize: 5}
+	scaleDown := v1alpha1.Operation{Type: state.OperationScaleDown, MIG: "mig-a", Instance: "node-2", PreviousSize: 3, ExpectedSize: 2}
+
+	tests := []struct {
+		name            string
+		operation       v1alpha1.Operation
+		targetSize      int32
+		instanceActions map[string]string
+		want            bool
+	}{
+		{name: "scale up resized", operation: scaleUp, targetSize: 5, want: true},
+		{name: "scale up not resized", operation: scaleUp, targetSize: 3, want: false},
+		{name: "scale down removed", operation: scaleDown, targetSize: 2, instanceActions: map[string]string{"node-1": "NONE"}, want: true},
+		{name: "scale down deleting", operation: scaleDown, targetSize: 2, instanceActions: map[string]string{"node-2": "DELETING"}, want: true},
+		{name: "scale down not removed", operation: scaleDown, targetSize: 3, instanceActions: map[string]string{"node-2": "NONE"}, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := operationApplied(test.operation, test.targetSize, test.instanceActions)
+			if got != test.want {
+				t.Errorf("operationApplied() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package google
+
+import (
+	"context"
+	"sync"
+
+	"custom-vm-autoscaler/api/v1alpha1"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// instanceCaches holds the managed instances listed during the decision cycle in progress of every autoscaler,
+// by their context, so MIGs with hundreds of instances are only listed once per cycle
+var instanceCaches sync.Map
+
+// instanceCache holds the managed instances of every MIG listed in a decision cycle, by MIG
+type instanceCache struct {
+	mutex     sync.Mutex
+	instances map[string][]*computepb.ManagedInstance
+}
+
+// CacheInstances caches the managed instances listed by the autoscaler until the returned function is called, so
+// a decision cycle lists every MIG once. Changes to a MIG made by the autoscaler discard its cached instances
+func CacheInstances(ctx *v1alpha1.Context) func() {
+	instanceCaches.Store(ctx, &instanceCache{instances: map[string][]*computepb.ManagedInstance{}})
+	return func() { instanceCaches.Delete(ctx) }
+}
+
+// migCacheKey identifies the MIG in the cache, as zonal and regional MIGs may share the name
+func migCacheKey(mig v1alpha1.MIGSpec) string {
+	return mig.Region + "/" + mig.Zone + "/" + mig.Name
+}
+
+// listCachedManagedInstances returns the managed instances of the MIG listed in the decision cycle in progress,
+// listing them when they are not cached yet. Without a cycle in progress they are always listed
+func (c *migClient) listCachedManagedInstances(ctxConn context.Context, ctx *v1alpha1.Context, mig v1alpha1.MIGSpec) ([]*computepb.ManagedInstance, error) {
+	value, ok := instanceCaches.Load(ctx)
+	if !ok {
+		return c.listManagedInstances(ctxConn, ctx, mig)
+	}
+	cache := value.(*instanceCache)
+
+	cache.mutex.Lock()
+	instances, ok := cache.instances[migCacheKey(mig)]
+	cache.mutex.Unlock()
+	if ok {
+		return instances, nil
+	}
+
+	instances, err := c.listManagedInstances(ctxConn, ctx, mig)
+	if err != nil {
+		return nil, err
+	}
+	cache.mutex.Lock()
+	cache.instances[migCacheKey(mig)] = instances
+	cache.mutex.Unlock()
+	return instances, nil
+}
+
+// invalidateInstances discards the cached instances of the MIG, once the autoscaler changed it
+func invalidateInstances(ctx *v1alpha1.Context, mig v1alpha1.MIGSpec) {
+	value, ok := instanceCaches.Load(ctx)
+	if !ok {
+		return
+	}
+	cache := value.(*instanceCache)
+	cache.mutex.Lock()
+	delete(cache.instances, migCacheKey(mig))
+	cache.mutex.Unlock()
+}
@@ -0,0 +1,66 @@
+package google
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"custom-vm-autoscaler/api/v1alpha1"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// ErrMIGInterlocked is returned when a MIG is managed by a GCE autoscaler or another controller, so the autoscaler
+// refuses to operate it instead of fighting over its target size
+var ErrMIGInterlocked = errors.New("MIG managed by another controller")
+
+// checkInterlock returns ErrMIGInterlocked, describing why, when a GCE autoscaler is attached to the MIG, or when the
+// MIG is not stable and unstable MIGs are not allowed. MIGs without status are considered stable
+func checkInterlock(mig v1alpha1.MIGSpec, instanceGroupManager *computepb.InstanceGroupManager, allowUnstable bool) error {
+	status := instanceGroupManager.GetStatus()
+	if status.GetAutoscaler() != "" {
+		return fmt.Errorf("%w: MIG %s has the GCE autoscaler %s attached, detach it to let this autoscaler operate the MIG",
+			ErrMIGInterlocked, mig.Name, getInstanceNameFromURL(status.GetAutoscaler()))
+	}
+	if !allowUnstable && status != nil && status.IsStable != nil && !status.GetIsStable() {
+		return fmt.Errorf("%w: MIG %s is not stable, its instances are being changed by another controller or a previous action",
+			ErrMIGInterlocked, mig.Name)
+	}
+	return nil
+}
+
+// getOwnedMIGTargetSizes is getMIGTargetSizes for the scaling actions, returning ErrMIGInterlocked when another
+// controller manages any of the MIGs
+func getOwnedMIGTargetSizes(ctxConn context.Context, client *migClient, ctx *v1alpha1.Context, migs []v1alpha1.MIGSpec) ([]int32, int32, error) {
+	sizes := make([]int32, len(migs))
+	var totalSize int32
+
+	for i, mig := range migs {
+		instanceGroupManager, err := client.get(ctxConn, ctx, mig)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to get MIG %s: %v", mig.Name, err)
+		}
+		err = checkInterlock(mig, instanceGroupManager, ctx.Config.Infrastructure.GCP.AllowUnstableMIGs)
+		if err != nil {
+			return nil, 0, err
+		}
+		sizes[i] = instanceGroupManager.GetTargetSize()
+		totalSize += sizes[i]
+	}
+
+	return sizes, totalSize, nil
+}
+
+// CheckInterlock returns ErrMIGInterlocked when a GCE autoscaler or another controller manages any of the MIGs of the
+// autoscaler, so no scaling decisions are taken for them
+func CheckInterlock(ctx *v1alpha1.Context) error {
+	ctxConn := ctx.ConnContext()
+	client, err := newMIGClient(ctxConn, ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	_, _, err = getOwnedMIGTargetSizes(ctxConn, client, ctx, getMIGs(ctx))
+	return err
+}
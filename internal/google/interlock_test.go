@@ -0,0 +1,40 @@
+package google
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"errors"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+func TestCheckInterlock(t *testing.T) {
+	autoscaler := "https://www.googleapis.com/compute/v1/projects/p/zones/z/autoscalers/gce-autoscaler"
+	stable, unstable := true, false
+
+	tests := []struct {
+		name          string
+		status        *computepb.InstanceGroupManagerStatus
+		allowUnstable bool
+		wantErr       bool
+	}{
+		{name: "stable MIG", status: &computepb.InstanceGroupManagerStatus{IsStable: &stable}},
+		{name: "MIG without status"},
+		{name: "GCE autoscaler attached", status: &computepb.InstanceGroupManagerStatus{Autoscaler: &autoscaler, IsStable: &stable}, wantErr: true},
+		{name: "GCE autoscaler attached allowing unstable MIGs", status: &computepb.InstanceGroupManagerStatus{Autoscaler: &autoscaler}, allowUnstable: true, wantErr: true},
+		{name: "unstable MIG", status: &computepb.InstanceGroupManagerStatus{IsStable: &unstable}, wantErr: true},
+		{name: "unstable MIG allowed", status: &computepb.InstanceGroupManagerStatus{IsStable: &unstable}, allowUnstable: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkInterlock(v1alpha1.MIGSpec{Name: "mig"}, &computepb.InstanceGroupManager{Status: tt.status}, tt.allowUnstable)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkInterlock() = %v, want error %t", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrMIGInterlocked) {
+				t.Errorf("checkInterlock() = %v, want %v", err, ErrMIGInterlocked)
+			}
+		})
+	}
+}
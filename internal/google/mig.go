@@ -3,109 +3,341 @@ package google
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
-	"strconv"
+	"slices"
 	"strings"
 	"time"
 
 	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/approval"
+	"custom-vm-autoscaler/internal/decision"
+	"custom-vm-autoscaler/internal/dryrun"
 	"custom-vm-autoscaler/internal/elasticsearch"
-	"custom-vm-autoscaler/internal/slack"
+	"custom-vm-autoscaler/internal/gke"
+	"custom-vm-autoscaler/internal/hooks"
+	"custom-vm-autoscaler/internal/notifier"
+	"custom-vm-autoscaler/internal/retry"
+	"custom-vm-autoscaler/internal/state"
+)
+
+// ErrDeletionProtected is returned when every instance that could be removed has deletion protection enabled
+var ErrDeletionProtected = errors.New("every removable instance has deletion protection enabled")
+
+const (
+	// ScaleDownActionDelete deletes the instances removed from the MIG
+	ScaleDownActionDelete = "delete"
+
+	// ScaleDownActionAbandon removes the instances from the MIG keeping them alive
+	ScaleDownActionAbandon = "abandon"
 
-	compute "cloud.google.com/go/compute/apiv1"
-	computepb "cloud.google.com/go/compute/apiv1/computepb"
-	"google.golang.org/api/iterator"
+	// DeletionProtectionPolicySkip selects another instance when the selected one has deletion protection enabled
+	DeletionProtectionPolicySkip = "skip"
+
+	// DeletionProtectionPolicyStop abandons and stops the instances with deletion protection enabled
+	DeletionProtectionPolicyStop = "stop"
 )
 
-// AddNodeToMIG increases the size of the Managed Instance Group (MIG) by 1, if it has not reached the maximum limit.
-func AddNodeToMIG(ctx *v1alpha1.Context) (int32, int32, error) {
-	ctxConn := context.Background()
+// AddNodeToMIG increases the size of one of the Managed Instance Groups (MIG), if the maximum limit has not been reached.
+// It returns the name of the scaled MIG, the total size of all the MIGs before and after scaling, and the maximum size.
+// When the new instances do not pass the startup probe, the sizes are returned along with ErrStartupTimeout
+func AddNodeToMIG(ctx *v1alpha1.Context) (string, int32, int32, int32, error) {
+	return AddNodesToMIG(ctx, 0)
+}
+
+// AddNodesToMIG is AddNodeToMIG adding the given number of nodes, like the step of the condition met,
+// instead of the scale up threshold of the limits applied. A step of 0 uses the threshold.
+// The nodes are spread over the MIGs when none of them fits all of them, and the MIG receiving most of them is returned
+func AddNodesToMIG(ctx *v1alpha1.Context, step int32) (string, int32, int32, int32, error) {
+	ctxConn := ctx.ConnContext()
 
 	// Create a new Compute client for managing the MIG
-	client, err := createComputeClient(ctxConn, ctx, compute.NewInstanceGroupManagersRESTClient)
+	client, err := newMIGClient(ctxConn, ctx)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to create Instance Group Managers client: %v", err)
+		return "", 0, 0, 0, err
 	}
 	defer client.Close()
 
-	// Get the current target size of the MIG
-	targetSize, err := getMIGTargetSize(ctxConn, client, ctx)
+	// Get the current target size of every MIG
+	migs := getMIGs(ctx)
+	sizes, totalSize, err := getOwnedMIGTargetSizes(ctxConn, client, ctx, migs)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to get MIG target size: %v", err)
+		return "", 0, 0, 0, fmt.Errorf("failed to get MIG target size: %w", err)
 	}
-	log.Printf("Current size of MIG is %d nodes", targetSize)
+	log.Printf("Current size of MIG is %d nodes", totalSize)
 
 	// Get the scaling limits (minimum and maximum)
-	_, maxSize, scaleUpThreshold, _ := getMIGScalingLimits(ctx)
+	limits := getMIGScalingLimits(ctx).WithUpStep(step)
+	maxSize, scaleUpThreshold := limits.MaxSize, limits.ScaleUpThreshold
+
+	// Get the desired size of the MIG, checking if it has reached its maximum size
+	desiredSize, allowed := decision.ScaleUpSize(totalSize, limits)
+	if !allowed {
+		log.Printf("MIG has reached its maximum size (%d/%d), no further scaling is possible", totalSize, maxSize)
+		return "", -1, -1, -1, nil
+	}
 
-	// Get the desired size of the MIG
-	desiredSize := targetSize + scaleUpThreshold
+	// Select the MIGs where the new nodes will be created, spreading them when no single MIG fits all of them
+	added, placed := distributeScaleUp(ctx, migs, sizes, scaleUpThreshold)
+	if placed == 0 {
+		log.Printf("All the MIGs have reached their maximum size, no further scaling is possible")
+		return "", -1, -1, -1, nil
+	}
+	if placed < scaleUpThreshold {
+		log.Printf("The MIGs only have room for %d of the %d new nodes", placed, scaleUpThreshold)
+		desiredSize = totalSize + placed
+	}
+	mig := migs[largestShare(added)]
 
-	// Check if the MIG has reached its maximum size
-	if desiredSize > maxSize {
-		log.Printf("MIG has reached its maximum size (%d/%d), no further scaling is possible", targetSize, maxSize)
-		return -1, -1, nil
+	// Check that the new nodes do not exceed the monthly budget
+	err = checkScaleUpBudget(ctxConn, client, ctx, migs, sizes, added)
+	if err != nil {
+		return "", 0, 0, 0, err
+	}
+
+	// Add the new instances in waves of the surge, or all at once, waiting for every wave to be ready before
+	// the next one. The size reached is returned when a wave is not ready
+	probeStartup := ctx.Config.Autoscaler.StartupProbe.TimeoutSec > 0 && !ctx.Config.Autoscaler.DryRunInfrastructure
+	surge := int32(ctx.Config.Autoscaler.ScaleUpSurge)
+	if surge <= 0 || ctx.Config.Autoscaler.DryRunInfrastructure {
+		surge = placed
+	}
+	reachedSize := totalSize
+	for _, wave := range splitIntoWaves(added, surge) {
+		waveSize := sumSizes(wave)
+		err = addWave(ctxConn, client, ctx, migs, sizes, wave, reachedSize+waveSize, probeStartup)
+		if errors.Is(err, ErrStartupTimeout) {
+			return mig.Name, totalSize, reachedSize + waveSize, maxSize, err
+		}
+		if err != nil {
+			return "", 0, 0, 0, err
+		}
+		for i := range migs {
+			sizes[i] += wave[i]
+		}
+		reachedSize += waveSize
+		log.Printf("Added %d new nodes, %d/%d", waveSize, reachedSize, maxSize)
 	}
 
-	// Create a request to resize the MIG by increasing the target size by 1
-	req := &computepb.ResizeInstanceGroupManagerRequest{
-		Project:              ctx.Config.Infrastructure.GCP.ProjectID,
-		Zone:                 ctx.Config.Infrastructure.GCP.Zone,
-		InstanceGroupManager: ctx.Config.Infrastructure.GCP.MIGName,
-		Size:                 desiredSize,
+	return mig.Name, totalSize, desiredSize, maxSize, nil
+}
+
+// addWave resizes the MIGs adding the new nodes of the wave to their sizes, unless the infrastructure is a dry run or the leadership
+// was lost meanwhile, and executes the hooks defined after adding them with the total size reached. With probeStartup,
+// it waits for the new instances to be ready, returning ErrStartupTimeout when they are not. The MIGs are already resized then.
+// The nodes of a MIG failing with a zone stockout are moved in the wave to the least loaded MIG in another location
+func addWave(ctxConn context.Context, client *migClient, ctx *v1alpha1.Context, migs []v1alpha1.MIGSpec, sizes []int32, wave []int32,
+	totalSize int32, probeStartup bool) error {
+	// Remember the instances of the MIGs, so the new ones can be probed once created
+	var err error
+	existing := make([][]string, len(migs))
+	if probeStartup {
+		for i := range migs {
+			if wave[i] == 0 {
+				continue
+			}
+			existing[i], err = getMIGInstanceNames(ctxConn, client, ctx, migs[i])
+			if err != nil {
+				return err
+			}
+		}
 	}
 
-	// Resize the MIG if not in debug mode
-	if !ctx.Config.Autoscaler.DebugMode {
-		_, err = client.Resize(ctxConn, req)
+	err = ctx.CheckLeadership()
+	if err != nil {
+		return err
+	}
+	var pending []int
+	for i := range migs {
+		if wave[i] == 0 {
+			continue
+		}
+		if ctx.Config.Autoscaler.DryRunInfrastructure {
+			dryrun.Record(ctx, dryrun.ModuleGCP, "resize MIG %s to %d nodes", migs[i].Name, sizes[i]+wave[i])
+			continue
+		}
+		pending = append(pending, i)
+	}
+	stockedOut := make([]bool, len(migs))
+	for len(pending) > 0 {
+		i := pending[0]
+		pending = pending[1:]
+
+		// Record the resize as in-flight, so a crash does not leave it unknown whether it took effect
+		state.StartOperation(ctx, v1alpha1.Operation{
+			Type:         state.OperationScaleUp,
+			Phase:        state.PhaseResizing,
+			MIG:          migs[i].Name,
+			StartedAt:    time.Now(),
+			PreviousSize: sizes[i],
+			ExpectedSize: sizes[i] + wave[i],
+		})
+		err = client.resize(ctxConn, ctx, migs[i], sizes[i]+wave[i])
+		state.FinishOperation(ctx)
+
+		// Move the nodes of a MIG whose zone is out of resources to another MIG, in another location
+		var operationError *OperationError
+		if errors.As(err, &operationError) && operationError.Cause == CauseStockout {
+			for j := range migs {
+				stockedOut[j] = stockedOut[j] || getMIGLocation(migs[j]) == getMIGLocation(migs[i])
+			}
+			fallback := selectFallbackMIG(migs, sizes, wave, stockedOut, wave[i])
+			if fallback != -1 {
+				if probeStartup && wave[fallback] == 0 {
+					existing[fallback], err = getMIGInstanceNames(ctxConn, client, ctx, migs[fallback])
+					if err != nil {
+						return err
+					}
+				}
+				log.Printf("Zone stockout resizing MIG %s, adding its %d new nodes to MIG %s instead: %v", migs[i].Name, wave[i], migs[fallback].Name, operationError)
+				notifier.Notify(ctx, notifier.SeverityWarning, notifier.EventScaleUp,
+					fmt.Sprintf("%s is out of resources for MIG %s, adding its %d new nodes to MIG %s in %s instead",
+						getMIGLocation(migs[i]), migs[i].Name, wave[i], migs[fallback].Name, getMIGLocation(migs[fallback])))
+				if !slices.Contains(pending, fallback) {
+					pending = append(pending, fallback)
+				}
+				wave[fallback] += wave[i]
+				wave[i] = 0
+				continue
+			}
+		}
+		if err != nil && !appliedDespiteError(ctxConn, client, ctx, migs[i], v1alpha1.Operation{
+			Type: state.OperationScaleUp, MIG: migs[i].Name, ExpectedSize: sizes[i] + wave[i]}, err) {
+			return fmt.Errorf("failed to resize MIG %s: %w", migs[i].Name, err)
+		}
+		log.Printf("Scaled up MIG %s successfully by %d nodes, to %d nodes", migs[i].Name, wave[i], sizes[i]+wave[i])
+	}
+
+	// Execute the hooks defined after adding the instances
+	for i := range migs {
+		if wave[i] == 0 {
+			continue
+		}
+		err = hooks.RunHooks(ctx, hooks.Data{Event: hooks.EventPostScaleUp, MIG: migs[i].Name, Zone: getMIGLocation(migs[i]), Size: totalSize})
 		if err != nil {
-			return 0, 0, err
-		} else {
-			log.Printf("Scaled up MIG successfully %d/%d", desiredSize, maxSize)
+			log.Printf("Error executing hooks: %v", err)
 		}
 	}
-	return desiredSize, maxSize, nil
+
+	// Wait for the new instances to be ready
+	if probeStartup {
+		for i := range migs {
+			if wave[i] == 0 {
+				continue
+			}
+			err = waitForStartup(ctxConn, client, ctx, migs[i], existing[i], wave[i])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
-// RemoveNodeFromMIG decreases the size of the Managed Instance Group (MIG) by 1, if it has not reached the minimum limit.
-func RemoveNodeFromMIG(ctx *v1alpha1.Context) (int32, int32, string, error) {
-	ctxConn := context.Background()
+// RemoveNodeFromMIG decreases the size of one of the Managed Instance Groups (MIG) by 1, if the minimum limit has not been reached.
+// It returns the name of the scaled MIG, the total size of all the MIGs before and after scaling, the minimum size and the removed instance.
+func RemoveNodeFromMIG(ctx *v1alpha1.Context) (string, int32, int32, int32, string, error) {
+	ctxConn := ctx.ConnContext()
 
 	// Create a new Compute client for managing the MIG
-	client, err := createComputeClient(ctxConn, ctx, compute.NewInstanceGroupManagersRESTClient)
+	client, err := newMIGClient(ctxConn, ctx)
 	if err != nil {
-		return 0, 0, "", fmt.Errorf("failed to create Instance Group Managers client: %v", err)
+		return "", 0, 0, 0, "", err
 	}
 	defer client.Close()
 
-	// Get the current target size of the MIG
-	targetSize, err := getMIGTargetSize(ctxConn, client, ctx)
+	// Get the current target size of every MIG
+	migs := getMIGs(ctx)
+	sizes, totalSize, err := getOwnedMIGTargetSizes(ctxConn, client, ctx, migs)
 	if err != nil {
-		return 0, 0, "", fmt.Errorf("failed to get MIG target size: %v", err)
+		return "", 0, 0, 0, "", fmt.Errorf("failed to get MIG target size: %w", err)
 	}
-	log.Printf("Current size of MIG is %d nodes", targetSize)
+	log.Printf("Current size of MIG is %d nodes", totalSize)
 
 	// Get the scaling limits (minimum and maximum)
-	minSize, _, _, scaleDownThreshold := getMIGScalingLimits(ctx)
+	limits := getMIGScalingLimits(ctx)
+	minSize, scaleDownThreshold := limits.MinSize, limits.ScaleDownThreshold
+
+	// Get the desired size of the MIG, checking if it has reached its minimum size
+	desiredSize, allowed := decision.ScaleDownSize(totalSize, limits)
+	if !allowed {
+		log.Printf("MIG has reached its minimum size (%d/%d), no further scaling down is possible", totalSize, minSize)
+		return "", -1, -1, -1, "", nil
+	}
 
-	// Get the desired size of the MIG
-	desiredSize := targetSize - scaleDownThreshold
+	// Select the MIG where the node will be removed from, and a random instance of it to remove
+	selected, instanceURL, reason, err := selectInstanceForScaleDown(ctxConn, client, ctx, migs, sizes, scaleDownThreshold)
+	if err != nil {
+		return "", 0, 0, 0, "", err
+	}
+	if selected == -1 {
+		log.Printf("No instance can be removed, no further scaling down is possible: %s", reason)
+		return "", -1, -1, -1, "", nil
+	}
+	mig := migs[selected]
+	instanceToRemove := getInstanceNameFromURL(instanceURL)
+
+	// Instances with deletion protection enabled are parked instead: abandoned from the MIG and stopped.
+	// It is checked before draining the instance, so a failure leaves nothing to roll back
+	parkInstance := false
+	if ctx.Config.Infrastructure.GCP.ScaleDownAction == ScaleDownActionDelete &&
+		ctx.Config.Infrastructure.GCP.DeletionProtectionPolicy == DeletionProtectionPolicyStop {
+		parkInstance, err = client.isDeletionProtected(ctxConn, ctx, instanceURL)
+		if err != nil {
+			return "", 0, 0, 0, "", fmt.Errorf("error checking deletion protection: %v", err)
+		}
+	}
 
-	// Check if the MIG has reached its minimum size
-	if desiredSize < minSize {
-		log.Printf("MIG has reached its minimum size (%d/%d), no further scaling down is possible", targetSize, minSize)
-		return -1, -1, "", nil
+	// Wait for a human to approve the scale down on Slack before draining the instance, when required
+	err = approval.Request(ctx, mig.Name, instanceToRemove)
+	if err != nil {
+		return "", 0, 0, 0, "", err
 	}
 
-	// Get a random instance from the MIG to remove
-	instanceToRemove, err := GetInstanceToRemove(ctxConn, client, ctx)
+	// Record the operation as in-flight until it finishes, so it can be recovered after a crash
+	operation := v1alpha1.Operation{
+		Type:         state.OperationScaleDown,
+		Phase:        state.PhaseDraining,
+		MIG:          mig.Name,
+		Instance:     instanceToRemove,
+		Zone:         getZoneFromURL(instanceURL),
+		StartedAt:    time.Now(),
+		PreviousSize: sizes[selected],
+		ExpectedSize: sizes[selected] - 1,
+	}
+	state.StartOperation(ctx, operation)
+	defer func() {
+		// Keep the operation when panicking, so it is recovered when the loop of the autoscaler is restarted
+		if r := recover(); r != nil {
+			panic(r)
+		}
+		// Keep it too when the leadership was lost in the middle of it, as this replica must not act anymore
+		if ctx.CheckLeadership() != nil {
+			log.Printf("Leadership lost while removing instance %s, its operation is left to be recovered", instanceToRemove)
+			return
+		}
+		state.FinishOperation(ctx)
+	}()
+
+	// Check the leadership before every destructive step, as the approval and the drains may take long
+	err = ctx.CheckLeadership()
 	if err != nil {
-		return 0, 0, "", fmt.Errorf("error getting instance to remove: %v", err)
+		return "", 0, 0, 0, "", err
 	}
 
-	// If not in debug mode, drain the node from Elasticsearch before removal
+	// Label the instance as draining until the scale down finishes, unless it is deleted and takes the label with it.
+	// When the leadership is lost, the label is left to the recovery of the operation
+	deleted := false
+	client.markDraining(ctxConn, ctx, instanceURL)
+	defer func() {
+		if !deleted && ctx.CheckLeadership() == nil {
+			client.unmarkDraining(ctxConn, ctx, instanceURL)
+		}
+	}()
+
+	// Drain the node from Elasticsearch before removal, unless the target is a dry run
 	// Chech if elasticsearch is defined in the target
 	if ctx.Config.Target.Elasticsearch.URL != "" {
 
@@ -113,131 +345,157 @@ func RemoveNodeFromMIG(ctx *v1alpha1.Context) (int32, int32, string, error) {
 		log.Printf("Instance to remove: %s. Draining from elasticsearch cluster", instanceToRemove)
 		err = elasticsearch.DrainElasticsearchNode(ctx, instanceToRemove)
 		if err != nil {
-			return 0, 0, "", fmt.Errorf("error draining Elasticsearch node: %v", err)
+			return "", 0, 0, 0, "", fmt.Errorf("error draining Elasticsearch node: %w", err)
 		}
 		log.Printf("Instance drained successfully from elasticsearch cluster")
 	}
 
-	// Create a request to delete the selected instance and reduce the MIG size
-	instanceURL := fmt.Sprintf("projects/%s/zones/%s/instances/%s", ctx.Config.Infrastructure.GCP.ProjectID, ctx.Config.Infrastructure.GCP.Zone, instanceToRemove)
-	deleteReq := &computepb.DeleteInstancesInstanceGroupManagerRequest{
-		Project:              ctx.Config.Infrastructure.GCP.ProjectID,
-		Zone:                 ctx.Config.Infrastructure.GCP.Zone,
-		InstanceGroupManager: ctx.Config.Infrastructure.GCP.MIGName,
-		InstanceGroupManagersDeleteInstancesRequestResource: &computepb.InstanceGroupManagersDeleteInstancesRequest{
-			Instances: []string{instanceURL},
-		},
-	}
-
-	// Delete the instance if not in debug mode
-	if !ctx.Config.Autoscaler.DebugMode {
-		_, err = client.DeleteInstances(ctxConn, deleteReq)
+	// Cordon and drain the Kubernetes node too when the MIG belongs to a GKE node pool
+	if gke.Enabled(ctx.Config.Infrastructure.GCP) {
+		err = ctx.CheckLeadership()
 		if err != nil {
-			return 0, 0, "", fmt.Errorf("error deleting instance: %v", err)
+			return "", 0, 0, 0, "", err
 		}
-	}
-
-	log.Printf("Scaled down MIG successfully %d/%d", desiredSize, minSize)
-
-	// Wait 90 seconds until instance is fully deleted
-	// Google Cloud has a deletion timeout of 90 seconds max
-	if !ctx.Config.Autoscaler.DebugMode {
-		time.Sleep(90 * time.Second)
-	} else {
-		log.Printf("Debug mode enabled. Skipping 90 seconds timeout until instance deletion")
-	}
-
-	// Chech if elasticsearch is defined in the target
-	if ctx.Config.Target.Elasticsearch.URL != "" {
-
-		// Remove the elasticsearch node from cluster settings
-		err = elasticsearch.ClearElasticsearchClusterSettings(ctx, instanceToRemove)
+		log.Printf("Draining Kubernetes node %s", instanceToRemove)
+		err = gke.DrainNode(ctx, instanceToRemove)
 		if err != nil {
-			return 0, 0, "", fmt.Errorf("error clearing Elasticsearch cluster settings: %v", err)
+			rollbackDrain(ctx, instanceToRemove)
+			return "", 0, 0, 0, "", fmt.Errorf("error draining Kubernetes node: %v", err)
 		}
-		log.Printf("Cleared up elasticsearch settings for draining node")
 	}
 
-	return desiredSize, minSize, instanceToRemove, nil
-}
-
-// getMIGScalingLimits retrieves the minimum and maximum scaling limits for a Managed Instance Group (MIG) and how many nodes to scale up/down.
-func getMIGScalingLimits(ctx *v1alpha1.Context) (int32, int32, int32, int32) {
-	currentTime := time.Now().UTC()
-	currentWeekday := int(currentTime.Weekday())
-	var scaleDownThreshold int32 = 1
+	// Execute the hooks defined before removing the instance. If any of them fails, the instance
+	// is not removed, so it is added back to the elasticsearch cluster allocation
+	hookData := hooks.Data{MIG: mig.Name, Zone: getZoneFromURL(instanceURL), InstanceName: instanceToRemove, Size: desiredSize}
+	hookData.Event = hooks.EventPreScaleDown
+	err = hooks.RunHooks(ctx, hookData)
+	if err != nil {
+		rollbackDrain(ctx, instanceToRemove)
+		return "", 0, 0, 0, "", err
+	}
 
-	for _, scalingConfig := range ctx.Config.Autoscaler.AdvancedCustomScalingConfiguration {
+	err = ctx.CheckLeadership()
+	if err != nil {
+		return "", 0, 0, 0, "", err
+	}
+	switch {
+	case ctx.Config.Infrastructure.GCP.ScaleDownAction == ScaleDownActionAbandon:
+		state.SetOperationPhase(ctx, state.PhaseAbandoning)
+	case parkInstance:
+		state.SetOperationPhase(ctx, state.PhaseParking)
+	default:
+		state.SetOperationPhase(ctx, state.PhaseDeleting)
+	}
 
-		// Set default values if not provided
-		if scalingConfig.ScaleUpThreshold == 0 {
-			scalingConfig.ScaleUpThreshold = ctx.Config.Autoscaler.ScaleUpThreshold
-		}
-		if scalingConfig.MinSize == 0 {
-			scalingConfig.MinSize = ctx.Config.Autoscaler.MinSize
+	switch {
+	// Abandoned instances keep running outside the MIG until ops destroy them
+	case ctx.Config.Infrastructure.GCP.ScaleDownAction == ScaleDownActionAbandon:
+		if ctx.Config.Autoscaler.DryRunInfrastructure {
+			dryrun.Record(ctx, dryrun.ModuleGCP, "abandon instance %s from MIG %s, resizing it to %d nodes", instanceToRemove, mig.Name, desiredSize)
+		} else {
+			err = client.abandonInstances(ctxConn, ctx, mig, []string{instanceURL})
+			if err != nil && !appliedDespiteError(ctxConn, client, ctx, mig, operation, err) {
+				rollbackDrain(ctx, instanceToRemove)
+				return "", 0, 0, 0, "", fmt.Errorf("error abandoning instance: %v", err)
+			}
 		}
-		if scalingConfig.MaxSize == 0 {
-			scalingConfig.MaxSize = ctx.Config.Autoscaler.MaxSize
+		log.Printf("Scaled down MIG %s successfully %d/%d. Instance %s abandoned and kept alive outside the MIG", mig.Name, desiredSize, minSize, instanceToRemove)
+
+	case parkInstance:
+		if ctx.Config.Autoscaler.DryRunInfrastructure {
+			dryrun.Record(ctx, dryrun.ModuleGCP, "abandon and stop instance %s from MIG %s, resizing it to %d nodes", instanceToRemove, mig.Name, desiredSize)
+		} else {
+			err = client.abandonInstances(ctxConn, ctx, mig, []string{instanceURL})
+			if err != nil && !appliedDespiteError(ctxConn, client, ctx, mig, operation, err) {
+				rollbackDrain(ctx, instanceToRemove)
+				return "", 0, 0, 0, "", fmt.Errorf("error abandoning instance: %v", err)
+			}
+			err = client.stopInstance(ctxConn, ctx, instanceURL)
+			if err != nil {
+				rollbackDrain(ctx, instanceToRemove)
+				return "", 0, 0, 0, "", fmt.Errorf("error stopping instance: %v", err)
+			}
 		}
+		log.Printf("Scaled down MIG %s successfully %d/%d. Instance %s has deletion protection enabled, so it was abandoned and stopped", mig.Name, desiredSize, minSize, instanceToRemove)
 
-		// Check if current day is within the critical period days
-		criticalPeriodDays := strings.Split(scalingConfig.Days, ",")
-		for _, criticalPeriodDay := range criticalPeriodDays {
-			if strings.TrimSpace(criticalPeriodDay) == strconv.Itoa(currentWeekday) {
-				if scalingConfig.HoursUTC != "" {
-					criticalPeriodHours := strings.Split(scalingConfig.HoursUTC, "-")
-					if len(criticalPeriodHours) != 2 {
-						log.Fatalf("Invalid hours format in advanced_scaling_configuration. Expected start and end hours separated by a dash (e.g., 4:00:00-6:00:00)")
-						return int32(ctx.Config.Autoscaler.MinSize), int32(ctx.Config.Autoscaler.MaxSize), int32(ctx.Config.Autoscaler.ScaleUpThreshold), scaleDownThreshold
-					}
-					// Parse start and end hours
-					startHour, err := time.Parse("15:04:05", criticalPeriodHours[0])
-					if err != nil {
-						log.Printf("Error parsing start hour: %v", err)
-						return int32(ctx.Config.Autoscaler.MinSize), int32(ctx.Config.Autoscaler.MaxSize), int32(ctx.Config.Autoscaler.ScaleUpThreshold), scaleDownThreshold
-					}
-					endHour, err := time.Parse("15:04:05", criticalPeriodHours[1])
-					if err != nil {
-						log.Printf("Error parsing end hour: %v", err)
-						return int32(ctx.Config.Autoscaler.MinSize), int32(ctx.Config.Autoscaler.MaxSize), int32(ctx.Config.Autoscaler.ScaleUpThreshold), scaleDownThreshold
-					}
+	default:
+		// Delete the selected instance, reducing the MIG size, unless the infrastructure is a dry run
+		if ctx.Config.Autoscaler.DryRunInfrastructure {
+			dryrun.Record(ctx, dryrun.ModuleGCP, "delete instance %s from MIG %s, resizing it to %d nodes", instanceToRemove, mig.Name, desiredSize)
+		} else {
+			err = client.deleteInstances(ctxConn, ctx, mig, []string{instanceURL})
+			if err != nil && !appliedDespiteError(ctxConn, client, ctx, mig, operation, err) {
+				return "", 0, 0, 0, "", fmt.Errorf("error deleting instance: %v", err)
+			}
+		}
+		deleted = true
 
-					// Adjust start and end times to match the current date
-					startTime := time.Date(currentTime.Year(), currentTime.Month(), currentTime.Day(), startHour.Hour(), startHour.Minute(), startHour.Second(), 0, currentTime.Location())
-					endTime := time.Date(currentTime.Year(), currentTime.Month(), currentTime.Day(), endHour.Hour(), endHour.Minute(), endHour.Second(), 0, currentTime.Location())
+		log.Printf("Scaled down MIG %s successfully %d/%d", mig.Name, desiredSize, minSize)
 
-					// Check if current time is within the critical period
-					if currentTime.After(startTime) && currentTime.Before(endTime) {
-						return int32(scalingConfig.MinSize), int32(scalingConfig.MaxSize), int32(scalingConfig.ScaleUpThreshold), scaleDownThreshold
-					}
-				} else {
-					// If no hours are provided, assume critical period is for the entire day
-					return int32(scalingConfig.MinSize), int32(scalingConfig.MaxSize), int32(scalingConfig.ScaleUpThreshold), scaleDownThreshold
-				}
+		// Wait 90 seconds until instance is fully deleted
+		// Google Cloud has a deletion timeout of 90 seconds max
+		if !ctx.Config.Autoscaler.DryRunInfrastructure {
+			err = retry.Sleep(ctxConn, 90*time.Second)
+			if err != nil {
+				return "", 0, 0, 0, "", fmt.Errorf("error waiting for the deletion of the instance: %v", err)
 			}
+		} else {
+			log.Printf("Dry run of the infrastructure enabled. Skipping 90 seconds timeout until instance deletion")
 		}
 	}
 
-	return int32(ctx.Config.Autoscaler.MinSize), int32(ctx.Config.Autoscaler.MaxSize), int32(ctx.Config.Autoscaler.ScaleUpThreshold), scaleDownThreshold
-}
+	// Remove the elasticsearch node from cluster settings once the instance has left the MIG, whatever the
+	// action, so the exclusions do not pile up with the instances abandoned or parked
+	if ctx.Config.Target.Elasticsearch.URL != "" {
+		err = elasticsearch.ClearElasticsearchClusterSettings(ctx, instanceToRemove)
+		if err != nil {
+			return "", 0, 0, 0, "", fmt.Errorf("error clearing Elasticsearch cluster settings: %v", err)
+		}
+		log.Printf("Cleared up elasticsearch settings for draining node")
+	}
 
-// getMIGTargetSize retrieves the current target size of a Managed Instance Group (MIG).
-func getMIGTargetSize(ctxConn context.Context, client *compute.InstanceGroupManagersClient, ctx *v1alpha1.Context) (int32, error) {
-	// Create a request to get the MIG details
-	req := &computepb.GetInstanceGroupManagerRequest{
-		Project:              ctx.Config.Infrastructure.GCP.ProjectID,
-		Zone:                 ctx.Config.Infrastructure.GCP.Zone,
-		InstanceGroupManager: ctx.Config.Infrastructure.GCP.MIGName,
+	// Execute the hooks defined after removing the instance
+	hookData.Event = hooks.EventPostScaleDown
+	err = hooks.RunHooks(ctx, hookData)
+	if err != nil {
+		log.Printf("Error executing hooks: %v", err)
 	}
 
+	return mig.Name, totalSize, desiredSize, minSize, instanceToRemove, nil
+}
+
+// getMIGScalingLimits retrieves the minimum and maximum scaling limits for a Managed Instance Group (MIG) and how many nodes to scale up/down.
+func getMIGScalingLimits(ctx *v1alpha1.Context) decision.Limits {
+	return decision.GetLimits(ctx.Config, time.Now().UTC())
+}
+
+// getMIGTargetSize retrieves the current target size of a Managed Instance Group (MIG).
+func getMIGTargetSize(ctxConn context.Context, client *migClient, ctx *v1alpha1.Context, mig v1alpha1.MIGSpec) (int32, error) {
 	// Get the MIG details from Google Cloud
-	mig, err := client.Get(ctxConn, req)
+	instanceGroupManager, err := client.get(ctxConn, ctx, mig)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get MIG: %v", err)
+		return 0, fmt.Errorf("failed to get MIG %s: %v", mig.Name, err)
 	}
 
 	// Return the current target size of the MIG
-	return mig.GetTargetSize(), nil
+	return instanceGroupManager.GetTargetSize(), nil
+}
+
+// getMIGTargetSizes retrieves the current target size of every given MIG, and the sum of all of them.
+func getMIGTargetSizes(ctxConn context.Context, client *migClient, ctx *v1alpha1.Context, migs []v1alpha1.MIGSpec) ([]int32, int32, error) {
+	sizes := make([]int32, len(migs))
+	var totalSize int32
+
+	for i, mig := range migs {
+		targetSize, err := getMIGTargetSize(ctxConn, client, ctx, mig)
+		if err != nil {
+			return nil, 0, err
+		}
+		sizes[i] = targetSize
+		totalSize += targetSize
+	}
+
+	return sizes, totalSize, nil
 }
 
 // getInstanceNameFromURL parses the Google Cloud instance name to get just the hostname
@@ -250,107 +508,287 @@ func getInstanceNameFromURL(instanceURL string) string {
 	return ""
 }
 
-// GetInstanceToRemove retrieves a random instance from the MIG to be removed.
-func GetInstanceToRemove(ctxConn context.Context, client *compute.InstanceGroupManagersClient, ctx *v1alpha1.Context) (string, error) {
+// getZoneFromURL parses the Google Cloud instance URL to get the zone where the instance lives
+func getZoneFromURL(instanceURL string) string {
+	parts := strings.Split(instanceURL, "/")
+	for i, part := range parts {
+		if part == "zones" && i+1 < len(parts) {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// GetInstanceToRemove retrieves the URL of a random instance from the MIG to be removed, living in the zone with
+// the most instances, so the instances of regional MIGs stay evenly spread across their zones.
+// Instances living in zones that would go below the minimum size per zone are never selected,
+// neither the ones with deletion protection enabled when they can not be stopped instead.
+// An empty URL is returned when the minimum size per zone prevents removing any instance, and ErrDeletionProtected
+// when the remaining ones are all protected.
+func GetInstanceToRemove(ctxConn context.Context, client *migClient, ctx *v1alpha1.Context, mig v1alpha1.MIGSpec) (string, error) {
 	// Get the list of instances in the MIG
-	instanceNames, err := getMIGInstanceNames(ctxConn, client, ctx)
+	instanceURLs, err := getMIGInstanceNames(ctxConn, client, ctx, mig)
 	if err != nil {
 		return "", err
 	}
-	if len(instanceNames) == 0 {
+	if len(instanceURLs) == 0 {
 		return "", fmt.Errorf("no instances found in the MIG")
 	}
 
-	// Randomly select an instance to remove
-	randomIndex, err := rand.Int(rand.Reader, big.NewInt(int64(len(instanceNames))))
-	if err != nil {
-		return "", fmt.Errorf("error selecting random instance: %v", err)
+	// Count the instances per zone to preserve the zone redundancy
+	instancesPerZone := map[string]int{}
+	for _, instanceURL := range instanceURLs {
+		instancesPerZone[getZoneFromURL(instanceURL)]++
 	}
-	randomInstance := int(randomIndex.Int64())
 
-	return getInstanceNameFromURL(instanceNames[randomInstance]), nil
-}
+	var candidates []string
+	for _, instanceURL := range instanceURLs {
+		if instancesPerZone[getZoneFromURL(instanceURL)] > mig.MinPerZone {
+			candidates = append(candidates, instanceURL)
+		}
+	}
 
-// getMIGInstanceNames retrieves the list of instance names in a Managed Instance Group (MIG).
-func getMIGInstanceNames(ctxConn context.Context, client *compute.InstanceGroupManagersClient, ctx *v1alpha1.Context) ([]string, error) {
-	// Create a request to list the managed instances in the MIG
-	req := &computepb.ListManagedInstancesInstanceGroupManagersRequest{
-		Project:              ctx.Config.Infrastructure.GCP.ProjectID,
-		Zone:                 ctx.Config.Infrastructure.GCP.Zone,
-		InstanceGroupManager: ctx.Config.Infrastructure.GCP.MIGName,
+	// Randomly select an instance to remove from the fullest zones. Instances with deletion protection enabled are
+	// skipped, unless they can be stopped instead of deleted
+	checkDeletionProtection := ctx.Config.Infrastructure.GCP.ScaleDownAction == ScaleDownActionDelete &&
+		ctx.Config.Infrastructure.GCP.DeletionProtectionPolicy != DeletionProtectionPolicyStop
+	for len(candidates) > 0 {
+		fullest := inFullestZones(candidates, instancesPerZone)
+		randomIndex, err := rand.Int(rand.Reader, big.NewInt(int64(len(fullest))))
+		if err != nil {
+			return "", fmt.Errorf("error selecting random instance: %v", err)
+		}
+		randomInstance := fullest[randomIndex.Int64()]
+
+		if !checkDeletionProtection {
+			return randomInstance, nil
+		}
+
+		protected, err := client.isDeletionProtected(ctxConn, ctx, randomInstance)
+		if err != nil {
+			return "", fmt.Errorf("error checking deletion protection: %v", err)
+		}
+		if !protected {
+			return randomInstance, nil
+		}
+
+		log.Printf("Instance %s has deletion protection enabled, selecting another one", getInstanceNameFromURL(randomInstance))
+		candidates = slices.DeleteFunc(candidates, func(candidate string) bool { return candidate == randomInstance })
+		if len(candidates) == 0 {
+			return "", ErrDeletionProtected
+		}
 	}
 
-	// Call the API and get an iterator for the managed instances
-	it := client.ListManagedInstances(ctxConn, req)
+	return "", nil
+}
+
+// getMIGInstanceNames retrieves the list of instance URLs in a Managed Instance Group (MIG),
+// listed once per decision cycle
+func getMIGInstanceNames(ctxConn context.Context, client *migClient, ctx *v1alpha1.Context, mig v1alpha1.MIGSpec) ([]string, error) {
+	// Call the API to get the managed instances, unless they were listed in this decision cycle
+	instances, err := client.listCachedManagedInstances(ctxConn, ctx, mig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list managed instances: %v", err)
+	}
 
 	// Store the instance names in a slice
 	var instanceNames []string
+	for _, instance := range instances {
+		instanceNames = append(instanceNames, instance.GetInstance())
+	}
 
-	// Iterate through the instances and collect their names
-	for {
-		instance, err := it.Next()
-		if err == iterator.Done {
-			break // End of iteration
+	return instanceNames, nil
+}
+
+// GetMIGInstanceNames returns the names of the instances of the given MIG
+func GetMIGInstanceNames(ctx *v1alpha1.Context, migName string) ([]string, error) {
+	ctxConn := ctx.ConnContext()
+
+	client, err := newMIGClient(ctxConn, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	for _, mig := range getMIGs(ctx) {
+		if mig.Name != migName {
+			continue
 		}
+		instanceURLs, err := getMIGInstanceNames(ctxConn, client, ctx, mig)
 		if err != nil {
-			return nil, fmt.Errorf("failed to list managed instances: %v", err)
+			return nil, err
+		}
+		names := make([]string, 0, len(instanceURLs))
+		for _, instanceURL := range instanceURLs {
+			names = append(names, getInstanceNameFromURL(instanceURL))
 		}
+		return names, nil
+	}
+	return nil, fmt.Errorf("MIG %s not found in the config", migName)
+}
+
+// RecreateInstance recreates the given instance of the MIG from its instance template, keeping its name
+func RecreateInstance(ctx *v1alpha1.Context, migName string, instanceName string) error {
+	ctxConn := ctx.ConnContext()
 
-		// Append the instance name to the list
-		instanceNames = append(instanceNames, *instance.Instance)
+	client, err := newMIGClient(ctxConn, ctx)
+	if err != nil {
+		return err
 	}
+	defer client.Close()
 
-	return instanceNames, nil
+	for _, mig := range getMIGs(ctx) {
+		if mig.Name != migName {
+			continue
+		}
+		instanceURLs, err := getMIGInstanceNames(ctxConn, client, ctx, mig)
+		if err != nil {
+			return err
+		}
+		for _, instanceURL := range instanceURLs {
+			if getInstanceNameFromURL(instanceURL) == instanceName {
+				return client.recreateInstances(ctxConn, ctx, mig, []string{instanceURL})
+			}
+		}
+		return fmt.Errorf("instance %s not found in MIG %s", instanceName, migName)
+	}
+	return fmt.Errorf("MIG %s not found in the config", migName)
 }
 
-// CheckMIGMinimumSize ensures that the MIG has at least the minimum number of instances running.
+// CheckMIGMinimumSize ensures that every MIG has at least its minimum number of instances running,
+// and that the sum of all of them reaches the minimum size of the autoscaler.
 func CheckMIGMinimumSize(ctx *v1alpha1.Context) error {
-	ctxConn := context.Background()
+	ctxConn := ctx.ConnContext()
 
 	// Create a Compute client for managing the MIG
-	client, err := createComputeClient(ctxConn, ctx, compute.NewInstanceGroupManagersRESTClient)
+	client, err := newMIGClient(ctxConn, ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create Instance Group Managers client: %v", err)
+		return err
 	}
 	defer client.Close()
 
-	// Get the current target size of the MIG
-	targetSize, err := getMIGTargetSize(ctxConn, client, ctx)
+	// Get the current target size of every MIG
+	migs := getMIGs(ctx)
+	sizes, totalSize, err := getOwnedMIGTargetSizes(ctxConn, client, ctx, migs)
 	if err != nil {
-		return fmt.Errorf("failed to get MIG target size: %v", err)
+		return fmt.Errorf("failed to get MIG target size: %w", err)
 	}
 
 	// Get the scaling limits (minimum and maximum) and scaling up/down thresholds
-	minSize, _, _, _ := getMIGScalingLimits(ctx)
+	minSize := getMIGScalingLimits(ctx).MinSize
+
+	// Calculate the size every MIG needs to reach its own minimum and, as a whole, the global minimum
+	desiredSizes := make([]int32, len(migs))
+	var desiredTotalSize int32
+	for i, mig := range migs {
+		desiredSizes[i] = max(sizes[i], int32(mig.MinSize))
+		desiredTotalSize += desiredSizes[i]
+	}
+	for ; desiredTotalSize < minSize; desiredTotalSize++ {
+		selected := selectMIGForScaleUp(ctx, migs, desiredSizes, 1)
+		if selected == -1 {
+			log.Printf("All the MIGs have reached their maximum size, unable to reach the minimum size %d", minSize)
+			break
+		}
+		desiredSizes[selected]++
+	}
+
+	if desiredTotalSize == totalSize {
+		return nil
+	}
 
 	// If the MIG size is below the minimum, scale it up to the minimum size
-	if targetSize < minSize {
-		log.Printf("MIG size is below the limit (%d/%d), scaling it up...", targetSize, minSize)
-		req := &computepb.ResizeInstanceGroupManagerRequest{
-			Project:              ctx.Config.Infrastructure.GCP.ProjectID,
-			Zone:                 ctx.Config.Infrastructure.GCP.Zone,
-			InstanceGroupManager: ctx.Config.Infrastructure.GCP.MIGName,
-			Size:                 minSize,
-		}
-
-		// Resize the MIG if not in debug mode
-		if !ctx.Config.Autoscaler.DebugMode {
-			_, err = client.Resize(ctxConn, req)
+	log.Printf("MIG size is below the limit (%d/%d), scaling it up...", totalSize, minSize)
+	for i, mig := range migs {
+		if desiredSizes[i] == sizes[i] {
+			continue
+		}
+
+		// Resize the MIG unless the infrastructure is a dry run
+		if ctx.Config.Autoscaler.DryRunInfrastructure {
+			dryrun.Record(ctx, dryrun.ModuleGCP, "resize MIG %s to its minimum size %d", mig.Name, desiredSizes[i])
+		} else {
+			err = client.resize(ctxConn, ctx, mig, desiredSizes[i])
 			if err != nil {
 				return err
 			}
-			log.Printf("MIG %s scaled up to its minimum size %d", ctx.Config.Infrastructure.GCP.MIGName, minSize)
-			if ctx.Config.Notifications.Slack.WebhookURL != "" {
-				message := fmt.Sprintf("MIG %s scaled up to its minimum size %d", ctx.Config.Infrastructure.GCP.MIGName, minSize)
-				err = slack.NotifySlack(message, ctx.Config.Notifications.Slack.WebhookURL)
-				if err != nil {
-					log.Printf("Error sending Slack notification: %v", err)
-				}
-			}
-			time.Sleep(time.Duration(ctx.Config.Autoscaler.DefaultCooldownPeriodSec) * time.Second)
+			log.Printf("MIG %s scaled up to its minimum size %d", mig.Name, desiredSizes[i])
+			notifier.Notify(ctx, notifier.SeverityInfo, notifier.EventScaleUp, fmt.Sprintf("MIG %s scaled up to its minimum size %d", mig.Name, desiredSizes[i]))
 		}
 	}
 
+	if !ctx.Config.Autoscaler.DryRunInfrastructure {
+		return retry.Sleep(ctxConn, time.Duration(ctx.Config.Autoscaler.DefaultCooldownPeriodSec)*time.Second)
+	}
+
 	return nil
 
 }
+
+// GetMIGSizes returns the current target size of every Managed Instance Group (MIG) by name,
+// the total size of all of them and the scaling limits (minimum and maximum) currently applied
+func GetMIGSizes(ctx *v1alpha1.Context) (map[string]int32, int32, int32, int32, error) {
+	ctxConn := ctx.ConnContext()
+
+	// Create a Compute client for managing the MIG
+	client, err := newMIGClient(ctxConn, ctx)
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+	defer client.Close()
+
+	migs := getMIGs(ctx)
+	sizes, totalSize, err := getMIGTargetSizes(ctxConn, client, ctx, migs)
+	if err != nil {
+		return nil, 0, 0, 0, fmt.Errorf("failed to get MIG target size: %v", err)
+	}
+
+	migSizes := make(map[string]int32, len(migs))
+	for i, mig := range migs {
+		migSizes[mig.Name] = sizes[i]
+	}
+
+	limits := getMIGScalingLimits(ctx)
+	return migSizes, totalSize, limits.MinSize, limits.MaxSize, nil
+}
+
+// GetScaleUpHeadroom returns how many nodes can still be added to the Managed Instance Groups (MIGs), within the
+// maximum size of the limits currently applied and of every MIG
+func GetScaleUpHeadroom(ctx *v1alpha1.Context) (int32, error) {
+	ctxConn := ctx.ConnContext()
+
+	// Create a Compute client for managing the MIG
+	client, err := newMIGClient(ctxConn, ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	migs := getMIGs(ctx)
+	sizes, _, err := getMIGTargetSizes(ctxConn, client, ctx, migs)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get MIG target size: %v", err)
+	}
+
+	return scaleUpHeadroom(migs, sizes, getMIGScalingLimits(ctx).MaxSize), nil
+}
+
+// rollbackDrain adds the instance back to the elasticsearch cluster allocation and uncordons its Kubernetes node,
+// when configured, once its removal is cancelled. Nothing is done once the leadership is lost
+func rollbackDrain(ctx *v1alpha1.Context, instanceName string) {
+	if ctx.CheckLeadership() != nil {
+		return
+	}
+	if ctx.Config.Target.Elasticsearch.URL != "" {
+		err := elasticsearch.ClearElasticsearchClusterSettings(ctx, instanceName)
+		if err != nil {
+			log.Printf("Error clearing Elasticsearch cluster settings: %v", err)
+		}
+	}
+	if gke.Enabled(ctx.Config.Infrastructure.GCP) {
+		err := gke.UncordonNode(ctx, instanceName)
+		if err != nil {
+			log.Printf("Error uncordoning Kubernetes node: %v", err)
+		}
+	}
+}
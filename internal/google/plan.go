@@ -0,0 +1,112 @@
+package google
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/cost"
+	"custom-vm-autoscaler/internal/decision"
+)
+
+// Plan describes the scaling action the autoscaler would take, computed without modifying the MIGs
+type Plan struct {
+	MIG          string
+	PreviousSize int32
+	Size         int32
+
+	// Instance is the instance that would be removed when scaling down
+	Instance string
+
+	// Reason explains why no scaling action would be taken. It is empty when there is one
+	Reason string
+}
+
+// PlanScaleUp computes which MIGs would receive the new nodes if an up condition with the given step is met,
+// like AddNodesToMIG but only reading from GCP
+func PlanScaleUp(ctx *v1alpha1.Context, step int32) (Plan, error) {
+	ctxConn := ctx.ConnContext()
+
+	client, err := newMIGClient(ctxConn, ctx)
+	if err != nil {
+		return Plan{}, err
+	}
+	defer client.Close()
+
+	migs := getMIGs(ctx)
+	sizes, totalSize, err := getMIGTargetSizes(ctxConn, client, ctx, migs)
+	if err != nil {
+		return Plan{}, fmt.Errorf("failed to get MIG target size: %v", err)
+	}
+
+	limits := getMIGScalingLimits(ctx).WithUpStep(step)
+	scaleUpThreshold := limits.ScaleUpThreshold
+	desiredSize, allowed := decision.ScaleUpSize(totalSize, limits)
+	plan := Plan{PreviousSize: totalSize, Size: desiredSize}
+	if !allowed {
+		plan.Reason = fmt.Sprintf("maximum size reached (%d/%d)", totalSize, limits.MaxSize)
+		return plan, nil
+	}
+
+	added, placed := distributeScaleUp(ctx, migs, sizes, scaleUpThreshold)
+	if placed == 0 {
+		plan.Reason = "all the MIGs have reached their maximum size"
+		return plan, nil
+	}
+	plan.Size = totalSize + placed
+
+	var names []string
+	for i, mig := range migs {
+		if added[i] > 0 {
+			names = append(names, mig.Name)
+		}
+	}
+	plan.MIG = strings.Join(names, ", ")
+
+	err = checkScaleUpBudget(ctxConn, client, ctx, migs, sizes, added)
+	if errors.Is(err, cost.ErrBudgetExceeded) {
+		plan.Reason = err.Error()
+		return plan, nil
+	}
+	return plan, err
+}
+
+// PlanScaleDown computes which instance would be removed if the down condition is met, like RemoveNodeFromMIG
+// but only reading from GCP. The instance is selected randomly, so it can differ from the one removed later
+func PlanScaleDown(ctx *v1alpha1.Context) (Plan, error) {
+	ctxConn := ctx.ConnContext()
+
+	client, err := newMIGClient(ctxConn, ctx)
+	if err != nil {
+		return Plan{}, err
+	}
+	defer client.Close()
+
+	migs := getMIGs(ctx)
+	sizes, totalSize, err := getMIGTargetSizes(ctxConn, client, ctx, migs)
+	if err != nil {
+		return Plan{}, fmt.Errorf("failed to get MIG target size: %v", err)
+	}
+
+	limits := getMIGScalingLimits(ctx)
+	scaleDownThreshold := limits.ScaleDownThreshold
+	desiredSize, allowed := decision.ScaleDownSize(totalSize, limits)
+	plan := Plan{PreviousSize: totalSize, Size: desiredSize}
+	if !allowed {
+		plan.Reason = fmt.Sprintf("minimum size reached (%d/%d)", totalSize, limits.MinSize)
+		return plan, nil
+	}
+
+	selected, instanceURL, reason, err := selectInstanceForScaleDown(ctxConn, client, ctx, migs, sizes, scaleDownThreshold)
+	if err != nil {
+		return plan, err
+	}
+	if selected == -1 {
+		plan.Reason = reason
+		return plan, nil
+	}
+	plan.MIG = migs[selected].Name
+	plan.Instance = getInstanceNameFromURL(instanceURL)
+	return plan, nil
+}
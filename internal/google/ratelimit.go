@@ -0,0 +1,15 @@
+package google
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/ratelimit"
+)
+
+// limiter limits the calls to the Compute API made by every autoscaler in the process, so they stay under
+// the quotas of the project. Calls are not limited until it is configured
+var limiter *ratelimit.Limiter
+
+// SetupRateLimit configures the rate limit of the calls to the Compute API from the root of the config
+func SetupRateLimit(config *v1alpha1.ConfigSpec) {
+	limiter = ratelimit.New(config.GCPRateLimit.RequestsPerSecond, config.GCPRateLimit.Burst)
+}
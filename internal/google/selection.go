@@ -0,0 +1,300 @@
+package google
+
+import (
+	"cmp"
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/gke"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// MIGSelectionPolicyWeighted keeps the size of every MIG proportional to its weight
+	MIGSelectionPolicyWeighted = "weighted"
+
+	// MIGSelectionPolicyRoundRobin rotates scaling decisions across the MIGs
+	MIGSelectionPolicyRoundRobin = "round-robin"
+
+	// defaultRegionalMinPerZone keeps at least one instance per zone in regional MIGs
+	defaultRegionalMinPerZone = 1
+)
+
+// getMIGs returns the list of MIGs managed by the autoscaler.
+// When no list is configured, the single MIG defined by migName and zone (or region) is returned.
+func getMIGs(ctx *v1alpha1.Context) []v1alpha1.MIGSpec {
+	gcp := ctx.Config.Infrastructure.GCP
+
+	configuredMIGs := gcp.MIGs
+	switch {
+	case gke.Enabled(gcp):
+		configuredMIGs = gke.CachedMIGs(gcp)
+	case len(configuredMIGs) == 0:
+		configuredMIGs = []v1alpha1.MIGSpec{{Name: gcp.MIGName}}
+	}
+
+	// Fill the missing values with the global ones
+	migs := make([]v1alpha1.MIGSpec, 0, len(configuredMIGs))
+	for _, mig := range configuredMIGs {
+		if mig.Zone == "" && mig.Region == "" {
+			mig.Zone = gcp.Zone
+			mig.Region = gcp.Region
+		}
+		if mig.Weight <= 0 {
+			mig.Weight = 1
+		}
+		if mig.MinPerZone == 0 {
+			mig.MinPerZone = gcp.MinPerZone
+		}
+		if mig.MinPerZone == 0 && isRegional(mig) {
+			mig.MinPerZone = defaultRegionalMinPerZone
+		}
+		migs = append(migs, mig)
+	}
+	return migs
+}
+
+// selectMIGForScaleUp returns the index of the MIG that should receive the new nodes,
+// or -1 when none of them can grow by the given step without exceeding its maximum size.
+func selectMIGForScaleUp(ctx *v1alpha1.Context, migs []v1alpha1.MIGSpec, sizes []int32, step int32) int {
+	canGrow := func(i int) bool {
+		return migs[i].MaxSize == 0 || sizes[i]+step <= int32(migs[i].MaxSize)
+	}
+
+	if ctx.Config.Infrastructure.GCP.MIGSelectionPolicy == MIGSelectionPolicyRoundRobin {
+		return selectMIGRoundRobin(ctx, len(migs), canGrow)
+	}
+
+	// Choose the MIG that stays the least loaded, relative to its weight, after growing
+	selected := -1
+	for i := range migs {
+		if !canGrow(i) {
+			continue
+		}
+		if selected == -1 || float64(sizes[i]+step)/float64(migs[i].Weight) < float64(sizes[selected]+step)/float64(migs[selected].Weight) {
+			selected = i
+		}
+	}
+	return selected
+}
+
+// distributeScaleUp returns how many of the new nodes every MIG receives. They are added to a single MIG, chosen
+// by the selection policy, when it fits all of them, and spread over the MIGs one by one otherwise, so a step bigger
+// than the room left in every MIG still fits when the MIGs have room as a whole.
+// It also returns the total of nodes placed, lower than the step when the MIGs are full
+func distributeScaleUp(ctx *v1alpha1.Context, migs []v1alpha1.MIGSpec, sizes []int32, step int32) ([]int32, int32) {
+	added := make([]int32, len(migs))
+	if step <= 0 {
+		return added, 0
+	}
+
+	selected := selectMIGForScaleUp(ctx, migs, sizes, step)
+	if selected != -1 {
+		added[selected] = step
+		return added, step
+	}
+
+	desiredSizes := slices.Clone(sizes)
+	placed := int32(0)
+	for ; placed < step; placed++ {
+		selected = selectMIGForScaleUp(ctx, migs, desiredSizes, 1)
+		if selected == -1 {
+			break
+		}
+		desiredSizes[selected]++
+		added[selected]++
+	}
+	return added, placed
+}
+
+// selectFallbackMIG returns the index of the MIG, not excluded, that stays the least loaded relative to its weight after
+// growing by count nodes more than the ones added to it, or -1 when none of them has room for them
+func selectFallbackMIG(migs []v1alpha1.MIGSpec, sizes []int32, added []int32, excluded []bool, count int32) int {
+	selected := -1
+	load := func(i int) float64 {
+		return float64(sizes[i]+added[i]+count) / float64(max(migs[i].Weight, 1))
+	}
+	for i := range migs {
+		if excluded[i] || (migs[i].MaxSize != 0 && sizes[i]+added[i]+count > int32(migs[i].MaxSize)) {
+			continue
+		}
+		if selected == -1 || load(i) < load(selected) {
+			selected = i
+		}
+	}
+	return selected
+}
+
+// scaleUpHeadroom returns how many nodes can still be added to the MIGs, within the maximum size of the limits
+// and the maximum size of every MIG. MIGs without maximum size are only bounded by the limits
+func scaleUpHeadroom(migs []v1alpha1.MIGSpec, sizes []int32, maxSize int32) int32 {
+	totalSize, room := int32(0), int32(0)
+	bounded := true
+	for i, mig := range migs {
+		totalSize += sizes[i]
+		if mig.MaxSize == 0 {
+			bounded = false
+			continue
+		}
+		room += max(int32(mig.MaxSize)-sizes[i], 0)
+	}
+
+	headroom := max(maxSize-totalSize, 0)
+	if bounded {
+		headroom = min(headroom, room)
+	}
+	return headroom
+}
+
+// inFullestZones returns the candidate instances living in the zones with the most instances of the MIG, so removing
+// any of them keeps the instances spread across the zones
+func inFullestZones(candidates []string, instancesPerZone map[string]int) []string {
+	most := 0
+	for _, candidate := range candidates {
+		most = max(most, instancesPerZone[getZoneFromURL(candidate)])
+	}
+
+	var fullest []string
+	for _, candidate := range candidates {
+		if instancesPerZone[getZoneFromURL(candidate)] == most {
+			fullest = append(fullest, candidate)
+		}
+	}
+	return fullest
+}
+
+// splitIntoWaves splits the new nodes of every MIG in waves adding up to surge nodes, spread over the MIGs one by one.
+// A single wave is returned without surge
+func splitIntoWaves(added []int32, surge int32) [][]int32 {
+	if surge <= 0 {
+		return [][]int32{added}
+	}
+
+	remaining := slices.Clone(added)
+	var waves [][]int32
+	for sumSizes(remaining) > 0 {
+		wave := make([]int32, len(added))
+		for size := int32(0); size < surge && sumSizes(remaining) > 0; {
+			for i := range remaining {
+				if remaining[i] > 0 && size < surge {
+					wave[i]++
+					remaining[i]--
+					size++
+				}
+			}
+		}
+		waves = append(waves, wave)
+	}
+	return waves
+}
+
+// sumSizes returns the sum of the sizes, or of the nodes added to every MIG
+func sumSizes(sizes []int32) int32 {
+	total := int32(0)
+	for _, size := range sizes {
+		total += size
+	}
+	return total
+}
+
+// largestShare returns the index of the MIG receiving the most new nodes, named in the decision of the scale up
+func largestShare(added []int32) int {
+	selected := 0
+	for i := range added {
+		if added[i] > added[selected] {
+			selected = i
+		}
+	}
+	return selected
+}
+
+// scaleDownCandidates returns the indexes of the MIGs that can lose step nodes without going below their minimum size,
+// in the order of the selection policy: from the stored round-robin index, or the most loaded first relative to
+// their weight
+func scaleDownCandidates(ctx *v1alpha1.Context, migs []v1alpha1.MIGSpec, sizes []int32, step int32) []int {
+	var candidates []int
+	for i := range migs {
+		if sizes[i] > 0 && sizes[i]-step >= int32(migs[i].MinSize) {
+			candidates = append(candidates, i)
+		}
+	}
+
+	if ctx.Config.Infrastructure.GCP.MIGSelectionPolicy == MIGSelectionPolicyRoundRobin {
+		ctx.Mutex.Lock()
+		start := ctx.MIGRoundRobinIndex
+		ctx.Mutex.Unlock()
+		slices.SortStableFunc(candidates, func(a, b int) int {
+			return (a-start+len(migs))%len(migs) - (b-start+len(migs))%len(migs)
+		})
+		return candidates
+	}
+
+	load := func(i int) float64 {
+		return float64(sizes[i]) / float64(migs[i].Weight)
+	}
+	slices.SortStableFunc(candidates, func(a, b int) int {
+		return cmp.Compare(load(b), load(a))
+	})
+	return candidates
+}
+
+// selectInstanceForScaleDown returns the index of the MIG that should lose a node and the URL of the instance to
+// remove from it. The candidate MIGs are tried in the order of the selection policy until one has an instance that can
+// be removed, as the minimum size per zone or the deletion protection may prevent removing any instance of some of
+// them. It returns -1 and why no instance can be removed when none of the MIGs has any
+func selectInstanceForScaleDown(ctxConn context.Context, client *migClient, ctx *v1alpha1.Context, migs []v1alpha1.MIGSpec,
+	sizes []int32, step int32) (int, string, string, error) {
+	candidates := scaleDownCandidates(ctx, migs, sizes, step)
+	if len(candidates) == 0 {
+		return -1, "", "all the MIGs have reached their minimum size", nil
+	}
+
+	var reasons []string
+	for _, selected := range candidates {
+		mig := migs[selected]
+		instanceURL, err := GetInstanceToRemove(ctxConn, client, ctx, mig)
+		if errors.Is(err, ErrDeletionProtected) {
+			reasons = append(reasons, fmt.Sprintf("%v in MIG %s", err, mig.Name))
+			continue
+		}
+		if err != nil {
+			return -1, "", "", fmt.Errorf("error getting instance to remove: %v", err)
+		}
+		if instanceURL == "" {
+			reasons = append(reasons, fmt.Sprintf("every zone of MIG %s has reached its minimum size per zone (%d)", mig.Name, mig.MinPerZone))
+			continue
+		}
+
+		if ctx.Config.Infrastructure.GCP.MIGSelectionPolicy == MIGSelectionPolicyRoundRobin {
+			advanceRoundRobin(ctx, selected, len(migs))
+		}
+		return selected, instanceURL, "", nil
+	}
+	return -1, "", strings.Join(reasons, "; "), nil
+}
+
+// selectMIGRoundRobin returns the next MIG, starting from the stored round-robin index, accepted by the filter.
+// The index is moved forward so the following decision starts from the next MIG.
+func selectMIGRoundRobin(ctx *v1alpha1.Context, count int, accept func(int) bool) int {
+	ctx.Mutex.Lock()
+	start := ctx.MIGRoundRobinIndex
+	ctx.Mutex.Unlock()
+
+	for offset := 0; offset < count; offset++ {
+		i := (start + offset) % count
+		if accept(i) {
+			advanceRoundRobin(ctx, i, count)
+			return i
+		}
+	}
+	return -1
+}
+
+// advanceRoundRobin moves the stored round-robin index past the MIG selected
+func advanceRoundRobin(ctx *v1alpha1.Context, selected int, count int) {
+	ctx.Mutex.Lock()
+	defer ctx.Mutex.Unlock()
+	ctx.MIGRoundRobinIndex = (selected + 1) % count
+}
@@ -0,0 +1,284 @@
+package google
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"slices"
+	"testing"
+)
+
+func TestDistributeScaleUp(t *testing.T) {
+	migs := []v1alpha1.MIGSpec{
+		{Name: "mig-a", MaxSize: 4, Weight: 1},
+		{Name: "mig-b", MaxSize: 4, Weight: 1},
+		{Name: "mig-c", MaxSize: 4, Weight: 1},
+	}
+
+	tests := []struct {
+		name       string
+		policy     string
+		sizes      []int32
+		step       int32
+		wantAdded  []int32
+		wantPlaced int32
+	}{
+		{
+			name:       "single MIG fits the step",
+			sizes:      []int32{1, 2, 3},
+			step:       3,
+			wantAdded:  []int32{3, 0, 0},
+			wantPlaced: 3,
+		},
+		{
+			name:       "step bigger than the room of every MIG is split",
+			sizes:      []int32{2, 2, 3},
+			step:       4,
+			wantAdded:  []int32{2, 2, 0},
+			wantPlaced: 4,
+		},
+		{
+			name:       "step split with round-robin",
+			policy:     MIGSelectionPolicyRoundRobin,
+			sizes:      []int32{3, 3, 3},
+			step:       3,
+			wantAdded:  []int32{1, 1, 1},
+			wantPlaced: 3,
+		},
+		{
+			name:       "step reduced to the room left",
+			sizes:      []int32{4, 3, 4},
+			step:       2,
+			wantAdded:  []int32{0, 1, 0},
+			wantPlaced: 1,
+		},
+		{
+			name:       "every MIG full",
+			sizes:      []int32{4, 4, 4},
+			step:       1,
+			wantAdded:  []int32{0, 0, 0},
+			wantPlaced: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := &v1alpha1.Context{Config: &v1alpha1.ConfigSpec{}}
+			ctx.Config.Infrastructure.GCP.MIGSelectionPolicy = test.policy
+
+			added, placed := distributeScaleUp(ctx, migs, test.sizes, test.step)
+			if placed != test.wantPlaced || !slices.Equal(added, test.wantAdded) {
+				t.Errorf("distributeScaleUp() = %v, %d, want %v, %d", added, placed, test.wantAdded, test.wantPlaced)
+			}
+		})
+	}
+}
+
+func TestScaleUpHeadroom(t *testing.T) {
+	tests := []struct {
+		name    string
+		migs    []v1alpha1.MIGSpec
+		sizes   []int32
+		maxSize int32
+		want    int32
+	}{
+		{
+			name:    "bounded by the limits",
+			migs:    []v1alpha1.MIGSpec{{Name: "mig-a", MaxSize: 10}, {Name: "mig-b", MaxSize: 10}},
+			sizes:   []int32{2, 3},
+			maxSize: 8,
+			want:    3,
+		},
+		{
+			name:    "bounded by the maximum size of the MIGs",
+			migs:    []v1alpha1.MIGSpec{{Name: "mig-a", MaxSize: 3}, {Name: "mig-b", MaxSize: 4}},
+			sizes:   []int32{2, 3},
+			maxSize: 20,
+			want:    2,
+		},
+		{
+			name:    "MIG without maximum size",
+			migs:    []v1alpha1.MIGSpec{{Name: "mig-a", MaxSize: 3}, {Name: "mig-b"}},
+			sizes:   []int32{3, 3},
+			maxSize: 10,
+			want:    4,
+		},
+		{
+			name:    "already over the maximum size of the limits",
+			migs:    []v1alpha1.MIGSpec{{Name: "mig-a"}},
+			sizes:   []int32{12},
+			maxSize: 10,
+			want:    0,
+		},
+		{
+			name:    "every MIG full",
+			migs:    []v1alpha1.MIGSpec{{Name: "mig-a", MaxSize: 3}, {Name: "mig-b", MaxSize: 3}},
+			sizes:   []int32{3, 3},
+			maxSize: 10,
+			want:    0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := scaleUpHeadroom(test.migs, test.sizes, test.maxSize); got != test.want {
+				t.Errorf("scaleUpHeadroom() = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestInFullestZones(t *testing.T) {
+	url := func(zone string, name string) string {
+		return "https://www.googleapis.com/compute/v1/projects/p/zones/" + zone + "/instances/" + name
+	}
+	candidates := []string{url("zone-a", "a-1"), url("zone-a", "a-2"), url("zone-b", "b-1"), url("zone-b", "b-2"), url("zone-c", "c-1")}
+
+	tests := []struct {
+		name             string
+		instancesPerZone map[string]int
+		want             []string
+	}{
+		{
+			name:             "single fullest zone",
+			instancesPerZone: map[string]int{"zone-a": 2, "zone-b": 3, "zone-c": 1},
+			want:             []string{url("zone-b", "b-1"), url("zone-b", "b-2")},
+		},
+		{
+			name:             "evenly spread zones",
+			instancesPerZone: map[string]int{"zone-a": 2, "zone-b": 2, "zone-c": 2},
+			want:             candidates,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := inFullestZones(candidates, test.instancesPerZone); !slices.Equal(got, test.want) {
+				t.Errorf("inFullestZones() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSplitIntoWaves(t *testing.T) {
+	tests := []struct {
+		name  string
+		added []int32
+		surge int32
+		want  [][]int32
+	}{
+		{
+			name:  "all at once",
+			added: []int32{3, 0},
+			surge: 3,
+			want:  [][]int32{{3, 0}},
+		},
+		{
+			name:  "waves of a single MIG",
+			added: []int32{5},
+			surge: 2,
+			want:  [][]int32{{2}, {2}, {1}},
+		},
+		{
+			name:  "waves spread over the MIGs",
+			added: []int32{3, 1, 2},
+			surge: 2,
+			want:  [][]int32{{1, 1, 0}, {1, 0, 1}, {1, 0, 1}},
+		},
+		{
+			name:  "nothing added",
+			added: []int32{0, 0},
+			surge: 2,
+			want:  nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := splitIntoWaves(test.added, test.surge)
+			if !slices.EqualFunc(got, test.want, slices.Equal[[]int32]) {
+				t.Errorf("splitIntoWaves() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSelectFallbackMIG(t *testing.T) {
+	migs := []v1alpha1.MIGSpec{
+		{Name: "mig-a", MaxSize: 4, Weight: 1},
+		{Name: "mig-b", MaxSize: 4, Weight: 1},
+		{Name: "mig-c", MaxSize: 6, Weight: 2},
+	}
+
+	tests := []struct {
+		name     string
+		sizes    []int32
+		added    []int32
+		excluded []bool
+		count    int32
+		want     int
+	}{
+		{
+			name:     "least loaded relative to the weight",
+			sizes:    []int32{2, 1, 2},
+			added:    []int32{0, 0, 0},
+			excluded: []bool{true, false, false},
+			count:    2,
+			want:     2,
+		},
+		{
+			name:     "nodes already added are counted",
+			sizes:    []int32{2, 1, 2},
+			added:    []int32{0, 0, 3},
+			excluded: []bool{true, false, false},
+			count:    2,
+			want:     1,
+		},
+		{
+			name:     "no room left",
+			sizes:    []int32{2, 3, 5},
+			added:    []int32{0, 0, 0},
+			excluded: []bool{true, false, false},
+			count:    2,
+			want:     -1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := selectFallbackMIG(migs, test.sizes, test.added, test.excluded, test.count); got != test.want {
+				t.Errorf("selectFallbackMIG() = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestScaleDownCandidates(t *testing.T) {
+	migs := []v1alpha1.MIGSpec{
+		{Name: "mig-a", MinSize: 1, Weight: 1},
+		{Name: "mig-b", MinSize: 1, Weight: 2},
+		{Name: "mig-c", MinSize: 1, Weight: 1},
+		{Name: "mig-d", MinSize: 3, Weight: 1},
+	}
+	sizes := []int32{2, 6, 4, 3}
+
+	tests := []struct {
+		name            string
+		policy          string
+		roundRobinIndex int
+		want            []int
+	}{
+		{name: "most loaded first", want: []int{2, 1, 0}},
+		{name: "round-robin from the stored index", policy: MIGSelectionPolicyRoundRobin, roundRobinIndex: 2, want: []int{2, 0, 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := &v1alpha1.Context{Config: &v1alpha1.ConfigSpec{}, MIGRoundRobinIndex: test.roundRobinIndex}
+			ctx.Config.Infrastructure.GCP.MIGSelectionPolicy = test.policy
+
+			got := scaleDownCandidates(ctx, migs, sizes, 1)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("scaleDownCandidates() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
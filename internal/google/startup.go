@@ -0,0 +1,180 @@
+package google
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/retry"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+const (
+	// ProbeTypeHTTP requests the path of the probe, expecting a 2xx or 3xx response
+	ProbeTypeHTTP = "http"
+
+	// ProbeTypeTCP opens a connection to the port of the probe
+	ProbeTypeTCP = "tcp"
+
+	// instanceStatusRunning is the status of the instances once booted
+	instanceStatusRunning = "RUNNING"
+)
+
+// ErrStartupTimeout is returned when the new instances do not become ready in the timeout of the startup probe
+var ErrStartupTimeout = errors.New("new instances not ready in the startup probe timeout")
+
+// waitForStartup waits until count instances not in existing are running in the MIG and pass the startup probe,
+// polling them every period of the probe. It returns ErrStartupTimeout with the pending instances when the timeout expires,
+// wrapping the last OperationError of the MIG creating them, if any
+func waitForStartup(ctxConn context.Context, client *migClient, ctx *v1alpha1.Context, mig v1alpha1.MIGSpec, existing []string, count int32) error {
+	probe := ctx.Config.Autoscaler.StartupProbe
+	deadline := time.Now().Add(time.Duration(probe.TimeoutSec) * time.Second)
+	addresses := map[string]string{}
+	var creationError *OperationError
+
+	for {
+		pending, failure, err := pendingInstances(ctxConn, client, ctx, mig, existing, count, addresses)
+		if err == nil && len(pending) == 0 {
+			log.Printf("New instances of MIG %s are ready", mig.Name)
+			return nil
+		}
+		if err != nil {
+			pending = []string{err.Error()}
+		}
+		if failure != nil {
+			creationError = failure
+		}
+
+		if time.Now().After(deadline) {
+			if creationError != nil {
+				return fmt.Errorf("%w: %s: %w", ErrStartupTimeout, strings.Join(pending, ", "), creationError)
+			}
+			return fmt.Errorf("%w: %s", ErrStartupTimeout, strings.Join(pending, ", "))
+		}
+		log.Printf("Waiting for the new instances of MIG %s to be ready: %s", mig.Name, strings.Join(pending, ", "))
+		err = retry.Sleep(ctxConn, time.Duration(probe.PeriodSec)*time.Second)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// pendingInstances returns why the new instances of the MIG are not ready yet, or nothing once count of them are.
+// The internal IP of the running instances is cached in addresses. The failed creation of any of them is returned
+// when its cause is known
+func pendingInstances(ctxConn context.Context, client *migClient, ctx *v1alpha1.Context, mig v1alpha1.MIGSpec, existing []string, count int32,
+	addresses map[string]string) ([]string, *OperationError, error) {
+	instances, err := client.listManagedInstances(ctxConn, ctx, mig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list managed instances: %v", err)
+	}
+
+	var pending []string
+	var failure *OperationError
+	created := int32(0)
+	for _, instance := range instances {
+		if instance.GetInstance() == "" || slices.Contains(existing, instance.GetInstance()) {
+			continue
+		}
+		created++
+		name := getInstanceNameFromURL(instance.GetInstance())
+
+		if creationError := getCreationError(instance); creationError != nil {
+			failure = creationError
+			pending = append(pending, creationError.Error())
+			continue
+		}
+		if instance.GetInstanceStatus() != instanceStatusRunning {
+			pending = append(pending, fmt.Sprintf("%s is %s", name, strings.ToLower(instance.GetInstanceStatus())))
+			continue
+		}
+		if ctx.Config.Autoscaler.StartupProbe.Type == "" {
+			continue
+		}
+
+		if addresses[name] == "" {
+			addresses[name], err = client.getInternalIP(ctxConn, ctx, instance.GetInstance())
+			if err != nil {
+				pending = append(pending, fmt.Sprintf("%s has no address: %v", name, err))
+				continue
+			}
+		}
+		err = probeInstance(ctxConn, ctx.Config.Autoscaler.StartupProbe, addresses[name])
+		if err != nil {
+			pending = append(pending, fmt.Sprintf("%s failed the probe: %v", name, err))
+		}
+	}
+	if created < count {
+		pending = append(pending, fmt.Sprintf("%d of %d instances created", created, count))
+	}
+
+	return pending, failure, nil
+}
+
+// getInternalIP returns the internal IP of the first network interface of the instance
+func (c *migClient) getInternalIP(ctxConn context.Context, ctx *v1alpha1.Context, instanceURL string) (string, error) {
+	var instance *computepb.Instance
+	err := retryCall(ctxConn, ctx, "GCP instance get", func(ctxCall context.Context) (err error) {
+		instance, err = c.instances.Get(ctxCall, &computepb.GetInstanceRequest{
+			Project:  ctx.Config.Infrastructure.GCP.ProjectID,
+			Zone:     getZoneFromURL(instanceURL),
+			Instance: getInstanceNameFromURL(instanceURL),
+		})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	interfaces := instance.GetNetworkInterfaces()
+	if len(interfaces) == 0 || interfaces[0].GetNetworkIP() == "" {
+		return "", fmt.Errorf("no internal IP assigned")
+	}
+	return interfaces[0].GetNetworkIP(), nil
+}
+
+// probeInstance checks the port of the instance with the startup probe, bounded by the period of the probe
+func probeInstance(ctxConn context.Context, probe v1alpha1.StartupProbeSpec, address string) error {
+	timeout := time.Duration(probe.PeriodSec) * time.Second
+	host := net.JoinHostPort(address, strconv.Itoa(probe.Port))
+
+	if probe.Type == ProbeTypeTCP {
+		dialer := net.Dialer{Timeout: timeout}
+		conn, err := dialer.DialContext(ctxConn, "tcp", host)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: probe.InsecureSkipVerify},
+		},
+	}
+	req, err := http.NewRequestWithContext(ctxConn, http.MethodGet, fmt.Sprintf("%s://%s%s", probe.Scheme, host, probe.Path), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
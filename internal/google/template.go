@@ -0,0 +1,132 @@
+package google
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"fmt"
+	"sort"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// TemplateDrift is a MIG using an instance template different from the configured one, or having instances
+// created from another template
+type TemplateDrift struct {
+	MIG string
+
+	// Template is the URL of the instance template of the MIG
+	Template string
+
+	// Outdated are the names of the instances of the MIG created from another template, sorted
+	Outdated []string
+}
+
+// Matches returns true when the MIG already uses the configured instance template
+func (d TemplateDrift) Matches(name string) bool {
+	return matchesTemplate(d.Template, name)
+}
+
+// matchesTemplate returns true when the template URL references the configured template: the same URL,
+// or a global template with the configured name
+func matchesTemplate(templateURL string, name string) bool {
+	if strings.Contains(name, "/") {
+		return strings.HasSuffix(templateURL, strings.TrimPrefix(name, "https://www.googleapis.com/compute/v1/"))
+	}
+	return strings.HasSuffix(templateURL, "/global/instanceTemplates/"+name)
+}
+
+// templateURL returns the URL of the configured instance template, set in the MIGs
+func templateURL(ctx *v1alpha1.Context, name string) string {
+	if strings.Contains(name, "/") {
+		return name
+	}
+	return fmt.Sprintf("projects/%s/global/instanceTemplates/%s", ctx.Config.Infrastructure.GCP.ProjectID, name)
+}
+
+// GetTemplateDrifts returns the MIGs whose instance template, or the one of any of their instances, is not the
+// configured one, sorted by name
+func GetTemplateDrifts(ctx *v1alpha1.Context) ([]TemplateDrift, error) {
+	ctxConn := ctx.ConnContext()
+
+	client, err := newMIGClient(ctxConn, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	name := ctx.Config.Infrastructure.GCP.InstanceTemplate.Name
+	var drifts []TemplateDrift
+	for _, mig := range getMIGs(ctx) {
+		instanceGroupManager, err := client.get(ctxConn, ctx, mig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get MIG %s: %v", mig.Name, err)
+		}
+		instances, err := client.listManagedInstances(ctxConn, ctx, mig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list managed instances of MIG %s: %v", mig.Name, err)
+		}
+
+		drift := TemplateDrift{MIG: mig.Name, Template: instanceGroupManager.GetInstanceTemplate()}
+		for _, instance := range instances {
+			if !matchesTemplate(instance.GetVersion().GetInstanceTemplate(), name) {
+				drift.Outdated = append(drift.Outdated, getInstanceNameFromURL(instance.GetInstance()))
+			}
+		}
+		if drift.Matches(name) && len(drift.Outdated) == 0 {
+			continue
+		}
+		sort.Strings(drift.Outdated)
+		drifts = append(drifts, drift)
+	}
+
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].MIG < drifts[j].MIG })
+	return drifts, nil
+}
+
+// SetMIGInstanceTemplate sets the configured instance template in the MIG. Only its new instances, and the ones
+// recreated, are created from it
+func SetMIGInstanceTemplate(ctx *v1alpha1.Context, migName string) error {
+	ctxConn := ctx.ConnContext()
+
+	client, err := newMIGClient(ctxConn, ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	for _, mig := range getMIGs(ctx) {
+		if mig.Name == migName {
+			return client.setInstanceTemplate(ctxConn, ctx, mig, templateURL(ctx, ctx.Config.Infrastructure.GCP.InstanceTemplate.Name))
+		}
+	}
+	return fmt.Errorf("MIG %s not found in the config", migName)
+}
+
+// setInstanceTemplate sets the instance template of the MIG. Setting the same template again is harmless,
+// so it is retried when it fails
+func (c *migClient) setInstanceTemplate(ctxConn context.Context, ctx *v1alpha1.Context, mig v1alpha1.MIGSpec, templateURL string) error {
+	return retryCall(ctxConn, ctx, "GCP MIG set instance template", func(ctxCall context.Context) (err error) {
+		if isRegional(mig) {
+			_, err = c.regional.SetInstanceTemplate(ctxCall, &computepb.SetInstanceTemplateRegionInstanceGroupManagerRequest{
+				Project:              ctx.Config.Infrastructure.GCP.ProjectID,
+				Region:               mig.Region,
+				InstanceGroupManager: mig.Name,
+				RegionInstanceGroupManagersSetTemplateRequestResource: &computepb.RegionInstanceGroupManagersSetTemplateRequest{
+					InstanceTemplate: &templateURL,
+				},
+			})
+			return err
+		}
+
+		_, err = c.zonal.SetInstanceTemplate(ctxCall, &computepb.SetInstanceTemplateInstanceGroupManagerRequest{
+			Project:              ctx.Config.Infrastructure.GCP.ProjectID,
+			Zone:                 mig.Zone,
+			InstanceGroupManager: mig.Name,
+			InstanceGroupManagersSetInstanceTemplateRequestResource: &computepb.InstanceGroupManagersSetInstanceTemplateRequest{
+				InstanceTemplate: &templateURL,
+			},
+		})
+		return err
+	})
+}
@@ -0,0 +1,108 @@
+package health
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/google"
+	"custom-vm-autoscaler/internal/metrics"
+	"custom-vm-autoscaler/internal/prometheus"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// readinessCacheTTL is the time the result of the readiness checks is reused,
+// so frequent probes do not hammer Prometheus and the GCP API
+const readinessCacheTTL = 30 * time.Second
+
+// Server exposes the liveness and readiness endpoints used by the health checks of the platform, and the metrics
+type Server struct {
+	address     string
+	autoscalers []*v1alpha1.Context
+
+	mutex     sync.Mutex
+	checkedAt time.Time
+	failures  map[string]string
+}
+
+// NewServer creates the health endpoints for the autoscalers, configured from the root of the config
+func NewServer(config *v1alpha1.ConfigSpec, autoscalers []*v1alpha1.Context) *Server {
+	return &Server{
+		address:     config.Health.Address,
+		autoscalers: autoscalers,
+	}
+}
+
+// Run serves the health endpoints until it fails
+func (s *Server) Run() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.HandleFunc("GET /readyz", s.handleReadyz)
+	mux.Handle("GET /metrics", metrics.Handler(s.autoscalers))
+
+	log.Printf("Starting health endpoints on %s", s.address)
+	server := &http.Server{
+		Addr:              s.address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServe()
+}
+
+// handleHealthz reports the process is alive
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// handleReadyz reports whether every autoscaler can reach its metrics source and its MIGs.
+// The config is loaded before the server starts, so it is always ready at this point
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	failures := s.checkReadiness()
+
+	statusCode := http.StatusOK
+	if len(failures) > 0 {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(map[string]interface{}{"ready": len(failures) == 0, "failures": failures})
+	if err != nil {
+		log.Printf("Error encoding readiness response: %v", err)
+	}
+}
+
+// checkReadiness executes the readiness checks, or returns the cached result when it is recent enough
+func (s *Server) checkReadiness() map[string]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if time.Since(s.checkedAt) < readinessCacheTTL {
+		return s.failures
+	}
+
+	failures := map[string]string{}
+	for _, ctx := range s.autoscalers {
+		err := prometheus.CheckPrometheus(ctx)
+		if err != nil {
+			failures[fmt.Sprintf("%s/prometheus", ctx.Config.Name)] = err.Error()
+		}
+
+		// Reading the MIGs validates the credentials and permissions of the provider
+		_, _, _, _, err = google.GetMIGSizes(ctx)
+		if err != nil {
+			failures[fmt.Sprintf("%s/gcp", ctx.Config.Name)] = err.Error()
+		}
+	}
+
+	for check, failure := range failures {
+		log.Printf("Readiness check %s failed: %s", check, failure)
+	}
+
+	s.checkedAt = time.Now()
+	s.failures = failures
+	return failures
+}
@@ -0,0 +1,57 @@
+// Package heartbeat pings the URL of a dead man's switch, like Healthchecks.io or Better Uptime, after every
+// evaluation, so an external system alerts when the autoscaler silently stops evaluating
+package heartbeat
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/proxy"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Ping reports the result of the evaluation: the heartbeat URL is requested when it succeeded, and the failure URL,
+// if any, when it failed. Errors are logged, as the autoscaler must keep working
+func Ping(ctx *v1alpha1.Context, evaluationErr error) {
+	spec := ctx.Config.Heartbeat
+	url := spec.URL
+	if evaluationErr != nil {
+		url = spec.FailURL
+	}
+	if url == "" {
+		return
+	}
+
+	err := ping(ctx.ConnContext(), spec, url)
+	if err != nil {
+		log.Printf("Error pinging the heartbeat of autoscaler %s: %v", ctx.Config.Name, err)
+	}
+}
+
+// ping requests the URL, bounded by the timeout of the heartbeat
+func ping(ctxConn context.Context, spec v1alpha1.HeartbeatSpec, url string) error {
+	transport, err := proxy.Transport(spec.Proxy)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Transport: transport, Timeout: time.Duration(spec.TimeoutSec) * time.Second}
+
+	req, err := http.NewRequestWithContext(ctxConn, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to request %s: %w", url, err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s from %s", res.Status, url)
+	}
+	return nil
+}
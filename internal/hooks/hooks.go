@@ -0,0 +1,162 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/dryrun"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// Events around which the hooks are executed
+	EventPreScaleDown  = "preScaleDown"
+	EventPostScaleDown = "postScaleDown"
+	EventPostScaleUp   = "postScaleUp"
+
+	defaultTimeoutSec = 60
+)
+
+// Data holds the variables available in the hooks templates, and sent as payload to webhooks.
+type Data struct {
+	Event        string `json:"event"`
+	MIG          string `json:"mig"`
+	Zone         string `json:"zone"`
+	InstanceName string `json:"instanceName,omitempty"`
+	Size         int32  `json:"size"`
+}
+
+// RunHooks executes the hooks defined for the event in data, one after the other.
+// It stops at the first failing hook and returns its error.
+func RunHooks(ctx *v1alpha1.Context, data Data) error {
+	var hooks []v1alpha1.HookSpec
+	switch data.Event {
+	case EventPreScaleDown:
+		hooks = ctx.Config.Hooks.PreScaleDown
+	case EventPostScaleDown:
+		hooks = ctx.Config.Hooks.PostScaleDown
+	case EventPostScaleUp:
+		hooks = ctx.Config.Hooks.PostScaleUp
+	}
+
+	for i, hook := range hooks {
+		if ctx.Config.Autoscaler.DryRunInfrastructure {
+			dryrun.Record(ctx, dryrun.ModuleHooks, "run %s hook #%d for MIG %s with size %d", data.Event, i, data.MIG, data.Size)
+			continue
+		}
+
+		err := runHook(ctx.ConnContext(), hook, data)
+		if err != nil {
+			return fmt.Errorf("%s hook #%d failed: %w", data.Event, i, err)
+		}
+		log.Printf("Executed %s hook #%d successfully", data.Event, i)
+	}
+
+	return nil
+}
+
+// runHook executes a single hook: its shell command, its webhook, or both
+func runHook(ctxConn context.Context, hook v1alpha1.HookSpec, data Data) error {
+	timeoutSec := hook.TimeoutSec
+	if timeoutSec == 0 {
+		timeoutSec = defaultTimeoutSec
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctxConn, time.Duration(timeoutSec)*time.Second)
+	defer cancel()
+
+	if hook.Command != "" {
+		command, err := renderTemplate(hook.Command, shellQuoted(data))
+		if err != nil {
+			return err
+		}
+
+		cmd := exec.CommandContext(ctxWithTimeout, "sh", "-c", command)
+		cmd.Env = append(os.Environ(), commandEnv(data)...)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("command failed: %w. Output: %s", err, string(output))
+		}
+	}
+
+	if hook.WebhookURL != "" {
+		webhookURL, err := renderTemplate(hook.WebhookURL, data)
+		if err != nil {
+			return err
+		}
+
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("failed to marshal webhook payload: %w", err)
+		}
+
+		req, err := http.NewRequestWithContext(ctxWithTimeout, http.MethodPost, webhookURL, bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("failed to create webhook request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("webhook request failed: %w", err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode >= 300 {
+			return fmt.Errorf("webhook returned unexpected status: %s", res.Status)
+		}
+	}
+
+	return nil
+}
+
+// shellQuoted returns the data with its strings quoted for the shell, so the names of the MIGs and instances
+// rendered in the commands can not inject other commands
+func shellQuoted(data Data) Data {
+	data.Event = shellQuote(data.Event)
+	data.MIG = shellQuote(data.MIG)
+	data.Zone = shellQuote(data.Zone)
+	data.InstanceName = shellQuote(data.InstanceName)
+	return data
+}
+
+// shellQuote wraps the value in single quotes, escaping the ones it contains
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// commandEnv returns the data as the environment variables of the commands, an alternative to the templates
+func commandEnv(data Data) []string {
+	return []string{
+		"HOOK_EVENT=" + data.Event,
+		"HOOK_MIG=" + data.MIG,
+		"HOOK_ZONE=" + data.Zone,
+		"HOOK_INSTANCE_NAME=" + data.InstanceName,
+		"HOOK_SIZE=" + strconv.Itoa(int(data.Size)),
+	}
+}
+
+// renderTemplate replaces the template variables (e.g. {{ .InstanceName }}) with the values in data
+func renderTemplate(text string, data Data) (string, error) {
+	tmpl, err := template.New("hook").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse hook template: %w", err)
+	}
+
+	var rendered bytes.Buffer
+	err = tmpl.Execute(&rendered, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to render hook template: %w", err)
+	}
+
+	return rendered.String(), nil
+}
@@ -0,0 +1,33 @@
+package hooks
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunHookQuotesCommandValues(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "output")
+	hook := v1alpha1.HookSpec{Command: "printf '%s|%s' {{ .InstanceName }} \"$HOOK_MIG\" > " + output}
+	data := Data{Event: EventPreScaleDown, MIG: "mig; touch injected", InstanceName: "node-1'; touch injected; echo '"}
+
+	err := runHook(context.Background(), hook, data)
+	if err != nil {
+		t.Fatalf("runHook() error = %v", err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("error reading output: %v", err)
+	}
+	want := data.InstanceName + "|" + data.MIG
+	if string(got) != want {
+		t.Errorf("command output = %q, want %q", got, want)
+	}
+	if _, err := os.Stat("injected"); err == nil {
+		os.Remove("injected")
+		t.Errorf("command injected through the template values")
+	}
+}
@@ -0,0 +1,287 @@
+package kubeevents
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	serviceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	// component is the source of the events
+	component = "custom-vm-autoscaler"
+
+	// requestTimeout bounds every request to the Kubernetes API
+	requestTimeout = 10 * time.Second
+
+	// Reasons of the events
+	ReasonScaledUp        = "ScaledUp"
+	ReasonScaledDown      = "ScaledDown"
+	ReasonScaleUpFailed   = "ScaleUpFailed"
+	ReasonScaleDownFailed = "ScaleDownFailed"
+	ReasonDrainTimeout    = "DrainTimeout"
+
+	// Types of the events
+	TypeNormal  = "Normal"
+	TypeWarning = "Warning"
+)
+
+// objectReference is the object of Kubernetes the events are emitted on
+type objectReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	UID        string `json:"uid,omitempty"`
+}
+
+// kubernetesEvent is the subset of the core/v1 Event emitted
+type kubernetesEvent struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		GenerateName string            `json:"generateName"`
+		Namespace    string            `json:"namespace"`
+		Labels       map[string]string `json:"labels,omitempty"`
+	} `json:"metadata"`
+	InvolvedObject objectReference `json:"involvedObject"`
+	Reason         string          `json:"reason"`
+	Message        string          `json:"message"`
+	Type           string          `json:"type"`
+	Source         struct {
+		Component string `json:"component"`
+	} `json:"source"`
+	FirstTimestamp     string `json:"firstTimestamp"`
+	LastTimestamp      string `json:"lastTimestamp"`
+	Count              int    `json:"count"`
+	ReportingComponent string `json:"reportingComponent"`
+	ReportingInstance  string `json:"reportingInstance"`
+}
+
+// recorder emits the events through the Kubernetes API, using the in-cluster configuration
+type recorder struct {
+	client   *http.Client
+	host     string
+	token    string
+	object   objectReference
+	instance string
+}
+
+var (
+	// eventRecorder is nil when the autoscaler is not running inside Kubernetes, or the events are disabled
+	eventRecorder *recorder
+
+	// recorderMutex guards the recorder, shared by every autoscaler in the process
+	recorderMutex sync.Mutex
+)
+
+// Setup prepares the emission of the events when the autoscaler runs inside a Kubernetes cluster, on its own Pod
+// or on the object defined in the config. It does nothing outside Kubernetes or when the events are disabled.
+// When the Pod can not be read, as its service account is not allowed to, the events are disabled with a warning
+func Setup(config *v1alpha1.ConfigSpec) error {
+	recorderMutex.Lock()
+	defer recorderMutex.Unlock()
+
+	eventRecorder = nil
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if config.KubernetesEvents.Disabled || host == "" || port == "" {
+		return nil
+	}
+
+	r, err := newRecorder(config, "https://"+net.JoinHostPort(host, port))
+	if err != nil {
+		if config.KubernetesEvents.Object.Name != "" {
+			return err
+		}
+		log.Printf("Warning: Kubernetes events disabled: %v", err)
+		return nil
+	}
+	eventRecorder = r
+	log.Printf("Emitting Kubernetes events on %s %s/%s", r.object.Kind, r.object.Namespace, r.object.Name)
+	return nil
+}
+
+// newRecorder creates the recorder of the events on the object defined in the config, or on the Pod of the autoscaler,
+// named after its hostname unless POD_NAME is set
+func newRecorder(config *v1alpha1.ConfigSpec, host string) (*recorder, error) {
+	token, err := os.ReadFile(serviceAccountPath + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	caCert, err := os.ReadFile(serviceAccountPath + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA: %w", err)
+	}
+	caCertPool := x509.NewCertPool()
+	caCertPool.AppendCertsFromPEM(caCert)
+
+	r := &recorder{
+		client: &http.Client{
+			Timeout: requestTimeout,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: caCertPool, MinVersion: tls.VersionTLS12},
+			},
+		},
+		host:  host,
+		token: strings.TrimSpace(string(token)),
+	}
+	r.instance, _ = os.Hostname()
+
+	spec := config.KubernetesEvents.Object
+	r.object = objectReference{APIVersion: spec.APIVersion, Kind: spec.Kind, Namespace: spec.Namespace, Name: spec.Name}
+	if spec.Name == "" {
+		r.object = objectReference{APIVersion: "v1", Kind: "Pod", Name: os.Getenv("POD_NAME")}
+		if r.object.Name == "" {
+			r.object.Name = r.instance
+		}
+	}
+	if r.object.Namespace == "" {
+		namespace, err := os.ReadFile(serviceAccountPath + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service account namespace: %w", err)
+		}
+		r.object.Namespace = strings.TrimSpace(string(namespace))
+	}
+
+	// kubectl describe only shows the events referencing the UID of the object
+	r.object.UID, err = r.getUID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %s/%s: %w", r.object.Kind, r.object.Namespace, r.object.Name, err)
+	}
+	return r, nil
+}
+
+// RecordDecision emits the event of the scaling action of the decision. Evaluations without scaling actions are skipped
+func RecordDecision(decision v1alpha1.Decision) {
+	if decision.Action == v1alpha1.DecisionNone {
+		return
+	}
+
+	message := fmt.Sprintf("Autoscaler %s resized MIG %s from %d to %d nodes", decision.Autoscaler, decision.MIG, decision.PreviousSize, decision.Size)
+	if decision.Instance != "" {
+		message += fmt.Sprintf(", removing instance %s", decision.Instance)
+	}
+	eventType, reason := TypeNormal, ReasonScaledUp
+	if decision.Action == v1alpha1.DecisionScaleDown {
+		reason = ReasonScaledDown
+	}
+	if decision.Error != "" {
+		eventType, reason = TypeWarning, ReasonScaleUpFailed
+		if decision.Action == v1alpha1.DecisionScaleDown {
+			reason = ReasonScaleDownFailed
+		}
+		message = fmt.Sprintf("Autoscaler %s failed to %s: %s", decision.Autoscaler, decision.Action, decision.Error)
+	}
+	Emit(decision.Autoscaler, eventType, reason, message)
+}
+
+// Emit records the event of the autoscaler. Errors are logged, as the autoscaler must keep working
+func Emit(autoscaler string, eventType string, reason string, message string) {
+	recorderMutex.Lock()
+	defer recorderMutex.Unlock()
+
+	if eventRecorder == nil {
+		return
+	}
+	err := eventRecorder.emit(autoscaler, eventType, reason, message)
+	if err != nil {
+		log.Printf("Error emitting Kubernetes event %s: %v", reason, err)
+	}
+}
+
+func (r *recorder) emit(autoscaler string, eventType string, reason string, message string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	event := kubernetesEvent{
+		APIVersion:         "v1",
+		Kind:               "Event",
+		InvolvedObject:     r.object,
+		Reason:             reason,
+		Message:            message,
+		Type:               eventType,
+		FirstTimestamp:     now,
+		LastTimestamp:      now,
+		Count:              1,
+		ReportingComponent: component,
+		ReportingInstance:  r.instance,
+	}
+	event.Metadata.GenerateName = r.object.Name + "."
+	event.Metadata.Namespace = r.object.Namespace
+	event.Metadata.Labels = map[string]string{"custom-vm-autoscaler/autoscaler": autoscaler}
+	event.Source.Component = component
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	url := fmt.Sprintf("%s/api/v1/namespaces/%s/events", r.host, r.object.Namespace)
+	status, err := r.do(http.MethodPost, url, data, nil)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusCreated && status != http.StatusOK {
+		return fmt.Errorf("unexpected status creating event: %d", status)
+	}
+	return nil
+}
+
+// getUID returns the UID of the object the events are emitted on. The resource of the kind is its lowercase plural
+func (r *recorder) getUID() (string, error) {
+	prefix := "/apis/" + r.object.APIVersion
+	if !strings.Contains(r.object.APIVersion, "/") {
+		prefix = "/api/" + r.object.APIVersion
+	}
+	url := fmt.Sprintf("%s%s/namespaces/%s/%ss/%s", r.host, prefix, r.object.Namespace, strings.ToLower(r.object.Kind), r.object.Name)
+
+	var object struct {
+		Metadata struct {
+			UID string `json:"uid"`
+		} `json:"metadata"`
+	}
+	status, err := r.do(http.MethodGet, url, nil, &object)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("unexpected status: %d", status)
+	}
+	return object.Metadata.UID, nil
+}
+
+// do executes a request against the Kubernetes API, decoding the response into out when it succeeds
+func (r *recorder) do(method string, url string, data []byte, out any) (int, error) {
+	ctxConn, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctxConn, method, url, bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+r.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute request against Kubernetes API: %w", err)
+	}
+	defer res.Body.Close()
+
+	if out != nil && res.StatusCode == http.StatusOK {
+		err = json.NewDecoder(res.Body).Decode(out)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return res.StatusCode, nil
+}
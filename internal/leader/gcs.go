@@ -0,0 +1,106 @@
+package leader
+
+import (
+	"bytes"
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// gcsLock stores the lease in a GCS object, using its generation to avoid concurrent updates
+type gcsLock struct {
+	service *storage.Service
+	bucket  string
+	object  string
+}
+
+// newGCSLock creates a lock backed by the GCS object defined in the config
+func newGCSLock(config *v1alpha1.ConfigSpec) (*gcsLock, error) {
+	gcs := config.LeaderElection.GCS
+	if gcs.Bucket == "" || gcs.Object == "" {
+		return nil, fmt.Errorf("bucket and object are required for gcs leader election")
+	}
+
+	var opts []option.ClientOption
+	if gcs.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(gcs.CredentialsFile))
+	}
+
+	service, err := storage.NewService(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	return &gcsLock{service: service, bucket: gcs.Bucket, object: gcs.Object}, nil
+}
+
+// tryAcquireOrRenew reads the lease from the object and overwrites it when it is free, expired, or already ours.
+// The write is conditioned to the generation read, so only one replica can win a race.
+func (l *gcsLock) tryAcquireOrRenew(ctxConn context.Context, identity string, leaseDuration time.Duration) (bool, error) {
+	now := time.Now()
+
+	// Get the current lease, if any. Generation 0 makes the write succeed only if the object does not exist
+	var generation int64
+	res, err := l.service.Objects.Get(l.bucket, l.object).Context(ctxConn).Download()
+	if err != nil && !isGoogleAPIError(err, http.StatusNotFound) {
+		return false, fmt.Errorf("failed to get lease object: %w", err)
+	}
+	if err == nil {
+		defer res.Body.Close()
+
+		var current lease
+		err = json.NewDecoder(res.Body).Decode(&current)
+		if err != nil {
+			return false, fmt.Errorf("failed to decode lease object: %w", err)
+		}
+
+		if current.HolderIdentity != identity && !current.expired(now) {
+			return false, nil
+		}
+
+		generation, err = strconv.ParseInt(res.Header.Get("X-Goog-Generation"), 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse lease object generation: %w", err)
+		}
+	}
+
+	// Write our lease
+	data, err := json.Marshal(lease{
+		HolderIdentity:   identity,
+		RenewTime:        now,
+		LeaseDurationSec: int(leaseDuration.Seconds()),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal lease: %w", err)
+	}
+
+	_, err = l.service.Objects.Insert(l.bucket, &storage.Object{Name: l.object, ContentType: "application/json"}).
+		IfGenerationMatch(generation).
+		Media(bytes.NewReader(data)).
+		Context(ctxConn).
+		Do()
+	if isGoogleAPIError(err, http.StatusPreconditionFailed) {
+		// Another replica updated the lease in the meantime
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to write lease object: %w", err)
+	}
+
+	return true, nil
+}
+
+// isGoogleAPIError returns true when err is an error returned by a Google API with the given HTTP code
+func isGoogleAPIError(err error, code int) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
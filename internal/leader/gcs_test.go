@@ -0,0 +1,162 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/option"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// gcsServer serves a single GCS object, rejecting the writes whose generation precondition does not match
+type gcsServer struct {
+	mutex      sync.Mutex
+	data       []byte
+	generation int64
+
+	// beforeWrite is called before checking the precondition of a write, to simulate concurrent writers
+	beforeWrite func()
+}
+
+func (s *gcsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.generation == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": http.StatusNotFound, "message": "not found"}})
+			return
+		}
+		w.Header().Set("X-Goog-Generation", strconv.FormatInt(s.generation, 10))
+		w.Write(s.data)
+
+	case http.MethodPost:
+		if s.beforeWrite != nil {
+			s.beforeWrite()
+		}
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if r.URL.Query().Get("ifGenerationMatch") != strconv.FormatInt(s.generation, 10) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": http.StatusPreconditionFailed, "message": "precondition failed"}})
+			return
+		}
+
+		// The multipart upload holds the metadata of the object first, and then its content
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		reader := multipart.NewReader(r.Body, params["boundary"])
+		reader.NextPart()
+		part, err := reader.NextPart()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.data, _ = io.ReadAll(part)
+		s.generation++
+		json.NewEncoder(w).Encode(map[string]any{"name": "leader.json", "generation": strconv.FormatInt(s.generation, 10)})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// holder returns the lease stored in the object
+func (s *gcsServer) holder(t *testing.T) lease {
+	t.Helper()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var current lease
+	err := json.Unmarshal(s.data, &current)
+	if err != nil {
+		t.Fatalf("error decoding the lease stored: %v", err)
+	}
+	return current
+}
+
+// newTestGCSLock starts a gcsServer, returning it with a lock using it
+func newTestGCSLock(t *testing.T) (*gcsServer, *gcsLock) {
+	t.Helper()
+	server := &gcsServer{}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	service, err := storage.NewService(context.Background(), option.WithEndpoint(httpServer.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("error creating GCS client: %v", err)
+	}
+	return server, &gcsLock{service: service, bucket: "bucket", object: "leader.json"}
+}
+
+func TestGCSLock(t *testing.T) {
+	server, lock := newTestGCSLock(t)
+	ctxConn := context.Background()
+
+	// The lease is acquired when the object does not exist, and renewed by its holder
+	for _, step := range []string{"acquire", "renew"} {
+		acquired, err := lock.tryAcquireOrRenew(ctxConn, "replica-1", time.Minute)
+		if err != nil || !acquired {
+			t.Fatalf("%s: tryAcquireOrRenew() = %t, %v, want the lease", step, acquired, err)
+		}
+	}
+	if server.generation != 2 {
+		t.Errorf("object written %d times, want 2", server.generation)
+	}
+
+	// Other replicas do not take the lease while it is valid
+	acquired, err := lock.tryAcquireOrRenew(ctxConn, "replica-2", time.Minute)
+	if err != nil || acquired {
+		t.Fatalf("tryAcquireOrRenew() of a valid lease held by another replica = %t, %v, want it refused", acquired, err)
+	}
+
+	// The lease is taken over once expired
+	server.mutex.Lock()
+	server.data, _ = json.Marshal(lease{HolderIdentity: "replica-1", RenewTime: time.Now().Add(-2 * time.Minute), LeaseDurationSec: 60})
+	server.mutex.Unlock()
+	acquired, err = lock.tryAcquireOrRenew(ctxConn, "replica-2", time.Minute)
+	if err != nil || !acquired {
+		t.Fatalf("tryAcquireOrRenew() of an expired lease = %t, %v, want it taken over", acquired, err)
+	}
+	if holder := server.holder(t).HolderIdentity; holder != "replica-2" {
+		t.Errorf("lease held by %s, want replica-2", holder)
+	}
+
+	// The previous holder loses the leadership
+	acquired, err = lock.tryAcquireOrRenew(ctxConn, "replica-1", time.Minute)
+	if err != nil || acquired {
+		t.Errorf("tryAcquireOrRenew() of the previous holder = %t, %v, want it refused", acquired, err)
+	}
+}
+
+func TestGCSLockGenerationPrecondition(t *testing.T) {
+	server, lock := newTestGCSLock(t)
+	server.data, _ = json.Marshal(lease{HolderIdentity: "replica-1", RenewTime: time.Now().Add(-2 * time.Minute), LeaseDurationSec: 60})
+	server.generation = 1
+
+	// Another replica takes the expired lease over between the read and the write, so the write loses the race
+	server.beforeWrite = func() {
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		server.data, _ = json.Marshal(lease{HolderIdentity: "replica-3", RenewTime: time.Now(), LeaseDurationSec: 60})
+		server.generation++
+		server.beforeWrite = nil
+	}
+	acquired, err := lock.tryAcquireOrRenew(context.Background(), "replica-2", time.Minute)
+	if err != nil || acquired {
+		t.Fatalf("tryAcquireOrRenew() racing another replica = %t, %v, want it lost without error", acquired, err)
+	}
+	if holder := server.holder(t).HolderIdentity; holder != "replica-3" {
+		t.Errorf("lease held by %s, want the winner of the race replica-3", holder)
+	}
+}
@@ -0,0 +1,191 @@
+package leader
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	serviceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	// microTimeFormat is the format used by Kubernetes for the Lease times
+	microTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// kubernetesLease is the subset of the coordination.k8s.io/v1 Lease used for the leader election
+type kubernetesLease struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       string `json:"holderIdentity,omitempty"`
+		LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+		AcquireTime          string `json:"acquireTime,omitempty"`
+		RenewTime            string `json:"renewTime,omitempty"`
+	} `json:"spec"`
+}
+
+// kubernetesLock stores the lease in a Kubernetes Lease, using its resourceVersion to avoid concurrent updates.
+// It uses the in-cluster configuration, so the autoscaler must run inside a Pod.
+type kubernetesLock struct {
+	client    *http.Client
+	host      string
+	token     string
+	namespace string
+	name      string
+}
+
+// newKubernetesLock creates a lock backed by the Kubernetes Lease defined in the config
+func newKubernetesLock(config *v1alpha1.ConfigSpec) (*kubernetesLock, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("kubernetes leader election requires running inside a Kubernetes cluster")
+	}
+
+	token, err := os.ReadFile(serviceAccountPath + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+
+	caCert, err := os.ReadFile(serviceAccountPath + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA: %w", err)
+	}
+	caCertPool := x509.NewCertPool()
+	caCertPool.AppendCertsFromPEM(caCert)
+
+	namespace := config.LeaderElection.Kubernetes.Namespace
+	if namespace == "" {
+		currentNamespace, err := os.ReadFile(serviceAccountPath + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service account namespace: %w", err)
+		}
+		namespace = strings.TrimSpace(string(currentNamespace))
+	}
+
+	name := config.LeaderElection.Kubernetes.LeaseName
+	if name == "" {
+		name = "custom-vm-autoscaler"
+	}
+
+	return &kubernetesLock{
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: caCertPool, MinVersion: tls.VersionTLS12},
+			},
+		},
+		host:      "https://" + net.JoinHostPort(host, port),
+		token:     strings.TrimSpace(string(token)),
+		namespace: namespace,
+		name:      name,
+	}, nil
+}
+
+// tryAcquireOrRenew reads the Lease and updates it when it is free, expired, or already ours.
+// Kubernetes rejects the update when the resourceVersion changed, so only one replica can win a race.
+func (l *kubernetesLock) tryAcquireOrRenew(ctxConn context.Context, identity string, leaseDuration time.Duration) (bool, error) {
+	now := time.Now()
+	leasesURL := fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", l.host, l.namespace)
+
+	// Get the current Lease, if any
+	var current kubernetesLease
+	status, err := l.do(ctxConn, http.MethodGet, leasesURL+"/"+l.name, nil, &current)
+	if err != nil {
+		return false, err
+	}
+
+	method, url := http.MethodPut, leasesURL+"/"+l.name
+	switch status {
+	case http.StatusOK:
+		renewTime, _ := time.Parse(microTimeFormat, current.Spec.RenewTime)
+		currentLease := lease{
+			HolderIdentity:   current.Spec.HolderIdentity,
+			RenewTime:        renewTime,
+			LeaseDurationSec: current.Spec.LeaseDurationSeconds,
+		}
+		if currentLease.HolderIdentity != identity && !currentLease.expired(now) {
+			return false, nil
+		}
+		if currentLease.HolderIdentity != identity {
+			current.Spec.AcquireTime = now.UTC().Format(microTimeFormat)
+		}
+	case http.StatusNotFound:
+		method, url = http.MethodPost, leasesURL
+		current.APIVersion = "coordination.k8s.io/v1"
+		current.Kind = "Lease"
+		current.Metadata.Name = l.name
+		current.Metadata.Namespace = l.namespace
+		current.Spec.AcquireTime = now.UTC().Format(microTimeFormat)
+	default:
+		return false, fmt.Errorf("unexpected status getting lease: %d", status)
+	}
+
+	// Write our lease
+	current.Spec.HolderIdentity = identity
+	current.Spec.LeaseDurationSeconds = int(leaseDuration.Seconds())
+	current.Spec.RenewTime = now.UTC().Format(microTimeFormat)
+
+	status, err = l.do(ctxConn, method, url, &current, nil)
+	if err != nil {
+		return false, err
+	}
+	switch status {
+	case http.StatusOK, http.StatusCreated:
+		return true, nil
+	case http.StatusConflict:
+		// Another replica updated the Lease in the meantime
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status writing lease: %d", status)
+	}
+}
+
+// do executes a request against the Kubernetes API, decoding the response into out when it succeeds
+func (l *kubernetesLock) do(ctxConn context.Context, method, url string, in any, out any) (int, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal lease: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctxConn, method, url, body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+l.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := l.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute request against Kubernetes API: %w", err)
+	}
+	defer res.Body.Close()
+
+	if out != nil && res.StatusCode == http.StatusOK {
+		err = json.NewDecoder(res.Body).Decode(out)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decode lease: %w", err)
+		}
+	}
+
+	return res.StatusCode, nil
+}
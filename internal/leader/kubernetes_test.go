@@ -0,0 +1,153 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// leaseServer serves a single Kubernetes Lease, rejecting the updates whose resourceVersion is not the current one
+type leaseServer struct {
+	mutex   sync.Mutex
+	lease   *kubernetesLease
+	version int
+}
+
+func (s *leaseServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var body kubernetesLease
+	if r.Method != http.MethodGet {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/leases/autoscaler"):
+		if s.lease == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(s.lease)
+
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/leases"):
+		if s.lease != nil {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		s.store(&body)
+		w.WriteHeader(http.StatusCreated)
+
+	case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/leases/autoscaler"):
+		if s.lease == nil || body.Metadata.ResourceVersion != s.lease.Metadata.ResourceVersion {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		s.store(&body)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// store saves the Lease with a new resourceVersion
+func (s *leaseServer) store(lease *kubernetesLease) {
+	s.version++
+	lease.Metadata.ResourceVersion = strconv.Itoa(s.version)
+	s.lease = lease
+}
+
+// setHolder replaces the Lease with one held by the identity, renewed at the given time
+func (s *leaseServer) setHolder(identity string, renewTime time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	lease := &kubernetesLease{}
+	lease.Spec.HolderIdentity = identity
+	lease.Spec.LeaseDurationSeconds = 60
+	lease.Spec.RenewTime = renewTime.UTC().Format(microTimeFormat)
+	s.store(lease)
+}
+
+// holder returns the holder of the Lease, and when it acquired it
+func (s *leaseServer) holder() (string, string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lease.Spec.HolderIdentity, s.lease.Spec.AcquireTime
+}
+
+func TestKubernetesLock(t *testing.T) {
+	server := &leaseServer{}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	lock := &kubernetesLock{client: httpServer.Client(), host: httpServer.URL, token: "token", namespace: "default", name: "autoscaler"}
+	ctxConn := context.Background()
+
+	// The Lease is created when it does not exist, and renewed by its holder keeping its acquire time
+	acquired, err := lock.tryAcquireOrRenew(ctxConn, "replica-1", time.Minute)
+	if err != nil || !acquired {
+		t.Fatalf("tryAcquireOrRenew() of a missing Lease = %t, %v, want it created", acquired, err)
+	}
+	_, acquireTime := server.holder()
+	acquired, err = lock.tryAcquireOrRenew(ctxConn, "replica-1", time.Minute)
+	if err != nil || !acquired {
+		t.Fatalf("tryAcquireOrRenew() of the holder = %t, %v, want it renewed", acquired, err)
+	}
+	if holder, renewedAcquireTime := server.holder(); holder != "replica-1" || renewedAcquireTime != acquireTime {
+		t.Errorf("Lease held by %s since %s, want replica-1 since %s", holder, renewedAcquireTime, acquireTime)
+	}
+
+	// Other replicas do not take the Lease while it is valid
+	acquired, err = lock.tryAcquireOrRenew(ctxConn, "replica-2", time.Minute)
+	if err != nil || acquired {
+		t.Fatalf("tryAcquireOrRenew() of a valid Lease held by another replica = %t, %v, want it refused", acquired, err)
+	}
+
+	// The Lease is taken over once expired, and the previous holder loses the leadership
+	server.setHolder("replica-1", time.Now().Add(-2*time.Minute))
+	acquired, err = lock.tryAcquireOrRenew(ctxConn, "replica-2", time.Minute)
+	if err != nil || !acquired {
+		t.Fatalf("tryAcquireOrRenew() of an expired Lease = %t, %v, want it taken over", acquired, err)
+	}
+	if holder, takeoverTime := server.holder(); holder != "replica-2" || takeoverTime == "" {
+		t.Errorf("Lease held by %s since %q, want replica-2 since the takeover", holder, takeoverTime)
+	}
+	acquired, err = lock.tryAcquireOrRenew(ctxConn, "replica-1", time.Minute)
+	if err != nil || acquired {
+		t.Errorf("tryAcquireOrRenew() of the previous holder = %t, %v, want it refused", acquired, err)
+	}
+}
+
+func TestKubernetesLockConflict(t *testing.T) {
+	server := &leaseServer{}
+	server.setHolder("replica-1", time.Now().Add(-2*time.Minute))
+
+	// Another replica takes the expired Lease over between the read and the update, so the update loses the race
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			server.setHolder("replica-3", time.Now())
+		}
+		server.ServeHTTP(w, r)
+	})
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+	lock := &kubernetesLock{client: httpServer.Client(), host: httpServer.URL, token: "token", namespace: "default", name: "autoscaler"}
+
+	acquired, err := lock.tryAcquireOrRenew(context.Background(), "replica-2", time.Minute)
+	if err != nil || acquired {
+		t.Fatalf("tryAcquireOrRenew() racing another replica = %t, %v, want it lost without error", acquired, err)
+	}
+	if holder, _ := server.holder(); holder != "replica-3" {
+		t.Errorf("Lease held by %s, want the winner of the race replica-3", holder)
+	}
+}
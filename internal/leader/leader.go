@@ -0,0 +1,141 @@
+package leader
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Backends supported to store the lease
+	BackendGCS        = "gcs"
+	BackendKubernetes = "kubernetes"
+)
+
+// lease represents the lease stored in the backend
+type lease struct {
+	HolderIdentity   string    `json:"holderIdentity"`
+	RenewTime        time.Time `json:"renewTime"`
+	LeaseDurationSec int       `json:"leaseDurationSec"`
+}
+
+// expired returns true when the holder has not renewed the lease in time
+func (l *lease) expired(now time.Time) bool {
+	return now.After(l.RenewTime.Add(time.Duration(l.LeaseDurationSec) * time.Second))
+}
+
+// lock is implemented by every backend able to store the lease.
+// tryAcquireOrRenew returns true when the identity holds the lease after calling it.
+type lock interface {
+	tryAcquireOrRenew(ctxConn context.Context, identity string, leaseDuration time.Duration) (bool, error)
+}
+
+// Elector keeps trying to acquire or renew the lease, so only one of the replicas of
+// the autoscaler acts at the same time. A nil Elector is always the leader.
+type Elector struct {
+	lock          lock
+	identity      string
+	leaseDuration time.Duration
+	renewInterval time.Duration
+
+	isLeader  atomic.Bool
+	lastRenew time.Time
+}
+
+// NewElector creates an Elector for the backend defined in the config
+func NewElector(config *v1alpha1.ConfigSpec) (*Elector, error) {
+	leaderElection := config.LeaderElection
+
+	// The lease would expire before being renewed, and the leadership would flap between the replicas
+	if leaderElection.RenewIntervalSec >= leaderElection.LeaseDurationSec {
+		return nil, fmt.Errorf("leader election renew interval (%ds) must be lower than the lease duration (%ds)",
+			leaderElection.RenewIntervalSec, leaderElection.LeaseDurationSec)
+	}
+
+	identity := leaderElection.Identity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hostname for leader election identity: %w", err)
+		}
+		identity = hostname
+	}
+
+	var backendLock lock
+	var err error
+	switch leaderElection.Backend {
+	case BackendGCS:
+		backendLock, err = newGCSLock(config)
+	case BackendKubernetes:
+		backendLock, err = newKubernetesLock(config)
+	default:
+		return nil, fmt.Errorf("unsupported leader election backend: %s", leaderElection.Backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Elector{
+		lock:          backendLock,
+		identity:      identity,
+		leaseDuration: time.Duration(leaderElection.LeaseDurationSec) * time.Second,
+		renewInterval: time.Duration(leaderElection.RenewIntervalSec) * time.Second,
+	}, nil
+}
+
+// Run tries to acquire or renew the lease periodically. It never returns
+func (e *Elector) Run() {
+	for {
+		e.tryAcquireOrRenew()
+		time.Sleep(e.renewInterval)
+	}
+}
+
+// tryAcquireOrRenew executes a single attempt to acquire or renew the lease, updating the leadership state
+func (e *Elector) tryAcquireOrRenew() {
+	ctxConn, cancel := context.WithTimeout(context.Background(), e.renewInterval)
+	defer cancel()
+
+	acquired, err := e.lock.tryAcquireOrRenew(ctxConn, e.identity, e.leaseDuration)
+	if err != nil {
+		log.Printf("Error acquiring or renewing leader election lease: %v", err)
+
+		// Keep the leadership while the lease is still valid, as nobody else can take it
+		acquired = e.isLeader.Load() && time.Since(e.lastRenew) < e.leaseDuration
+	}
+	if acquired && err == nil {
+		e.lastRenew = time.Now()
+	}
+
+	if acquired && !e.isLeader.Load() {
+		log.Printf("Leadership acquired by %s. This replica is now the leader", e.identity)
+	}
+	if !acquired && e.isLeader.Load() {
+		log.Printf("Leadership lost by %s. This replica is now on standby", e.identity)
+	}
+	e.isLeader.Store(acquired)
+}
+
+// IsLeader returns true when this replica holds the lease
+func (e *Elector) IsLeader() bool {
+	if e == nil {
+		return true
+	}
+	return e.isLeader.Load()
+}
+
+// WaitForLeadership blocks until this replica holds the lease
+func (e *Elector) WaitForLeadership() {
+	if e.IsLeader() {
+		return
+	}
+
+	log.Printf("Waiting for leadership as %s", e.identity)
+	for !e.IsLeader() {
+		time.Sleep(e.renewInterval)
+	}
+}
@@ -0,0 +1,75 @@
+package leader
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeLock returns the results queued, one per attempt
+type fakeLock struct {
+	results []error
+}
+
+// errLeaseHeld is queued in a fakeLock to refuse the lease, as held by another replica
+var errLeaseHeld = errors.New("held by another replica")
+
+func (l *fakeLock) tryAcquireOrRenew(_ context.Context, _ string, _ time.Duration) (bool, error) {
+	result := l.results[0]
+	l.results = l.results[1:]
+	if errors.Is(result, errLeaseHeld) {
+		return false, nil
+	}
+	return result == nil, result
+}
+
+func TestElectorLeadership(t *testing.T) {
+	backendError := errors.New("backend unavailable")
+	lock := &fakeLock{results: []error{nil, backendError, errLeaseHeld}}
+	elector := &Elector{lock: lock, identity: "replica-1", leaseDuration: time.Minute, renewInterval: time.Second}
+
+	elector.tryAcquireOrRenew()
+	if !elector.IsLeader() {
+		t.Fatalf("IsLeader() = false after acquiring the lease")
+	}
+
+	// The leadership is kept while the backend fails, as the lease is still valid
+	elector.tryAcquireOrRenew()
+	if !elector.IsLeader() {
+		t.Errorf("IsLeader() = false after failing to renew a valid lease")
+	}
+
+	// The leadership is lost once another replica holds the lease
+	elector.tryAcquireOrRenew()
+	if elector.IsLeader() {
+		t.Errorf("IsLeader() = true after the lease was taken over")
+	}
+}
+
+func TestElectorLeadershipExpires(t *testing.T) {
+	backendError := errors.New("backend unavailable")
+	lock := &fakeLock{results: []error{nil, backendError}}
+	elector := &Elector{lock: lock, identity: "replica-1", leaseDuration: time.Minute, renewInterval: time.Second}
+
+	// The leadership is lost when the backend fails past the expiration of the last lease renewed
+	elector.tryAcquireOrRenew()
+	elector.lastRenew = time.Now().Add(-2 * time.Minute)
+	elector.tryAcquireOrRenew()
+	if elector.IsLeader() {
+		t.Errorf("IsLeader() = true after the lease expired without renewals")
+	}
+}
+
+func TestNewElectorRenewInterval(t *testing.T) {
+	config := &v1alpha1.ConfigSpec{}
+	config.LeaderElection.Backend = BackendGCS
+	config.LeaderElection.LeaseDurationSec = 10
+	config.LeaderElection.RenewIntervalSec = 10
+
+	_, err := NewElector(config)
+	if err == nil {
+		t.Errorf("NewElector() with the renew interval equal to the lease duration succeeded, want an error")
+	}
+}
@@ -0,0 +1,155 @@
+package logging
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	cloudlogging "google.golang.org/api/logging/v2"
+	"google.golang.org/api/option"
+)
+
+const (
+	// cloudLoggingFlushInterval is the maximum time the entries are buffered before writing them
+	cloudLoggingFlushInterval = 5 * time.Second
+
+	// cloudLoggingBatchSize is the number of entries buffered that are written right away
+	cloudLoggingBatchSize = 100
+
+	// cloudLoggingTimeout bounds every write of the buffered entries
+	cloudLoggingTimeout = 30 * time.Second
+)
+
+// cloudLoggingSink writes the logs to Google Cloud Logging. The lines are written as text entries, and the decisions
+// as structured entries labeled with their autoscaler and MIG. Entries are buffered, and written in batches
+// from the background, so logging never waits for the API
+type cloudLoggingSink struct {
+	service  *cloudlogging.Service
+	logName  string
+	resource *cloudlogging.MonitoredResource
+
+	mutex   sync.Mutex
+	entries []*cloudlogging.LogEntry
+	flushed chan struct{}
+	done    chan struct{}
+}
+
+// newCloudLoggingSink creates a sink writing to the log of the project defined in the config
+func newCloudLoggingSink(config *v1alpha1.ConfigSpec) (*cloudLoggingSink, error) {
+	spec := config.Logging.CloudLogging
+
+	var opts []option.ClientOption
+	if spec.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(spec.CredentialsFile))
+	}
+
+	service, err := cloudlogging.NewService(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Logging client: %w", err)
+	}
+
+	s := &cloudLoggingSink{
+		service:  service,
+		logName:  fmt.Sprintf("projects/%s/logs/%s", spec.ProjectID, spec.LogName),
+		resource: &cloudlogging.MonitoredResource{Type: "global", Labels: map[string]string{"project_id": spec.ProjectID}},
+		flushed:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+func (s *cloudLoggingSink) writeLine(line string) error {
+	s.add(&cloudlogging.LogEntry{TextPayload: line, Severity: "DEFAULT"})
+	return nil
+}
+
+func (s *cloudLoggingSink) writeDecision(decision v1alpha1.Decision) error {
+	payload, err := json.Marshal(decision)
+	if err != nil {
+		return fmt.Errorf("failed to encode decision: %w", err)
+	}
+
+	severity := "INFO"
+	if decision.Error != "" {
+		severity = "ERROR"
+	}
+	s.add(&cloudlogging.LogEntry{
+		JsonPayload: googleapi.RawMessage(payload),
+		Severity:    severity,
+		Labels:      map[string]string{"autoscaler": decision.Autoscaler, "mig": decision.MIG, "action": decision.Action},
+	})
+	return nil
+}
+
+func (s *cloudLoggingSink) close() error {
+	close(s.done)
+	<-s.flushed
+	return s.flush()
+}
+
+// add buffers the entry, timestamped now, waking up the background writer when the batch is full
+func (s *cloudLoggingSink) add(entry *cloudlogging.LogEntry) {
+	entry.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+
+	s.mutex.Lock()
+	s.entries = append(s.entries, entry)
+	full := len(s.entries) >= cloudLoggingBatchSize
+	s.mutex.Unlock()
+
+	if full {
+		go func() {
+			err := s.flush()
+			if err != nil {
+				reportError(err)
+			}
+		}()
+	}
+}
+
+// run writes the buffered entries periodically until the sink is closed
+func (s *cloudLoggingSink) run() {
+	defer close(s.flushed)
+	ticker := time.NewTicker(cloudLoggingFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			err := s.flush()
+			if err != nil {
+				reportError(err)
+			}
+		}
+	}
+}
+
+// flush writes the buffered entries. They are dropped when the write fails, so a broken sink does not grow forever
+func (s *cloudLoggingSink) flush() error {
+	s.mutex.Lock()
+	entries := s.entries
+	s.entries = nil
+	s.mutex.Unlock()
+	if len(entries) == 0 {
+		return nil
+	}
+
+	ctxConn, cancel := context.WithTimeout(context.Background(), cloudLoggingTimeout)
+	defer cancel()
+
+	_, err := s.service.Entries.Write(&cloudlogging.WriteLogEntriesRequest{
+		LogName:  s.logName,
+		Resource: s.resource,
+		Entries:  entries,
+	}).Context(ctxConn).Do()
+	if err != nil {
+		return fmt.Errorf("failed to write %d entries to Cloud Logging: %w", len(entries), err)
+	}
+	return nil
+}
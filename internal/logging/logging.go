@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// sink is implemented by every backend receiving the logs in addition to stderr
+type sink interface {
+	writeLine(line string) error
+	writeDecision(decision v1alpha1.Decision) error
+	close() error
+}
+
+var (
+	// sinks are the backends configured. When empty, the logs are only written to stderr
+	sinks []sink
+
+	// sinksMutex serializes the accesses to the sinks, shared by every autoscaler in the process
+	sinksMutex sync.Mutex
+)
+
+// Setup exports the logs to the backends defined in the config, in addition to stderr. Several of them can be
+// used at the same time
+func Setup(config *v1alpha1.ConfigSpec) error {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+
+	if config.Logging.CloudLogging.ProjectID != "" {
+		cloudLoggingSink, err := newCloudLoggingSink(config)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, cloudLoggingSink)
+	}
+	if config.Logging.Syslog.Enabled {
+		syslogSink, err := newSyslogSink(config)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, syslogSink)
+	}
+
+	if len(sinks) > 0 {
+		log.SetOutput(io.MultiWriter(os.Stderr, writer{}))
+	}
+	return nil
+}
+
+// RecordDecision exports the decision as a structured entry, labeled with its autoscaler and MIG
+func RecordDecision(decision v1alpha1.Decision) {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+
+	for _, s := range sinks {
+		err := s.writeDecision(decision)
+		if err != nil {
+			reportError(err)
+		}
+	}
+}
+
+// Close flushes the logs buffered by the backends and closes them
+func Close() {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+
+	for _, s := range sinks {
+		err := s.close()
+		if err != nil {
+			reportError(err)
+		}
+	}
+	sinks = nil
+}
+
+// writer sends every line written by the standard logger to the backends
+type writer struct{}
+
+func (writer) Write(p []byte) (int, error) {
+	line := strings.TrimRight(string(p), "\n")
+
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+
+	for _, s := range sinks {
+		err := s.writeLine(line)
+		if err != nil {
+			reportError(err)
+		}
+	}
+	return len(p), nil
+}
+
+// reportError writes the errors of the backends to stderr only, as logging them would send them to the backends again
+func reportError(err error) {
+	fmt.Fprintf(os.Stderr, "Error exporting logs: %v\n", err)
+}
@@ -0,0 +1,42 @@
+package logging
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+)
+
+// syslogSink writes the logs to a syslog daemon, local or remote. The decisions are written as JSON messages
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+// newSyslogSink connects to the syslog daemon defined in the config. Without address, the local one is used
+func newSyslogSink(config *v1alpha1.ConfigSpec) (*syslogSink, error) {
+	spec := config.Logging.Syslog
+	writer, err := syslog.Dial(spec.Network, spec.Address, syslog.LOG_INFO|syslog.LOG_DAEMON, spec.Tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return &syslogSink{writer: writer}, nil
+}
+
+func (s *syslogSink) writeLine(line string) error {
+	return s.writer.Info(line)
+}
+
+func (s *syslogSink) writeDecision(decision v1alpha1.Decision) error {
+	payload, err := json.Marshal(decision)
+	if err != nil {
+		return fmt.Errorf("failed to encode decision: %w", err)
+	}
+	if decision.Error != "" {
+		return s.writer.Err(string(payload))
+	}
+	return s.writer.Info(string(payload))
+}
+
+func (s *syslogSink) close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,102 @@
+package maintenance
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Modes of the maintenance windows
+	ModeBlock       = "block"
+	ModeScaleUpOnly = "scale-up-only"
+)
+
+// GetActiveWindow returns the maintenance window in progress, or nil when there is none.
+// Windows use the same days and hours format as the advanced custom scaling configuration
+func GetActiveWindow(ctx *v1alpha1.Context) (*v1alpha1.MaintenanceWindowSpec, error) {
+	return GetActiveWindowAt(ctx, time.Now().UTC())
+}
+
+// GetActiveWindowAt returns the maintenance window in progress at the given time, or nil when there is none
+func GetActiveWindowAt(ctx *v1alpha1.Context, currentTime time.Time) (*v1alpha1.MaintenanceWindowSpec, error) {
+	for i, window := range ctx.Config.Autoscaler.MaintenanceWindows {
+		if window.Mode == "" {
+			window.Mode = ModeBlock
+		}
+		if window.Mode != ModeBlock && window.Mode != ModeScaleUpOnly {
+			return nil, fmt.Errorf("invalid mode %s in maintenance window %d", window.Mode, i)
+		}
+
+		active, err := IsWithinWindow(currentTime, window.Days, window.HoursUTC)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hours in maintenance window %d: %w", i, err)
+		}
+		if active {
+			return &window, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// IsWithinWindow checks if the time is inside the comma-separated weekdays (0 is Sunday) and the range of hours.
+// If no hours are provided, the window lasts the entire day
+func IsWithinWindow(currentTime time.Time, days, hours string) (bool, error) {
+	currentWeekday := strconv.Itoa(int(currentTime.Weekday()))
+
+	for _, day := range strings.Split(days, ",") {
+		if strings.TrimSpace(day) != currentWeekday {
+			continue
+		}
+		if hours == "" {
+			return true, nil
+		}
+		return isWithinHours(currentTime, hours)
+	}
+
+	return false, nil
+}
+
+// ValidateWindow checks the format of the comma-separated weekdays (0 is Sunday to 6 is Saturday) and the range of hours
+func ValidateWindow(days, hours string) error {
+	if strings.TrimSpace(days) == "" {
+		return fmt.Errorf("days are required")
+	}
+	for _, day := range strings.Split(days, ",") {
+		weekday, err := strconv.Atoi(strings.TrimSpace(day))
+		if err != nil || weekday < 0 || weekday > 6 {
+			return fmt.Errorf("invalid day %q, expected a number from 0 (Sunday) to 6 (Saturday)", strings.TrimSpace(day))
+		}
+	}
+	if hours == "" {
+		return nil
+	}
+	_, err := isWithinHours(time.Now().UTC(), hours)
+	return err
+}
+
+// isWithinHours checks if the time is inside the range of hours, e.g. 4:00:00-6:00:00
+func isWithinHours(currentTime time.Time, hours string) (bool, error) {
+	hoursRange := strings.Split(hours, "-")
+	if len(hoursRange) != 2 {
+		return false, fmt.Errorf("expected start and end hours separated by a dash (e.g., 4:00:00-6:00:00)")
+	}
+
+	startHour, err := time.Parse("15:04:05", hoursRange[0])
+	if err != nil {
+		return false, fmt.Errorf("error parsing start hour: %w", err)
+	}
+	endHour, err := time.Parse("15:04:05", hoursRange[1])
+	if err != nil {
+		return false, fmt.Errorf("error parsing end hour: %w", err)
+	}
+
+	// Adjust start and end times to match the current date
+	startTime := time.Date(currentTime.Year(), currentTime.Month(), currentTime.Day(), startHour.Hour(), startHour.Minute(), startHour.Second(), 0, currentTime.Location())
+	endTime := time.Date(currentTime.Year(), currentTime.Month(), currentTime.Day(), endHour.Hour(), endHour.Minute(), endHour.Second(), 0, currentTime.Location())
+
+	return currentTime.After(startTime) && currentTime.Before(endTime), nil
+}
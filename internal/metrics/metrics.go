@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/elasticsearch"
+	"custom-vm-autoscaler/internal/state"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the names of every metric of the autoscaler
+const namespace = "custom_vm_autoscaler"
+
+var (
+	nextScaleUpDesc = prometheus.NewDesc(namespace+"_next_scale_up_timestamp_seconds",
+		"Time when the autoscaler is allowed to scale up again. Absent while scaling up is not allowed in the foreseeable future",
+		[]string{"autoscaler"}, nil)
+	nextScaleDownDesc = prometheus.NewDesc(namespace+"_next_scale_down_timestamp_seconds",
+		"Time when the autoscaler is allowed to scale down again. Absent while scaling down is not allowed in the foreseeable future",
+		[]string{"autoscaler"}, nil)
+	cooldownRemainingDesc = prometheus.NewDesc(namespace+"_cooldown_remaining_seconds",
+		"Seconds until the cooldown or the wait for the next evaluation ends",
+		[]string{"autoscaler"}, nil)
+	pausedDesc = prometheus.NewDesc(namespace+"_paused",
+		"Whether the scaling decisions of the autoscaler are paused",
+		[]string{"autoscaler"}, nil)
+	drainDurationDesc = prometheus.NewDesc(namespace+"_drain_duration_seconds",
+		"Median and 95th percentile of the duration of the last drains of Elasticsearch nodes. Absent until a drain finishes",
+		[]string{"autoscaler", "quantile"}, nil)
+	lastDecisionDesc = prometheus.NewDesc(namespace+"_last_decision_timestamp_seconds",
+		"Time of the last decision taken by the autoscaler, labeled with its action and outcome. Absent until the first decision",
+		[]string{"autoscaler", "action", "outcome"}, nil)
+)
+
+// collector exports the state of the autoscalers, read when the metrics are scraped
+type collector struct {
+	autoscalers []*v1alpha1.Context
+}
+
+// Handler returns the endpoint exposing the metrics of the autoscalers in the Prometheus format
+func Handler(autoscalers []*v1alpha1.Context) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&collector{autoscalers: autoscalers})
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- nextScaleUpDesc
+	ch <- nextScaleDownDesc
+	ch <- cooldownRemainingDesc
+	ch <- pausedDesc
+	ch <- drainDurationDesc
+	ch <- lastDecisionDesc
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+	for _, ctx := range c.autoscalers {
+		name := ctx.Config.Name
+
+		next, err := state.GetNextScaling(ctx, now)
+		if err != nil {
+			log.Printf("Error getting next scaling times of autoscaler %s: %v", name, err)
+		}
+		if !next.ScaleUpAt.IsZero() {
+			ch <- prometheus.MustNewConstMetric(nextScaleUpDesc, prometheus.GaugeValue, float64(next.ScaleUpAt.Unix()), name)
+		}
+		if !next.ScaleDownAt.IsZero() {
+			ch <- prometheus.MustNewConstMetric(nextScaleDownDesc, prometheus.GaugeValue, float64(next.ScaleDownAt.Unix()), name)
+		}
+
+		ctx.Mutex.Lock()
+		cooldownRemaining := max(0, ctx.State.CooldownUntil.Sub(now).Seconds())
+		paused := ctx.State.Pause != nil && (ctx.State.Pause.Until.IsZero() || now.Before(ctx.State.Pause.Until))
+		lastDecision := ctx.LastDecision
+		ctx.Mutex.Unlock()
+
+		ch <- prometheus.MustNewConstMetric(cooldownRemainingDesc, prometheus.GaugeValue, cooldownRemaining, name)
+		pausedValue := 0.0
+		if paused {
+			pausedValue = 1
+		}
+		ch <- prometheus.MustNewConstMetric(pausedDesc, prometheus.GaugeValue, pausedValue, name)
+
+		estimate := elasticsearch.GetDrainEstimate(ctx)
+		if estimate.Drains > 0 {
+			ch <- prometheus.MustNewConstMetric(drainDurationDesc, prometheus.GaugeValue, estimate.P50.Seconds(), name, "0.5")
+			ch <- prometheus.MustNewConstMetric(drainDurationDesc, prometheus.GaugeValue, estimate.P95.Seconds(), name, "0.95")
+		}
+
+		if lastDecision != nil {
+			ch <- prometheus.MustNewConstMetric(lastDecisionDesc, prometheus.GaugeValue, float64(lastDecision.Time.Unix()),
+				name, lastDecision.Action, lastDecision.Outcome)
+		}
+	}
+}
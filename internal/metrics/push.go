@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// pushTimeout bounds the push of the metrics to the Pushgateway
+const pushTimeout = 30 * time.Second
+
+// Push sends the metrics of the autoscalers, along with the outcome of their last decisions, to the Pushgateway
+// defined in the config, replacing the ones pushed by the previous execution. It is used by the executions with --once,
+// which end before being scraped
+func Push(config *v1alpha1.ConfigSpec, autoscalers []*v1alpha1.Context) error {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&collector{autoscalers: autoscalers})
+
+	pusher := push.New(config.Pushgateway.URL, config.Pushgateway.Job).
+		Gatherer(registry).
+		Client(&http.Client{Timeout: pushTimeout})
+	if config.Pushgateway.Username != "" {
+		pusher = pusher.BasicAuth(config.Pushgateway.Username, config.Pushgateway.Password)
+	}
+
+	ctxPush, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+	err := pusher.PushContext(ctxPush)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics to %s: %w", config.Pushgateway.URL, err)
+	}
+	return nil
+}
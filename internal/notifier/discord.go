@@ -0,0 +1,56 @@
+package notifier
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// discordMaxContentLength is the maximum length of the messages accepted by Discord
+const discordMaxContentLength = 2000
+
+// discordNotifier sends the notifications to a Discord channel using a webhook URL
+type discordNotifier struct {
+	webhookURL string
+	client     *http.Client
+}
+
+// newDiscordNotifier creates the notifier for the Discord webhook defined in the channel
+func newDiscordNotifier(spec v1alpha1.NotificationChannelSpec, client *http.Client) (*discordNotifier, error) {
+	if spec.Discord.WebhookURL == "" {
+		return nil, fmt.Errorf("webhookUrl is required for discord channels")
+	}
+
+	return &discordNotifier{
+		webhookURL: spec.Discord.WebhookURL,
+		client:     client,
+	}, nil
+}
+
+func (n *discordNotifier) Notify(ctxConn context.Context, notification Notification) error {
+	content := notification.Message
+	if len(content) > discordMaxContentLength {
+		content = content[:discordMaxContentLength-3] + "..."
+	}
+
+	body, err := json.Marshal(map[string]string{"content": content})
+	if err != nil {
+		return fmt.Errorf("failed to marshal Discord message: %w", err)
+	}
+
+	res, err := postJSON(ctxConn, n.client, n.webhookURL, body)
+	if err != nil {
+		return fmt.Errorf("failed to send Discord message: %w", err)
+	}
+	defer res.Body.Close()
+
+	// Discord answers 204 without content, unless the message is requested back
+	if res.StatusCode >= 300 {
+		resBody, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("error sending Discord message: %s: %s", res.Status, resBody)
+	}
+	return nil
+}
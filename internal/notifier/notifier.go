@@ -0,0 +1,300 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/proxy"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// Types of the notification channels
+	ChannelTypeSlack     = "slack"
+	ChannelTypePagerDuty = "pagerduty"
+	ChannelTypeWebhook   = "webhook"
+	ChannelTypeTelegram  = "telegram"
+	ChannelTypeDiscord   = "discord"
+
+	// Types of the events notified, so channels can subscribe only to some of them
+	EventScaleUp   = "scale-up"
+	EventScaleDown = "scale-down"
+	EventRecovery  = "recovery"
+	EventError     = "error"
+	EventAlert     = "alert"
+	EventLimit     = "limit-reached"
+	EventAutoheal  = "autohealing"
+	EventTemplate  = "instance-template"
+	EventStatus    = "status"
+
+	// Severities of the notifications, from the lowest to the highest
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+
+	// Keys of the alerts, raised when a failure starts and resolved when it clears
+	AlertDrainTimeout     = "drain-timeout"
+	AlertRepeatedErrors   = "repeated-errors"
+	AlertMaxSizeSustained = "max-size-sustained"
+	AlertBudgetExceeded   = "budget-exceeded"
+	AlertCircuitOpen      = "circuit-open"
+	AlertNodeDrift        = "node-drift"
+	AlertUnhealthyNodes   = "unhealthy-nodes"
+	AlertStartupTimeout   = "startup-timeout"
+	AlertTemplateDrift    = "template-drift"
+	AlertDrainTimeoutLow  = "drain-timeout-low"
+	AlertInvalidConfig    = "invalid-config"
+	AlertMIGInterlocked   = "mig-interlocked"
+)
+
+// severityLevels orders the severities, so channels can filter the notifications below a minimum
+var severityLevels = map[string]int{
+	SeverityInfo:    0,
+	SeverityWarning: 1,
+	SeverityError:   2,
+}
+
+var (
+	// activeKeys holds the alerts raised and not resolved yet, and the notifications sent once and not rearmed yet,
+	// by autoscaler and key
+	activeKeys = map[string]bool{}
+
+	// activeKeysMutex serializes the accesses to the active keys, shared by every autoscaler in the process
+	activeKeysMutex sync.Mutex
+)
+
+// eventTypes are the types of the events notified
+var eventTypes = map[string]bool{
+	EventScaleUp:   true,
+	EventScaleDown: true,
+	EventRecovery:  true,
+	EventError:     true,
+	EventAlert:     true,
+	EventLimit:     true,
+	EventAutoheal:  true,
+	EventTemplate:  true,
+	EventStatus:    true,
+}
+
+// Notification is the message sent to the notification channels
+type Notification struct {
+	Autoscaler string
+	Severity   string
+	Event      string
+	Message    string
+
+	// AlertKey identifies the failure the notification belongs to. Empty for plain notifications
+	AlertKey string
+
+	// Resolved is set when the failure identified by the alert key has cleared
+	Resolved bool
+
+	// Fields are the details of the event, e.g. the MIG and its sizes, shown by the providers supporting them
+	Fields []Field
+
+	// Thread identifies the operation the notification belongs to, so providers supporting threads
+	// reply to the first notification of the operation. Empty for standalone notifications
+	Thread string
+
+	// Progress is set for the follow-ups of an operation in progress. They are only delivered to the
+	// providers supporting threads, so the rest of channels are not flooded
+	Progress bool
+}
+
+// Field is a detail of the event notified
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Notifier is implemented by every provider able to deliver notifications
+type Notifier interface {
+	Notify(ctxConn context.Context, notification Notification) error
+}
+
+// threadedNotifier is implemented by the providers able to group the notifications of an operation in a thread
+type threadedNotifier interface {
+	Notifier
+	threaded() bool
+}
+
+// channel is a notifier configured to receive the notifications from a minimum severity.
+// When events are defined, only the notifications of those types are received
+type channel struct {
+	name        string
+	minSeverity string
+	events      map[string]bool
+	notifier    Notifier
+}
+
+// newNotifier creates the notifier of the provider defined in the channel, sending its requests with the given client
+func newNotifier(spec v1alpha1.NotificationChannelSpec, client *http.Client) (Notifier, error) {
+	switch spec.Type {
+	case ChannelTypeSlack:
+		return newSlackNotifier(spec, client)
+	case ChannelTypePagerDuty:
+		return newPagerDutyNotifier(spec, client)
+	case ChannelTypeWebhook:
+		return newWebhookNotifier(spec, client)
+	case ChannelTypeTelegram:
+		return newTelegramNotifier(spec, client)
+	case ChannelTypeDiscord:
+		return newDiscordNotifier(spec, client)
+	default:
+		return nil, fmt.Errorf("unsupported notification channel type: %s", spec.Type)
+	}
+}
+
+// getChannels returns every notification channel defined in the config of the autoscaler.
+// The slack webhook defined directly in the notifications section is a channel receiving every event
+func getChannels(config *v1alpha1.ConfigSpec) ([]channel, error) {
+	specs := config.Notifications.Channels
+	if config.Notifications.Slack.WebhookURL != "" || config.Notifications.Slack.BotToken != "" {
+		spec := v1alpha1.NotificationChannelSpec{Name: ChannelTypeSlack, Type: ChannelTypeSlack, MinSeverity: config.Notifications.Slack.MinSeverity}
+		spec.Slack.WebhookURL = config.Notifications.Slack.WebhookURL
+		spec.Slack.BotToken = config.Notifications.Slack.BotToken
+		spec.Slack.Channel = config.Notifications.Slack.Channel
+		specs = append([]v1alpha1.NotificationChannelSpec{spec}, specs...)
+	}
+
+	transport, err := proxy.Transport(config.Notifications.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: time.Duration(config.Notifications.TimeoutSec) * time.Second, Transport: transport}
+	channels := make([]channel, 0, len(specs))
+	for i, spec := range specs {
+		if spec.Name == "" {
+			spec.Name = fmt.Sprintf("%s-%d", spec.Type, i)
+		}
+		if spec.MinSeverity == "" {
+			spec.MinSeverity = SeverityInfo
+		}
+		if _, ok := severityLevels[spec.MinSeverity]; !ok {
+			return nil, fmt.Errorf("invalid minSeverity %s in notification channel %s", spec.MinSeverity, spec.Name)
+		}
+
+		var events map[string]bool
+		if len(spec.Events) > 0 {
+			events = map[string]bool{}
+			for _, event := range spec.Events {
+				if !eventTypes[event] {
+					return nil, fmt.Errorf("invalid event %s in notification channel %s", event, spec.Name)
+				}
+				events[event] = true
+			}
+		}
+
+		notifier, err := newNotifier(spec, client)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notification channel %s: %w", spec.Name, err)
+		}
+		channels = append(channels, channel{name: spec.Name, minSeverity: spec.MinSeverity, events: events, notifier: notifier})
+	}
+	return channels, nil
+}
+
+// Validate checks the notification channels defined in the config of the autoscaler
+func Validate(config *v1alpha1.ConfigSpec) error {
+	_, err := getChannels(config)
+	return err
+}
+
+// Notify sends the message to every notification channel of the autoscaler accepting its severity and event type.
+// Errors are logged, as the autoscaler must keep working
+func Notify(ctx *v1alpha1.Context, severity, event, message string) {
+	send(ctx, Notification{Autoscaler: ctx.Config.Name, Severity: severity, Event: event, Message: message})
+}
+
+// NotifyDetailed sends the notification, with its fields and thread, like Notify
+func NotifyDetailed(ctx *v1alpha1.Context, notification Notification) {
+	notification.Autoscaler = ctx.Config.Name
+	send(ctx, notification)
+}
+
+// NotifyOnce sends the message like Notify, but only the first time until the key is rearmed,
+// so a situation repeated on every evaluation is notified once
+func NotifyOnce(ctx *v1alpha1.Context, severity, event, key, message string) {
+	if setActive(ctx, "once/"+key, true) {
+		return
+	}
+	Notify(ctx, severity, event, message)
+}
+
+// Rearm allows the notification identified by the key to be sent again
+func Rearm(ctx *v1alpha1.Context, key string) {
+	setActive(ctx, "once/"+key, false)
+}
+
+// Alert raises the alert identified by the key. It is only sent when it is not active yet,
+// so a failure repeated on every evaluation is notified once until it is resolved
+func Alert(ctx *v1alpha1.Context, severity, key, message string) {
+	if setActive(ctx, "alert/"+key, true) {
+		return
+	}
+	send(ctx, Notification{Autoscaler: ctx.Config.Name, Severity: severity, Event: EventAlert, Message: message, AlertKey: key})
+}
+
+// Resolve clears the alert identified by the key, notifying it only when the alert was active
+func Resolve(ctx *v1alpha1.Context, key, message string) {
+	if !setActive(ctx, "alert/"+key, false) {
+		return
+	}
+	send(ctx, Notification{Autoscaler: ctx.Config.Name, Severity: SeverityInfo, Event: EventAlert, Message: message, AlertKey: key, Resolved: true})
+}
+
+// setActive marks the key of the autoscaler as active or not, returning whether it was active before
+func setActive(ctx *v1alpha1.Context, key string, active bool) bool {
+	activeKeysMutex.Lock()
+	defer activeKeysMutex.Unlock()
+
+	id := ctx.Config.Name + "/" + key
+	wasActive := activeKeys[id]
+	if active {
+		activeKeys[id] = true
+	} else {
+		delete(activeKeys, id)
+	}
+	return wasActive
+}
+
+// send delivers the notification to every channel of the autoscaler accepting its severity and event type.
+// Resolutions are delivered to the same channels the alert was delivered to, and follow-ups only to the threaded ones
+func send(ctx *v1alpha1.Context, notification Notification) {
+	channels, err := getChannels(ctx.Config)
+	if err != nil {
+		log.Printf("Error getting notification channels: %v", err)
+		return
+	}
+
+	for _, c := range channels {
+		if !notification.Resolved && severityLevels[notification.Severity] < severityLevels[c.minSeverity] {
+			continue
+		}
+		if c.events != nil && !c.events[notification.Event] {
+			continue
+		}
+		if t, ok := c.notifier.(threadedNotifier); notification.Progress && (!ok || !t.threaded()) {
+			continue
+		}
+
+		err = c.notifier.Notify(ctx.ConnContext(), notification)
+		if err != nil {
+			log.Printf("Error sending notification to channel %s: %v", c.name, err)
+		}
+	}
+}
+
+// postJSON posts the JSON body to the URL, bound to the context
+func postJSON(ctxConn context.Context, client *http.Client, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctxConn, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return client.Do(req)
+}
@@ -0,0 +1,110 @@
+package notifier
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const (
+	// pagerDutyEventsURL is the endpoint of the PagerDuty Events API v2
+	pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+	// Actions of the PagerDuty events
+	pagerDutyActionTrigger = "trigger"
+	pagerDutyActionResolve = "resolve"
+)
+
+// defaultPagerDutySeverities maps the severities of the notifications to the PagerDuty ones
+var defaultPagerDutySeverities = map[string]string{
+	SeverityInfo:    "info",
+	SeverityWarning: "warning",
+	SeverityError:   "error",
+}
+
+// pagerDutyNotifier sends the alerts as PagerDuty events. Plain notifications are ignored,
+// as only alerts can be deduplicated and resolved automatically
+type pagerDutyNotifier struct {
+	routingKey string
+	severities map[string]string
+	client     *http.Client
+}
+
+// pagerDutyEvent is the payload of the PagerDuty Events API v2
+type pagerDutyEvent struct {
+	RoutingKey  string                 `json:"routing_key"`
+	EventAction string                 `json:"event_action"`
+	DedupKey    string                 `json:"dedup_key"`
+	Payload     *pagerDutyEventPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyEventPayload struct {
+	Summary  string `json:"summary"`
+	Source   string `json:"source"`
+	Severity string `json:"severity"`
+}
+
+// newPagerDutyNotifier creates the notifier for the PagerDuty service defined in the channel
+func newPagerDutyNotifier(spec v1alpha1.NotificationChannelSpec, client *http.Client) (*pagerDutyNotifier, error) {
+	if spec.PagerDuty.RoutingKey == "" {
+		return nil, fmt.Errorf("routingKey is required for pagerduty channels")
+	}
+
+	severities := map[string]string{}
+	for severity, pagerDutySeverity := range defaultPagerDutySeverities {
+		severities[severity] = pagerDutySeverity
+	}
+	for severity, pagerDutySeverity := range spec.PagerDuty.Severities {
+		if _, ok := severityLevels[severity]; !ok {
+			return nil, fmt.Errorf("invalid severity %s in pagerduty severities", severity)
+		}
+		severities[severity] = pagerDutySeverity
+	}
+
+	return &pagerDutyNotifier{
+		routingKey: spec.PagerDuty.RoutingKey,
+		severities: severities,
+		client:     client,
+	}, nil
+}
+
+func (n *pagerDutyNotifier) Notify(ctxConn context.Context, notification Notification) error {
+	if notification.AlertKey == "" {
+		return nil
+	}
+
+	event := pagerDutyEvent{
+		RoutingKey:  n.routingKey,
+		EventAction: pagerDutyActionTrigger,
+		DedupKey:    fmt.Sprintf("custom-vm-autoscaler/%s/%s", notification.Autoscaler, notification.AlertKey),
+	}
+	if notification.Resolved {
+		event.EventAction = pagerDutyActionResolve
+	} else {
+		event.Payload = &pagerDutyEventPayload{
+			Summary:  notification.Message,
+			Source:   notification.Autoscaler,
+			Severity: n.severities[notification.Severity],
+		}
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal PagerDuty event: %w", err)
+	}
+
+	res, err := postJSON(ctxConn, n.client, pagerDutyEventsURL, body)
+	if err != nil {
+		return fmt.Errorf("failed to send PagerDuty event: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusAccepted {
+		resBody, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("error sending PagerDuty event: %s: %s", res.Status, resBody)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package notifier
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/version"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	// Colors of the attachments by severity
+	slackColorInfo     = "#2eb886"
+	slackColorWarning  = "#daa038"
+	slackColorError    = "#a30200"
+	slackColorResolved = "#2eb886"
+
+	// slackThreadTTL is the time the first message of an operation is remembered to reply to it
+	slackThreadTTL = 24 * time.Hour
+)
+
+// slackColors maps the severities to the colors of the attachments
+var slackColors = map[string]string{
+	SeverityInfo:    slackColorInfo,
+	SeverityWarning: slackColorWarning,
+	SeverityError:   slackColorError,
+}
+
+// slackThread is the first message posted for an operation, where the follow-ups are replied
+type slackThread struct {
+	timestamp string
+	createdAt time.Time
+}
+
+var (
+	// slackThreads holds the first message of every operation notified, by channel, autoscaler and thread
+	slackThreads = map[string]slackThread{}
+
+	// slackThreadsMutex serializes the accesses to the threads, shared by every autoscaler in the process
+	slackThreadsMutex sync.Mutex
+)
+
+// slackNotifier sends the notifications to a Slack channel as Block Kit messages, using a webhook URL or a bot token.
+// Only bots can reply in threads, so the follow-ups of the operations are only sent using a bot token
+type slackNotifier struct {
+	webhookURL string
+	botToken   string
+	channel    string
+	client     *http.Client
+}
+
+// newSlackNotifier creates the notifier for the Slack webhook or bot defined in the channel
+func newSlackNotifier(spec v1alpha1.NotificationChannelSpec, client *http.Client) (*slackNotifier, error) {
+	if spec.Slack.WebhookURL == "" && spec.Slack.BotToken == "" {
+		return nil, fmt.Errorf("webhookUrl or botToken is required for slack channels")
+	}
+	if spec.Slack.BotToken != "" && spec.Slack.Channel == "" {
+		return nil, fmt.Errorf("channel is required for slack channels using a botToken")
+	}
+
+	return &slackNotifier{
+		webhookURL: spec.Slack.WebhookURL,
+		botToken:   spec.Slack.BotToken,
+		channel:    spec.Slack.Channel,
+		client:     client,
+	}, nil
+}
+
+func (n *slackNotifier) threaded() bool {
+	return n.botToken != ""
+}
+
+func (n *slackNotifier) Notify(ctxConn context.Context, notification Notification) error {
+	attachment := newSlackAttachment(notification)
+
+	if !n.threaded() {
+		// Post the message to Slack using the webhook URL
+		msg := slack.WebhookMessage{
+			Text:        notification.Message,
+			Attachments: []slack.Attachment{attachment},
+		}
+		return slack.PostWebhookCustomHTTPContext(ctxConn, n.webhookURL, n.client, &msg)
+	}
+
+	options := []slack.MsgOption{
+		slack.MsgOptionText(notification.Message, false),
+		slack.MsgOptionAttachments(attachment),
+	}
+
+	// Reply to the first message of the operation, broadcasting the final result to the channel
+	threadKey := n.channel + "/" + notification.Autoscaler + "/" + notification.Thread
+	var thread slackThread
+	if notification.Thread != "" {
+		thread = getSlackThread(threadKey)
+	}
+	if thread.timestamp != "" {
+		options = append(options, slack.MsgOptionTS(thread.timestamp))
+		if !notification.Progress {
+			options = append(options, slack.MsgOptionBroadcast())
+		}
+	}
+
+	_, timestamp, err := slack.New(n.botToken, slack.OptionHTTPClient(n.client)).PostMessageContext(ctxConn, n.channel, options...)
+	if err != nil {
+		return err
+	}
+	if notification.Thread != "" && thread.timestamp == "" {
+		setSlackThread(threadKey, timestamp)
+	}
+	return nil
+}
+
+// newSlackAttachment builds the attachment of the notification, colored by severity and with its fields
+func newSlackAttachment(notification Notification) slack.Attachment {
+	color := slackColors[notification.Severity]
+	title := fmt.Sprintf("*%s* · %s · %s", notification.Autoscaler, notification.Event, notification.Severity)
+	if notification.Resolved {
+		color = slackColorResolved
+		title = fmt.Sprintf("*%s* · %s · resolved", notification.Autoscaler, notification.Event)
+	}
+
+	// The build is shown so the decisions can be traced to the version of the autoscaler taking them
+	blocks := []slack.Block{
+		slack.NewContextBlock("",
+			slack.NewTextBlockObject(slack.MarkdownType, title, false, false),
+			slack.NewTextBlockObject(slack.MarkdownType, "build "+version.String(), false, false),
+		),
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, notification.Message, false, false), nil, nil),
+	}
+	if len(notification.Fields) > 0 {
+		fields := make([]*slack.TextBlockObject, 0, len(notification.Fields))
+		for _, field := range notification.Fields {
+			fields = append(fields, slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*%s*\n%s", field.Name, field.Value), false, false))
+		}
+		blocks = append(blocks, slack.NewSectionBlock(nil, fields, nil))
+	}
+
+	return slack.Attachment{
+		Color:    color,
+		Fallback: notification.Message,
+		Blocks:   slack.Blocks{BlockSet: blocks},
+	}
+}
+
+// getSlackThread returns the first message posted for the operation, if any
+func getSlackThread(key string) slackThread {
+	slackThreadsMutex.Lock()
+	defer slackThreadsMutex.Unlock()
+	return slackThreads[key]
+}
+
+// setSlackThread remembers the first message posted for the operation, forgetting the expired ones
+func setSlackThread(key, timestamp string) {
+	slackThreadsMutex.Lock()
+	defer slackThreadsMutex.Unlock()
+
+	for k, thread := range slackThreads {
+		if time.Since(thread.createdAt) > slackThreadTTL {
+			delete(slackThreads, k)
+		}
+	}
+	slackThreads[key] = slackThread{timestamp: timestamp, createdAt: time.Now()}
+}
@@ -0,0 +1,56 @@
+package notifier
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// telegramAPIURL is the endpoint of the Telegram Bot API to send messages
+const telegramAPIURL = "https://api.telegram.org/bot%s/sendMessage"
+
+// telegramNotifier sends the notifications to a Telegram chat using a bot
+type telegramNotifier struct {
+	botToken string
+	chatID   string
+	client   *http.Client
+}
+
+// newTelegramNotifier creates the notifier for the Telegram chat defined in the channel
+func newTelegramNotifier(spec v1alpha1.NotificationChannelSpec, client *http.Client) (*telegramNotifier, error) {
+	if spec.Telegram.BotToken == "" || spec.Telegram.ChatID == "" {
+		return nil, fmt.Errorf("botToken and chatID are required for telegram channels")
+	}
+
+	return &telegramNotifier{
+		botToken: spec.Telegram.BotToken,
+		chatID:   spec.Telegram.ChatID,
+		client:   client,
+	}, nil
+}
+
+func (n *telegramNotifier) Notify(ctxConn context.Context, notification Notification) error {
+	body, err := json.Marshal(map[string]string{
+		"chat_id": n.chatID,
+		"text":    notification.Message,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal Telegram message: %w", err)
+	}
+
+	res, err := postJSON(ctxConn, n.client, fmt.Sprintf(telegramAPIURL, n.botToken), body)
+	if err != nil {
+		// The URL includes the token of the bot, so it is not included in the error
+		return fmt.Errorf("failed to send Telegram message")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		resBody, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("error sending Telegram message: %s: %s", res.Status, resBody)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/retry"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// webhookSignatureHeader holds the HMAC-SHA256 of the body, signed with the secret of the channel
+	webhookSignatureHeader = "X-Autoscaler-Signature"
+
+	// defaultWebhookMaxRetries is the number of retries when the webhook fails and no other is configured
+	defaultWebhookMaxRetries = 3
+
+	// webhookRetryInterval is the time to wait before the first retry, doubled on every retry
+	webhookRetryInterval = time.Second
+)
+
+// webhookNotifier sends the notifications as JSON payloads to a URL defined by the user
+type webhookNotifier struct {
+	url        string
+	secret     string
+	maxRetries int
+	client     *http.Client
+}
+
+// webhookPayload is the body posted to the webhook for every notification
+type webhookPayload struct {
+	Time       time.Time `json:"time"`
+	Autoscaler string    `json:"autoscaler"`
+	Severity   string    `json:"severity"`
+	Event      string    `json:"event"`
+	Message    string    `json:"message"`
+	AlertKey   string    `json:"alertKey,omitempty"`
+	Resolved   bool      `json:"resolved,omitempty"`
+}
+
+// newWebhookNotifier creates the notifier for the webhook defined in the channel
+func newWebhookNotifier(spec v1alpha1.NotificationChannelSpec, client *http.Client) (*webhookNotifier, error) {
+	if spec.Webhook.URL == "" {
+		return nil, fmt.Errorf("url is required for webhook channels")
+	}
+
+	maxRetries := spec.Webhook.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultWebhookMaxRetries
+	}
+
+	return &webhookNotifier{
+		url:        spec.Webhook.URL,
+		secret:     spec.Webhook.Secret,
+		maxRetries: maxRetries,
+		client:     client,
+	}, nil
+}
+
+// Notify posts the notification, retrying with exponential backoff on connection errors and 5xx or 429 responses
+func (n *webhookNotifier) Notify(ctxConn context.Context, notification Notification) error {
+	body, err := json.Marshal(webhookPayload{
+		Time:       time.Now(),
+		Autoscaler: notification.Autoscaler,
+		Severity:   notification.Severity,
+		Event:      notification.Event,
+		Message:    notification.Message,
+		AlertKey:   notification.AlertKey,
+		Resolved:   notification.Resolved,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	retryInterval := webhookRetryInterval
+	for attempt := 0; ; attempt++ {
+		var retryable bool
+		retryable, err = n.post(ctxConn, body)
+		if err == nil || !retryable || attempt >= n.maxRetries {
+			return err
+		}
+
+		if sleepErr := retry.Sleep(ctxConn, retryInterval); sleepErr != nil {
+			return err
+		}
+		retryInterval *= 2
+	}
+}
+
+// post sends the body once, returning whether the error can be retried
+func (n *webhookNotifier) post(ctxConn context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctxConn, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Sign the body, so the receiver can verify the notification comes from the autoscaler
+	if n.secret != "" {
+		mac := hmac.New(sha256.New, []byte(n.secret))
+		mac.Write(body)
+		req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to call webhook: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		retryable := res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests
+		return retryable, fmt.Errorf("webhook responded with status %s", res.Status)
+	}
+	return false, nil
+}
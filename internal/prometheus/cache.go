@@ -0,0 +1,60 @@
+package prometheus
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cachedQuery is the result of an instant query executed before, and when it was executed
+type cachedQuery struct {
+	met       bool
+	samples   []v1alpha1.MetricSample
+	fetchedAt time.Time
+}
+
+var (
+	// queryCache holds the results of the instant queries, by server, headers and query
+	queryCache = map[string]cachedQuery{}
+
+	// queryCacheMutex serializes the accesses to the cached results
+	queryCacheMutex sync.Mutex
+)
+
+// queryCacheKey identifies the query sent to the Prometheus server of the config. Headers are part of the key,
+// as they can select the tenant of the server
+func queryCacheKey(config *v1alpha1.ConfigSpec, query string) string {
+	headers := make([]string, 0, len(config.Metrics.Prometheus.Headers))
+	for name, value := range config.Metrics.Prometheus.Headers {
+		headers = append(headers, name+"="+value)
+	}
+	sort.Strings(headers)
+	return config.Metrics.Prometheus.URL + "\n" + strings.Join(headers, "\n") + "\n" + query
+}
+
+// getCachedQuery returns the result of the query, when it was executed less than ttl ago
+func getCachedQuery(key string, ttl time.Duration) (cachedQuery, bool) {
+	queryCacheMutex.Lock()
+	defer queryCacheMutex.Unlock()
+
+	cached, ok := queryCache[key]
+	if !ok || time.Since(cached.fetchedAt) >= ttl {
+		return cachedQuery{}, false
+	}
+	return cached, true
+}
+
+// setCachedQuery stores the result of the query, forgetting the results older than ttl so the cache does not grow
+func setCachedQuery(key string, ttl time.Duration, result cachedQuery) {
+	queryCacheMutex.Lock()
+	defer queryCacheMutex.Unlock()
+
+	for cachedKey, cached := range queryCache {
+		if time.Since(cached.fetchedAt) >= ttl {
+			delete(queryCache, cachedKey)
+		}
+	}
+	queryCache[key] = result
+}
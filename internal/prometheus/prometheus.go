@@ -3,9 +3,18 @@ package prometheus
 import (
 	"context"
 	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/decision"
+	"custom-vm-autoscaler/internal/elasticsearch"
+	"custom-vm-autoscaler/internal/proxy"
+	"custom-vm-autoscaler/internal/retry"
+	"custom-vm-autoscaler/internal/tlsconfig"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/api"
@@ -33,37 +42,64 @@ func (t *customTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 // prometheusURL: The URL of the Prometheus server.
 // prometheusCondition: The Prometheus query condition to be evaluated.
 func GetPrometheusCondition(prometheusCondition string, ctx *v1alpha1.Context) (bool, error) {
+	conditionMet, _, err := GetPrometheusConditionValues(prometheusCondition, ctx)
+	return conditionMet, err
+}
 
-	// Create a custom HTTP client with the custom transport
-	httpClient := &http.Client{
-		Timeout: 10 * time.Second,
-		Transport: &customTransport{
-			Transport: http.DefaultTransport,
-			Config:    ctx.Config},
+// maxFormattedSamples is the number of samples shown in the logs and notifications
+const maxFormattedSamples = 10
+
+// GetPrometheusConditionValues executes a Prometheus query and checks if the condition is true.
+// It also returns the values of the samples returned by the query, so they can be audited.
+func GetPrometheusConditionValues(prometheusCondition string, ctx *v1alpha1.Context) (bool, []float64, error) {
+	conditionMet, samples, err := GetPrometheusConditionSamples(prometheusCondition, ctx)
+	return conditionMet, SampleValues(samples), err
+}
+
+// GetPrometheusConditionSamples executes a Prometheus query and checks if the condition is true.
+// It also returns the samples returned by the query with their labels, explaining the decisions taken.
+// Results are reused for cacheTtlSec by every autoscaler querying the same server, when it is set
+func GetPrometheusConditionSamples(prometheusCondition string, ctx *v1alpha1.Context) (bool, []v1alpha1.MetricSample, error) {
+	ttl := time.Duration(ctx.Config.Metrics.Prometheus.CacheTTLSec) * time.Second
+	if ttl <= 0 {
+		return queryConditionSamples(prometheusCondition, ctx)
 	}
 
-	// Create a Prometheus API client
-	client, err := api.NewClient(api.Config{
-		Address: ctx.Config.Metrics.Prometheus.URL, // Set the Prometheus server address
-		Client:  httpClient,
-	})
+	key := queryCacheKey(ctx.Config, prometheusCondition)
+	if cached, ok := getCachedQuery(key, ttl); ok {
+		return cached.met, cached.samples, nil
+	}
+	met, samples, err := queryConditionSamples(prometheusCondition, ctx)
 	if err != nil {
-		// Return an error if the client fails to be created
-		return false, fmt.Errorf("failed to create Prometheus client: %w", err)
+		return false, nil, err
 	}
+	setCachedQuery(key, ttl, cachedQuery{met: met, samples: samples, fetchedAt: time.Now()})
+	return met, samples, nil
+}
+
+// queryConditionSamples executes the instant query of the condition, returning whether it is met and its samples
+func queryConditionSamples(prometheusCondition string, ctx *v1alpha1.Context) (bool, []v1alpha1.MetricSample, error) {
 
 	// Create a new Prometheus v1 API instance
-	v1api := v1.NewAPI(client)
+	v1api, err := newPrometheusAPI(ctx)
+	if err != nil {
+		return false, nil, err
+	}
 
-	// Set a timeout context for the query
-	ctxConn, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel() // Ensure that the context is canceled after query execution
+	// Execute the Prometheus query, retrying it when it fails
+	var result model.Value
+	var warnings v1.Warnings
+	err = retry.Do(ctx.ConnContext(), retry.NewPolicy(ctx.Config), "Prometheus query", func() error {
+		// Set a timeout context for every attempt of the query
+		ctxConn, cancel := context.WithTimeout(ctx.ConnContext(), time.Duration(ctx.Config.Metrics.Prometheus.TimeoutSec)*time.Second)
+		defer cancel() // Ensure that the context is canceled after query execution
 
-	// Execute the Prometheus query
-	result, warnings, err := v1api.Query(ctxConn, prometheusCondition, time.Now())
+		result, warnings, err = v1api.Query(ctxConn, prometheusCondition, time.Now())
+		return retryableError(err)
+	})
 	if err != nil {
 		// Return an error if the query fails
-		return false, fmt.Errorf("failed to query Prometheus: %w", err)
+		return false, nil, fmt.Errorf("failed to query Prometheus: %w", err)
 	}
 	if len(warnings) > 0 {
 		// Log any warnings returned by the Prometheus query
@@ -72,16 +108,265 @@ func GetPrometheusCondition(prometheusCondition string, ctx *v1alpha1.Context) (
 	// Check if the result is a vector (expected format)
 	if result.Type() == model.ValVector {
 		vector := result.(model.Vector)
-		// If the vector has results, check the first value
-		if len(vector) > 0 {
-			// Return true if vector has any value, which indicates the condition is met
-			return true, nil
-		} else {
-			// No values returned, so the condition is not met
-			return false, nil
+		samples := make([]v1alpha1.MetricSample, 0, len(vector))
+		for _, sample := range vector {
+			labels := make(map[string]string, len(sample.Metric))
+			for name, value := range sample.Metric {
+				labels[string(name)] = string(value)
+			}
+			samples = append(samples, v1alpha1.MetricSample{Labels: labels, Value: float64(sample.Value)})
 		}
+		// Return true if vector has any value, which indicates the condition is met
+		return len(vector) > 0, samples, nil
 	}
 
 	// Return an error if the result type is unexpected
-	return false, fmt.Errorf("unexpected result type from Prometheus: %v", result.Type())
+	return false, nil, fmt.Errorf("unexpected result type from Prometheus: %v", result.Type())
+}
+
+// GetFirstConditionMet evaluates the conditions in order until one of them is met, returning it with its samples.
+// When none is met, the first condition is returned with its samples, so the decision can be explained
+func GetFirstConditionMet(conditions []decision.Condition, ctx *v1alpha1.Context) (decision.Condition, bool, []v1alpha1.MetricSample, error) {
+	var first decision.Condition
+	var firstSamples []v1alpha1.MetricSample
+	warming := getWarmingNodes(ctx, conditions)
+	for i, condition := range conditions {
+		met, samples, err := GetPrometheusConditionSamples(resolveWarmingNodes(condition.Query, warming), ctx)
+		if err != nil {
+			return condition, false, nil, fmt.Errorf("condition %s: %w", condition.Name, err)
+		}
+		if met {
+			return condition, true, samples, nil
+		}
+		if i == 0 {
+			first, firstSamples = condition, samples
+		}
+	}
+	return first, false, firstSamples, nil
+}
+
+// WarmingNodesPlaceholder is replaced in the queries of the conditions by a regular expression matching the names of
+// the Elasticsearch nodes warming up, like in avg(cpu_usage{node!~"{{warmingNodes}}"}), so they are excluded
+const WarmingNodesPlaceholder = "{{warmingNodes}}"
+
+// getWarmingNodes returns the Elasticsearch nodes started less than warmupExclusion.periodSec ago, when any of the
+// conditions excludes them. Errors are logged and no node is excluded, as the conditions must still be evaluated
+func getWarmingNodes(ctx *v1alpha1.Context, conditions []decision.Condition) []string {
+	period := time.Duration(ctx.Config.Metrics.Prometheus.WarmupExclusion.PeriodSec) * time.Second
+	if period <= 0 || ctx.Config.Target.Elasticsearch.URL == "" ||
+		!slices.ContainsFunc(conditions, func(c decision.Condition) bool { return strings.Contains(c.Query, WarmingNodesPlaceholder) }) {
+		return nil
+	}
+	warming, err := elasticsearch.GetWarmingNodes(ctx, period)
+	if err != nil {
+		log.Printf("Error getting the Elasticsearch nodes warming up, none is excluded from the conditions: %v", err)
+		return nil
+	}
+	if len(warming) > 0 {
+		log.Printf("Excluding the nodes warming up from the conditions: %s", strings.Join(warming, ", "))
+	}
+	return warming
+}
+
+// resolveWarmingNodes replaces the placeholder of the query by an alternation of the nodes, escaped for a regular
+// expression within a PromQL string. Without nodes it is replaced by an empty string, matching only the series
+// without the label
+func resolveWarmingNodes(query string, nodes []string) string {
+	if !strings.Contains(query, WarmingNodesPlaceholder) {
+		return query
+	}
+	escaped := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		escaped = append(escaped, strings.ReplaceAll(regexp.QuoteMeta(node), `\`, `\\`))
+	}
+	return strings.ReplaceAll(query, WarmingNodesPlaceholder, strings.Join(escaped, "|"))
+}
+
+// SampleValues returns the values of the samples, without their labels
+func SampleValues(samples []v1alpha1.MetricSample) []float64 {
+	if samples == nil {
+		return nil
+	}
+	values := make([]float64, 0, len(samples))
+	for _, sample := range samples {
+		values = append(values, sample.Value)
+	}
+	return values
+}
+
+// FormatSamples formats the samples with their labels as Prometheus shows them, like: up{job="es"} = 1.
+// Only the first samples are shown, counting the rest
+func FormatSamples(samples []v1alpha1.MetricSample) string {
+	if len(samples) == 0 {
+		return "no samples"
+	}
+
+	formatted := make([]string, 0, min(len(samples), maxFormattedSamples)+1)
+	for i, sample := range samples {
+		if i == maxFormattedSamples {
+			formatted = append(formatted, fmt.Sprintf("and %d more", len(samples)-maxFormattedSamples))
+			break
+		}
+		metric := model.Metric{}
+		for name, value := range sample.Labels {
+			metric[model.LabelName(name)] = model.LabelValue(value)
+		}
+		formatted = append(formatted, fmt.Sprintf("%s = %g", metric.String(), sample.Value))
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// ConditionSample is the result of a condition evaluated at one step of a range query
+type ConditionSample struct {
+	Time   time.Time
+	Values []float64
+}
+
+// GetPrometheusConditionRange executes a Prometheus range query, evaluating the condition at every step between
+// start and end. One sample is returned per step, the condition being met at the steps with values
+func GetPrometheusConditionRange(prometheusCondition string, ctx *v1alpha1.Context, start, end time.Time, step time.Duration) ([]ConditionSample, error) {
+	v1api, err := newPrometheusAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.Value
+	var warnings v1.Warnings
+	err = retry.Do(ctx.ConnContext(), retry.NewPolicy(ctx.Config), "Prometheus range query", func() error {
+		ctxConn, cancel := context.WithTimeout(ctx.ConnContext(), time.Duration(ctx.Config.Metrics.Prometheus.TimeoutSec)*time.Second)
+		defer cancel()
+
+		// The nodes warming up in the past are not known, so none is excluded
+		result, warnings, err = v1api.QueryRange(ctxConn, resolveWarmingNodes(prometheusCondition, nil), v1.Range{Start: start, End: end, Step: step})
+		return retryableError(err)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Prometheus: %w", err)
+	}
+	if len(warnings) > 0 {
+		log.Println("Warnings:", warnings)
+	}
+	if result.Type() != model.ValMatrix {
+		return nil, fmt.Errorf("unexpected result type from Prometheus: %v", result.Type())
+	}
+
+	// Group the values of every series by the step they were evaluated at
+	values := map[int64][]float64{}
+	for _, series := range result.(model.Matrix) {
+		for _, pair := range series.Values {
+			values[pair.Timestamp.Unix()] = append(values[pair.Timestamp.Unix()], float64(pair.Value))
+		}
+	}
+
+	var samples []ConditionSample
+	for t := start; !t.After(end); t = t.Add(step) {
+		samples = append(samples, ConditionSample{Time: t, Values: values[t.Unix()]})
+	}
+	return samples, nil
+}
+
+// CheckPrometheus checks that the Prometheus server is reachable executing a trivial query
+func CheckPrometheus(ctx *v1alpha1.Context) error {
+	v1api, err := newPrometheusAPI(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctxConn, cancel := context.WithTimeout(ctx.ConnContext(), time.Duration(ctx.Config.Metrics.Prometheus.TimeoutSec)*time.Second)
+	defer cancel()
+
+	_, _, err = v1api.Query(ctxConn, "vector(1)", time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to query Prometheus: %w", err)
+	}
+	return nil
+}
+
+// retryableError marks the errors caused by invalid queries as permanent, as retrying them is useless
+func retryableError(err error) error {
+	var apiError *v1.Error
+	if errors.As(err, &apiError) && apiError.Type == v1.ErrBadData {
+		return retry.Permanent(err)
+	}
+	return err
+}
+
+// newPrometheusAPI creates a Prometheus v1 API client sending the headers defined in the config
+func newPrometheusAPI(ctx *v1alpha1.Context) (v1.API, error) {
+
+	transport, err := proxy.Transport(ctx.Config.Metrics.Prometheus.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	prometheusConfig := ctx.Config.Metrics.Prometheus
+	transport.TLSClientConfig, err = tlsconfig.New(prometheusConfig.TLSMinVersion, prometheusConfig.TLSMaxVersion, prometheusConfig.TLSCipherSuites, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a custom HTTP client with the custom transport
+	httpClient := &http.Client{
+		Timeout: time.Duration(ctx.Config.Metrics.Prometheus.TimeoutSec) * time.Second,
+		Transport: &customTransport{
+			Transport: transport,
+			Config:    ctx.Config},
+	}
+
+	// Create a Prometheus API client
+	client, err := api.NewClient(api.Config{
+		Address: ctx.Config.Metrics.Prometheus.URL, // Set the Prometheus server address
+		Client:  httpClient,
+	})
+	if err != nil {
+		// Return an error if the client fails to be created
+		return nil, fmt.Errorf("failed to create Prometheus client: %w", err)
+	}
+
+	return v1.NewAPI(client), nil
+}
+
+// ValidateQuery checks the basic syntax of a PromQL query without a Prometheus server:
+// it must not be empty, and its parentheses, brackets, braces and quotes must be balanced
+func ValidateQuery(query string) error {
+	if strings.TrimSpace(query) == "" {
+		return fmt.Errorf("query is empty")
+	}
+
+	closing := map[rune]rune{')': '(', ']': '[', '}': '{'}
+	var open []rune
+	var quote rune
+	escaped := false
+	for i, char := range query {
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case char == '\\' && quote != '`':
+				escaped = true
+			case char == quote:
+				quote = 0
+			}
+			continue
+		}
+
+		switch char {
+		case '"', '\'', '`':
+			quote = char
+		case '(', '[', '{':
+			open = append(open, char)
+		case ')', ']', '}':
+			if len(open) == 0 || open[len(open)-1] != closing[char] {
+				return fmt.Errorf("unexpected %q at position %d", char, i)
+			}
+			open = open[:len(open)-1]
+		}
+	}
+
+	if quote != 0 {
+		return fmt.Errorf("unclosed string starting with %q", quote)
+	}
+	if len(open) > 0 {
+		return fmt.Errorf("unclosed %q", open[len(open)-1])
+	}
+	return nil
 }
@@ -0,0 +1,59 @@
+// Package proxy builds the transports of the HTTP clients talking to Elasticsearch, Prometheus and the
+// notification channels, so they reach them through a proxy when the network requires it
+package proxy
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// schemes are the schemes of the proxies supported by the HTTP transport
+var schemes = []string{"http", "https", "socks5"}
+
+// Validate checks that the URL of the proxy, if any, is absolute and has a supported scheme
+func Validate(spec v1alpha1.ProxySpec) error {
+	if spec.URL == "" {
+		return nil
+	}
+	proxyURL, err := url.Parse(spec.URL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy url %q: %w", spec.URL, err)
+	}
+	if !slices.Contains(schemes, proxyURL.Scheme) || proxyURL.Host == "" {
+		return fmt.Errorf("invalid proxy url %q, expected <scheme>://<host>[:<port>] with scheme http, https or socks5", spec.URL)
+	}
+	return nil
+}
+
+// Func returns the function choosing the proxy of every request. Without a proxy URL, the HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY environment variables are honored, as the default transport does
+func Func(spec v1alpha1.ProxySpec) (func(*http.Request) (*url.URL, error), error) {
+	if spec.URL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	err := Validate(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	proxyFunc := (&httpproxy.Config{HTTPProxy: spec.URL, HTTPSProxy: spec.URL, NoProxy: spec.NoProxy}).ProxyFunc()
+	return func(r *http.Request) (*url.URL, error) {
+		return proxyFunc(r.URL)
+	}, nil
+}
+
+// Transport returns a copy of the default transport sending the requests through the proxy
+func Transport(spec v1alpha1.ProxySpec) (*http.Transport, error) {
+	proxyFunc, err := Func(spec)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc
+	return transport, nil
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket allowing rate requests per second on average, with bursts of up to burst requests.
+// It is safe for concurrent use, so it can be shared by every autoscaler in the process
+type Limiter struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// New creates a limiter starting with its bucket full
+func New(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a request is allowed, or the context is done. A nil limiter allows every request
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	// Refill the bucket for the time elapsed and reserve a token, which may leave it in debt
+	l.mutex.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	wait := time.Duration(0)
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mutex.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+
+	// Give the token back when the request cannot wait for it
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+		l.release()
+		return fmt.Errorf("rate limit exceeded, waiting %s would exceed the deadline", wait.Round(time.Millisecond))
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.release()
+		return ctx.Err()
+	}
+}
+
+// release returns a reserved token to the bucket
+func (l *Limiter) release() {
+	l.mutex.Lock()
+	l.tokens = min(l.burst, l.tokens+1)
+	l.mutex.Unlock()
+}
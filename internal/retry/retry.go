@@ -0,0 +1,105 @@
+package retry
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Policy defines how many times and how often a failed call is retried
+type Policy struct {
+	MaxAttempts     int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+}
+
+// permanentError wraps the errors that must not be retried
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks the error as not retryable, e.g. when the request is invalid
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// NewPolicy returns the retry policy defined in the config of the autoscaler
+func NewPolicy(config *v1alpha1.ConfigSpec) Policy {
+	return Policy{
+		MaxAttempts:     config.Retry.MaxAttempts,
+		InitialInterval: time.Duration(config.Retry.InitialIntervalSec) * time.Second,
+		MaxInterval:     time.Duration(config.Retry.MaxIntervalSec) * time.Second,
+		Multiplier:      config.Retry.Multiplier,
+	}
+}
+
+// Do executes the call until it succeeds, it fails with a permanent error or the attempts are exhausted,
+// waiting an exponential backoff with jitter between the attempts. The last error is returned, or the error
+// of the context when it is cancelled while waiting
+func Do(ctxConn context.Context, policy Policy, name string, call func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = call()
+		if err == nil {
+			return nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if attempt >= policy.MaxAttempts {
+			return err
+		}
+
+		delay := Backoff(policy, attempt)
+		log.Printf("Error in %s (attempt %d/%d), retrying in %s: %v", name, attempt, policy.MaxAttempts, delay.Round(time.Millisecond), err)
+		if sleepErr := Sleep(ctxConn, delay); sleepErr != nil {
+			return fmt.Errorf("%w, last error: %v", sleepErr, err)
+		}
+	}
+}
+
+// Sleep waits for the given duration, returning the error of the context when it is cancelled before
+func Sleep(ctxConn context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctxConn.Done():
+		return ctxConn.Err()
+	}
+}
+
+// Backoff returns the time to wait after the given failed attempt, starting at 1: the initial interval multiplied
+// on every attempt, up to the maximum interval, with a random jitter of up to half of it
+func Backoff(policy Policy, attempt int) time.Duration {
+	multiplier := max(policy.Multiplier, 1)
+	delay := float64(policy.InitialInterval) * math.Pow(multiplier, float64(max(attempt, 1)-1))
+	if policy.MaxInterval > 0 && delay > float64(policy.MaxInterval) {
+		delay = float64(policy.MaxInterval)
+	}
+
+	// Spread the retries of the autoscalers failing at the same time
+	jitter := rand.Float64() * delay / 2
+	return time.Duration(delay - jitter)
+}
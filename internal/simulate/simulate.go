@@ -0,0 +1,226 @@
+package simulate
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/decision"
+	"custom-vm-autoscaler/internal/maintenance"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Evaluation is the result of the scaling conditions at a moment of the replayed period
+type Evaluation struct {
+	Time          time.Time
+	UpCondition   bool
+	DownCondition bool
+
+	// UpStep and DownStep are the steps of the conditions met, 0 when the default steps apply
+	UpStep   int32
+	DownStep int32
+
+	// Up is the up condition met, and UpValues its values, sizing the step when the condition has a threshold
+	Up       decision.Condition
+	UpValues []float64
+}
+
+// Action is a scaling action the autoscaler would have taken
+type Action struct {
+	Time         time.Time
+	Action       string
+	PreviousSize int32
+	Size         int32
+
+	// Flapping is set when the action reverts the previous one within the flapping window
+	Flapping bool
+}
+
+// Result summarizes the scaling actions the autoscaler would have taken over the replayed period
+type Result struct {
+	Start       time.Time
+	End         time.Time
+	Evaluations int
+	Actions     []Action
+	ScaleUps    int
+	ScaleDowns  int
+	Flaps       int
+	NodeHours   float64
+	MinSize     int32
+	MaxSize     int32
+}
+
+// Run replays the evaluations through the decision logic of the autoscaler, starting with the given size.
+// Time is simulated: after every evaluation the next one happens once the cooldown or evaluation interval elapsed,
+// using the last evaluation recorded at that moment. Scaling actions are assumed to complete instantly
+func Run(ctx *v1alpha1.Context, evaluations []Evaluation, initialSize int32, flapWindow time.Duration) (Result, error) {
+	if len(evaluations) == 0 {
+		return Result{}, fmt.Errorf("no evaluations to replay")
+	}
+	sort.Slice(evaluations, func(i, j int) bool { return evaluations[i].Time.Before(evaluations[j].Time) })
+
+	result := Result{
+		Start:   evaluations[0].Time,
+		End:     evaluations[len(evaluations)-1].Time,
+		MinSize: initialSize,
+		MaxSize: initialSize,
+	}
+	size := initialSize
+	var lastAction *Action
+	var lastScaleUp time.Time
+	warmup := time.Duration(ctx.Config.Autoscaler.Warmup.PeriodSec) * time.Second
+
+	// record applies a scaling action, detecting when it reverts the previous one too soon
+	record := func(t time.Time, action string, newSize int32) {
+		a := Action{Time: t, Action: action, PreviousSize: size, Size: newSize}
+		if lastAction != nil && lastAction.Action != action && t.Sub(lastAction.Time) <= flapWindow {
+			a.Flapping = true
+			result.Flaps++
+		}
+		if action == v1alpha1.DecisionScaleUp {
+			result.ScaleUps++
+			lastScaleUp = t
+		} else {
+			result.ScaleDowns++
+		}
+		result.Actions = append(result.Actions, a)
+		lastAction = &result.Actions[len(result.Actions)-1]
+		size = newSize
+		result.MinSize = min(result.MinSize, size)
+		result.MaxSize = max(result.MaxSize, size)
+	}
+
+	current := 0
+	for t := result.Start; !t.After(result.End); {
+
+		// Use the last evaluation recorded at this moment
+		for current+1 < len(evaluations) && !evaluations[current+1].Time.After(t) {
+			current++
+		}
+		evaluation := evaluations[current]
+		result.Evaluations++
+
+		limits := decision.GetLimits(ctx.Config, t)
+		wait := ctx.Config.Autoscaler.EvaluationIntervalSec
+
+		maintenanceWindow, err := maintenance.GetActiveWindowAt(ctx, t)
+		if err != nil {
+			return result, fmt.Errorf("error checking maintenance windows: %v", err)
+		}
+
+		upStep := evaluation.UpStep
+		if evaluation.Up.Threshold > 0 {
+			upStep = evaluation.Up.ObservedStep(size, limits.MaxSize, evaluation.UpValues)
+		}
+
+		// Nodes warming up are assumed to join the Elasticsearch cluster by the end of the warm-up period
+		input := decision.Input{
+			Now:               t,
+			MaintenanceWindow: maintenanceWindow,
+			UpCondition:       evaluation.UpCondition,
+			DownCondition:     evaluation.DownCondition,
+			Size:              size,
+			Limits:            limits.WithUpStep(upStep).WithDownStep(evaluation.DownStep),
+		}
+		if !lastScaleUp.IsZero() && t.Sub(lastScaleUp) < warmup {
+			input.Guard = decision.Guard{Trigger: decision.TriggerWarmup, Reason: "the new nodes are warming up"}
+		}
+		taken := decision.Decide(ctx.Config, input)
+
+		switch {
+		case maintenanceWindow != nil && maintenanceWindow.Mode == maintenance.ModeBlock:
+
+		// The MIG is scaled up to its minimum size before checking the conditions
+		case size < limits.MinSize:
+			record(t, v1alpha1.DecisionScaleUp, limits.MinSize)
+			wait = ctx.Config.Autoscaler.DefaultCooldownPeriodSec
+
+		case taken.Action == v1alpha1.DecisionScaleUp:
+			newSize, _ := decision.ScaleUpSize(size, input.Limits)
+			record(t, v1alpha1.DecisionScaleUp, newSize)
+			wait = taken.CooldownSec
+
+		// The nodes of a step are removed one by one until the minimum size is reached
+		case taken.Action == v1alpha1.DecisionScaleDown:
+			newSize, _ := decision.ScaleDownSize(size, input.Limits)
+			record(t, v1alpha1.DecisionScaleDown, max(newSize, limits.MinSize))
+			wait = taken.CooldownSec
+		}
+
+		next := t.Add(time.Duration(wait) * time.Second)
+		if next.After(result.End) {
+			next = result.End.Add(time.Second)
+			result.NodeHours += float64(size) * result.End.Sub(t).Hours()
+		} else {
+			result.NodeHours += float64(size) * next.Sub(t).Hours()
+		}
+		t = next
+	}
+
+	return result, nil
+}
+
+// ReadCSV reads the evaluations from a CSV file with the columns time, up and down. Time is in RFC3339 format
+// or a Unix timestamp, and the conditions are met when their value is a number different from 0 or true.
+// A header row is skipped
+func ReadCSV(reader io.Reader) ([]Evaluation, error) {
+	rows, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var evaluations []Evaluation
+	for i, row := range rows {
+		if len(row) != 3 {
+			return nil, fmt.Errorf("line %d: expected 3 columns (time, up, down), got %d", i+1, len(row))
+		}
+		if i == 0 && strings.EqualFold(strings.TrimSpace(row[0]), "time") {
+			continue
+		}
+
+		evaluationTime, err := parseTime(strings.TrimSpace(row[0]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		up, err := parseCondition(strings.TrimSpace(row[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid up value: %v", i+1, err)
+		}
+		down, err := parseCondition(strings.TrimSpace(row[2]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid down value: %v", i+1, err)
+		}
+		evaluations = append(evaluations, Evaluation{Time: evaluationTime, UpCondition: up, DownCondition: down})
+	}
+	return evaluations, nil
+}
+
+// parseTime parses a time in RFC3339 format or as a Unix timestamp
+func parseTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected RFC3339 or a Unix timestamp", value)
+	}
+	return t.UTC(), nil
+}
+
+// parseCondition parses whether a condition is met. Empty values mean the condition returned no samples
+func parseCondition(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	if met, err := strconv.ParseBool(value); err == nil {
+		return met, nil
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false, err
+	}
+	return number != 0, nil
+}
@@ -0,0 +1,82 @@
+package state
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// fileStore persists the state of every autoscaler in a single local JSON file
+type fileStore struct {
+	path string
+}
+
+// newFileStore creates a store backed by the local file defined in the config
+func newFileStore(config *v1alpha1.ConfigSpec) (*fileStore, error) {
+	if config.State.File.Path == "" {
+		return nil, fmt.Errorf("path is required for file state backend")
+	}
+
+	err := os.MkdirAll(filepath.Dir(config.State.File.Path), 0o750)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	return &fileStore{path: config.State.File.Path}, nil
+}
+
+// readAll reads the states of every autoscaler from the file
+func (s *fileStore) readAll() (map[string]v1alpha1.AutoscalerState, error) {
+	states := map[string]v1alpha1.AutoscalerState{}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return states, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &states)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode state file: %w", err)
+	}
+	return states, nil
+}
+
+func (s *fileStore) load(name string) (*v1alpha1.AutoscalerState, error) {
+	states, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+
+	state, ok := states[name]
+	if !ok {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+// save writes the state to a temporary file, renamed afterwards, so a crash never leaves a corrupted file
+func (s *fileStore) save(name string, state v1alpha1.AutoscalerState) error {
+	states, err := s.readAll()
+	if err != nil {
+		return err
+	}
+	states[name] = state
+
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	err = os.WriteFile(tmpPath, data, 0o640)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
@@ -0,0 +1,87 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// gcsStore persists the state of every autoscaler in its own GCS object
+type gcsStore struct {
+	service *storage.Service
+	bucket  string
+	prefix  string
+}
+
+// newGCSStore creates a store backed by the GCS bucket defined in the config
+func newGCSStore(config *v1alpha1.ConfigSpec) (*gcsStore, error) {
+	gcs := config.State.GCS
+	if gcs.Bucket == "" {
+		return nil, fmt.Errorf("bucket is required for gcs state backend")
+	}
+
+	var opts []option.ClientOption
+	if gcs.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(gcs.CredentialsFile))
+	}
+
+	service, err := storage.NewService(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	return &gcsStore{service: service, bucket: gcs.Bucket, prefix: gcs.Prefix}, nil
+}
+
+// objectName returns the name of the object holding the state of the autoscaler
+func (s *gcsStore) objectName(name string) string {
+	return path.Join(s.prefix, name+".json")
+}
+
+func (s *gcsStore) load(name string) (*v1alpha1.AutoscalerState, error) {
+	ctxConn, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := s.service.Objects.Get(s.bucket, s.objectName(name)).Context(ctxConn).Download()
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var state v1alpha1.AutoscalerState
+	err = json.NewDecoder(res.Body).Decode(&state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode state object: %w", err)
+	}
+	return &state, nil
+}
+
+func (s *gcsStore) save(name string, state v1alpha1.AutoscalerState) error {
+	ctxConn, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	_, err = s.service.Objects.Insert(s.bucket, &storage.Object{Name: s.objectName(name), ContentType: "application/json"}).
+		Media(bytes.NewReader(data)).
+		Context(ctxConn).
+		Do()
+	return err
+}
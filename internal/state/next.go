@@ -0,0 +1,100 @@
+package state
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/maintenance"
+	"strings"
+	"time"
+)
+
+const (
+	// Reasons of the autoscaler for being idle
+	IdleReasonPaused      = "paused"
+	IdleReasonCooldown    = "cooldown after "
+	IdleReasonEvaluation  = "waiting for the next evaluation"
+	IdleReasonMaintenance = "maintenance window"
+	IdleReasonScaleUpOnly = "maintenance window only allowing scaling up"
+	IdleReasonWarmup      = "warm-up of the nodes added"
+	IdleReasonQuarantine  = "quarantine after a failed scale down"
+
+	// nextScalingHorizon is how far ahead the end of the maintenance windows is searched
+	nextScalingHorizon = 7 * 24 * time.Hour
+)
+
+// GetNextScaling returns when the autoscaler is allowed to scale up and down again, considering the pause,
+// the cooldown after the last scaling action or the wait for the next evaluation, and the maintenance windows
+func GetNextScaling(ctx *v1alpha1.Context, now time.Time) (v1alpha1.NextScaling, error) {
+	ctx.Mutex.Lock()
+	cooldownUntil, pause, lastDecision := ctx.State.CooldownUntil, ctx.State.Pause, ctx.LastDecision
+	lastScaleUp, scaleDownFailed := ctx.State.LastScaleUpTime, ctx.State.ScaleDownFailedTime
+	ctx.Mutex.Unlock()
+
+	next := v1alpha1.NextScaling{ScaleUpAt: now, ScaleDownAt: now}
+	var reasons []string
+	if pause != nil && (pause.Until.IsZero() || now.Before(pause.Until)) {
+		if pause.Until.IsZero() {
+			return v1alpha1.NextScaling{Reason: IdleReasonPaused + " until resumed"}, nil
+		}
+		next.ScaleUpAt, next.ScaleDownAt = pause.Until, pause.Until
+		reasons = append(reasons, IdleReasonPaused)
+	}
+	if cooldownUntil.After(next.ScaleUpAt) {
+		next.ScaleUpAt, next.ScaleDownAt = cooldownUntil, cooldownUntil
+		if lastDecision != nil && lastDecision.Action != v1alpha1.DecisionNone {
+			reasons = append(reasons, IdleReasonCooldown+lastDecision.Action)
+		} else {
+			reasons = append(reasons, IdleReasonEvaluation)
+		}
+	}
+
+	// Scaling down waits for the warm-up period of the nodes added by the last scale up.
+	// Waiting for them to join Elasticsearch is not foreseeable, so it is not considered
+	if warmupPeriod := ctx.Config.Autoscaler.Warmup.PeriodSec; warmupPeriod > 0 && !lastScaleUp.IsZero() {
+		if warmupEnd := lastScaleUp.Add(time.Duration(warmupPeriod) * time.Second); warmupEnd.After(next.ScaleDownAt) {
+			next.ScaleDownAt = warmupEnd
+			reasons = append(reasons, IdleReasonWarmup)
+		}
+	}
+
+	// Scaling down waits for the quarantine after a failed scale down too. The cluster turning green is not foreseeable
+	if quarantinePeriod := ctx.Config.Autoscaler.Quarantine.PeriodSec; quarantinePeriod > 0 && !scaleDownFailed.IsZero() {
+		if quarantineEnd := scaleDownFailed.Add(time.Duration(quarantinePeriod) * time.Second); quarantineEnd.After(next.ScaleDownAt) {
+			next.ScaleDownAt = quarantineEnd
+			reasons = append(reasons, IdleReasonQuarantine)
+		}
+	}
+
+	// Skip the maintenance windows in progress at those times
+	scaleUpAt, delayed, err := skipMaintenanceWindows(ctx, next.ScaleUpAt, v1alpha1.DecisionScaleUp)
+	if err != nil {
+		return next, err
+	}
+	if delayed {
+		reasons = append(reasons, IdleReasonMaintenance)
+	}
+	scaleDownAt, delayedDown, err := skipMaintenanceWindows(ctx, next.ScaleDownAt, v1alpha1.DecisionScaleDown)
+	if err != nil {
+		return next, err
+	}
+	if delayedDown && !delayed {
+		reasons = append(reasons, IdleReasonScaleUpOnly)
+	}
+	next.ScaleUpAt, next.ScaleDownAt = scaleUpAt, scaleDownAt
+	next.Reason = strings.Join(reasons, ", ")
+	return next, nil
+}
+
+// skipMaintenanceWindows returns the first time from the given one when no maintenance window blocks the action,
+// searching minute by minute, and whether it was delayed. It is zero when the action is blocked beyond the horizon
+func skipMaintenanceWindows(ctx *v1alpha1.Context, start time.Time, action string) (time.Time, bool, error) {
+	for t := start; t.Before(start.Add(nextScalingHorizon)); t = t.Truncate(time.Minute).Add(time.Minute) {
+		window, err := maintenance.GetActiveWindowAt(ctx, t.UTC())
+		if err != nil {
+			return start, false, err
+		}
+		if window == nil || (window.Mode == maintenance.ModeScaleUpOnly && action == v1alpha1.DecisionScaleUp) {
+			return t, !t.Equal(start), nil
+		}
+	}
+	return time.Time{}, true, nil
+}
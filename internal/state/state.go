@@ -0,0 +1,222 @@
+package state
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// Backends supported to persist the state
+	BackendFile = "file"
+	BackendGCS  = "gcs"
+
+	// Types of in-flight operations
+	OperationScaleUp   = "scale-up"
+	OperationScaleDown = "scale-down"
+	OperationRecreate  = "recreate"
+
+	// Phases of the scaling and recreate operations
+	PhaseResizing   = "resizing"
+	PhaseDraining   = "draining"
+	PhaseDeleting   = "deleting"
+	PhaseAbandoning = "abandoning"
+	PhaseParking    = "parking"
+	PhaseRecreating = "recreating"
+)
+
+// store is implemented by every backend able to persist the state of the autoscalers
+type store interface {
+	load(name string) (*v1alpha1.AutoscalerState, error)
+	save(name string, state v1alpha1.AutoscalerState) error
+}
+
+var (
+	// currentStore is the backend configured. When nil, the state is only kept in memory
+	currentStore store
+
+	// storeMutex serializes the accesses to the store, shared by every autoscaler in the process
+	storeMutex sync.Mutex
+)
+
+// Setup configures the state store defined in the config
+func Setup(config *v1alpha1.ConfigSpec) error {
+	var err error
+	switch config.State.Backend {
+	case "":
+		return nil
+	case BackendFile:
+		currentStore, err = newFileStore(config)
+	case BackendGCS:
+		currentStore, err = newGCSStore(config)
+	default:
+		return fmt.Errorf("unsupported state backend: %s", config.State.Backend)
+	}
+	return err
+}
+
+// Load reads the persisted state of the autoscaler into its context
+func Load(ctx *v1alpha1.Context) error {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+
+	if currentStore == nil {
+		return nil
+	}
+
+	state, err := currentStore.load(ctx.Config.Name)
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	if state != nil {
+		ctx.Mutex.Lock()
+		ctx.State = *state
+		ctx.Mutex.Unlock()
+	}
+	return nil
+}
+
+// Save persists the current state of the autoscaler. Errors are logged, as the autoscaler must keep working.
+// The state is copied under the mutex of the context, as the admin API and the health endpoints read it meanwhile
+func Save(ctx *v1alpha1.Context) {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+
+	if currentStore == nil {
+		return
+	}
+
+	// The pause is managed from outside the autoscaler loop, so keep the persisted one
+	refreshPause(ctx)
+
+	ctx.Mutex.Lock()
+	snapshot := ctx.State
+	ctx.Mutex.Unlock()
+
+	err := currentStore.save(ctx.Config.Name, snapshot)
+	if err != nil {
+		log.Printf("Error saving state: %v", err)
+	}
+}
+
+// SetPause pauses the autoscaler until resumed, or until the TTL expires when it is not zero.
+// A nil pause resumes the autoscaler
+func SetPause(ctx *v1alpha1.Context, pause *v1alpha1.Pause) error {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+
+	ctx.Mutex.Lock()
+	ctx.State.Pause = pause
+	ctx.Mutex.Unlock()
+	if currentStore == nil {
+		return nil
+	}
+
+	// Modify only the pause, as the state can be owned by a running autoscaler
+	state, err := currentStore.load(ctx.Config.Name)
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	if state == nil {
+		state = &v1alpha1.AutoscalerState{}
+	}
+	state.Pause = pause
+
+	err = currentStore.save(ctx.Config.Name, *state)
+	if err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	return nil
+}
+
+// IsPaused returns whether the scaling decisions are suspended, looking for changes made outside the process.
+// Pauses whose TTL expired are removed, resuming the autoscaler
+func IsPaused(ctx *v1alpha1.Context) bool {
+	return GetPause(ctx) != nil
+}
+
+// GetPause returns the pause suspending the scaling decisions, or nil when the autoscaler is not paused, looking for
+// changes made outside the process. Pauses whose TTL expired are removed, resuming the autoscaler
+func GetPause(ctx *v1alpha1.Context) *v1alpha1.Pause {
+	storeMutex.Lock()
+	refreshPause(ctx)
+	storeMutex.Unlock()
+
+	ctx.Mutex.Lock()
+	pause := ctx.State.Pause
+	ctx.Mutex.Unlock()
+	if pause == nil {
+		return nil
+	}
+	if pause.Until.IsZero() || time.Now().Before(pause.Until) {
+		return pause
+	}
+
+	log.Printf("Pause of autoscaler %s expired, resuming it", ctx.Config.Name)
+	err := SetPause(ctx, nil)
+	if err != nil {
+		log.Printf("Error resuming autoscaler: %v", err)
+	}
+	return nil
+}
+
+// refreshPause reads the persisted pause into the context. The store mutex must be held
+func refreshPause(ctx *v1alpha1.Context) {
+	if currentStore == nil {
+		return
+	}
+
+	state, err := currentStore.load(ctx.Config.Name)
+	if err != nil {
+		log.Printf("Error loading pause from state: %v", err)
+		return
+	}
+
+	ctx.Mutex.Lock()
+	defer ctx.Mutex.Unlock()
+	ctx.State.Pause = nil
+	if state != nil {
+		ctx.State.Pause = state.Pause
+	}
+}
+
+// StartOperation records the operation as in-flight, so it can be recovered after a crash.
+// Operations without idempotency key are given a new one
+func StartOperation(ctx *v1alpha1.Context, operation v1alpha1.Operation) {
+	if operation.Key == "" {
+		operation.Key = fmt.Sprintf("%s/%s/%d", ctx.Config.Name, operation.Type, operation.StartedAt.UnixNano())
+	}
+	ctx.Mutex.Lock()
+	ctx.State.InFlightOperation = &operation
+	ctx.Mutex.Unlock()
+	Save(ctx)
+}
+
+// SetOperationPhase moves the in-flight operation to the next phase. The operation is replaced instead of modified,
+// as the admin API may be reading the previous one
+func SetOperationPhase(ctx *v1alpha1.Context, phase string) {
+	ctx.Mutex.Lock()
+	if ctx.State.InFlightOperation == nil {
+		ctx.Mutex.Unlock()
+		return
+	}
+	operation := *ctx.State.InFlightOperation
+	operation.Phase = phase
+	ctx.State.InFlightOperation = &operation
+	ctx.Mutex.Unlock()
+	Save(ctx)
+}
+
+// FinishOperation clears the in-flight operation
+func FinishOperation(ctx *v1alpha1.Context) {
+	ctx.Mutex.Lock()
+	if ctx.State.InFlightOperation == nil {
+		ctx.Mutex.Unlock()
+		return
+	}
+	ctx.State.InFlightOperation = nil
+	ctx.Mutex.Unlock()
+	Save(ctx)
+}
@@ -0,0 +1,96 @@
+package state
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setupFileStore configures a file store in a temporary directory, restoring the memory only state after the test
+func setupFileStore(t *testing.T) *v1alpha1.ConfigSpec {
+	t.Helper()
+	config := &v1alpha1.ConfigSpec{Name: "es"}
+	config.State.Backend = BackendFile
+	config.State.File.Path = filepath.Join(t.TempDir(), "state", "state.json")
+	err := Setup(config)
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	t.Cleanup(func() { currentStore = nil })
+	return config
+}
+
+// loaded returns the state persisted for the autoscaler, read from the store by a new context
+func loaded(t *testing.T, config *v1alpha1.ConfigSpec) v1alpha1.AutoscalerState {
+	t.Helper()
+	ctx := &v1alpha1.Context{Config: config}
+	err := Load(ctx)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	return ctx.State
+}
+
+func TestFileStoreOperationRoundTrip(t *testing.T) {
+	config := setupFileStore(t)
+	ctx := &v1alpha1.Context{Config: config}
+
+	StartOperation(ctx, v1alpha1.Operation{
+		Type:      OperationScaleDown,
+		Phase:     PhaseDraining,
+		MIG:       "mig-a",
+		Instance:  "mig-a-0001",
+		StartedAt: time.Now(),
+	})
+	operation := loaded(t, config).InFlightOperation
+	if operation == nil || operation.Instance != "mig-a-0001" || operation.Phase != PhaseDraining || operation.Key == "" {
+		t.Fatalf("operation persisted = %+v, want the drain of mig-a-0001 with a key", operation)
+	}
+
+	SetOperationPhase(ctx, PhaseDeleting)
+	if phase := loaded(t, config).InFlightOperation.Phase; phase != PhaseDeleting {
+		t.Errorf("phase persisted = %s, want %s", phase, PhaseDeleting)
+	}
+
+	FinishOperation(ctx)
+	if operation := loaded(t, config).InFlightOperation; operation != nil {
+		t.Errorf("operation persisted after finishing it = %+v, want none", operation)
+	}
+}
+
+func TestFileStorePause(t *testing.T) {
+	config := setupFileStore(t)
+	ctx := &v1alpha1.Context{Config: config}
+
+	// Pauses set from outside the autoscaler, like from the admin API, are seen by it and kept when it saves its state
+	err := SetPause(&v1alpha1.Context{Config: config}, &v1alpha1.Pause{Reason: "maintenance", PausedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("SetPause() error = %v", err)
+	}
+	Save(ctx)
+	if pause := GetPause(ctx); pause == nil || pause.Reason != "maintenance" {
+		t.Fatalf("GetPause() = %+v, want the maintenance pause", pause)
+	}
+
+	// Resuming it clears the pause persisted
+	err = SetPause(ctx, nil)
+	if err != nil {
+		t.Fatalf("SetPause(nil) error = %v", err)
+	}
+	if pause := loaded(t, config).Pause; pause != nil {
+		t.Errorf("pause persisted after resuming = %+v, want none", pause)
+	}
+
+	// Pauses whose TTL expired resume the autoscaler
+	err = SetPause(ctx, &v1alpha1.Pause{Reason: "expired", PausedAt: time.Now().Add(-time.Hour), Until: time.Now().Add(-time.Minute)})
+	if err != nil {
+		t.Fatalf("SetPause() error = %v", err)
+	}
+	if IsPaused(ctx) {
+		t.Errorf("IsPaused() = true with an expired pause")
+	}
+	if pause := loaded(t, config).Pause; pause != nil {
+		t.Errorf("pause persisted after expiring = %+v, want none", pause)
+	}
+}
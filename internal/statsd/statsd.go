@@ -0,0 +1,123 @@
+package statsd
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// client sends the metrics to the StatsD endpoint configured. It is nil when StatsD is not configured
+type client struct {
+	conn      net.Conn
+	prefix    string
+	dogStatsD bool
+	tags      []string
+}
+
+var (
+	statsdClient *client
+
+	// clientMutex serializes the writes to the endpoint, shared by every autoscaler in the process
+	clientMutex sync.Mutex
+)
+
+// Setup connects to the StatsD endpoint defined in the config. Metrics are sent over UDP, so the endpoint
+// does not need to be reachable when the autoscaler starts
+func Setup(config *v1alpha1.ConfigSpec) error {
+	clientMutex.Lock()
+	defer clientMutex.Unlock()
+
+	if statsdClient != nil {
+		statsdClient.conn.Close()
+		statsdClient = nil
+	}
+	if config.StatsD.Address == "" {
+		return nil
+	}
+
+	conn, err := net.Dial("udp", config.StatsD.Address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to StatsD endpoint %s: %w", config.StatsD.Address, err)
+	}
+	statsdClient = &client{
+		conn:      conn,
+		prefix:    config.StatsD.Prefix,
+		dogStatsD: config.StatsD.DogStatsD,
+		tags:      config.StatsD.Tags,
+	}
+	return nil
+}
+
+// Record counts the decision by its action and outcome, with the duration and the size reached by the scaling actions.
+// With DogStatsD, the scaling actions are sent as events too. Errors are logged, as the autoscaler must keep working
+func Record(decision v1alpha1.Decision) {
+	clientMutex.Lock()
+	defer clientMutex.Unlock()
+
+	if statsdClient == nil {
+		return
+	}
+
+	tags := []string{"autoscaler:" + decision.Autoscaler, "action:" + decision.Action, "outcome:" + decision.Outcome}
+	lines := []string{statsdClient.format("decisions", "1|c", tags)}
+	if decision.Action != v1alpha1.DecisionNone {
+		if decision.MIG != "" {
+			tags = append(tags, "mig:"+decision.MIG)
+		}
+		lines = append(lines, statsdClient.format("scaling_duration", fmt.Sprintf("%d|ms", decision.DurationMs), tags))
+		if decision.Size > 0 {
+			lines = append(lines, statsdClient.format("size", fmt.Sprintf("%d|g", decision.Size), tags[:1]))
+		}
+		if statsdClient.dogStatsD {
+			lines = append(lines, statsdClient.event(decision, tags))
+		}
+	}
+
+	for _, line := range lines {
+		_, err := statsdClient.conn.Write([]byte(line))
+		if err != nil {
+			log.Printf("Error sending metrics to StatsD: %v", err)
+			return
+		}
+	}
+}
+
+// format returns the line of the metric. Plain StatsD does not support tags, so their values are appended to the name
+func (c *client) format(name string, value string, tags []string) string {
+	if !c.dogStatsD {
+		for _, tag := range tags {
+			_, tagValue, _ := strings.Cut(tag, ":")
+			name += "." + sanitize(tagValue)
+		}
+		return fmt.Sprintf("%s%s:%s", c.prefix, name, value)
+	}
+	return fmt.Sprintf("%s%s:%s|#%s", c.prefix, name, value, strings.Join(slices.Concat(tags, c.tags), ","))
+}
+
+// event returns the DogStatsD event of the scaling action, as an error when it failed
+func (c *client) event(decision v1alpha1.Decision, tags []string) string {
+	title := fmt.Sprintf("Autoscaler %s: %s %s", decision.Autoscaler, decision.Action, decision.Outcome)
+	text := fmt.Sprintf("Resizing MIG %s from %d to %d nodes", decision.MIG, decision.PreviousSize, decision.Size)
+	if decision.Instance != "" {
+		text += fmt.Sprintf(", removing instance %s", decision.Instance)
+	}
+	if decision.Reason != "" {
+		text += ". " + decision.Reason
+	}
+	alertType := "success"
+	if decision.Error != "" {
+		alertType = "error"
+		text += ". Error: " + decision.Error
+	}
+	text = strings.ReplaceAll(text, "\n", "\\n")
+	return fmt.Sprintf("_e{%d,%d}:%s|%s|t:%s|#%s", len(title), len(text), title, text, alertType, strings.Join(slices.Concat(tags, c.tags), ","))
+}
+
+// sanitize replaces the characters reserved by the StatsD protocol in the names of the metrics
+func sanitize(value string) string {
+	return strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ".", "_", " ", "_").Replace(value)
+}
@@ -0,0 +1,59 @@
+// Package tlsconfig builds the TLS configs of the clients talking to Elasticsearch and Prometheus,
+// from the versions and cipher suites allowed in the config
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// versions are the TLS versions allowed to connect to the targets and the metric sources
+var versions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Validate checks that the versions are supported, the minimum is not greater than the maximum, and
+// every cipher suite is one of the secure suites implemented by Go. The maximum version is optional
+func Validate(minVersion, maxVersion string, cipherSuites []string) error {
+	_, err := New(minVersion, maxVersion, cipherSuites, false)
+	return err
+}
+
+// New returns the TLS config allowing the given versions and cipher suites. Without cipher suites, the defaults
+// of Go are used. They only apply to TLS 1.2, as the suites of TLS 1.3 are not configurable
+func New(minVersion, maxVersion string, cipherSuites []string, insecureSkipVerify bool) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+
+	var ok bool
+	config.MinVersion, ok = versions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("invalid TLS min version %q, expected 1.2 or 1.3", minVersion)
+	}
+	if maxVersion != "" {
+		config.MaxVersion, ok = versions[maxVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid TLS max version %q, expected 1.2 or 1.3", maxVersion)
+		}
+		if config.MaxVersion < config.MinVersion {
+			return nil, fmt.Errorf("TLS max version %s lower than min version %s", maxVersion, minVersion)
+		}
+	}
+
+	suites := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+	for _, name := range cipherSuites {
+		id, ok := suites[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure TLS cipher suite %q", name)
+		}
+		config.CipherSuites = append(config.CipherSuites, id)
+	}
+	if len(config.CipherSuites) > 0 && config.MinVersion == tls.VersionTLS13 {
+		return nil, fmt.Errorf("TLS cipher suites are only configurable for TLS 1.2, got min version %s", minVersion)
+	}
+
+	return config, nil
+}
@@ -0,0 +1,144 @@
+package trigger
+
+import (
+	"crypto/subtle"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/leader"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Server receives the scaling actions requested by ChatOps bots and runbooks, and enqueues them in the autoscalers
+type Server struct {
+	address     string
+	token       string
+	autoscalers []*v1alpha1.Context
+	elector     *leader.Elector
+}
+
+// triggerRequest is the optional body of the trigger endpoints
+type triggerRequest struct {
+	Autoscaler  string `json:"autoscaler"`
+	Reason      string `json:"reason"`
+	RequestedBy string `json:"requestedBy"`
+}
+
+// NewServer creates the trigger endpoints for the autoscalers, configured from the root of the config
+func NewServer(config *v1alpha1.ConfigSpec, autoscalers []*v1alpha1.Context, elector *leader.Elector) (*Server, error) {
+	if config.Triggers.Token == "" {
+		return nil, fmt.Errorf("token is required for the trigger endpoints")
+	}
+
+	return &Server{
+		address:     config.Triggers.Address,
+		token:       config.Triggers.Token,
+		autoscalers: autoscalers,
+		elector:     elector,
+	}, nil
+}
+
+// Run serves the trigger endpoints until it fails
+func (s *Server) Run() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /trigger/scale-up", s.authenticate(s.handleTrigger(v1alpha1.DecisionScaleUp)))
+	mux.HandleFunc("POST /trigger/scale-down", s.authenticate(s.handleTrigger(v1alpha1.DecisionScaleDown)))
+
+	log.Printf("Starting trigger endpoints on %s", s.address)
+	server := &http.Server{
+		Addr:              s.address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServe()
+}
+
+// authenticate rejects the requests without the bearer token configured
+func (s *Server) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	expected := []byte("Bearer " + s.token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleTrigger enqueues the scaling action in the autoscaler, which executes it right away ignoring the conditions.
+// The autoscaler is named in the body or in the "autoscaler" query parameter, and can be omitted when there is only one
+func (s *Server) handleTrigger(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request triggerRequest
+		if r.ContentLength != 0 {
+			err := json.NewDecoder(r.Body).Decode(&request)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid body: %v", err))
+				return
+			}
+		}
+		if request.Autoscaler == "" {
+			request.Autoscaler = r.URL.Query().Get("autoscaler")
+		}
+
+		ctx, err := s.selectAutoscaler(request.Autoscaler)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if !s.elector.IsLeader() {
+			writeError(w, http.StatusServiceUnavailable, "this replica is not the leader")
+			return
+		}
+
+		reason := "Requested from the trigger endpoints"
+		if request.RequestedBy != "" {
+			reason += " by " + request.RequestedBy
+		}
+		if request.Reason != "" {
+			reason += ": " + request.Reason
+		}
+
+		select {
+		case ctx.Requests <- v1alpha1.ActionRequest{Action: action, Reason: reason}:
+			log.Printf("Triggered %s for autoscaler %s. %s", action, ctx.Config.Name, reason)
+			writeJSON(w, http.StatusAccepted, map[string]string{"result": "requested", "autoscaler": ctx.Config.Name})
+		default:
+			writeError(w, http.StatusConflict, "another scaling action is already pending")
+		}
+	}
+}
+
+// selectAutoscaler returns the autoscaler with the given name, or the only one running when the name is empty
+func (s *Server) selectAutoscaler(name string) (*v1alpha1.Context, error) {
+	if name == "" {
+		if len(s.autoscalers) != 1 {
+			return nil, fmt.Errorf("the autoscaler is required when several autoscalers are running")
+		}
+		return s.autoscalers[0], nil
+	}
+
+	for _, ctx := range s.autoscalers {
+		if ctx.Config.Name == name {
+			return ctx, nil
+		}
+	}
+	return nil, fmt.Errorf("autoscaler %s not found", name)
+}
+
+// writeJSON encodes the body as the JSON response
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		log.Printf("Error encoding trigger response: %v", err)
+	}
+}
+
+// writeError sends the error message as the JSON response
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	writeJSON(w, statusCode, map[string]string{"error": message})
+}
@@ -0,0 +1,56 @@
+package version
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Build metadata, set at build time with:
+// -ldflags "-X custom-vm-autoscaler/internal/version.Version=v1.2.3 -X custom-vm-autoscaler/internal/version.Commit=abc1234 ..."
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info is the build metadata of the binary
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+}
+
+// Get returns the build metadata. When not set at build time, the commit and its date are taken from
+// the VCS information embedded by the Go toolchain, if any
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildDate: BuildDate}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
+
+// String returns the build metadata in a single line, as shown in the logs and notifications
+func String() string {
+	info := Get()
+	commit := info.Commit
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	return fmt.Sprintf("%s (commit %s, built %s)", info.Version, commit, info.BuildDate)
+}
@@ -0,0 +1,226 @@
+package autoscaler
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/dryrun"
+	"custom-vm-autoscaler/internal/elasticsearch"
+	"custom-vm-autoscaler/internal/google"
+	"custom-vm-autoscaler/internal/notifier"
+	"custom-vm-autoscaler/internal/state"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unhealthyNode is an instance of the MIGs whose Elasticsearch node is missing or overloaded
+type unhealthyNode struct {
+	MIG      string
+	Instance string
+	Reason   string
+
+	// Joined is true when the instance is a node of the Elasticsearch cluster, so it must be drained before recreating it
+	Joined bool
+}
+
+// runAutohealing checks the nodes of the MIGs once every autohealing interval, between the evaluations of the conditions.
+// Instances unhealthy in enough consecutive checks raise an alert and, when enabled, the first of them is drained and
+// recreated. Replacements are not done while a maintenance window restricts the scaling actions
+func runAutohealing(ctx *v1alpha1.Context, maintenanceWindow *v1alpha1.MaintenanceWindowSpec) {
+	spec := ctx.Config.Autohealing
+	if spec.IntervalSec <= 0 || ctx.Config.Target.Elasticsearch.URL == "" ||
+		time.Since(ctx.LastHealthCheck) < time.Duration(spec.IntervalSec)*time.Second {
+		return
+	}
+	ctx.LastHealthCheck = time.Now()
+
+	nodes, err := findUnhealthyNodes(ctx)
+	if err != nil {
+		log.Printf("Error checking the health of the nodes of the MIGs: %v", err)
+		return
+	}
+
+	// Count the consecutive checks of every instance, forgetting the ones healthy again
+	checks := map[string]int{}
+	var persistent []unhealthyNode
+	var descriptions []string
+	for _, node := range nodes {
+		checks[node.Instance] = ctx.UnhealthyChecks[node.Instance] + 1
+		if checks[node.Instance] >= spec.UnhealthyChecks {
+			persistent = append(persistent, node)
+			descriptions = append(descriptions, fmt.Sprintf("%s (%s)", node.Instance, node.Reason))
+		}
+	}
+	ctx.UnhealthyChecks = checks
+
+	if len(persistent) == 0 {
+		notifier.Resolve(ctx, notifier.AlertUnhealthyNodes, "Every node of the MIGs is healthy again")
+		return
+	}
+	notifier.Alert(ctx, notifier.SeverityWarning, notifier.AlertUnhealthyNodes,
+		fmt.Sprintf("Instances unhealthy in %d consecutive checks: %s", spec.UnhealthyChecks, strings.Join(descriptions, ", ")))
+
+	if !spec.Recreate {
+		return
+	}
+	if maintenanceWindow != nil {
+		log.Printf("Maintenance window on days %s and hours %s in progress, unhealthy instances are not recreated", maintenanceWindow.Days, maintenanceWindow.HoursUTC)
+		return
+	}
+
+	node := persistent[0]
+	err = recreateNode(ctx, node)
+	if err != nil {
+		log.Printf("Error recreating unhealthy instance %s: %v", node.Instance, err)
+		notifier.Notify(ctx, notifier.SeverityError, notifier.EventError, fmt.Sprintf("Error recreating unhealthy instance %s of MIG %s: %v", node.Instance, node.MIG, err))
+		trackErrors(ctx, err)
+		return
+	}
+	delete(ctx.UnhealthyChecks, node.Instance)
+}
+
+// findUnhealthyNodes returns the instances of the MIGs which are not nodes of the Elasticsearch cluster,
+// or whose heap or disk usage reach the limits of the autohealing, sorted by name.
+// The instance of the scaling operation in flight is excluded
+func findUnhealthyNodes(ctx *v1alpha1.Context) ([]unhealthyNode, error) {
+	migSizes, _, _, _, err := google.GetMIGSizes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := elasticsearch.GetNodes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes of the Elasticsearch cluster: %v", err)
+	}
+	nodesByName := map[string]v1alpha1.NodeInfo{}
+	for _, node := range nodes {
+		nodesByName[node.Name] = node
+	}
+
+	ctx.Mutex.Lock()
+	operation := ctx.State.InFlightOperation
+	ctx.Mutex.Unlock()
+
+	spec := ctx.Config.Autohealing
+	var unhealthy []unhealthyNode
+	for migName := range migSizes {
+		instances, err := google.GetMIGInstanceNames(ctx, migName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get instances of MIG %s: %v", migName, err)
+		}
+
+		for _, instance := range instances {
+			if operation != nil && operation.Instance == instance {
+				continue
+			}
+
+			candidate := unhealthyNode{MIG: migName, Instance: instance}
+			node, joined := nodesByName[instance]
+			candidate.Joined = joined
+			heap, _ := strconv.ParseFloat(node.HeapPercent, 64)
+			disk, _ := strconv.ParseFloat(node.DiskPercent, 64)
+			switch {
+			case !joined:
+				candidate.Reason = "not a node of the Elasticsearch cluster"
+			case heap >= spec.MaxHeapPercent:
+				candidate.Reason = fmt.Sprintf("heap at %s%%", node.HeapPercent)
+			case disk >= spec.MaxDiskPercent:
+				candidate.Reason = fmt.Sprintf("disk at %s%%", node.DiskPercent)
+			default:
+				continue
+			}
+			unhealthy = append(unhealthy, candidate)
+		}
+	}
+
+	sort.Slice(unhealthy, func(i, j int) bool { return unhealthy[i].Instance < unhealthy[j].Instance })
+	return unhealthy, nil
+}
+
+// recreateNode replaces the unhealthy instance, notifying it once recreated
+func recreateNode(ctx *v1alpha1.Context, node unhealthyNode) error {
+	log.Printf("Recreating unhealthy instance %s of MIG %s: %s", node.Instance, node.MIG, node.Reason)
+	err := replaceNode(ctx, node)
+	if err != nil {
+		return err
+	}
+
+	notifier.Notify(ctx, notifier.SeverityWarning, notifier.EventAutoheal,
+		fmt.Sprintf("Recreated unhealthy instance %s of MIG %s: %s", node.Instance, node.MIG, node.Reason))
+	return nil
+}
+
+// replaceNode drains the instance from the Elasticsearch cluster, when configured, recreates it from the template of
+// its MIG and allows the allocation of shards in it again. It is recorded as an in-flight operation, so it is recovered
+// after a crash. Nodes are not recreated while the cluster is red, as draining them could lose the only copy of some shards
+func replaceNode(ctx *v1alpha1.Context, node unhealthyNode) error {
+	elasticsearchTarget := ctx.Config.Target.Elasticsearch.URL != ""
+	if elasticsearchTarget {
+		health, err := elasticsearch.GetClusterHealth(ctx)
+		if err != nil {
+			return fmt.Errorf("error getting the health of the Elasticsearch cluster: %v", err)
+		}
+		if health.Status == "red" {
+			return fmt.Errorf("the Elasticsearch cluster is red, instances are not recreated until it recovers")
+		}
+	}
+
+	state.StartOperation(ctx, v1alpha1.Operation{
+		Type:      state.OperationRecreate,
+		Phase:     state.PhaseDraining,
+		MIG:       node.MIG,
+		Instance:  node.Instance,
+		StartedAt: time.Now(),
+	})
+	defer func() {
+		// Keep the operation when panicking, so it is recovered when the loop of the autoscaler is restarted
+		if r := recover(); r != nil {
+			panic(r)
+		}
+		// Keep it too when the leadership was lost in the middle of it, as this replica must not act anymore
+		if ctx.CheckLeadership() != nil {
+			return
+		}
+		state.FinishOperation(ctx)
+	}()
+
+	var err error
+	if node.Joined {
+		err = elasticsearch.DrainElasticsearchNode(ctx, node.Instance)
+		if err != nil {
+			return fmt.Errorf("error draining Elasticsearch node: %v", err)
+		}
+	}
+
+	err = ctx.CheckLeadership()
+	if err != nil {
+		return err
+	}
+	state.SetOperationPhase(ctx, state.PhaseRecreating)
+	if ctx.Config.Autoscaler.DryRunInfrastructure {
+		dryrun.Record(ctx, dryrun.ModuleGCP, "recreate instance %s of MIG %s", node.Instance, node.MIG)
+	} else {
+		err = google.RecreateInstance(ctx, node.MIG, node.Instance)
+		if err != nil {
+			if elasticsearchTarget {
+				clearErr := elasticsearch.ClearElasticsearchClusterSettings(ctx, node.Instance)
+				if clearErr != nil {
+					log.Printf("Error clearing Elasticsearch cluster settings: %v", clearErr)
+				}
+			}
+			return fmt.Errorf("error recreating instance: %v", err)
+		}
+	}
+	if !elasticsearchTarget {
+		return nil
+	}
+
+	// The recreated instance keeps its name, so shards can be allocated in it again once it joins the cluster
+	err = elasticsearch.ClearElasticsearchClusterSettings(ctx, node.Instance)
+	if err != nil {
+		return fmt.Errorf("error clearing Elasticsearch cluster settings: %v", err)
+	}
+	return nil
+}
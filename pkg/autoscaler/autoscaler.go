@@ -0,0 +1,244 @@
+// Package autoscaler embeds the autoscaler of Managed Instance Groups in other programs, instead of executing the binary.
+//
+// The config is the same one read by the binary:
+//
+//	configContent, err := autoscaler.ReadConfig("autoscaler.yaml")
+//	autoscalers, err := autoscaler.New(configContent)
+//	for _, a := range autoscalers {
+//		go a.Run(ctxRun)
+//	}
+//
+// Run executes the loop of the autoscaler until its context is cancelled, while Step evaluates the conditions once,
+// leaving the wait until the next evaluation to the caller. RunOnce evaluates them once too, respecting the cooldown
+// of the previous executions, for autoscalers driven by a scheduler.
+package autoscaler
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/approval"
+	"custom-vm-autoscaler/internal/audit"
+	"custom-vm-autoscaler/internal/config"
+	"custom-vm-autoscaler/internal/google"
+	"custom-vm-autoscaler/internal/heartbeat"
+	"custom-vm-autoscaler/internal/kubeevents"
+	"custom-vm-autoscaler/internal/leader"
+	"custom-vm-autoscaler/internal/notifier"
+	"custom-vm-autoscaler/internal/state"
+	"custom-vm-autoscaler/internal/statsd"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrCooldown is returned by RunOnce when the cooldown of a previous execution is still in progress
+var ErrCooldown = errors.New("cooldown in progress")
+
+// Elector tells whether this replica may act, blocking until it is the leader. The leadership is checked again
+// before every destructive step of the scaling operations, which stop when it is lost
+type Elector interface {
+	WaitForLeadership()
+	IsLeader() bool
+}
+
+// Option customizes the autoscalers created by New
+type Option func(*Autoscaler)
+
+// WithElector runs the autoscaler only while the elector holds the leadership. By default it always runs
+func WithElector(elector Elector) Option {
+	return func(a *Autoscaler) {
+		a.elector = elector
+	}
+}
+
+// Autoscaler scales the MIGs of a single autoscaler defined in the config
+type Autoscaler struct {
+	ctx     *v1alpha1.Context
+	elector Elector
+}
+
+// ReadConfig reads and normalizes the config like the binary, from a local file or a remote location
+// (gs://, s3://, https://)
+func ReadConfig(path string) (v1alpha1.ConfigSpec, error) {
+	return config.ReadFile(path)
+}
+
+// New creates every autoscaler defined in the config, restoring the state of the previous execution.
+// The state store, the audit log, StatsD, the Kubernetes events and the rate limit of the GCP API are configured from the root of the config,
+// and shared by every autoscaler in the process
+func New(configContent v1alpha1.ConfigSpec, options ...Option) ([]*Autoscaler, error) {
+	config.Normalize(&configContent)
+
+	err := state.Setup(&configContent)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring state store: %w", err)
+	}
+	err = audit.Setup(&configContent)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring audit log: %w", err)
+	}
+	err = statsd.Setup(&configContent)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring StatsD: %w", err)
+	}
+	err = kubeevents.Setup(&configContent)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring Kubernetes events: %w", err)
+	}
+	google.SetupRateLimit(&configContent)
+
+	var autoscalers []*Autoscaler
+	for _, autoscalerConfig := range config.GetAutoscalers(configContent) {
+		a := &Autoscaler{
+			ctx: &v1alpha1.Context{
+				Config:   &autoscalerConfig,
+				Requests: make(chan v1alpha1.ActionRequest, 1),
+				Reloads:  make(chan *v1alpha1.ConfigSpec, 1),
+			},
+			elector: (*leader.Elector)(nil),
+		}
+		for _, option := range options {
+			option(a)
+		}
+		a.ctx.IsLeader = a.elector.IsLeader
+
+		err = validateAutoscaler(a.ctx.Config)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring autoscaler %s: %w", autoscalerConfig.Name, err)
+		}
+
+		// Restore the state persisted by the previous execution
+		err = state.Load(a.ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error loading state of autoscaler %s: %w", autoscalerConfig.Name, err)
+		}
+		autoscalers = append(autoscalers, a)
+	}
+
+	return autoscalers, nil
+}
+
+// Name returns the name of the autoscaler
+func (a *Autoscaler) Name() string {
+	return a.ctx.Config.Name
+}
+
+// Context returns the runtime context of the autoscaler, shared with the admin API and the health endpoints
+func (a *Autoscaler) Context() *v1alpha1.Context {
+	return a.ctx
+}
+
+// Run executes the loop of the autoscaler until the context is cancelled, restarting it when it panics.
+// The calls to GCP, Elasticsearch, Prometheus, the hooks and the notification channels are cancelled with it.
+// The instances of the MIGs are reconciled with the Elasticsearch nodes meanwhile, when enabled
+func (a *Autoscaler) Run(ctxRun context.Context) error {
+	a.ctx.Parent = ctxRun
+	log.Printf("Starting autoscaler %s", a.ctx.Config.Name)
+	if a.ctx.Config.Autoscaler.DryRunTarget && !a.ctx.Config.Autoscaler.DryRunInfrastructure {
+		log.Printf("Warning: autoscaler %s removes instances without draining them, as only dryRunTarget is enabled", a.ctx.Config.Name)
+	}
+	go runReconciliation(ctxRun, a.ctx, a.elector)
+	superviseAutoscaler(ctxRun, a.ctx, a.elector)
+	return ctxRun.Err()
+}
+
+// Step finishes the scaling operation interrupted by a previous execution, if any, and evaluates the conditions once,
+// taking the scaling decision. It returns the time to wait before the next step: the cooldown after the decision, or
+// the retry backoff along with the error failing the evaluation. Step does not wait for the leadership
+func (a *Autoscaler) Step(ctxRun context.Context) (time.Duration, error) {
+	err := ctxRun.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	a.ctx.Parent = ctxRun
+	recoverInFlightOperation(a.ctx)
+	cooldownSec, err := evaluate(a.ctx)
+	heartbeat.Ping(a.ctx, err)
+	if err != nil {
+		return retryBackoff(a.ctx), err
+	}
+	return time.Duration(cooldownSec) * time.Second, nil
+}
+
+// RunOnce executes a single step of the autoscaler, for executions driven by a scheduler instead of the loop of Run.
+// The step is skipped with ErrCooldown while the cooldown of a previous scaling action is in progress, and the cooldown
+// after a scaling action is persisted in the state, so a state backend must be configured to respect it across
+// executions. The wait for the next evaluation is left to the scheduler
+func (a *Autoscaler) RunOnce(ctxRun context.Context) error {
+	a.elector.WaitForLeadership()
+
+	a.ctx.Mutex.Lock()
+	cooldownUntil := a.ctx.State.CooldownUntil
+	previousDecision := a.ctx.LastDecision
+	a.ctx.Mutex.Unlock()
+	if remaining := time.Until(cooldownUntil); remaining > 0 {
+		return fmt.Errorf("%w, %s remaining", ErrCooldown, remaining.Round(time.Second))
+	}
+
+	cooldown, err := a.Step(ctxRun)
+	if err != nil {
+		return err
+	}
+
+	a.ctx.Mutex.Lock()
+	decision := a.ctx.LastDecision
+	a.ctx.Mutex.Unlock()
+	if decision == previousDecision || decision == nil || decision.Action == v1alpha1.DecisionNone {
+		return nil
+	}
+
+	a.ctx.Mutex.Lock()
+	a.ctx.State.CooldownUntil = time.Now().Add(cooldown)
+	a.ctx.Mutex.Unlock()
+	state.Save(a.ctx)
+	return nil
+}
+
+// Reload validates the changed config of the autoscaler, and applies it before its next evaluation.
+// The sections only read from the root of the config are not reloaded
+func (a *Autoscaler) Reload(autoscalerConfig v1alpha1.ConfigSpec) error {
+	err := validateAutoscaler(&autoscalerConfig)
+	if err != nil {
+		return err
+	}
+
+	// Replace the change pending to be applied, if any
+	select {
+	case <-a.ctx.Reloads:
+	default:
+	}
+	a.ctx.Reloads <- &autoscalerConfig
+	return nil
+}
+
+// validateAutoscaler checks the parts of the config of the autoscaler validated before running it
+func validateAutoscaler(config *v1alpha1.ConfigSpec) error {
+	err := notifier.Validate(config)
+	if err != nil {
+		return fmt.Errorf("invalid notifications: %w", err)
+	}
+
+	err = validateApproval(config)
+	if err != nil {
+		return fmt.Errorf("invalid scale down approval: %w", err)
+	}
+	return nil
+}
+
+// validateApproval checks the scale down approval defined in the config of the autoscaler
+func validateApproval(config *v1alpha1.ConfigSpec) error {
+	spec := config.Autoscaler.ScaleDownApproval
+	if !spec.Enabled {
+		return nil
+	}
+	if spec.SlackWebhookURL == "" {
+		return fmt.Errorf("slackWebhookUrl is required")
+	}
+	if spec.OnTimeout != approval.OnTimeoutProceed && spec.OnTimeout != approval.OnTimeoutCancel {
+		return fmt.Errorf("invalid onTimeout %s, expected %s or %s", spec.OnTimeout, approval.OnTimeoutProceed, approval.OnTimeoutCancel)
+	}
+	_, err := approval.IsRequired(&v1alpha1.Context{Config: config})
+	return err
+}
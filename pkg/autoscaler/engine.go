@@ -0,0 +1,850 @@
+package autoscaler
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/approval"
+	"custom-vm-autoscaler/internal/audit"
+	"custom-vm-autoscaler/internal/breaker"
+	"custom-vm-autoscaler/internal/controlplane"
+	"custom-vm-autoscaler/internal/cost"
+	"custom-vm-autoscaler/internal/decision"
+	"custom-vm-autoscaler/internal/elasticsearch"
+	"custom-vm-autoscaler/internal/google"
+	"custom-vm-autoscaler/internal/heartbeat"
+	"custom-vm-autoscaler/internal/kubeevents"
+	"custom-vm-autoscaler/internal/logging"
+	"custom-vm-autoscaler/internal/maintenance"
+	"custom-vm-autoscaler/internal/notifier"
+	"custom-vm-autoscaler/internal/prometheus"
+	"custom-vm-autoscaler/internal/retry"
+	"custom-vm-autoscaler/internal/state"
+	"custom-vm-autoscaler/internal/statsd"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+const (
+	// Triggers of the decisions taken by the autoscaler
+	TriggerCondition   = decision.TriggerCondition
+	TriggerManual      = decision.TriggerManual
+	TriggerPause       = decision.TriggerPause
+	TriggerMaintenance = decision.TriggerMaintenance
+	TriggerCircuit     = decision.TriggerCircuit
+	TriggerWarmup      = decision.TriggerWarmup
+	TriggerRelocation  = decision.TriggerRelocation
+	TriggerReplication = decision.TriggerReplication
+	TriggerEmergency   = decision.TriggerEmergency
+	TriggerQuarantine  = decision.TriggerQuarantine
+	TriggerLimits      = decision.TriggerLimits
+	TriggerInterlock   = decision.TriggerInterlock
+
+	TriggerIndexOperations = decision.TriggerIndexOperations
+	TriggerMinHealthy      = decision.TriggerMinHealthy
+
+	// Keys of the notifications sent once when the limits of the MIG are reached
+	limitMaxSize = "max-size"
+	limitMinSize = "min-size"
+
+	// historySize is the number of scaling actions kept in the history of every autoscaler
+	historySize = 100
+)
+
+var (
+	// errScaleUp and errScaleDown fail the evaluations whose scaling action failed, which is already notified
+	errScaleUp   = errors.New("error adding node to MIG")
+	errScaleDown = errors.New("error draining node from MIG")
+)
+
+// runAutoscaler executes the main loop of a single autoscaler until the run context is cancelled.
+// When leader election is enabled, the loop is only executed while being the leader
+func runAutoscaler(ctxRun context.Context, ctx *v1alpha1.Context, elector Elector) {
+
+	// Wait until this replica is the leader
+	elector.WaitForLeadership()
+
+	// Finish the operation interrupted by a crash of the previous execution, and respect its cooldown
+	recoverInFlightOperation(ctx)
+	recoverRaisedSettings(ctx)
+	if remaining := time.Until(ctx.State.CooldownUntil); remaining > 0 {
+		log.Printf("Cooldown from previous execution in progress, waiting %s before checking the conditions", remaining.Round(time.Second))
+		if !sleep(ctxRun, remaining) {
+			return
+		}
+	}
+
+	// Spread the first evaluation of the autoscalers started at the same time
+	if jitter := evaluationJitter(ctx); jitter > 0 {
+		log.Printf("Waiting %s before the first evaluation", jitter.Round(time.Second))
+		if !sleep(ctxRun, jitter) {
+			return
+		}
+	}
+
+	// Main loop to monitor scaling conditions and manage the MIG
+	for {
+
+		// Wait until this replica is the leader
+		elector.WaitForLeadership()
+
+		cooldownSec, err := evaluate(ctx)
+		heartbeat.Ping(ctx, err)
+		if err != nil {
+			if !waitRetry(ctxRun, ctx) {
+				return
+			}
+			continue
+		}
+		if !waitCooldown(ctxRun, ctx, cooldownSec) {
+			return
+		}
+	}
+}
+
+// evaluate applies the pending config change and evaluates the conditions once, taking the scaling decision.
+// It returns the cooldown to wait before the next evaluation, or the error failing the evaluation, which is
+// already notified and retried with backoff
+func evaluate(ctx *v1alpha1.Context) (int, error) {
+	// Apply the config changed in its remote location
+	select {
+	case newConfig := <-ctx.Reloads:
+		ctx.Mutex.Lock()
+		ctx.Config = newConfig
+		ctx.Mutex.Unlock()
+		log.Printf("Applied the changed config to autoscaler %s", ctx.Config.Name)
+	default:
+	}
+
+	// List the instances of every MIG once in this decision cycle, as it is slow and costly for large MIGs
+	defer google.CacheInstances(ctx)()
+
+	// While paused, keep evaluating the conditions without taking scaling decisions
+	if pause := state.GetPause(ctx); pause != nil {
+		result := decision.Decide(ctx.Config, decision.Input{Now: time.Now(), Pause: pause})
+		reportConditions(ctx, result.Reason)
+		recordDecision(ctx, v1alpha1.Decision{Action: result.Action, Trigger: result.Trigger, Reason: result.Reason})
+		return result.CooldownSec, nil
+	}
+
+	// Stop scaling while the calls to a dependency keep failing, probing it until it recovers
+	if open := breaker.OpenCircuits(ctx); len(open) > 0 {
+		probeDependencies(ctx, open)
+		if open = breaker.OpenCircuits(ctx); len(open) > 0 {
+			reason := fmt.Sprintf("Circuit open for %s after repeated failures", strings.Join(open, ", "))
+			log.Printf("%s. No scaling decisions are taken until it recovers", reason)
+			recordDecision(ctx, v1alpha1.Decision{Action: v1alpha1.DecisionNone, Trigger: TriggerCircuit, Reason: reason})
+			return ctx.Config.CircuitBreaker.ProbeIntervalSec, nil
+		}
+	}
+
+	// Check if a maintenance window restricts the scaling actions
+	maintenanceWindow, err := maintenance.GetActiveWindow(ctx)
+	if err != nil {
+		log.Printf("Error checking maintenance windows: %v", err)
+		notifier.Notify(ctx, notifier.SeverityError, notifier.EventError, fmt.Sprintf("Error checking maintenance windows: %v", err))
+		trackErrors(ctx, err)
+		return 0, err
+	}
+	if maintenanceWindow != nil && maintenanceWindow.Mode == maintenance.ModeBlock {
+		result := decision.Decide(ctx.Config, decision.Input{Now: time.Now(), MaintenanceWindow: maintenanceWindow})
+		reportConditions(ctx, result.Reason)
+		recordDecision(ctx, v1alpha1.Decision{Action: result.Action, Trigger: result.Trigger, Reason: result.Reason})
+		return result.CooldownSec, nil
+	}
+
+	// Refuse to operate the MIGs managed by a GCE autoscaler or another controller, instead of fighting over their size
+	err = google.CheckInterlock(ctx)
+	if errors.Is(err, google.ErrMIGInterlocked) {
+		log.Printf("%v. No scaling decisions are taken until it is released", err)
+		notifier.Alert(ctx, notifier.SeverityError, notifier.AlertMIGInterlocked, fmt.Sprintf("Refusing to operate the MIGs: %v", err))
+		recordDecision(ctx, v1alpha1.Decision{Action: v1alpha1.DecisionNone, Trigger: TriggerInterlock, Reason: err.Error()})
+		return ctx.Config.Autoscaler.EvaluationIntervalSec, nil
+	}
+	if err != nil {
+		log.Printf("Error checking the controllers of the MIGs: %v", err)
+		notifier.Notify(ctx, notifier.SeverityError, notifier.EventError, fmt.Sprintf("Error checking the controllers of the MIGs: %v", err))
+		trackErrors(ctx, err)
+		return 0, err
+	}
+	notifier.Resolve(ctx, notifier.AlertMIGInterlocked, "The MIGs are not managed by another controller anymore, scaling resumed")
+
+	// Detect the unhealthy nodes of the MIGs, recreating them when enabled
+	runAutohealing(ctx, maintenanceWindow)
+
+	// Keep the MIGs on the configured instance template, replacing the outdated instances when enabled
+	runTemplateSwitch(ctx, maintenanceWindow)
+
+	// Check if the MIG is at its minimum size at least. If not, scale it up to minSize
+	err = google.CheckMIGMinimumSize(ctx)
+	if err != nil {
+		log.Printf("Error checking minimum size for MIG nodes: %v", err)
+		notifier.Notify(ctx, notifier.SeverityError, notifier.EventError, fmt.Sprintf("Error checking minimum size for MIG nodes: %v", err))
+		trackErrors(ctx, err)
+		return 0, err
+	}
+
+	// Get the current size of the MIGs and the scaling limits applied to it, the decisions are taken within them
+	_, size, _, _, err := google.GetMIGSizes(ctx)
+	if err != nil {
+		log.Printf("Error getting the size of the MIGs: %v", err)
+		notifier.Notify(ctx, notifier.SeverityError, notifier.EventError, fmt.Sprintf("Error getting the size of the MIGs: %v", err))
+		trackErrors(ctx, err)
+		return 0, err
+	}
+	limits := decision.GetLimits(ctx.Config, time.Now().UTC())
+
+	// Fetch the scale up conditions from Prometheus, by priority, until one of them is met
+	up, upCondition, upSamples, err := prometheus.GetFirstConditionMet(decision.UpConditions(ctx.Config), ctx)
+	if err != nil {
+		log.Printf("Error querying Prometheus: %v", err)
+		notifier.Notify(ctx, notifier.SeverityError, notifier.EventError, fmt.Sprintf("Error quering prometheus: %v", err))
+		trackErrors(ctx, err)
+		return 0, err
+	}
+	upValues := prometheus.SampleValues(upSamples)
+
+	// Count how many consecutive times an up condition has been met
+	ctx.Mutex.Lock()
+	if upCondition {
+		ctx.State.ConsecutiveUpConditions++
+		ctx.State.ConsecutiveDownConditions = 0
+	} else {
+		ctx.State.ConsecutiveUpConditions = 0
+	}
+	ctx.Mutex.Unlock()
+	if !upCondition {
+		notifier.Resolve(ctx, notifier.AlertMaxSizeSustained, "Up condition not met anymore, load is not sustained over the maximum size")
+	}
+
+	// If an up condition is met, add its step of nodes to the MIG, sized from the load observed when it has a threshold
+	upStep := up.Step
+	if upCondition {
+		upStep = up.ObservedStep(size, limits.MaxSize, upValues)
+	}
+	input := decision.Input{Now: time.Now(), MaintenanceWindow: maintenanceWindow, UpCondition: upCondition,
+		Size: size, Limits: limits.WithUpStep(upStep)}
+	result := decision.Decide(ctx.Config, input)
+	if result.Trigger == TriggerLimits {
+		log.Print(result.Reason)
+		notifyMaxSizeReached(ctx)
+		recordDecision(ctx, v1alpha1.Decision{Action: result.Action, Trigger: result.Trigger, Reason: result.Reason,
+			Condition: up.Query, MetricValues: upValues, MetricSamples: upSamples})
+		return result.CooldownSec, nil
+	}
+	if result.Action == v1alpha1.DecisionScaleUp {
+		log.Printf("Up condition %s met with %s: Trying to create a new node!", up.Name, prometheus.FormatSamples(upSamples))
+		scaling := v1alpha1.Decision{Trigger: result.Trigger, Condition: up.Query,
+			MetricValues: upValues, MetricSamples: upSamples}
+		if up.Threshold > 0 {
+			log.Printf("Adding %d nodes to the %d existing, from the load observed over the threshold %g", upStep, size, up.Threshold)
+		}
+		if !scaleUp(ctx, scaling, upStep) {
+			return 0, errScaleUp
+		}
+		// Wait for the default cooldown period before checking the conditions again
+		return cooldownAfterDecision(ctx, result.CooldownSec), nil
+	}
+
+	// Fetch the scale down conditions from Prometheus, by priority, until one of them is met
+	down, downCondition, downSamples, err := prometheus.GetFirstConditionMet(decision.DownConditions(ctx.Config), ctx)
+	if err != nil {
+		log.Printf("Error querying Prometheus: %v", err)
+		notifier.Notify(ctx, notifier.SeverityError, notifier.EventError, fmt.Sprintf("Error quering prometheus: %v", err))
+		trackErrors(ctx, err)
+		return 0, err
+	}
+	downValues := prometheus.SampleValues(downSamples)
+
+	// Count how many consecutive times a down condition has been met
+	ctx.Mutex.Lock()
+	if downCondition {
+		ctx.State.ConsecutiveDownConditions++
+	} else {
+		ctx.State.ConsecutiveDownConditions = 0
+	}
+	ctx.Mutex.Unlock()
+
+	// Scaling down is not allowed during scale-up-only maintenance windows, and is deferred while the nodes added by
+	// the last scale up are warming up, the data of the last node removed is not fully replicated, or the Elasticsearch
+	// cluster is relocating shards. The guards are only checked when nothing else prevents the scale down
+	input.DownCondition = downCondition
+	input.DownConditionName = down.Name
+	input.Limits = limits.WithDownStep(down.Step)
+	result = decision.Decide(ctx.Config, input)
+	if result.Action == v1alpha1.DecisionScaleDown {
+		input.Guard, err = checkScaleDownGuards(ctx)
+		if err != nil {
+			log.Printf("Error checking scale down guards: %v", err)
+			notifier.Notify(ctx, notifier.SeverityError, notifier.EventError, fmt.Sprintf("Error checking scale down guards: %v", err))
+			trackErrors(ctx, err)
+			return 0, err
+		}
+		result = decision.Decide(ctx.Config, input)
+	}
+
+	// If a down condition is met, remove its step of nodes from the MIG, one by one
+	if result.Action == v1alpha1.DecisionScaleDown {
+		log.Printf("Down condition %s met with %s. Trying to remove one node!", down.Name, prometheus.FormatSamples(downSamples))
+		scaling := v1alpha1.Decision{Trigger: result.Trigger, Condition: down.Query,
+			MetricValues: downValues, MetricSamples: downSamples}
+		for removed := int32(0); removed < max(down.Step, 1); removed++ {
+			nodeRemoved, ok := scaleDown(ctx, scaling)
+			if !ok {
+				return 0, errScaleDown
+			}
+			if !nodeRemoved {
+				break
+			}
+		}
+		// Wait for the scaledown cooldown period before checking the conditions again
+		return cooldownAfterDecision(ctx, result.CooldownSec), nil
+	}
+
+	if result.Trigger == TriggerLimits {
+		notifier.NotifyOnce(ctx, notifier.SeverityWarning, notifier.EventLimit, limitMinSize,
+			"Down condition met, but the MIG has reached its minimum size")
+	}
+	if downCondition {
+		log.Print(result.Reason)
+	} else {
+		// No scaling conditions met, so no changes to the MIG
+		log.Printf("No condition %s (%s) or %s (%s) met, keeping the same number of nodes!",
+			describeConditions(decision.UpConditions(ctx.Config)), prometheus.FormatSamples(upSamples),
+			describeConditions(decision.DownConditions(ctx.Config)), prometheus.FormatSamples(downSamples))
+	}
+	notifyNoAction(ctx, size, limits, up, down, upSamples, downSamples)
+	recordDecision(ctx, v1alpha1.Decision{Action: result.Action, Trigger: result.Trigger, Reason: result.Reason,
+		Condition: down.Query, MetricValues: downValues, MetricSamples: downSamples})
+	return result.CooldownSec, nil
+}
+
+// describeConditions returns the names of the conditions, joined to be logged
+func describeConditions(conditions []decision.Condition) string {
+	names := make([]string, 0, len(conditions))
+	for _, condition := range conditions {
+		names = append(names, condition.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// scaleUp adds the step of nodes to the MIG, or the scale up threshold when it is 0, and notifies the result,
+// completing the decision that triggered it. It returns false when the scaling failed
+func scaleUp(ctx *v1alpha1.Context, decision v1alpha1.Decision, step int32) bool {
+	decision.Action = v1alpha1.DecisionScaleUp
+	startTime := time.Now()
+	migName, previousSize, currentSize, maxSize, err := google.AddNodesToMIG(ctx, step)
+	decision.DurationMs = time.Since(startTime).Milliseconds()
+	if errors.Is(err, cost.ErrBudgetExceeded) {
+		log.Printf("Scale up blocked: %v", err)
+		notifier.Alert(ctx, notifier.SeverityWarning, notifier.AlertBudgetExceeded, fmt.Sprintf("Up condition met, but scale up blocked: %v", err))
+		decision.Action = v1alpha1.DecisionNone
+		decision.Reason = err.Error()
+		recordDecision(ctx, decision)
+		return true
+	}
+	if errors.Is(err, google.ErrStartupTimeout) {
+		// The MIG is resized anyway, so the scale up is recorded, and the cooldown applied, with the error
+		log.Printf("Added new node to MIG %s, but it is not ready: %v", migName, err)
+		notifier.Alert(ctx, notifier.SeverityError, notifier.AlertStartupTimeout, fmt.Sprintf("Added new node to MIG %s, but it is not ready: %v", migName, err))
+		decision.MIG, decision.PreviousSize, decision.Size = migName, previousSize, currentSize
+		decision.Error = err.Error()
+		ctx.Mutex.Lock()
+		ctx.State.LastScaleUpTime = time.Now()
+		ctx.State.LastScaleUpMIG = migName
+		ctx.Mutex.Unlock()
+		recordDecision(ctx, decision)
+		return true
+	}
+	if errors.Is(err, v1alpha1.ErrLeadershipLost) {
+		log.Printf("Scale up interrupted: %v", err)
+		decision.Action = v1alpha1.DecisionNone
+		decision.Reason = err.Error()
+		recordDecision(ctx, decision)
+		return true
+	}
+	if err != nil {
+		log.Printf("Error adding node to MIG: %v", err)
+		notifier.Notify(ctx, notifier.SeverityError, notifier.EventError, fmt.Sprintf("Error adding node to MIG: %v", err))
+		decision.Error = err.Error()
+		recordDecision(ctx, decision)
+		return false
+	}
+
+	// The MIG has already reached its maximum size. Alert when the load keeps requiring more nodes
+	if currentSize == -1 {
+		notifyMaxSizeReached(ctx)
+		decision.Action = v1alpha1.DecisionNone
+		decision.Reason = "maximum size reached"
+		recordDecision(ctx, decision)
+		return true
+	}
+
+	decision.MIG, decision.PreviousSize, decision.Size = migName, previousSize, currentSize
+	decision.HourlyCostDelta = estimateHourlyCostDelta(ctx, migName, currentSize-previousSize)
+	if ctx.Config.Cost.MonthlyBudget > 0 {
+		notifier.Resolve(ctx, notifier.AlertBudgetExceeded, "Scale up within the monthly budget again")
+	}
+
+	if ctx.Config.Autoscaler.StartupProbe.TimeoutSec > 0 {
+		notifier.Resolve(ctx, notifier.AlertStartupTimeout, fmt.Sprintf("New node of MIG %s ready after the scale up", migName))
+	}
+
+	// Notify that a node has been added. The MIG is not at its minimum size anymore
+	notifier.Rearm(ctx, limitMinSize)
+	notifier.NotifyDetailed(ctx, notifier.Notification{
+		Severity: notifier.SeverityInfo,
+		Event:    notifier.EventScaleUp,
+		Message:  fmt.Sprintf("Added new node to MIG %s. Current size is %d nodes and the maximum nodes to create are %d", migName, currentSize, maxSize),
+		Fields:   scalingFields(ctx, decision, "Max size", maxSize),
+	})
+
+	ctx.Mutex.Lock()
+	ctx.State.LastScaleUpTime = time.Now()
+	ctx.State.LastScaleUpMIG = migName
+	ctx.Mutex.Unlock()
+	recordDecision(ctx, decision)
+	syncReplicas(ctx)
+	return true
+}
+
+// syncReplicas sets the replicas of the indices following the data nodes of the cluster after the scaling action.
+// The nodes of the MIG are not counted, as the cluster may have data nodes out of it, and nodes without data roles in
+// it. Nothing is synced in a dry run of the target, as the cluster does not change. Errors are notified, but do not
+// fail the scaling action, already done
+func syncReplicas(ctx *v1alpha1.Context) {
+	if len(ctx.Config.Target.Elasticsearch.ReplicaSync) == 0 || ctx.Config.Target.Elasticsearch.URL == "" || ctx.Config.Autoscaler.DryRunTarget {
+		return
+	}
+
+	nodes, err := elasticsearch.GetNodes(ctx)
+	if err != nil {
+		log.Printf("Error getting the data nodes to sync the replicas of the indices: %v", err)
+		notifier.Notify(ctx, notifier.SeverityError, notifier.EventError, fmt.Sprintf("Error getting the data nodes to sync the replicas of the indices: %v", err))
+		return
+	}
+	var dataNodes int32
+	for _, node := range nodes {
+		if elasticsearch.IsDataNode(node) {
+			dataNodes++
+		}
+	}
+
+	err = elasticsearch.SyncReplicas(ctx, dataNodes)
+	if err != nil {
+		log.Printf("Error syncing the replicas of the indices: %v", err)
+		notifier.Notify(ctx, notifier.SeverityError, notifier.EventError, fmt.Sprintf("Error syncing the replicas of the indices with %d data nodes: %v", dataNodes, err))
+	}
+}
+
+// notifyMaxSizeReached notifies once that the up condition is met at the maximum size, alerting when the load keeps
+// requiring more nodes in consecutive evaluations
+func notifyMaxSizeReached(ctx *v1alpha1.Context) {
+	notifier.NotifyOnce(ctx, notifier.SeverityWarning, notifier.EventLimit, limitMaxSize,
+		"Up condition met, but the MIG has reached its maximum size")
+	ctx.Mutex.Lock()
+	consecutiveUpConditions := ctx.State.ConsecutiveUpConditions
+	ctx.Mutex.Unlock()
+	if consecutiveUpConditions >= ctx.Config.Notifications.Alerts.MaxSizeEvaluations {
+		notifier.Alert(ctx, notifier.SeverityWarning, notifier.AlertMaxSizeSustained,
+			fmt.Sprintf("Up condition met in %d consecutive evaluations, but the MIG has reached its maximum size", consecutiveUpConditions))
+	}
+}
+
+// scaleDown removes a node from the MIG and notifies the result, completing the decision that triggered it.
+// It returns whether a node was removed, and false when the scaling failed
+func scaleDown(ctx *v1alpha1.Context, decision v1alpha1.Decision) (bool, bool) {
+	decision.Action = v1alpha1.DecisionScaleDown
+	startTime := time.Now()
+	migName, previousSize, currentSize, minSize, nodeRemoved, err := google.RemoveNodeFromMIG(ctx)
+	decision.DurationMs = time.Since(startTime).Milliseconds()
+	if errors.Is(err, approval.ErrRejected) {
+		log.Printf("Scale down cancelled: %v", err)
+		notifier.Notify(ctx, notifier.SeverityInfo, notifier.EventScaleDown, fmt.Sprintf("Scale down cancelled: %v", err))
+		decision.Action = v1alpha1.DecisionNone
+		decision.Reason = err.Error()
+		recordDecision(ctx, decision)
+		return false, true
+	}
+	if errors.Is(err, v1alpha1.ErrLeadershipLost) {
+		log.Printf("Scale down interrupted: %v", err)
+		decision.Action = v1alpha1.DecisionNone
+		decision.Reason = err.Error()
+		recordDecision(ctx, decision)
+		return false, true
+	}
+	if err != nil {
+		log.Printf("Error draining node from MIG: %v", err)
+		notifier.Notify(ctx, notifier.SeverityError, notifier.EventError, fmt.Sprintf("Error draining node from MIG: %v", err))
+		decision.Error = err.Error()
+		startQuarantine(ctx)
+		recordDecision(ctx, decision)
+		return false, false
+	}
+
+	// The MIG has already reached its minimum size, or no instance can be removed
+	if nodeRemoved == "" {
+		notifier.NotifyOnce(ctx, notifier.SeverityWarning, notifier.EventLimit, limitMinSize,
+			"Down condition met, but the MIG has reached its minimum size or no node can be removed")
+		decision.Action = v1alpha1.DecisionNone
+		decision.Reason = "minimum size reached or no removable node"
+		recordDecision(ctx, decision)
+		return false, true
+	}
+
+	decision.MIG, decision.Instance, decision.PreviousSize, decision.Size = migName, nodeRemoved, previousSize, currentSize
+	decision.HourlyCostDelta = estimateHourlyCostDelta(ctx, migName, currentSize-previousSize)
+
+	// Notify that a node has been removed. The MIG is not at its maximum size anymore
+	notifier.Rearm(ctx, limitMaxSize)
+	notifier.NotifyDetailed(ctx, notifier.Notification{
+		Severity: notifier.SeverityInfo,
+		Event:    notifier.EventScaleDown,
+		Message:  fmt.Sprintf("Removed node %s from MIG %s. Current size is %d nodes and the minimum nodes to exist are %d", nodeRemoved, migName, currentSize, minSize),
+		Fields:   scalingFields(ctx, decision, "Min size", minSize),
+		Thread:   elasticsearch.DrainThread(nodeRemoved),
+	})
+
+	ctx.Mutex.Lock()
+	ctx.State.LastScaleDownTime = time.Now()
+	ctx.State.AwaitingReplication = ctx.Config.Target.Elasticsearch.URL != ""
+	ctx.Mutex.Unlock()
+	recordDecision(ctx, decision)
+	syncReplicas(ctx)
+	return true, true
+}
+
+// scalingFields returns the details of a scaling action shown in the notifications
+func scalingFields(ctx *v1alpha1.Context, decision v1alpha1.Decision, limitName string, limit int32) []notifier.Field {
+	fields := []notifier.Field{
+		{Name: "MIG", Value: decision.MIG},
+		{Name: "Size", Value: fmt.Sprintf("%d → %d", decision.PreviousSize, decision.Size)},
+		{Name: limitName, Value: fmt.Sprintf("%d", limit)},
+		{Name: "Duration", Value: (time.Duration(decision.DurationMs) * time.Millisecond).Round(time.Second).String()},
+	}
+	if decision.Condition != "" {
+		fields = append(fields, notifier.Field{Name: "Condition", Value: fmt.Sprintf("`%s`", decision.Condition)})
+		fields = append(fields, notifier.Field{Name: "Samples", Value: prometheus.FormatSamples(decision.MetricSamples)})
+	}
+	if decision.HourlyCostDelta != 0 {
+		fields = append(fields, notifier.Field{Name: "Hourly cost", Value: cost.FormatHourlyDelta(ctx, decision.HourlyCostDelta)})
+	}
+	return fields
+}
+
+// estimateHourlyCostDelta returns the difference of the hourly cost caused by adding or removing the nodes of the MIG.
+// Errors are logged, as the estimation is only informative
+func estimateHourlyCostDelta(ctx *v1alpha1.Context, migName string, nodes int32) float64 {
+	if !ctx.Config.Cost.Enabled {
+		return 0
+	}
+
+	price, err := google.GetMIGHourlyPrice(ctx, migName)
+	if err != nil {
+		log.Printf("Error estimating cost of MIG %s: %v", migName, err)
+		return 0
+	}
+	return price * float64(nodes)
+}
+
+// probeDependencies executes a harmless call to every dependency whose circuit is open.
+// The circuit of the dependencies answering is closed
+func probeDependencies(ctx *v1alpha1.Context, dependencies []string) {
+	for _, dependency := range dependencies {
+		log.Printf("Probing %s, whose circuit is open", dependency)
+
+		var err error
+		switch dependency {
+		case breaker.DependencyGCP:
+			_, _, _, _, err = google.GetMIGSizes(ctx)
+		case breaker.DependencyElasticsearch:
+			err = elasticsearch.CheckCluster(ctx)
+		}
+		if err != nil {
+			log.Printf("Probe of %s failed: %v", dependency, err)
+		}
+	}
+}
+
+// waitRetry sleeps before evaluating the conditions again after a failed evaluation.
+// It returns false when the run context is cancelled meanwhile
+func waitRetry(ctxRun context.Context, ctx *v1alpha1.Context) bool {
+	delay := retryBackoff(ctx)
+	log.Printf("Evaluating the conditions again in %s", delay.Round(time.Second))
+	return sleep(ctxRun, delay)
+}
+
+// sleep waits for the given duration, returning false when the run context is cancelled before
+func sleep(ctxRun context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctxRun.Done():
+		return false
+	}
+}
+
+// retryBackoff returns the time to wait after a failed evaluation, growing exponentially from retryIntervalSec
+// up to maxRetryIntervalSec while the evaluations keep failing
+func retryBackoff(ctx *v1alpha1.Context) time.Duration {
+	ctx.Mutex.Lock()
+	consecutiveErrors := ctx.ConsecutiveErrors
+	ctx.Mutex.Unlock()
+
+	return retry.Backoff(retry.Policy{
+		InitialInterval: time.Duration(ctx.Config.Autoscaler.RetryIntervalSec) * time.Second,
+		MaxInterval:     time.Duration(ctx.Config.Autoscaler.MaxRetryIntervalSec) * time.Second,
+		Multiplier:      ctx.Config.Retry.Multiplier,
+	}, consecutiveErrors)
+}
+
+// cooldownAfterDecision returns the cooldown to wait after the last decision: the given one when a scaling action
+// was taken, or the evaluation interval when nothing was done (e.g. the limits of the MIG were reached)
+func cooldownAfterDecision(ctx *v1alpha1.Context, cooldownSec int) int {
+	ctx.Mutex.Lock()
+	defer ctx.Mutex.Unlock()
+
+	if ctx.LastDecision != nil && ctx.LastDecision.Action == v1alpha1.DecisionNone {
+		return ctx.Config.Autoscaler.EvaluationIntervalSec
+	}
+	return cooldownSec
+}
+
+// waitCooldown records the end of the cooldown in the state, so it is respected after a restart, and sleeps until then
+// plus the evaluation jitter.
+// Scaling actions requested manually during the cooldown are executed right away, starting their own cooldown.
+// It returns false when the run context is cancelled meanwhile
+func waitCooldown(ctxRun context.Context, ctx *v1alpha1.Context, cooldownSec int) bool {
+	for {
+		ctx.Mutex.Lock()
+		ctx.State.CooldownUntil = time.Now().Add(time.Duration(cooldownSec) * time.Second)
+		ctx.Mutex.Unlock()
+		state.Save(ctx)
+		logNextScaling(ctx)
+
+		select {
+		case <-time.After(time.Duration(cooldownSec)*time.Second + evaluationJitter(ctx)):
+			return true
+		case request := <-ctx.Requests:
+			cooldownSec = runRequestedAction(ctx, request)
+		case <-ctxRun.Done():
+			return false
+		}
+	}
+}
+
+// evaluationJitter returns a random delay of up to evaluationJitterSec, added to the wait before every evaluation
+// so the autoscalers sharing a Prometheus server do not query it at the same time
+func evaluationJitter(ctx *v1alpha1.Context) time.Duration {
+	if ctx.Config.Autoscaler.EvaluationJitterSec <= 0 {
+		return 0
+	}
+	return rand.N(time.Duration(ctx.Config.Autoscaler.EvaluationJitterSec) * time.Second)
+}
+
+// logNextScaling logs when the autoscaler is allowed to scale again, so operators understand why it is idle.
+// The regular wait for the next evaluation is not logged
+func logNextScaling(ctx *v1alpha1.Context) {
+	next, err := state.GetNextScaling(ctx, time.Now())
+	if err != nil {
+		log.Printf("Error getting next scaling times: %v", err)
+		return
+	}
+	if next.Reason == "" || next.Reason == state.IdleReasonEvaluation {
+		return
+	}
+	log.Printf("Autoscaler %s idle (%s): next scale up allowed at %s, next scale down allowed at %s",
+		ctx.Config.Name, next.Reason, formatNextScaling(next.ScaleUpAt), formatNextScaling(next.ScaleDownAt))
+}
+
+// formatNextScaling formats the time when scaling is allowed again, which is zero when it is not foreseeable
+func formatNextScaling(t time.Time) string {
+	if t.IsZero() {
+		return "an unknown time"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// runRequestedAction executes a scaling action requested manually, ignoring the conditions. Actions requested while
+// the autoscaler is paused, or a maintenance window does not allow them, are rejected, keeping the cooldown in progress.
+// It returns the cooldown to wait afterwards
+func runRequestedAction(ctx *v1alpha1.Context, request v1alpha1.ActionRequest) int {
+	trigger, reason, err := checkRequestedAction(ctx, request.Action)
+	if err != nil {
+		log.Printf("Error checking %s requested manually: %v", request.Action, err)
+		notifier.Notify(ctx, notifier.SeverityError, notifier.EventError, fmt.Sprintf("Error checking %s requested manually: %v", request.Action, err))
+		trackErrors(ctx, err)
+		return int(retryBackoff(ctx).Seconds())
+	}
+	if reason != "" {
+		reason = fmt.Sprintf("%s requested manually rejected: %s. %s", request.Action, reason, request.Reason)
+		log.Print(reason)
+		notifier.Notify(ctx, notifier.SeverityWarning, notifier.EventLimit, reason)
+		recordDecision(ctx, v1alpha1.Decision{Action: v1alpha1.DecisionNone, Trigger: trigger, Reason: reason})
+
+		ctx.Mutex.Lock()
+		defer ctx.Mutex.Unlock()
+		return max(0, int(time.Until(ctx.State.CooldownUntil).Seconds()))
+	}
+
+	log.Printf("Executing %s requested manually. %s", request.Action, request.Reason)
+
+	switch request.Action {
+	case v1alpha1.DecisionScaleUp:
+		if scaleUp(ctx, v1alpha1.Decision{Trigger: TriggerManual, Reason: request.Reason}, 0) {
+			return ctx.Config.Autoscaler.DefaultCooldownPeriodSec
+		}
+	case v1alpha1.DecisionScaleDown:
+		if _, ok := scaleDown(ctx, v1alpha1.Decision{Trigger: TriggerManual, Reason: request.Reason}); ok {
+			return ctx.Config.Autoscaler.ScaleDownCooldownPeriodSec
+		}
+	case v1alpha1.ActionScaleToMax:
+		if scaleToMax(ctx, v1alpha1.Decision{Trigger: TriggerEmergency, Reason: request.Reason}) {
+			return ctx.Config.Autoscaler.DefaultCooldownPeriodSec
+		}
+	default:
+		log.Printf("Unknown action %s requested manually", request.Action)
+		return 0
+	}
+	return int(retryBackoff(ctx).Seconds())
+}
+
+// checkRequestedAction returns the trigger and the reason rejecting the scaling action requested manually: the pause
+// of the autoscaler and the maintenance windows not allowing it, and for the scale downs, the open circuits and the
+// guards deferring the ones of the conditions, like the quarantine. The reason is empty when the action is allowed
+func checkRequestedAction(ctx *v1alpha1.Context, action string) (string, string, error) {
+	if pause := state.GetPause(ctx); pause != nil {
+		return TriggerPause, fmt.Sprintf("the autoscaler is paused (reason: %q)", pause.Reason), nil
+	}
+
+	window, err := maintenance.GetActiveWindow(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to check maintenance windows: %v", err)
+	}
+	if window != nil && (window.Mode != maintenance.ModeScaleUpOnly || action == v1alpha1.DecisionScaleDown) {
+		return TriggerMaintenance, fmt.Sprintf("the maintenance window on days %s and hours %s does not allow it", window.Days, window.HoursUTC), nil
+	}
+	if action != v1alpha1.DecisionScaleDown {
+		return "", "", nil
+	}
+
+	if open := breaker.OpenCircuits(ctx); len(open) > 0 {
+		return TriggerCircuit, fmt.Sprintf("the circuit is open for %s after repeated failures", strings.Join(open, ", ")), nil
+	}
+	guard, err := checkScaleDownGuards(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to check scale down guards: %v", err)
+	}
+	return guard.Trigger, guard.Reason, nil
+}
+
+// scaleToMax adds every node missing up to the maximum size of the limits applied, at once, for incident response.
+// The headroom left within the maximum size of the limits and of every MIG is computed once, and spread over the MIGs
+// by a single scale up, so it ends even in a dry run of the infrastructure, where the sizes never change. It returns false when the scaling failed
+func scaleToMax(ctx *v1alpha1.Context, decision v1alpha1.Decision) bool {
+	notifier.Notify(ctx, notifier.SeverityWarning, notifier.EventScaleUp, fmt.Sprintf("Emergency scale up to the maximum size requested. %s", decision.Reason))
+
+	headroom, err := google.GetScaleUpHeadroom(ctx)
+	if err != nil {
+		log.Printf("Error getting MIG sizes: %v", err)
+		decision.Action = v1alpha1.DecisionScaleUp
+		decision.Error = err.Error()
+		recordDecision(ctx, decision)
+		return false
+	}
+	if headroom == 0 {
+		log.Printf("MIGs already at their maximum size, nothing to do")
+		decision.Action = v1alpha1.DecisionNone
+		decision.Reason = fmt.Sprintf("maximum size already reached. %s", decision.Reason)
+		recordDecision(ctx, decision)
+		return true
+	}
+
+	return scaleUp(ctx, decision, headroom)
+}
+
+// recordDecision publishes the decision as the last one taken and writes it to the audit log, the log sinks, StatsD and the Kubernetes events.
+// Scaling actions are kept in the history too
+func recordDecision(ctx *v1alpha1.Context, decision v1alpha1.Decision) {
+	decision.Time = time.Now()
+	decision.Autoscaler = ctx.Config.Name
+	switch {
+	case decision.Error != "":
+		decision.Outcome = v1alpha1.OutcomeFailed
+	case decision.Action == v1alpha1.DecisionNone:
+		decision.Outcome = v1alpha1.OutcomeSkipped
+	default:
+		decision.Outcome = v1alpha1.OutcomeSuccess
+	}
+	audit.Record(decision)
+	logging.RecordDecision(decision)
+	statsd.Record(decision)
+	kubeevents.RecordDecision(decision)
+	controlplane.RecordDecision(decision)
+
+	// Evaluations skipped by an open circuit neither fail nor succeed, the circuit alert follows them
+	switch {
+	case decision.Trigger == TriggerCircuit:
+	case decision.Error != "":
+		trackErrors(ctx, errors.New(decision.Error))
+	default:
+		trackErrors(ctx, nil)
+	}
+
+	ctx.Mutex.Lock()
+	defer ctx.Mutex.Unlock()
+
+	ctx.LastDecision = &decision
+	if decision.Action == v1alpha1.DecisionNone {
+		return
+	}
+	ctx.History = append(ctx.History, decision)
+	if len(ctx.History) > historySize {
+		ctx.History = ctx.History[len(ctx.History)-historySize:]
+	}
+}
+
+// trackErrors counts the evaluations failed in a row, alerting when they reach the threshold,
+// and resolves the alert as soon as an evaluation succeeds
+func trackErrors(ctx *v1alpha1.Context, err error) {
+	ctx.Mutex.Lock()
+	if err == nil {
+		ctx.ConsecutiveErrors = 0
+	} else {
+		ctx.ConsecutiveErrors++
+	}
+	consecutiveErrors := ctx.ConsecutiveErrors
+	ctx.Mutex.Unlock()
+
+	if err == nil {
+		notifier.Resolve(ctx, notifier.AlertRepeatedErrors, "Evaluations succeeding again after repeated errors")
+		return
+	}
+	if consecutiveErrors >= ctx.Config.Notifications.Alerts.RepeatedErrors {
+		notifier.Alert(ctx, notifier.SeverityError, notifier.AlertRepeatedErrors,
+			fmt.Sprintf("%d consecutive evaluations failed. Last error: %v", consecutiveErrors, err))
+	}
+}
+
+// reportConditions evaluates the scaling conditions and logs them, without acting on them
+func reportConditions(ctx *v1alpha1.Context, reason string) {
+	up, upCondition, upSamples, err := prometheus.GetFirstConditionMet(decision.UpConditions(ctx.Config), ctx)
+	if err != nil {
+		log.Printf("Error querying Prometheus: %v", err)
+		return
+	}
+	down, downCondition, downSamples, err := prometheus.GetFirstConditionMet(decision.DownConditions(ctx.Config), ctx)
+	if err != nil {
+		log.Printf("Error querying Prometheus: %v", err)
+		return
+	}
+
+	log.Printf("%s. Up condition %s met: %t (%s), down condition %s met: %t (%s). No scaling decisions are taken",
+		reason, up.Name, upCondition, prometheus.FormatSamples(upSamples), down.Name, downCondition, prometheus.FormatSamples(downSamples))
+}
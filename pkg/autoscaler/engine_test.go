@@ -0,0 +1,119 @@
+package autoscaler
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/fake"
+	"custom-vm-autoscaler/internal/google"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+const engineTestConfig = `
+name: "engine"
+metrics:
+  prometheus:
+    upCondition: "up_condition"
+    downCondition: "down_condition"
+infrastructure:
+  gcp:
+    projectId: "fake-project"
+    zone: "europe-west1-b"
+    migName: "fake-mig"
+    scaleDownAction: "abandon"
+target:
+  elasticsearch:
+    url: "https://localhost:9200"
+    drainTimeoutSec: 2
+    drainPollIntervalSec: 1
+    replicaSync:
+      - pattern: "logs-*"
+retry:
+  maxAttempts: 2
+  initialIntervalSec: 1
+autoscaler:
+  minSize: 1
+  maxSize: 4
+  scaleUpThreshold: 1
+`
+
+// newEngineAutoscaler starts the fake backend, and creates an autoscaler using it with two nodes in its MIG. The
+// cluster also has a data node and a dedicated master out of the MIG
+func newEngineAutoscaler(t *testing.T, configure func(backend *fake.Backend, config *v1alpha1.ConfigSpec)) (*fake.Backend, *v1alpha1.Context) {
+	t.Helper()
+	backend := fake.Start()
+	t.Cleanup(backend.Close)
+	backend.Elasticsearch.SetDrainDelay(0)
+	backend.Elasticsearch.AddNodeWithRoles("other-data", "dh")
+	backend.Elasticsearch.AddNodeWithRoles("master", "m")
+
+	var config v1alpha1.ConfigSpec
+	err := yaml.UnmarshalStrict([]byte(engineTestConfig), &config)
+	if err != nil {
+		t.Fatalf("error parsing config: %v", err)
+	}
+	backend.Apply(&config)
+
+	autoscalers, err := New(config)
+	if err != nil {
+		t.Fatalf("error creating autoscaler: %v", err)
+	}
+	ctx := autoscalers[0].Context()
+
+	for range 2 {
+		_, _, _, _, err = google.AddNodesToMIG(ctx, 1)
+		if err != nil {
+			t.Fatalf("error adding node: %v", err)
+		}
+	}
+	if configure != nil {
+		configure(backend, ctx.Config)
+	}
+	return backend, ctx
+}
+
+func TestScaleUpSyncsReplicasWithDataNodes(t *testing.T) {
+	backend, ctx := newEngineAutoscaler(t, nil)
+
+	// The MIG has 3 nodes, but the cluster has 4 data nodes
+	if !scaleUp(ctx, v1alpha1.Decision{}, 1) {
+		t.Fatalf("scaleUp() failed")
+	}
+	if replicas, ok := backend.Elasticsearch.Replicas("logs-*"); !ok || replicas != 3 {
+		t.Errorf("replicas after the scale up = %d, %t, want 3", replicas, ok)
+	}
+}
+
+func TestScaleUpSkipsReplicaSync(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(backend *fake.Backend, config *v1alpha1.ConfigSpec)
+	}{
+		{
+			name: "dry run of the target",
+			configure: func(_ *fake.Backend, config *v1alpha1.ConfigSpec) {
+				config.Autoscaler.DryRunTarget = true
+			},
+		},
+		{
+			name: "startup timeout",
+			configure: func(backend *fake.Backend, config *v1alpha1.ConfigSpec) {
+				backend.Compute.SetBooting(true)
+				config.Autoscaler.StartupProbe = v1alpha1.StartupProbeSpec{TimeoutSec: 1, PeriodSec: 1}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backend, ctx := newEngineAutoscaler(t, test.configure)
+
+			if !scaleUp(ctx, v1alpha1.Decision{}, 1) {
+				t.Fatalf("scaleUp() failed")
+			}
+			if replicas, ok := backend.Elasticsearch.Replicas("logs-*"); ok {
+				t.Errorf("replicas set to %d, want them untouched", replicas)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package autoscaler
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/decision"
+	"custom-vm-autoscaler/internal/elasticsearch"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// checkScaleDownGuards returns the guard deferring the scale down when the down condition is met,
+// or a guard without reason when nothing prevents it
+func checkScaleDownGuards(ctx *v1alpha1.Context) (decision.Guard, error) {
+	reason, err := checkWarmup(ctx)
+	if err != nil || reason != "" {
+		return decision.Guard{Trigger: TriggerWarmup, Reason: reason}, err
+	}
+
+	if ctx.Config.Target.Elasticsearch.URL == "" {
+		reason = checkQuarantine(ctx, nil)
+		if reason != "" {
+			return decision.Guard{Trigger: TriggerQuarantine, Reason: reason}, nil
+		}
+		return decision.Guard{}, nil
+	}
+	health, err := elasticsearch.GetClusterHealth(ctx)
+	if err != nil {
+		return decision.Guard{}, fmt.Errorf("failed to get Elasticsearch cluster health: %v", err)
+	}
+
+	reason = checkQuarantine(ctx, &health)
+	if reason != "" {
+		return decision.Guard{Trigger: TriggerQuarantine, Reason: reason}, nil
+	}
+
+	reason = checkReplication(ctx, health)
+	if reason != "" {
+		return decision.Guard{Trigger: TriggerReplication, Reason: reason}, nil
+	}
+
+	reason = checkRelocatingShards(ctx, health)
+	if reason != "" {
+		return decision.Guard{Trigger: TriggerRelocation, Reason: reason}, nil
+	}
+
+	reason, err = checkIndexOperations(ctx)
+	if err != nil || reason != "" {
+		return decision.Guard{Trigger: TriggerIndexOperations, Reason: reason}, err
+	}
+
+	reason, err = checkMinHealthy(ctx, health)
+	if err != nil || reason != "" {
+		return decision.Guard{Trigger: TriggerMinHealthy, Reason: reason}, err
+	}
+	return decision.Guard{}, nil
+}
+
+// checkReplication returns why the scale downs are deferred after removing a node, until the cluster has no unassigned
+// nor initializing shards, so the data of the node is fully replicated before removing the next one
+func checkReplication(ctx *v1alpha1.Context, health v1alpha1.ClusterHealth) string {
+	ctx.Mutex.Lock()
+	defer ctx.Mutex.Unlock()
+
+	if !ctx.State.AwaitingReplication {
+		return ""
+	}
+	if health.UnassignedShards > 0 || health.InitializingShards > 0 {
+		return fmt.Sprintf("the data of the last node removed is not fully replicated yet (%d unassigned and %d initializing shards)",
+			health.UnassignedShards, health.InitializingShards)
+	}
+	ctx.State.AwaitingReplication = false
+	return ""
+}
+
+// checkRelocatingShards returns why the scale downs are deferred while the Elasticsearch cluster relocates
+// more shards than allowed, as draining a node on top of a rebalance doubles the recovery traffic
+func checkRelocatingShards(ctx *v1alpha1.Context, health v1alpha1.ClusterHealth) string {
+	if health.RelocatingShards > ctx.Config.Target.Elasticsearch.MaxRelocatingShards {
+		return fmt.Sprintf("the Elasticsearch cluster is relocating %d shards (maximum %d)",
+			health.RelocatingShards, ctx.Config.Target.Elasticsearch.MaxRelocatingShards)
+	}
+	return ""
+}
+
+// checkIndexOperations returns why the scale downs are deferred while force merges or rollovers run on the indices
+// of the patterns configured, as draining a node on top of them stacks their I/O
+func checkIndexOperations(ctx *v1alpha1.Context) (string, error) {
+	operations, err := elasticsearch.GetIndexOperations(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the index operations in progress: %v", err)
+	}
+	if len(operations) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("%d force merges or rollovers are running on the indices (%s)", len(operations), strings.Join(operations, "; ")), nil
+}
+
+// checkMinHealthy returns why the scale downs are refused when removing a node would leave less healthy data nodes
+// than minHealthy.nodes, independently of the minimum size of the MIGs. The node removed is not known yet, so it is
+// counted as healthy
+func checkMinHealthy(ctx *v1alpha1.Context, health v1alpha1.ClusterHealth) (string, error) {
+	spec := ctx.Config.Target.Elasticsearch.MinHealthy
+	if spec.Nodes == 0 {
+		return "", nil
+	}
+	nodes, err := elasticsearch.GetNodes(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get Elasticsearch nodes: %v", err)
+	}
+	healthy := countHealthyNodes(nodes, health.Status, spec.MaxHeapPercent)
+	if healthy-1 < spec.Nodes {
+		return fmt.Sprintf("removing a node would leave %d healthy data nodes (minimum %d, cluster %s)",
+			max(healthy-1, 0), spec.Nodes, health.Status), nil
+	}
+	return "", nil
+}
+
+// countHealthyNodes returns the data nodes with their heap below maxHeapPercent, none of them being healthy
+// while the cluster is not green
+func countHealthyNodes(nodes []v1alpha1.NodeInfo, status string, maxHeapPercent float64) int {
+	if status != "green" {
+		return 0
+	}
+	healthy := 0
+	for _, node := range nodes {
+		if !elasticsearch.IsDataNode(node) {
+			continue
+		}
+		heap, err := strconv.ParseFloat(node.HeapPercent, 64)
+		if err == nil && heap < maxHeapPercent {
+			healthy++
+		}
+	}
+	return healthy
+}
@@ -0,0 +1,35 @@
+package autoscaler
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"testing"
+)
+
+func TestCountHealthyNodes(t *testing.T) {
+	nodes := []v1alpha1.NodeInfo{
+		{Name: "data-1", NodeRole: "dim", HeapPercent: "40"},
+		{Name: "data-2", NodeRole: "d", HeapPercent: "90"},
+		{Name: "data-3", NodeRole: "hs", HeapPercent: "60"},
+		{Name: "data-4", NodeRole: "d", HeapPercent: ""},
+		{Name: "master-1", NodeRole: "m", HeapPercent: "10"},
+	}
+
+	tests := []struct {
+		name   string
+		status string
+		want   int
+	}{
+		{name: "green cluster", status: "green", want: 2},
+		{name: "yellow cluster", status: "yellow", want: 0},
+		{name: "red cluster", status: "red", want: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := countHealthyNodes(nodes, test.status, 85)
+			if got != test.want {
+				t.Errorf("countHealthyNodes() = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package autoscaler
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/state"
+	"fmt"
+	"log"
+	"time"
+)
+
+// startQuarantine defers the next scale downs after a failed one, so another node is not selected and drained
+// on the next evaluation while the cluster is still recovering from the failure. The failure is recorded even
+// without quarantine period, so the scale downs still wait for the Elasticsearch cluster to be green
+func startQuarantine(ctx *v1alpha1.Context) {
+	if period := ctx.Config.Autoscaler.Quarantine.PeriodSec; period > 0 {
+		log.Printf("Scale downs quarantined for %ds after the failed scale down", period)
+	}
+
+	ctx.Mutex.Lock()
+	ctx.State.ScaleDownFailedTime = time.Now()
+	ctx.Mutex.Unlock()
+	state.Save(ctx)
+}
+
+// checkQuarantine returns why the scale downs are deferred after a failed one: during the quarantine period and,
+// when the health of the Elasticsearch cluster is given, until it is green. The quarantine ends once both are over
+func checkQuarantine(ctx *v1alpha1.Context, health *v1alpha1.ClusterHealth) string {
+	ctx.Mutex.Lock()
+	if ctx.State.ScaleDownFailedTime.IsZero() {
+		ctx.Mutex.Unlock()
+		return ""
+	}
+	period := time.Duration(ctx.Config.Autoscaler.Quarantine.PeriodSec) * time.Second
+	if elapsed := time.Since(ctx.State.ScaleDownFailedTime); elapsed < period {
+		ctx.Mutex.Unlock()
+		return fmt.Sprintf("the last scale down failed, quarantined for %s more", (period - elapsed).Round(time.Second))
+	}
+	if health != nil && health.Status != "green" {
+		ctx.Mutex.Unlock()
+		return fmt.Sprintf("the last scale down failed, quarantined until the Elasticsearch cluster is green (now %s)", health.Status)
+	}
+	ctx.State.ScaleDownFailedTime = time.Time{}
+	ctx.Mutex.Unlock()
+
+	log.Printf("Quarantine of the scale downs over")
+	state.Save(ctx)
+	return ""
+}
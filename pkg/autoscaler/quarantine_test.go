@@ -0,0 +1,97 @@
+package autoscaler
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckQuarantine(t *testing.T) {
+	green := &v1alpha1.ClusterHealth{Status: "green"}
+	yellow := &v1alpha1.ClusterHealth{Status: "yellow"}
+
+	tests := []struct {
+		name       string
+		periodSec  int
+		failedAgo  time.Duration
+		health     *v1alpha1.ClusterHealth
+		wantReason string
+		wantOver   bool
+	}{
+		{
+			name:     "no failed scale down",
+			health:   yellow,
+			wantOver: true,
+		},
+		{
+			name:       "during the period",
+			periodSec:  600,
+			failedAgo:  time.Minute,
+			health:     green,
+			wantReason: "quarantined for 9m0s more",
+		},
+		{
+			name:       "period expired, cluster not green",
+			periodSec:  600,
+			failedAgo:  time.Hour,
+			health:     yellow,
+			wantReason: "quarantined until the Elasticsearch cluster is green (now yellow)",
+		},
+		{
+			name:      "period expired, cluster green",
+			periodSec: 600,
+			failedAgo: time.Hour,
+			health:    green,
+			wantOver:  true,
+		},
+		{
+			name:      "period expired without Elasticsearch",
+			periodSec: 600,
+			failedAgo: time.Hour,
+			wantOver:  true,
+		},
+		{
+			name:       "no period, cluster not green",
+			failedAgo:  time.Second,
+			health:     yellow,
+			wantReason: "quarantined until the Elasticsearch cluster is green (now yellow)",
+		},
+		{
+			name:      "no period, cluster green",
+			failedAgo: time.Second,
+			health:    green,
+			wantOver:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := &v1alpha1.Context{Config: &v1alpha1.ConfigSpec{}}
+			ctx.Config.Autoscaler.Quarantine.PeriodSec = test.periodSec
+			if test.failedAgo > 0 {
+				ctx.State.ScaleDownFailedTime = time.Now().Add(-test.failedAgo)
+			}
+
+			reason := checkQuarantine(ctx, test.health)
+			if test.wantReason == "" && reason != "" || !strings.HasSuffix(reason, test.wantReason) {
+				t.Errorf("checkQuarantine() = %q, want it ending with %q", reason, test.wantReason)
+			}
+			if over := ctx.State.ScaleDownFailedTime.IsZero(); over != test.wantOver {
+				t.Errorf("quarantine over = %v, want %v", over, test.wantOver)
+			}
+		})
+	}
+}
+
+func TestStartQuarantineWithoutPeriod(t *testing.T) {
+	ctx := &v1alpha1.Context{Config: &v1alpha1.ConfigSpec{}}
+
+	startQuarantine(ctx)
+	if ctx.State.ScaleDownFailedTime.IsZero() {
+		t.Fatal("startQuarantine() did not record the failed scale down")
+	}
+	if reason := checkQuarantine(ctx, &v1alpha1.ClusterHealth{Status: "red"}); reason == "" {
+		t.Error("checkQuarantine() did not require the Elasticsearch cluster to be green")
+	}
+}
@@ -0,0 +1,112 @@
+package autoscaler
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/elasticsearch"
+	"custom-vm-autoscaler/internal/google"
+	"custom-vm-autoscaler/internal/notifier"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// reconciliationDisabledInterval is the time between the checks of whether the reconciliation was enabled
+// by a change of the config
+const reconciliationDisabledInterval = time.Minute
+
+// runReconciliation periodically checks that every instance of the MIGs has joined the Elasticsearch cluster
+// as a data node, while this replica is the leader, until the run context is cancelled
+func runReconciliation(ctxRun context.Context, ctx *v1alpha1.Context, elector Elector) {
+	missingSince := map[string]time.Time{}
+	for {
+		intervalSec := ctx.Config.Target.Elasticsearch.Reconciliation.IntervalSec
+		if intervalSec <= 0 || ctx.Config.Target.Elasticsearch.URL == "" {
+			if !sleep(ctxRun, reconciliationDisabledInterval) {
+				return
+			}
+			continue
+		}
+		if !sleep(ctxRun, time.Duration(intervalSec)*time.Second) {
+			return
+		}
+
+		elector.WaitForLeadership()
+		err := reconcileNodes(ctx, missingSince, time.Now())
+		if err != nil {
+			log.Printf("Error reconciling the instances of the MIGs with the Elasticsearch nodes: %v", err)
+		}
+	}
+}
+
+// reconcileNodes alerts when instances of the MIGs have not been data nodes of the Elasticsearch cluster for longer than
+// the grace period, resolving the alert once all of them are. The instance of the scaling operation in flight is excluded.
+// missingSince holds when every instance was first seen missing from the cluster
+func reconcileNodes(ctx *v1alpha1.Context, missingSince map[string]time.Time, now time.Time) error {
+	migSizes, targetSize, _, _, err := google.GetMIGSizes(ctx)
+	if err != nil {
+		return err
+	}
+	var instances []string
+	for migName := range migSizes {
+		names, err := google.GetMIGInstanceNames(ctx, migName)
+		if err != nil {
+			return fmt.Errorf("failed to get instances of MIG %s: %v", migName, err)
+		}
+		instances = append(instances, names...)
+	}
+
+	nodes, err := elasticsearch.GetNodes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get nodes of the Elasticsearch cluster: %v", err)
+	}
+	dataNodes := map[string]bool{}
+	for _, node := range nodes {
+		if elasticsearch.IsDataNode(node) {
+			dataNodes[node.Name] = true
+		}
+	}
+
+	ctx.Mutex.Lock()
+	operation := ctx.State.InFlightOperation
+	ctx.Mutex.Unlock()
+
+	grace := time.Duration(ctx.Config.Target.Elasticsearch.Reconciliation.GraceSec) * time.Second
+	joined := 0
+	current := map[string]bool{}
+	var missing []string
+	for _, instance := range instances {
+		if operation != nil && operation.Instance == instance {
+			continue
+		}
+		if dataNodes[instance] {
+			joined++
+			continue
+		}
+
+		current[instance] = true
+		if _, ok := missingSince[instance]; !ok {
+			missingSince[instance] = now
+		}
+		if now.Sub(missingSince[instance]) >= grace {
+			missing = append(missing, instance)
+		}
+	}
+	for instance := range missingSince {
+		if !current[instance] {
+			delete(missingSince, instance)
+		}
+	}
+
+	if len(missing) == 0 {
+		notifier.Resolve(ctx, notifier.AlertNodeDrift, "Every instance of the MIGs is a data node of the Elasticsearch cluster again")
+		return nil
+	}
+	sort.Strings(missing)
+	notifier.Alert(ctx, notifier.SeverityWarning, notifier.AlertNodeDrift,
+		fmt.Sprintf("Instances %s have not joined the Elasticsearch cluster as data nodes in %s. The MIGs have a target size of %d nodes, and %d of their instances are data nodes",
+			strings.Join(missing, ", "), grace, targetSize, joined))
+	return nil
+}
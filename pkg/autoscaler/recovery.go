@@ -0,0 +1,159 @@
+package autoscaler
+
+import (
+	"context"
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/elasticsearch"
+	"custom-vm-autoscaler/internal/google"
+	"custom-vm-autoscaler/internal/notifier"
+	"custom-vm-autoscaler/internal/state"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"slices"
+	"time"
+)
+
+// superviseAutoscaler runs the main loop of the autoscaler until the run context is cancelled, restarting it after
+// a delay when it panics, so an unexpected failure does not kill the whole process with every other autoscaler
+func superviseAutoscaler(ctxRun context.Context, ctx *v1alpha1.Context, elector Elector) {
+	for {
+		err := runRecovering(ctxRun, ctx, elector)
+		if err == nil {
+			return
+		}
+
+		trackErrors(ctx, err)
+		delay := retryBackoff(ctx)
+		notifier.Notify(ctx, notifier.SeverityError, notifier.EventError,
+			fmt.Sprintf("Autoscaler %s failed unexpectedly, restarting it in %s: %v", ctx.Config.Name, delay.Round(time.Second), err))
+		if !sleep(ctxRun, delay) {
+			return
+		}
+		log.Printf("Restarting autoscaler %s", ctx.Config.Name)
+	}
+}
+
+// runRecovering runs the main loop of the autoscaler, returning the panic raised by it, if any
+func runRecovering(ctxRun context.Context, ctx *v1alpha1.Context, elector Elector) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Autoscaler %s panicked: %v\n%s", ctx.Config.Name, r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	runAutoscaler(ctxRun, ctx, elector)
+	return nil
+}
+
+// recoverInFlightOperation finishes the scaling operation interrupted by a crash of the previous execution,
+// or by a panic of the loop of the autoscaler.
+// Scale ups and scale downs interrupted while resizing or removing the instance are checked against the MIG, so they
+// are not issued twice: the ones already applied start their cooldown, and the removals not applied yet are resumed.
+// Interrupted drains, deletions, abandons and recreations leave the node excluded from the elasticsearch allocation,
+// so it is cleared, as is the drain state label of the instances of the scale downs.
+func recoverInFlightOperation(ctx *v1alpha1.Context) {
+	ctx.Mutex.Lock()
+	operation := ctx.State.InFlightOperation
+	ctx.Mutex.Unlock()
+	if operation == nil {
+		return
+	}
+
+	log.Printf("Recovering %s operation %s of instance %s interrupted in phase %s", operation.Type, operation.Key, operation.Instance, operation.Phase)
+
+	if operation.Type == state.OperationScaleUp {
+		recoverScaleUp(ctx, *operation)
+		return
+	}
+
+	if operation.Type == state.OperationScaleDown && slices.Contains(removalPhases, operation.Phase) {
+		applied, err := google.OperationApplied(ctx, *operation)
+		if err != nil {
+			// Keep the operation, so it is recovered again on the next start
+			log.Printf("Error checking whether the interrupted operation took effect: %v", err)
+			return
+		}
+		if !applied {
+			err = google.ResumeScaleDown(ctx, *operation)
+			if err != nil {
+				log.Printf("Error resuming the interrupted operation: %v", err)
+				return
+			}
+		}
+		startRecoveredCooldown(ctx, ctx.Config.Autoscaler.ScaleDownCooldownPeriodSec)
+		ctx.Mutex.Lock()
+		ctx.State.LastScaleDownTime = time.Now()
+		ctx.Mutex.Unlock()
+	}
+
+	if ctx.Config.Target.Elasticsearch.URL != "" {
+		err := elasticsearch.ClearElasticsearchClusterSettings(ctx, operation.Instance)
+		if err != nil {
+			// Keep the operation, so it is recovered again on the next start
+			log.Printf("Error clearing Elasticsearch cluster settings for interrupted operation: %v", err)
+			return
+		}
+		log.Printf("Cleared up elasticsearch settings for instance %s", operation.Instance)
+	}
+
+	// The drain state label is not worth keeping the operation for, so errors removing it are only logged
+	if operation.Type == state.OperationScaleDown {
+		err := google.ClearDrainState(ctx, *operation)
+		if err != nil {
+			log.Printf("Error removing the drain state label of instance %s: %v", operation.Instance, err)
+		}
+	}
+
+	notifier.Notify(ctx, notifier.SeverityWarning, notifier.EventRecovery, fmt.Sprintf("Recovered %s operation of instance %s in MIG %s, interrupted in phase %s", operation.Type, operation.Instance, operation.MIG, operation.Phase))
+
+	state.FinishOperation(ctx)
+}
+
+// removalPhases are the phases of the scale downs in which the instance may have been removed from the MIG
+var removalPhases = []string{state.PhaseDeleting, state.PhaseAbandoning, state.PhaseParking}
+
+// recoverRaisedSettings restores the Elasticsearch cluster settings raised by a drain interrupted by a crash of the
+// previous execution. They are kept in the state when they can not be restored, to retry on the next start
+func recoverRaisedSettings(ctx *v1alpha1.Context) {
+	err := elasticsearch.RestoreRaisedSettings(ctx)
+	if err != nil {
+		log.Printf("Error restoring the Elasticsearch cluster settings raised by the interrupted drain: %v", err)
+	}
+}
+
+// recoverScaleUp checks whether the resize interrupted by a crash took effect, starting the cooldown of the scale up
+// when it did. Otherwise nothing is issued again, as the conditions are evaluated anew
+func recoverScaleUp(ctx *v1alpha1.Context, operation v1alpha1.Operation) {
+	applied, err := google.OperationApplied(ctx, operation)
+	if err != nil {
+		// Keep the operation, so it is recovered again on the next start
+		log.Printf("Error checking whether the interrupted operation took effect: %v", err)
+		return
+	}
+
+	if applied {
+		startRecoveredCooldown(ctx, ctx.Config.Autoscaler.DefaultCooldownPeriodSec)
+		ctx.Mutex.Lock()
+		ctx.State.LastScaleUpTime = time.Now()
+		ctx.State.LastScaleUpMIG = operation.MIG
+		ctx.Mutex.Unlock()
+		notifier.Notify(ctx, notifier.SeverityWarning, notifier.EventRecovery, fmt.Sprintf("Recovered scale up of MIG %s to %d nodes, interrupted after taking effect", operation.MIG, operation.ExpectedSize))
+	} else {
+		log.Printf("Scale up of MIG %s to %d nodes interrupted before taking effect, the conditions are evaluated again", operation.MIG, operation.ExpectedSize)
+	}
+
+	state.FinishOperation(ctx)
+}
+
+// startRecoveredCooldown starts the cooldown of the scaling action recovered, so it is not repeated right after
+// the restart, before its effect is reflected in the metrics
+func startRecoveredCooldown(ctx *v1alpha1.Context, cooldownSec int) {
+	ctx.Mutex.Lock()
+	cooldownUntil := time.Now().Add(time.Duration(cooldownSec) * time.Second)
+	if cooldownUntil.After(ctx.State.CooldownUntil) {
+		ctx.State.CooldownUntil = cooldownUntil
+	}
+	ctx.Mutex.Unlock()
+}
@@ -0,0 +1,101 @@
+package autoscaler
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/state"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRecoverInterruptedScaleDown(t *testing.T) {
+	tests := []struct {
+		name         string
+		phase        string
+		wantRemoved  bool
+		wantCooldown bool
+	}{
+		{name: "interrupted while draining", phase: state.PhaseDraining},
+		{name: "interrupted while abandoning", phase: state.PhaseAbandoning, wantRemoved: true, wantCooldown: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backend, ctx := newEngineAutoscaler(t, nil)
+			instance := backend.Compute.Instances("europe-west1-b", "fake-mig")[0]
+			backend.Elasticsearch.Exclude(instance)
+			ctx.State.InFlightOperation = &v1alpha1.Operation{
+				Key:          "engine/scale-down/1",
+				Type:         state.OperationScaleDown,
+				Phase:        test.phase,
+				MIG:          "fake-mig",
+				Instance:     instance,
+				Zone:         "europe-west1-b",
+				StartedAt:    time.Now(),
+				PreviousSize: 2,
+				ExpectedSize: 1,
+			}
+
+			recoverInFlightOperation(ctx)
+
+			// The exclusion left by the drain is rolled back, and the operation finished
+			if excluded := backend.Elasticsearch.Excluded(); len(excluded) != 0 {
+				t.Errorf("excluded nodes after the recovery = %v, want none", excluded)
+			}
+			if ctx.State.InFlightOperation != nil {
+				t.Errorf("operation kept after the recovery: %+v", ctx.State.InFlightOperation)
+			}
+
+			// The removal is only resumed when it was interrupted after the drain
+			removed := !slices.Contains(backend.Compute.Instances("europe-west1-b", "fake-mig"), instance)
+			if removed != test.wantRemoved {
+				t.Errorf("instance removed = %t, want %t", removed, test.wantRemoved)
+			}
+			if cooldown := time.Now().Before(ctx.State.CooldownUntil); cooldown != test.wantCooldown {
+				t.Errorf("cooldown started = %t, want %t", cooldown, test.wantCooldown)
+			}
+		})
+	}
+}
+
+func TestRecoverInterruptedScaleUp(t *testing.T) {
+	tests := []struct {
+		name         string
+		expectedSize int32
+		wantCooldown bool
+	}{
+		{name: "interrupted after taking effect", expectedSize: 2, wantCooldown: true},
+		{name: "interrupted before taking effect", expectedSize: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backend, ctx := newEngineAutoscaler(t, nil)
+			ctx.State.InFlightOperation = &v1alpha1.Operation{
+				Key:          "engine/scale-up/1",
+				Type:         state.OperationScaleUp,
+				Phase:        state.PhaseResizing,
+				MIG:          "fake-mig",
+				StartedAt:    time.Now(),
+				PreviousSize: 1,
+				ExpectedSize: test.expectedSize,
+			}
+
+			recoverInFlightOperation(ctx)
+
+			// The resize is never issued again, the conditions decide whether to scale up
+			if size := backend.Compute.Size("europe-west1-b", "fake-mig"); size != 2 {
+				t.Errorf("MIG size after the recovery = %d, want 2", size)
+			}
+			if ctx.State.InFlightOperation != nil {
+				t.Errorf("operation kept after the recovery: %+v", ctx.State.InFlightOperation)
+			}
+			if cooldown := time.Now().Before(ctx.State.CooldownUntil); cooldown != test.wantCooldown {
+				t.Errorf("cooldown started = %t, want %t", cooldown, test.wantCooldown)
+			}
+			if scaledUp := ctx.State.LastScaleUpMIG == "fake-mig"; scaledUp != test.wantCooldown {
+				t.Errorf("scale up recorded = %t, want %t", scaledUp, test.wantCooldown)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package autoscaler
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/decision"
+	"custom-vm-autoscaler/internal/notifier"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// notifyNoAction posts the compact status of the autoscaler when no action is taken, at most once every
+// notifyOnNoAction.intervalSec, so on-call can confirm it is alive during incidents
+func notifyNoAction(ctx *v1alpha1.Context, size int32, limits decision.Limits, up, down decision.Condition, upSamples, downSamples []v1alpha1.MetricSample) {
+	spec := ctx.Config.Notifications.NotifyOnNoAction
+	if !spec.Enabled {
+		return
+	}
+
+	now := time.Now()
+	ctx.Mutex.Lock()
+	if now.Sub(ctx.LastStatusNotification) < time.Duration(spec.IntervalSec)*time.Second {
+		ctx.Mutex.Unlock()
+		return
+	}
+	ctx.LastStatusNotification = now
+	ctx.Mutex.Unlock()
+
+	notifier.Notify(ctx, notifier.SeverityInfo, notifier.EventStatus, formatStatus(size, limits.MaxSize, up, down, upSamples, downSamples))
+}
+
+// formatStatus returns the status in a single line, like "size 7/10, no action, cpu-high=0.43, cpu-low=0.43"
+func formatStatus(size, maxSize int32, up, down decision.Condition, upSamples, downSamples []v1alpha1.MetricSample) string {
+	status := []string{fmt.Sprintf("size %d/%d", size, maxSize), "no action"}
+	for _, condition := range []struct {
+		name    string
+		samples []v1alpha1.MetricSample
+	}{{up.Name, upSamples}, {down.Name, downSamples}} {
+		if condition.name == "" {
+			continue
+		}
+		values := make([]string, 0, len(condition.samples))
+		for _, sample := range condition.samples {
+			values = append(values, fmt.Sprintf("%.2f", sample.Value))
+		}
+		if len(values) == 0 {
+			values = append(values, "none")
+		}
+		status = append(status, fmt.Sprintf("%s=%s", condition.name, strings.Join(values, ",")))
+	}
+	return strings.Join(status, ", ")
+}
@@ -0,0 +1,31 @@
+package autoscaler
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/decision"
+	"testing"
+)
+
+func TestFormatStatus(t *testing.T) {
+	up := decision.Condition{Name: "cpu-high"}
+	down := decision.Condition{Name: "cpu-low"}
+	samples := []v1alpha1.MetricSample{{Value: 0.4321}}
+
+	tests := []struct {
+		name        string
+		downSamples []v1alpha1.MetricSample
+		want        string
+	}{
+		{name: "with samples", downSamples: samples, want: "size 7/10, no action, cpu-high=0.43, cpu-low=0.43"},
+		{name: "without samples", want: "size 7/10, no action, cpu-high=0.43, cpu-low=none"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := formatStatus(7, 10, up, down, samples, test.downSamples)
+			if got != test.want {
+				t.Errorf("formatStatus() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
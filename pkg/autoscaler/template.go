@@ -0,0 +1,120 @@
+package autoscaler
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/dryrun"
+	"custom-vm-autoscaler/internal/elasticsearch"
+	"custom-vm-autoscaler/internal/google"
+	"custom-vm-autoscaler/internal/notifier"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// runTemplateSwitch checks the instance templates of the MIGs once every check interval, between the evaluations of
+// the conditions, alerting when the MIGs or their instances are not on the configured one. With rollingReplace, the
+// template is set in the MIGs and the first outdated instance is drained and recreated from it on every check.
+// Instances are not replaced while a maintenance window restricts the scaling actions
+func runTemplateSwitch(ctx *v1alpha1.Context, maintenanceWindow *v1alpha1.MaintenanceWindowSpec) {
+	spec := ctx.Config.Infrastructure.GCP.InstanceTemplate
+	if spec.Name == "" || time.Since(ctx.LastTemplateCheck) < time.Duration(spec.IntervalSec)*time.Second {
+		return
+	}
+	ctx.LastTemplateCheck = time.Now()
+
+	drifts, err := google.GetTemplateDrifts(ctx)
+	if err != nil {
+		log.Printf("Error checking the instance templates of the MIGs: %v", err)
+		return
+	}
+	if len(drifts) == 0 {
+		notifier.Resolve(ctx, notifier.AlertTemplateDrift, fmt.Sprintf("Every MIG and instance uses the instance template %s again", spec.Name))
+		return
+	}
+
+	var descriptions []string
+	for _, drift := range drifts {
+		descriptions = append(descriptions, fmt.Sprintf("%s (template %s, %d outdated instances)", drift.MIG, drift.Template, len(drift.Outdated)))
+	}
+	notifier.Alert(ctx, notifier.SeverityWarning, notifier.AlertTemplateDrift,
+		fmt.Sprintf("MIGs not on the instance template %s: %s", spec.Name, strings.Join(descriptions, ", ")))
+
+	if !spec.RollingReplace {
+		return
+	}
+	if maintenanceWindow != nil {
+		log.Printf("Maintenance window on days %s and hours %s in progress, instances are not replaced with the template %s", maintenanceWindow.Days, maintenanceWindow.HoursUTC, spec.Name)
+		return
+	}
+
+	err = replaceOutdatedNode(ctx, drifts)
+	if err != nil {
+		log.Printf("Error switching the instance template of the MIGs: %v", err)
+		notifier.Notify(ctx, notifier.SeverityError, notifier.EventError, fmt.Sprintf("Error switching the instance template of the MIGs to %s: %v", spec.Name, err))
+		trackErrors(ctx, err)
+	}
+}
+
+// replaceOutdatedNode sets the configured template in the MIGs still using another one, and replaces the first
+// instance created from another template. The instance of the scaling operation in flight is excluded
+func replaceOutdatedNode(ctx *v1alpha1.Context, drifts []google.TemplateDrift) error {
+	name := ctx.Config.Infrastructure.GCP.InstanceTemplate.Name
+	for _, drift := range drifts {
+		if drift.Matches(name) {
+			continue
+		}
+		log.Printf("Setting the instance template %s in MIG %s, using %s", name, drift.MIG, drift.Template)
+		if ctx.Config.Autoscaler.DryRunInfrastructure {
+			dryrun.Record(ctx, dryrun.ModuleGCP, "set instance template %s in MIG %s", name, drift.MIG)
+		} else {
+			err := google.SetMIGInstanceTemplate(ctx, drift.MIG)
+			if err != nil {
+				return fmt.Errorf("error setting the instance template in MIG %s: %v", drift.MIG, err)
+			}
+		}
+		notifier.Notify(ctx, notifier.SeverityInfo, notifier.EventTemplate,
+			fmt.Sprintf("Instance template of MIG %s switched from %s to %s", drift.MIG, drift.Template, name))
+	}
+
+	ctx.Mutex.Lock()
+	operation := ctx.State.InFlightOperation
+	ctx.Mutex.Unlock()
+
+	for _, drift := range drifts {
+		for _, instance := range drift.Outdated {
+			if operation != nil && operation.Instance == instance {
+				continue
+			}
+			return replaceOutdatedInstance(ctx, drift.MIG, instance)
+		}
+	}
+	return nil
+}
+
+// replaceOutdatedInstance drains the instance, when it is a node of the Elasticsearch cluster, and recreates it
+// from the template of its MIG
+func replaceOutdatedInstance(ctx *v1alpha1.Context, migName string, instance string) error {
+	node := unhealthyNode{MIG: migName, Instance: instance, Reason: "created from an outdated instance template"}
+	if ctx.Config.Target.Elasticsearch.URL != "" {
+		nodes, err := elasticsearch.GetNodes(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get nodes of the Elasticsearch cluster: %v", err)
+		}
+		for _, esNode := range nodes {
+			if esNode.Name == instance {
+				node.Joined = true
+			}
+		}
+	}
+
+	log.Printf("Replacing instance %s of MIG %s: %s", instance, migName, node.Reason)
+	err := replaceNode(ctx, node)
+	if err != nil {
+		return err
+	}
+
+	notifier.Notify(ctx, notifier.SeverityInfo, notifier.EventTemplate,
+		fmt.Sprintf("Replaced instance %s of MIG %s with the instance template %s", instance, migName, ctx.Config.Infrastructure.GCP.InstanceTemplate.Name))
+	return nil
+}
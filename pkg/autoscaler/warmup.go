@@ -0,0 +1,56 @@
+package autoscaler
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/elasticsearch"
+	"custom-vm-autoscaler/internal/google"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// checkWarmup returns why the scale downs are skipped while the nodes added by the last scale up warm up,
+// or an empty string when they are warm. Nodes are warm once the warm-up period has passed since the scale up
+// and every instance of the scaled MIG has joined the Elasticsearch cluster. Nodes not joining it
+// in the join timeout stop blocking the scale downs
+func checkWarmup(ctx *v1alpha1.Context) (string, error) {
+	warmup := ctx.Config.Autoscaler.Warmup
+	ctx.Mutex.Lock()
+	lastScaleUp, migName := ctx.State.LastScaleUpTime, ctx.State.LastScaleUpMIG
+	ctx.Mutex.Unlock()
+	if warmup.PeriodSec <= 0 || lastScaleUp.IsZero() {
+		return "", nil
+	}
+
+	elapsed := time.Since(lastScaleUp)
+	period := time.Duration(warmup.PeriodSec) * time.Second
+	if elapsed < period {
+		return fmt.Sprintf("the nodes added to MIG %s are warming up for %s more", migName, (period - elapsed).Round(time.Second)), nil
+	}
+	if ctx.Config.Target.Elasticsearch.URL == "" || migName == "" ||
+		elapsed >= time.Duration(warmup.JoinTimeoutSec)*time.Second {
+		return "", nil
+	}
+
+	// Every instance of the MIG must have joined the cluster, so the new ones are not removed before holding data
+	instances, err := google.GetMIGInstanceNames(ctx, migName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get instances of MIG %s: %v", migName, err)
+	}
+	nodes, err := elasticsearch.GetNodes(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get nodes of the Elasticsearch cluster: %v", err)
+	}
+
+	var pending []string
+	for _, instance := range instances {
+		if !slices.ContainsFunc(nodes, func(node v1alpha1.NodeInfo) bool { return node.Name == instance }) {
+			pending = append(pending, instance)
+		}
+	}
+	if len(pending) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("the instances %s of MIG %s have not joined Elasticsearch yet", strings.Join(pending, ", "), migName), nil
+}
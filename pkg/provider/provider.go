@@ -0,0 +1,49 @@
+// Package provider exposes the operations of the autoscaler on the Managed Instance Groups (MIG) of GCP, so other
+// programs can act on the MIGs of an autoscaler as the autoscaler does. Every operation receives the runtime context
+// of an autoscaler, created by the autoscaler package, and respects its rate limit, retries and circuit breaker.
+package provider
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/google"
+)
+
+// ErrStartupTimeout is returned by AddNode when the new instances do not pass the startup probe in time
+var ErrStartupTimeout = google.ErrStartupTimeout
+
+// ErrMIGInterlocked is returned by AddNode and RemoveNode when a GCE autoscaler or another controller manages a MIG
+var ErrMIGInterlocked = google.ErrMIGInterlocked
+
+// AddNode adds nodes to the MIG selected for scaling up, if the maximum size has not been reached.
+// It returns the name of the scaled MIG, the total size of all the MIGs before and after scaling, and the maximum size,
+// which are -1 when the maximum size has been reached
+func AddNode(ctx *v1alpha1.Context) (string, int32, int32, int32, error) {
+	return google.AddNodeToMIG(ctx)
+}
+
+// AddNodes is AddNode adding the given number of nodes instead of the scale up threshold of the autoscaler
+func AddNodes(ctx *v1alpha1.Context, step int32) (string, int32, int32, int32, error) {
+	return google.AddNodesToMIG(ctx, step)
+}
+
+// RemoveNode drains a node of the MIG selected for scaling down from Elasticsearch, when configured, and removes it,
+// if the minimum size has not been reached. It returns the name of the scaled MIG, the total size of all the MIGs
+// before and after scaling, the minimum size and the removed instance
+func RemoveNode(ctx *v1alpha1.Context) (string, int32, int32, int32, string, error) {
+	return google.RemoveNodeFromMIG(ctx)
+}
+
+// Sizes returns the target size of every MIG, their total target size, and the minimum and maximum sizes applied now
+func Sizes(ctx *v1alpha1.Context) (map[string]int32, int32, int32, int32, error) {
+	return google.GetMIGSizes(ctx)
+}
+
+// InstanceNames returns the names of the instances of the given MIG
+func InstanceNames(ctx *v1alpha1.Context, migName string) ([]string, error) {
+	return google.GetMIGInstanceNames(ctx, migName)
+}
+
+// RecreateInstance recreates the given instance of the MIG from its instance template, keeping its name
+func RecreateInstance(ctx *v1alpha1.Context, migName string, instanceName string) error {
+	return google.RecreateInstance(ctx, migName, instanceName)
+}
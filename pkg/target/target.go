@@ -0,0 +1,35 @@
+// Package target exposes the operations of the autoscaler on the Elasticsearch cluster running in the MIGs, so other
+// programs can drain and inspect its nodes as the autoscaler does. Every operation receives the runtime context of an
+// autoscaler, created by the autoscaler package, whose config defines the Elasticsearch target.
+package target
+
+import (
+	"custom-vm-autoscaler/api/v1alpha1"
+	"custom-vm-autoscaler/internal/elasticsearch"
+)
+
+// DrainNode excludes the node from the allocation of shards and waits until it holds none, coordinating
+// with the other autoscalers through the drain locks
+func DrainNode(ctx *v1alpha1.Context, nodeName string) error {
+	return elasticsearch.DrainElasticsearchNode(ctx, nodeName)
+}
+
+// ClearNode allows the allocation of shards in the node again
+func ClearNode(ctx *v1alpha1.Context, nodeName string) error {
+	return elasticsearch.ClearElasticsearchClusterSettings(ctx, nodeName)
+}
+
+// ClusterHealth returns the status and the shards being moved of the cluster
+func ClusterHealth(ctx *v1alpha1.Context) (v1alpha1.ClusterHealth, error) {
+	return elasticsearch.GetClusterHealth(ctx)
+}
+
+// Nodes returns the name and roles of every node of the cluster, with their heap and disk usage
+func Nodes(ctx *v1alpha1.Context) ([]v1alpha1.NodeInfo, error) {
+	return elasticsearch.GetNodes(ctx)
+}
+
+// IsDataNode returns true when the node holds data, with any of the data roles
+func IsDataNode(node v1alpha1.NodeInfo) bool {
+	return elasticsearch.IsDataNode(node)
+}